@@ -2,67 +2,101 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"orderservice/internal/observability"
 	"orderservice/internal/producer"
 	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
 
-	"github.com/google/uuid"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/otel"
 )
 
 func main() {
-	file := flag.String("f", "test.json", "path to JSON file")
+	file := flag.String("f", "test.json", "path to JSON file with a single order")
+	dir := flag.String("dir", "", "publish every *.json / *.ndjson file in this directory")
+	ndjson := flag.String("ndjson", "", "publish one order per line from this NDJSON file (- for stdin)")
+	gen := flag.Int("gen", 0, "publish N generated orders")
+	rate := flag.Float64("rate", 0, "target messages per second (0 = unlimited)")
+	workers := flag.Int("workers", 1, "number of concurrent writers")
+	seed := flag.Uint64("seed", 0, "seed for -gen to get reproducible orders (random when unset)")
+	batchTimeout := flag.Duration("batch-timeout", 10*time.Millisecond, "kafka writer batch timeout")
 	flag.Parse()
 
-	data, err := os.ReadFile(*file)
-	if err != nil {
-		slog.Error("read file", "err", err)
-		return
-	}
-
-	var o models.Order
-	if err := json.Unmarshal(data, &o); err != nil {
-		slog.Error("parse json", "err", err)
-		return
-	}
-
 	var cfg producerConfig
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
 		slog.Error("config", "err", err)
-		return
+		os.Exit(1)
+	}
+
+	var src producer.Source
+	switch {
+	case *gen > 0:
+		var opts []fake.Option
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "seed" {
+				opts = append(opts, fake.WithSeed(*seed))
+			}
+		})
+		g := fake.New(opts...)
+		src = producer.GeneratedSource(*gen, func() models.Order { return g.Order() })
+	case *ndjson != "":
+		src = producer.NDJSONFileSource(*ndjson)
+	case *dir != "":
+		src = producer.DirSource(*dir)
+	default:
+		src = producer.FileSource(*file)
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	tp, err := observability.InitTracer(ctx, cfg.ServiceName, cfg.JaegerEndpoint)
 	if err != nil {
 		slog.Error("tracer", "err", err)
-		return
+		os.Exit(1)
 	}
 	if tp != nil {
 		defer tp.Shutdown(context.Background())
 	}
-	tracer := otel.Tracer(cfg.ServiceName)
 
-	ctx = observability.WithRequestID(ctx, uuid.NewString())
-	ctx, span := tracer.Start(ctx, "producer.publish")
-	defer span.End()
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(strings.Split(cfg.KafkaBrokers, ",")...),
+		Topic:        cfg.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: *batchTimeout,
+	}
+	defer w.Close()
 
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: strings.Split(cfg.KafkaBrokers, ","),
-		Topic:   cfg.KafkaTopic,
+	stats, err := producer.Run(ctx, w, src, producer.RunConfig{
+		Workers: *workers,
+		Rate:    *rate,
+		Tracer:  otel.Tracer(cfg.ServiceName),
+		OnError: func(o models.Order, err error) {
+			slog.Error("publish", "uid", o.OrderUID, "err", err)
+		},
 	})
-	defer w.Close()
+	if err != nil {
+		slog.Error("source", "err", err)
+	}
 
-	if err := producer.Publish(ctx, w, o); err != nil {
-		slog.Error("publish", "err", err)
+	throughput := 0.0
+	if stats.Elapsed > 0 {
+		throughput = float64(stats.Sent) / stats.Elapsed.Seconds()
+	}
+	fmt.Printf("sent=%d failed=%d elapsed=%s rate=%.1f/s p50=%s p99=%s\n",
+		stats.Sent, stats.Failed, stats.Elapsed.Round(time.Millisecond), throughput,
+		stats.Percentile(50), stats.Percentile(99))
+	if err != nil || stats.Failed > 0 {
+		os.Exit(1)
 	}
 }
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"orderservice/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "usage: orders-service config validate|print [-config path] [-redacted]"
+
+// runConfig выполняет `orders-service config validate|print`: собирает
+// конфиг так же, как сервис при старте (файл из -config или CONFIG_FILE,
+// поверх него переменные окружения), и проверяет его или печатает в YAML.
+func runConfig(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(config.FileEnv), "YAML or TOML config file")
+	redacted := fs.Bool("redacted", false, "hide secrets when printing")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New(configUsage)
+	}
+
+	cfg, err := config.LoadFile(*path)
+	if err != nil {
+		return err
+	}
+	if err := checkConfig(cfg); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "validate":
+		fmt.Fprintln(out, "config is valid")
+		return nil
+	case "print":
+		if *redacted {
+			cfg = cfg.Redacted()
+		}
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return errors.New(configUsage)
+	}
+}
@@ -2,45 +2,124 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
-	"sync"
+	"syscall"
+	"time"
 
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
 	"orderservice/internal/config"
 	"orderservice/internal/consumer"
 	"orderservice/internal/db"
+	"orderservice/internal/features"
+	"orderservice/internal/keyring"
+	"orderservice/internal/lifecycle"
 	"orderservice/internal/observability"
+	"orderservice/internal/ratelimit"
 	"orderservice/internal/repository/postgres"
 	redisrepo "orderservice/internal/repository/redis"
 	"orderservice/internal/server"
 	_ "orderservice/internal/server/docs"
 	"orderservice/internal/service"
+	"orderservice/internal/stream"
 	"orderservice/pkg/models"
+	"orderservice/pkg/redact"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 )
 
-// @title			Order Service API
-// @version		1.0
-// @description	REST proxy to gRPC OrderService
-// @BasePath		/
+// @title						Order Service API
+// @version					1.0
+// @description				REST proxy to gRPC OrderService
+// @BasePath					/
+//
+// @securityDefinitions.apikey	BearerAuth
+// @in							header
+// @name						Authorization
+//
+// @securityDefinitions.apikey	ApiKeyAuth
+// @in							header
+// @name						X-API-Key
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	level := new(slog.LevelVar)
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level, ReplaceAttr: redact.ReplaceAttr}))
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:], logger); err != nil {
+			logger.Error("migrate", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "config:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := run(logger, level); err != nil {
+		logger.Error("orders-service", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run собирает сервис и держит его компоненты под супервизором до сигнала
+// остановки или окончательного падения критичного компонента. Уровень
+// логов level меняется при перезагрузке конфига.
+func run(logger *slog.Logger, level *slog.LevelVar) error {
 	cfg, err := config.Load()
 	if err != nil {
-		logger.Error("config", "err", err)
-		return
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := checkConfig(cfg); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	lvl, err := cfg.Level()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
 	}
+	level.Set(lvl)
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	var authn *auth.Authenticator
+	if cfg.AuthEnabled {
+		if authn, err = auth.New(authConfig(cfg)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	} else {
+		logger.Warn("authentication is disabled, the order API is open to anyone")
+	}
+
+	svcOpts := []service.Option{service.WithAdmins(cfg.AdminSubjects...)}
+	if cfg.PolicyFile != "" {
+		policy, err := service.LoadPolicy(cfg.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("authz: %w", err)
+		}
+		svcOpts = append(svcOpts, service.WithPolicy(policy))
+	}
+
+	limits, err := rateLimits(cfg)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	defs, err := consumerDefinitions(cfg)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	tp, err := observability.InitTracer(ctx, cfg.ServiceName, cfg.JaegerEndpoint)
 	if err != nil {
-		logger.Error("tracer init", "err", err)
-		return
+		return fmt.Errorf("tracer init: %w", err)
 	}
 	if tp != nil {
 		defer tp.Shutdown(context.Background())
@@ -49,52 +128,216 @@ func main() {
 
 	pool, err := db.ConnectDB(ctx, cfg.DatabaseURL)
 	if err != nil {
-		logger.Error("db connect", "err", err)
-		return
+		return fmt.Errorf("db connect: %w", err)
 	}
 	defer pool.Close()
 
+	if err := checkSchema(ctx, pool, cfg.MigrateOnStart, logger); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     cfg.RedisAddr,
 		Password: cfg.RedisPassword,
 	})
 	defer redisClient.Close()
 
-	repo := postgres.NewOrderRepository(pool, tracer)
+	var repoOpts []postgres.Option
+	if cfg.EncryptionKeys != "" {
+		keys, err := openKeyring(ctx, cfg.EncryptionKeys, pool)
+		if err != nil {
+			return fmt.Errorf("keyring: %w", err)
+		}
+		repoOpts = append(repoOpts, postgres.WithKeyring(keys))
+	}
+	repo := postgres.NewOrderRepository(pool, tracer, repoOpts...)
 	cache := redisrepo.NewOrderCache(redisClient, tracer)
-	svc := service.New(repo, cache, cfg.CacheTTL, logger, tracer)
 
-	if err := svc.RestoreCache(ctx); err != nil {
-		logger.Error("restore cache", "err", err)
+	sup := lifecycle.New(logger)
+	// компоненты, вызывающие сервис, стартуют после потока заказов и аудита
+	// и останавливаются раньше них: рекордер дописывает то, что накопилось
+	// в буфере
+	svcDeps := []string{"stream"}
+	if cfg.AuditEnabled {
+		store := postgres.NewAuditStore(pool, tracer)
+		sinks := []audit.Sink{store}
+		if cfg.AuditTopic != "" {
+			kafkaSink := audit.NewKafkaSink(cfg.KafkaBrokers, cfg.AuditTopic)
+			defer kafkaSink.Close()
+			sinks = append(sinks, kafkaSink)
+		}
+		recorder := audit.NewRecorder(auditConfig(cfg), logger, sinks...)
+		svcOpts = append(svcOpts, service.WithAudit(recorder, store))
+		sup.Add(lifecycle.Component{
+			Name: "audit",
+			Run: lifecycle.Started(func(ctx context.Context) error {
+				recorder.Run(ctx)
+				return nil
+			}),
+			Critical:    true,
+			StopTimeout: 30 * time.Second,
+		})
+		svcDeps = append(svcDeps, "audit")
 	}
 
-	wg := sync.WaitGroup{}
+	hub := stream.NewHub(streamConfig(cfg), redisClient, logger)
+	svcOpts = append(svcOpts, service.WithStream(hub))
+	svcOpts = append(svcOpts, service.WithAnalytics(postgres.NewAnalyticsStore(pool, tracer)))
+	svcOpts = append(svcOpts, service.WithExport(postgres.NewExportStore(pool, tracer)))
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		save := func(ctx context.Context, o models.Order) error { return svc.SaveOrder(ctx, o) }
-		if err := consumer.StartKafkaConsumer(ctx, cfg.KafkaBrokers, cfg.KafkaTopic, "orders_consumer", save, logger, tracer); err != nil {
-			logger.Error("consumer", "err", err)
-		}
-	}()
+	replica := cfg.ReplicaName
+	if replica == "" {
+		replica, _ = os.Hostname()
+	}
+	flagConfig, err := featureFlags(cfg)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	flags := features.New(flagConfig, replica)
+	svcOpts = append(svcOpts, service.WithFlags(flags))
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := server.StartGRPCServer(ctx, cfg.GRPCAddr, svc, logger, tracer); err != nil {
-			logger.Error("grpc", "err", err)
-		}
-	}()
+	svc := service.New(repo, cache, cfg.CacheTTL, logger, tracer, svcOpts...)
+
+	save := func(ctx context.Context, o models.Order) error {
+		return svc.SaveOrder(audit.WithSource(ctx, audit.SourceKafka), o)
+	}
+	consumers, err := consumer.NewGroup(cfg.KafkaBrokers, defs, save, logger, tracer, consumer.WithFlags(flags))
+	if err != nil {
+		return fmt.Errorf("consumers: %w", err)
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := server.StartHTTPServer(ctx, cfg.HTTPAddr, cfg.GRPCAddr, logger); err != nil {
-			logger.Error("http", "err", err)
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimitOn {
+		limiter = ratelimit.New(limits, ratelimit.NewRedisStore(redisClient), logger)
+	}
+
+	mode, err := service.ParseReconcileMode(cfg.ReconcileMode)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	reconciler := service.NewReconciler(repo, cache, cfg.CacheTTL, service.ReconcilerConfig{
+		Interval:  cfg.ReconcileEvery,
+		BatchSize: cfg.ReconcileBatch,
+		Mode:      mode,
+	}, logger, tracer)
+
+	always := lifecycle.RestartPolicy{MaxRestarts: -1}
+	if path := os.Getenv(config.FileEnv); path != "" {
+		// безопасные настройки применяются на лету, остальные ждут рестарта
+		apply := func(c config.Config) {
+			// всё проверено в checkConfig
+			lvl, _ := c.Level()
+			level.Set(lvl)
+			svc.SetCacheTTL(c.CacheTTL)
+			reconciler.SetCacheTTL(c.CacheTTL)
+			flagConfig, _ := featureFlags(c)
+			flags.SetConfig(flagConfig)
+			if limiter != nil {
+				limits, _ := rateLimits(c)
+				limiter.SetConfig(limits)
+			}
 		}
-	}()
+		sup.Add(lifecycle.Component{
+			Name: "config-reload",
+			Run: lifecycle.Started(func(ctx context.Context) error {
+				return config.Watch(ctx, path, cfg, checkConfig, apply, logger)
+			}),
+			Restart: always,
+		})
+	}
+	if cfg.FlagsRedisKey != "" {
+		sup.Add(lifecycle.Component{
+			Name: "feature-flags",
+			Run: lifecycle.Started(func(ctx context.Context) error {
+				return flags.WatchRedis(ctx, redisClient, cfg.FlagsRedisKey, cfg.FlagsRedisPoll, logger)
+			}),
+			Restart: always,
+		})
+	}
+	sup.Add(lifecycle.Component{
+		Name:    "stream",
+		Run:     lifecycle.Started(hub.Run),
+		Restart: always,
+	})
+	sup.Add(lifecycle.Component{
+		Name:    "cache-warmer",
+		Run:     lifecycle.Started(svc.RestoreCache),
+		Restart: lifecycle.RestartPolicy{MaxRestarts: 3},
+	})
+	sup.Add(lifecycle.Component{
+		Name: "consumers",
+		Run: lifecycle.Started(func(ctx context.Context) error {
+			consumers.Run(ctx)
+			return nil
+		}),
+		DependsOn:   svcDeps,
+		StopTimeout: 15 * time.Second,
+	})
+	sup.Add(lifecycle.Component{
+		Name:    "reconciler",
+		Run:     lifecycle.Started(reconciler.Run),
+		Restart: always,
+	})
+	sup.Add(lifecycle.Component{
+		Name: "reencrypt",
+		Run: lifecycle.Started(func(ctx context.Context) error {
+			return repo.RunReencryption(ctx, cfg.ReencryptEvery, cfg.ReencryptBatch, logger)
+		}),
+		Restart: always,
+	})
+	sup.Add(lifecycle.Component{
+		Name: "grpc",
+		Run: func(ctx context.Context, ready func()) error {
+			lis, err := net.Listen("tcp", cfg.GRPCAddr)
+			if err != nil {
+				return err
+			}
+			ready()
+			return server.ServeGRPC(ctx, lis, svc, authn, limiter, logger, tracer)
+		},
+		DependsOn: svcDeps,
+		Critical:  true,
+		Restart:   lifecycle.RestartPolicy{MaxRestarts: 3},
+	})
+	sup.Add(lifecycle.Component{
+		Name: "http",
+		Run: func(ctx context.Context, ready func()) error {
+			lis, err := net.Listen("tcp", cfg.HTTPAddr)
+			if err != nil {
+				return err
+			}
+			ready()
+			return server.ServeHTTP(ctx, lis, cfg.GRPCAddr, cfg.OrderMaxAge, authn, svc, logger)
+		},
+		DependsOn: []string{"grpc"},
+		Critical:  true,
+		Restart:   lifecycle.RestartPolicy{MaxRestarts: 3},
+	})
+
+	return sup.Run(ctx)
+}
 
-	<-ctx.Done()
-	wg.Wait()
+// openKeyring загружает мастер-ключи из keyfile и ключи данных из БД.
+func openKeyring(ctx context.Context, path string, pool *pgxpool.Pool) (*keyring.Keyring, error) {
+	kf, err := keyring.ReadKeyfile(path)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Open(ctx, kf, postgres.NewDataKeyStore(pool))
+}
+
+// checkSchema при необходимости накатывает миграции и не даёт стартовать,
+// если схема БД отстаёт от встроенных миграций.
+func checkSchema(ctx context.Context, pool *pgxpool.Pool, migrate bool, logger *slog.Logger) error {
+	m, err := db.NewMigrator(pool, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if migrate {
+		if _, err := m.Up(ctx); err != nil {
+			return fmt.Errorf("auto-migrate: %w", err)
+		}
+	}
+	return m.CheckVersion(ctx)
 }
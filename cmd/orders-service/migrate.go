@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"orderservice/internal/db"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+type migrateConfig struct {
+	DatabaseURL string `env:"DATABASE_URL" env-required:"true"`
+}
+
+// runMigrate выполняет `orders-service migrate up|down|status|redo`.
+func runMigrate(args []string, logger *slog.Logger) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: orders-service migrate up|down|status|redo")
+	}
+	var cfg migrateConfig
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool, err := db.ConnectDB(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer pool.Close()
+
+	m, err := db.NewMigrator(pool, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			fmt.Println(r)
+		}
+		return err
+	case "down":
+		r, err := m.Down(ctx)
+		if r != nil {
+			fmt.Println(r)
+		}
+		return err
+	case "redo":
+		results, err := m.Redo(ctx)
+		for _, r := range results {
+			fmt.Println(r)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "Pending"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-20s %s\n", applied, s.Source.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
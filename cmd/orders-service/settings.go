@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/config"
+	"orderservice/internal/consumer"
+	"orderservice/internal/features"
+	"orderservice/internal/ratelimit"
+	"orderservice/internal/service"
+	"orderservice/internal/stream"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Настройки компонентов собираются из плоского конфига здесь, а не в
+// пакете config: тот не зависит от пакетов, которые настраивает.
+
+func authConfig(cfg config.Config) auth.Config {
+	return auth.Config{
+		JWKSFile:   cfg.AuthJWKSFile,
+		JWTKeys:    cfg.AuthJWTKeys,
+		HMACSecret: cfg.AuthJWTSecret,
+		Issuer:     cfg.AuthIssuer,
+		Audience:   cfg.AuthAudience,
+		Leeway:     cfg.AuthLeeway,
+		APIKeys:    cfg.AuthAPIKeys,
+	}
+}
+
+func auditConfig(cfg config.Config) audit.Config {
+	return audit.Config{
+		BufferSize:    cfg.AuditBuffer,
+		BatchSize:     cfg.AuditBatch,
+		FlushInterval: cfg.AuditFlush,
+	}
+}
+
+func streamConfig(cfg config.Config) stream.Config {
+	return stream.Config{
+		Replay:           cfg.StreamReplay,
+		SubscriberBuffer: cfg.StreamBuffer,
+	}
+}
+
+// rateLimits разбирает лимит по умолчанию и лимиты отдельных RPC.
+func rateLimits(cfg config.Config) (ratelimit.Config, error) {
+	return ratelimit.ParseConfig(cfg.RateLimit, cfg.RateLimitRoute)
+}
+
+// consumerDefinitions возвращает консьюмеры из KAFKA_CONSUMERS_FILE, из
+// kafka_consumers файла конфига или один консьюмер KAFKA_TOPIC в группе
+// KAFKA_GROUP_ID.
+func consumerDefinitions(cfg config.Config) ([]consumer.Definition, error) {
+	if cfg.ConsumersFile != "" {
+		return consumer.LoadDefinitions(cfg.ConsumersFile)
+	}
+	var defs []consumer.Definition
+	if len(cfg.KafkaConsumers) > 0 {
+		if err := decodeSection(cfg.KafkaConsumers, &defs); err != nil {
+			return nil, err
+		}
+	} else {
+		defs = []consumer.Definition{{Name: "orders", Topics: []string{cfg.KafkaTopic}, GroupID: cfg.KafkaGroupID}}
+	}
+	return defs, consumer.CheckDefinitions(defs)
+}
+
+// featureFlags разбирает и проверяет feature_flags файла конфига.
+func featureFlags(cfg config.Config) (map[string]features.Flag, error) {
+	var flags map[string]features.Flag
+	if len(cfg.FeatureFlags) > 0 {
+		if err := decodeSection(cfg.FeatureFlags, &flags); err != nil {
+			return nil, err
+		}
+	}
+	return flags, features.CheckFlags(flags)
+}
+
+// decodeSection раскладывает раздел файла конфига, который config хранит
+// как есть, в структуры компонента; неизвестные ключи — ошибка, как и в
+// остальном файле.
+func decodeSection(raw, v any) error {
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// checkConfig проверяет конфиг целиком вместе с файлами, на которые он
+// ссылается, чтобы ошибка нашлась до выкатки или перезагрузки.
+func checkConfig(cfg config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if _, err := rateLimits(cfg); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+	if _, err := service.ParseReconcileMode(cfg.ReconcileMode); err != nil {
+		return fmt.Errorf("reconcile_mode: %w", err)
+	}
+	if _, err := consumerDefinitions(cfg); err != nil {
+		return fmt.Errorf("kafka_consumers: %w", err)
+	}
+	if _, err := featureFlags(cfg); err != nil {
+		return fmt.Errorf("feature_flags: %w", err)
+	}
+	if cfg.PolicyFile != "" {
+		if _, err := service.LoadPolicy(cfg.PolicyFile); err != nil {
+			return err
+		}
+	}
+	if cfg.AuthEnabled {
+		if _, err := auth.New(authConfig(cfg)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	return nil
+}
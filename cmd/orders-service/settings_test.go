@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orderservice/internal/config"
+)
+
+func loadConfig(t *testing.T, content string) config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("kafka_brokers: [k]\ndatabase_url: x\n"+content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestConfigSections(t *testing.T) {
+	cfg := loadConfig(t, `
+kafka_consumers:
+  - name: orders
+    topics: [orders_topic]
+    group_id: orders_consumer
+    retry: {attempts: 5, backoff: 500ms}
+feature_flags:
+  lazy_cache: {enabled: true, percent: 50}
+`)
+	if err := checkConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defs, err := consumerDefinitions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].GroupID != "orders_consumer" || defs[0].Decoder != "json" || defs[0].Retry.Attempts != 5 {
+		t.Errorf("consumers %+v", defs)
+	}
+	flags, err := featureFlags(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := flags["lazy_cache"]; !f.Enabled || f.Percent == nil || *f.Percent != 50 {
+		t.Errorf("flags %+v", flags)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	for name, content := range map[string]string{
+		"log_level":       "log_level: loud\n",
+		"rate_limit":      "rate_limit: fast\n",
+		"reconcile_mode":  "reconcile_mode: fix\n",
+		"kafka_consumers": "kafka_group_id: \"\"\n",
+		"feature_flags":   "feature_flags: {a: {by: order}}\n",
+		"field enable":    "feature_flags: {lazy_cache: {enable: true}}\n",
+		"field topic":     "kafka_consumers: [{name: a, topic: t, group_id: g}]\n",
+	} {
+		if err := checkConfig(loadConfig(t, content)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
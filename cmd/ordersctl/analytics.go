@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"orderservice/pkg/api/orderpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type revenueRow struct {
+	Currency      string  `json:"currency"`
+	Amount        int64   `json:"amount"`
+	Orders        int64   `json:"orders"`
+	AvgOrderValue float64 `json:"avg_order_value"`
+}
+
+type statsRow struct {
+	Start           time.Time    `json:"start"`
+	Currency        string       `json:"currency,omitempty"`
+	Provider        string       `json:"provider,omitempty"`
+	DeliveryService string       `json:"delivery_service,omitempty"`
+	Orders          int64        `json:"orders"`
+	Items           int64        `json:"items"`
+	AvgBasketSize   float64      `json:"avg_basket_size"`
+	Revenue         []revenueRow `json:"revenue"`
+}
+
+type topItemRow struct {
+	Brand    string `json:"brand"`
+	NmID     int64  `json:"nm_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Quantity int64  `json:"quantity"`
+	Orders   int64  `json:"orders"`
+	Revenue  int64  `json:"revenue,omitempty"`
+}
+
+// rangeFlags registers -from and -to; the range defaults to the last 30 days.
+func rangeFlags(fs *flag.FlagSet) func() (*timestamppb.Timestamp, *timestamppb.Timestamp, error) {
+	from := fs.String("from", "", "first day (RFC 3339 or YYYY-MM-DD; default 30 days before -to)")
+	to := fs.String("to", "", "end of the range, exclusive (RFC 3339 or YYYY-MM-DD; default now)")
+	return func() (*timestamppb.Timestamp, *timestamppb.Timestamp, error) {
+		end := time.Now()
+		if *to != "" {
+			t, err := parseTime(*to)
+			if err != nil {
+				return nil, nil, usageError("-to: " + err.Error())
+			}
+			end = t
+		}
+		start := end.AddDate(0, 0, -30)
+		if *from != "" {
+			t, err := parseTime(*from)
+			if err != nil {
+				return nil, nil, usageError("-from: " + err.Error())
+			}
+			start = t
+		}
+		return timestamppb.New(start), timestamppb.New(end), nil
+	}
+}
+
+func (a *app) cmdStats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	bucket := fs.String("bucket", "day", "day, week, month or total")
+	groupBy := fs.String("group-by", "", "comma-separated dimensions: currency, provider, delivery_service")
+	parseRange := rangeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	from, to, err := parseRange()
+	if err != nil {
+		return err
+	}
+	req := &orderpb.OrderStatsRequest{From: from, To: to, Bucket: *bucket}
+	if *groupBy != "" {
+		req.GroupBy = strings.Split(*groupBy, ",")
+	}
+
+	client, err := a.analyticsClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.OrderStats(ctx, req)
+	if err != nil {
+		return err
+	}
+	rows := []statsRow{}
+	for _, r := range resp.GetRows() {
+		row := statsRow{
+			Start:           r.GetStart().AsTime(),
+			Currency:        r.GetCurrency(),
+			Provider:        r.GetProvider(),
+			DeliveryService: r.GetDeliveryService(),
+			Orders:          r.GetOrders(),
+			Items:           r.GetItems(),
+			AvgBasketSize:   r.GetAvgBasketSize(),
+		}
+		for _, rev := range r.GetRevenue() {
+			row.Revenue = append(row.Revenue, revenueRow{
+				Currency:      rev.GetCurrency(),
+				Amount:        rev.GetAmount(),
+				Orders:        rev.GetOrders(),
+				AvgOrderValue: rev.GetAvgOrderValue(),
+			})
+		}
+		rows = append(rows, row)
+	}
+	return a.out.print(rows, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "START\tCURRENCY\tPROVIDER\tDELIVERY_SERVICE\tORDERS\tITEMS\tBASKET\tREVENUE")
+		for _, r := range rows {
+			var revenue []string
+			for _, rev := range r.Revenue {
+				revenue = append(revenue, fmt.Sprintf("%d %s (avg %.2f)", rev.Amount, rev.Currency, rev.AvgOrderValue))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%.2f\t%s\n", r.Start.Format("2006-01-02"),
+				r.Currency, r.Provider, r.DeliveryService, r.Orders, r.Items, r.AvgBasketSize, strings.Join(revenue, ", "))
+		}
+	})
+}
+
+func (a *app) cmdTopItems(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("top-items", flag.ContinueOnError)
+	by := fs.String("by", "brand", "brand or item")
+	metric := fs.String("metric", "quantity", "quantity or revenue")
+	currency := fs.String("currency", "", "only orders paid in this currency (required for -metric revenue)")
+	limit := fs.Int("limit", 10, "entries to show (max 100)")
+	parseRange := rangeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	from, to, err := parseRange()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.analyticsClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.TopItems(ctx, &orderpb.TopItemsRequest{
+		From:     from,
+		To:       to,
+		By:       *by,
+		Metric:   *metric,
+		Currency: *currency,
+		Limit:    int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	rows := []topItemRow{}
+	for _, r := range resp.GetRows() {
+		rows = append(rows, topItemRow{
+			Brand:    r.GetBrand(),
+			NmID:     r.GetNmId(),
+			Name:     r.GetName(),
+			Quantity: r.GetQuantity(),
+			Orders:   r.GetOrders(),
+			Revenue:  r.GetRevenue(),
+		})
+	}
+	return a.out.print(rows, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "BRAND\tNM_ID\tNAME\tQUANTITY\tORDERS\tREVENUE")
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\t%d\n", r.Brand, r.NmID, r.Name, r.Quantity, r.Orders, r.Revenue)
+		}
+	})
+}
@@ -0,0 +1,770 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/consumer"
+	"orderservice/internal/importer"
+	"orderservice/internal/orderconv"
+	redisrepo "orderservice/internal/repository/redis"
+	"orderservice/internal/service"
+	"orderservice/internal/stream"
+	"orderservice/pkg/api/orderpb"
+	"orderservice/pkg/models"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (a *app) cmdGet(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: ordersctl get <order_uid>")
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderUid: args[0]})
+	if err != nil {
+		return err
+	}
+	o := orderconv.FromProto(resp.GetOrder())
+	return a.out.print(o, func(tw *tabwriter.Writer) { printOrder(tw, o) })
+}
+
+func (a *app) cmdLookup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	track := fs.String("track", "", "order track number")
+	transaction := fs.String("transaction", "", "payment transaction")
+	rid := fs.String("rid", "", "item rid")
+	chrtID := fs.Int64("chrt-id", 0, "item chrt_id")
+	nmID := fs.Int64("nm-id", 0, "item nm_id")
+	limit := fs.Int("limit", 50, "page size for item lookups")
+	pageToken := fs.String("page-token", "", "token of the page to fetch")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	set := 0
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "limit" && f.Name != "page-token" {
+			set++
+		}
+	})
+	if set != 1 {
+		return usageError("usage: ordersctl lookup -track T | -transaction T | -rid R | -chrt-id N | -nm-id N")
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	var resp *orderpb.GetOrderResponse
+	switch {
+	case *track != "":
+		resp, err = client.GetOrderByTrackNumber(ctx, &orderpb.GetOrderByTrackNumberRequest{TrackNumber: *track})
+	case *transaction != "":
+		resp, err = client.GetOrderByTransaction(ctx, &orderpb.GetOrderByTransactionRequest{Transaction: *transaction})
+	default:
+		items, err := client.FindOrdersByItem(ctx, &orderpb.FindOrdersByItemRequest{
+			Rid: *rid, ChrtId: *chrtID, NmId: *nmID, PageSize: int32(*limit), PageToken: *pageToken,
+		})
+		if err != nil {
+			return err
+		}
+		return a.printOrders(listResult{NextPageToken: items.GetNextPageToken()}, items.GetOrders())
+	}
+	if err != nil {
+		return err
+	}
+	o := orderconv.FromProto(resp.GetOrder())
+	return a.out.print(o, func(tw *tabwriter.Writer) { printOrder(tw, o) })
+}
+
+type listResult struct {
+	Orders        []models.Order `json:"orders"`
+	NextPageToken string         `json:"next_page_token,omitempty"`
+}
+
+func (a *app) cmdList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	customer := fs.String("customer", "", "filter by customer_id")
+	deliveryService := fs.String("delivery-service", "", "filter by delivery_service")
+	email := fs.String("email", "", "filter by delivery e-mail (exact)")
+	phone := fs.String("phone", "", "filter by delivery phone (exact)")
+	from := fs.String("from", "", "created at or after (RFC 3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "created before (RFC 3339 or YYYY-MM-DD)")
+	limit := fs.Int("limit", 50, "page size")
+	pageToken := fs.String("page-token", "", "token of the page to fetch")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+
+	req := &orderpb.ListOrdersRequest{
+		CustomerId:      *customer,
+		DeliveryService: *deliveryService,
+		Email:           *email,
+		Phone:           *phone,
+		PageSize:        int32(*limit),
+		PageToken:       *pageToken,
+	}
+	if *from != "" {
+		t, err := parseTime(*from)
+		if err != nil {
+			return usageError("-from: " + err.Error())
+		}
+		req.From = timestamppb.New(t)
+	}
+	if *to != "" {
+		t, err := parseTime(*to)
+		if err != nil {
+			return usageError("-to: " + err.Error())
+		}
+		req.To = timestamppb.New(t)
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ListOrders(ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.printOrders(listResult{NextPageToken: resp.GetNextPageToken()}, resp.GetOrders())
+}
+
+func (a *app) printOrders(res listResult, orders []*orderpb.Order) error {
+	for _, o := range orders {
+		res.Orders = append(res.Orders, orderconv.FromProto(o))
+	}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ORDER_UID\tCREATED\tCUSTOMER\tDELIVERY\tITEMS\tAMOUNT")
+		for _, o := range res.Orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d %s\n", o.OrderUID, o.DateCreated.UTC().Format(time.RFC3339),
+				o.CustomerID, o.DeliveryService, len(o.Items), o.Payment.Amount, o.Payment.Currency)
+		}
+		if res.NextPageToken != "" {
+			fmt.Fprintf(tw, "\nnext page: -page-token %s\n", res.NextPageToken)
+		}
+	})
+}
+
+type searchResult struct {
+	Hits          []searchHit `json:"hits"`
+	NextPageToken string      `json:"next_page_token,omitempty"`
+}
+
+type searchHit struct {
+	Order models.Order `json:"order"`
+	Rank  float32      `json:"rank"`
+}
+
+func (a *app) cmdSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "page size")
+	pageToken := fs.String("page-token", "", "token of the page to fetch")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() == 0 {
+		return usageError(`usage: ordersctl search [-limit N] [-page-token T] <query>, e.g. 'ivanov phone:4567 -service:cdek'`)
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SearchOrders(ctx, &orderpb.SearchOrdersRequest{
+		Query:     strings.Join(fs.Args(), " "),
+		PageSize:  int32(*limit),
+		PageToken: *pageToken,
+	})
+	if err != nil {
+		return err
+	}
+	res := searchResult{NextPageToken: resp.GetNextPageToken()}
+	for _, h := range resp.GetHits() {
+		res.Hits = append(res.Hits, searchHit{Order: orderconv.FromProto(h.GetOrder()), Rank: h.GetRank()})
+	}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ORDER_UID\tRANK\tCREATED\tTRACK\tRECIPIENT\tCITY\tITEMS")
+		for _, h := range res.Hits {
+			o := h.Order
+			fmt.Fprintf(tw, "%s\t%.3f\t%s\t%s\t%s\t%s\t%d\n", o.OrderUID, h.Rank, o.DateCreated.UTC().Format(time.RFC3339),
+				o.TrackNumber, o.Delivery.Name, o.Delivery.City, len(o.Items))
+		}
+		if res.NextPageToken != "" {
+			fmt.Fprintf(tw, "\nnext page: -page-token %s\n", res.NextPageToken)
+		}
+	})
+}
+
+type diffResult struct {
+	OrderUID string      `json:"order_uid"`
+	Cached   bool        `json:"cached"`
+	InSync   bool        `json:"in_sync"`
+	Diffs    []fieldDiff `json:"diffs,omitempty"`
+}
+
+func (a *app) cmdDiff(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: ordersctl diff <order_uid>")
+	}
+	uid := args[0]
+	repo, rdb, err := a.stores(ctx)
+	if err != nil {
+		return err
+	}
+	dbCopy, err := repo.GetOrder(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	cached, ok, err := redisrepo.NewOrderCache(rdb, otel.Tracer("ordersctl")).Get(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	res := diffResult{OrderUID: uid, Cached: ok}
+	if ok {
+		if res.Diffs, err = diffOrders(cached, dbCopy); err != nil {
+			return err
+		}
+		res.InSync = len(res.Diffs) == 0
+	}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		switch {
+		case !res.Cached:
+			fmt.Fprintf(tw, "%s is not cached\n", uid)
+		case res.InSync:
+			fmt.Fprintf(tw, "%s: cache and database are in sync\n", uid)
+		default:
+			fmt.Fprintln(tw, "FIELD\tCACHE\tDB")
+			for _, d := range res.Diffs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Field, d.Cache, d.DB)
+			}
+		}
+	})
+}
+
+type cacheResult struct {
+	Action string   `json:"action"`
+	Keys   []string `json:"keys"`
+}
+
+func (a *app) cmdCache(ctx context.Context, args []string) error {
+	if len(args) < 2 || (args[0] != "evict" && args[0] != "warm") {
+		return usageError("usage: ordersctl cache evict|warm <order_uid>...")
+	}
+	svc, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	action, uids := args[0], args[1:]
+	if action == "evict" {
+		err = svc.EvictCache(ctx, uids...)
+	} else {
+		err = svc.WarmCache(ctx, uids...)
+	}
+	if err != nil {
+		return err
+	}
+	res := cacheResult{Action: action, Keys: uids}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ORDER_UID\tACTION")
+		for _, uid := range uids {
+			fmt.Fprintf(tw, "%s\t%s\n", uid, action)
+		}
+	})
+}
+
+func (a *app) cmdLag(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lag", flag.ContinueOnError)
+	topic := fs.String("topic", a.cfg.KafkaTopic, "topic")
+	group := fs.String("group", a.cfg.KafkaGroupID, "consumer group id")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	lag, err := consumer.Lag(ctx, a.cfg.KafkaBrokers, *topic, *group)
+	if err != nil {
+		return err
+	}
+	return a.out.print(lag, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "PARTITION\tCOMMITTED\tEND\tLAG")
+		var total int64
+		for _, p := range lag {
+			committed := fmt.Sprint(p.Committed)
+			if p.Committed < 0 {
+				committed = "-"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", p.Partition, committed, p.End, p.Lag)
+			total += p.Lag
+		}
+		fmt.Fprintf(tw, "total\t\t\t%d\n", total)
+	})
+}
+
+type reencryptResult struct {
+	Rows    int    `json:"rows"`
+	KeyID   string `json:"key_id,omitempty"`
+	Decrypt bool   `json:"decrypt"`
+}
+
+func (a *app) cmdReencrypt(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	batch := fs.Int("batch", 500, "rows per transaction")
+	decrypt := fs.Bool("decrypt", false, "store PII as plaintext again (before rolling back the encryption migration)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if a.cfg.Keyfile == "" {
+		return usageError("ENCRYPTION_KEYFILE is required for reencrypt")
+	}
+	repo, _, err := a.stores(ctx)
+	if err != nil {
+		return err
+	}
+	res := reencryptResult{Decrypt: *decrypt}
+	for {
+		n, err := repo.Reencrypt(ctx, *batch, *decrypt)
+		res.Rows += n
+		if err != nil {
+			return fmt.Errorf("after %d rows: %w", res.Rows, err)
+		}
+		if n < *batch {
+			break
+		}
+	}
+	if !*decrypt {
+		res.KeyID = a.keys.ActiveKeyID()
+	}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		if res.Decrypt {
+			fmt.Fprintf(tw, "decrypted %d rows\n", res.Rows)
+			return
+		}
+		fmt.Fprintf(tw, "re-encrypted %d rows with key %s\n", res.Rows, res.KeyID)
+	})
+}
+
+func (a *app) cmdReconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	uid := fs.String("uid", "", "check a single order")
+	from := fs.String("from", "", "check orders created at or after (RFC 3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "check orders created before (RFC 3339 or YYYY-MM-DD)")
+	modeName := fs.String("mode", string(service.ReconcileRepair), "what to do with stale entries: repair, evict or report")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	mode, err := service.ParseReconcileMode(*modeName)
+	if err != nil {
+		return usageError(err.Error())
+	}
+	if (*uid == "") == (*from == "" || *to == "") {
+		return usageError("usage: ordersctl reconcile -uid X | -from A -to B [-mode repair|evict|report]")
+	}
+
+	repo, rdb, err := a.stores(ctx)
+	if err != nil {
+		return err
+	}
+	tracer := otel.Tracer("ordersctl")
+	r := service.NewReconciler(repo, redisrepo.NewOrderCache(rdb, tracer), a.cfg.CacheTTL,
+		service.ReconcilerConfig{Mode: mode}, a.logger, tracer)
+
+	var report service.ReconcileReport
+	if *uid != "" {
+		res, err := r.ReconcileUID(ctx, *uid)
+		if err != nil {
+			return err
+		}
+		report.Checked, report.Outcomes = 1, map[string]int{res.Outcome: 1}
+		if res.Outcome != service.OutcomeOK && res.Outcome != service.OutcomeNotCache {
+			report.Problems = []service.ReconcileResult{res}
+		}
+	} else {
+		fromT, err := parseTime(*from)
+		if err != nil {
+			return usageError("-from: " + err.Error())
+		}
+		toT, err := parseTime(*to)
+		if err != nil {
+			return usageError("-to: " + err.Error())
+		}
+		if report, err = r.ReconcileRange(ctx, fromT, toT); err != nil {
+			return err
+		}
+	}
+	return a.out.print(report, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "checked: %d\n", report.Checked)
+		for _, k := range []string{service.OutcomeOK, service.OutcomeNotCache, service.OutcomeMismatch, service.OutcomeOrphan, service.OutcomeError} {
+			fmt.Fprintf(tw, "%s:\t%d\n", k, report.Outcomes[k])
+		}
+		if len(report.Problems) > 0 {
+			fmt.Fprintln(tw, "\nORDER_UID\tOUTCOME\tACTION\tERROR")
+			for _, p := range report.Problems {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.OrderUID, p.Outcome, p.Action, p.Error)
+			}
+		}
+	})
+}
+
+type auditResult struct {
+	Events        []audit.Event `json:"events"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+}
+
+func (a *app) cmdAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	actor := fs.String("actor", "", "filter by actor (subject)")
+	action := fs.String("action", "", "filter by action: read, create, update-status, erase")
+	uid := fs.String("uid", "", "filter by order_uid")
+	source := fs.String("source", "", "filter by source: http, grpc, kafka")
+	outcome := fs.String("outcome", "", "filter by outcome: ok, denied, not_found, invalid, error")
+	from := fs.String("from", "", "at or after (RFC 3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "before (RFC 3339 or YYYY-MM-DD)")
+	limit := fs.Int("limit", 100, "page size")
+	pageToken := fs.String("page-token", "", "token of the page to fetch")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+
+	req := &orderpb.QueryAuditLogRequest{
+		Actor:     *actor,
+		Action:    *action,
+		OrderUid:  *uid,
+		Source:    *source,
+		Outcome:   *outcome,
+		PageSize:  int32(*limit),
+		PageToken: *pageToken,
+	}
+	if *from != "" {
+		t, err := parseTime(*from)
+		if err != nil {
+			return usageError("-from: " + err.Error())
+		}
+		req.From = timestamppb.New(t)
+	}
+	if *to != "" {
+		t, err := parseTime(*to)
+		if err != nil {
+			return usageError("-to: " + err.Error())
+		}
+		req.To = timestamppb.New(t)
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.QueryAuditLog(ctx, req)
+	if err != nil {
+		return err
+	}
+	res := auditResult{Events: []audit.Event{}, NextPageToken: resp.GetNextPageToken()}
+	for _, e := range resp.GetEvents() {
+		res.Events = append(res.Events, audit.Event{
+			ID:        e.GetId(),
+			At:        e.GetAt().AsTime(),
+			Actor:     e.GetActor(),
+			Action:    e.GetAction(),
+			OrderUID:  e.GetOrderUid(),
+			RequestID: e.GetRequestId(),
+			TraceID:   e.GetTraceId(),
+			Source:    e.GetSource(),
+			Outcome:   e.GetOutcome(),
+		})
+	}
+	return a.out.print(res, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "AT\tACTOR\tACTION\tORDER_UID\tSOURCE\tOUTCOME\tREQ_ID")
+		for _, e := range res.Events {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.At.Format(time.RFC3339),
+				e.Actor, e.Action, e.OrderUID, e.Source, e.Outcome, e.RequestID)
+		}
+		if res.NextPageToken != "" {
+			fmt.Fprintf(tw, "\nnext page: -page-token %s\n", res.NextPageToken)
+		}
+	})
+}
+
+type erasureResult struct {
+	CustomerID string    `json:"customer_id"`
+	OrderUIDs  []string  `json:"order_uids"`
+	ErasedAt   time.Time `json:"erased_at"`
+}
+
+func (a *app) cmdGDPR(ctx context.Context, args []string) error {
+	const help = "usage: ordersctl gdpr export <customer_id> [-out file] | gdpr erase <customer_id> -confirm"
+	if len(args) < 2 || (args[0] != "export" && args[0] != "erase") {
+		return usageError(help)
+	}
+	fs := flag.NewFlagSet("gdpr", flag.ContinueOnError)
+	out := fs.String("out", "", "write the export archive (JSON) to this file")
+	confirm := fs.Bool("confirm", false, "confirm the erasure; it cannot be undone")
+	if err := fs.Parse(args[2:]); err != nil {
+		return usageError(err.Error())
+	}
+	action, customerID := args[0], args[1]
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	if action == "erase" {
+		if !*confirm {
+			return usageError("erasure cannot be undone, pass -confirm")
+		}
+		resp, err := client.EraseCustomerData(ctx, &orderpb.EraseCustomerDataRequest{CustomerId: customerID})
+		if err != nil {
+			return err
+		}
+		res := erasureResult{CustomerID: customerID, OrderUIDs: resp.GetOrderUids(), ErasedAt: resp.GetErasedAt().AsTime()}
+		return a.out.print(res, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "%s: %d orders anonymized at %s\n", customerID, len(res.OrderUIDs), res.ErasedAt.Format(time.RFC3339))
+			for _, uid := range res.OrderUIDs {
+				fmt.Fprintln(tw, uid)
+			}
+		})
+	}
+
+	resp, err := client.ExportCustomerData(ctx, &orderpb.ExportCustomerDataRequest{CustomerId: customerID})
+	if err != nil {
+		return err
+	}
+	export := service.CustomerExport{CustomerID: resp.GetCustomerId(), ExportedAt: resp.GetExportedAt().AsTime(), Orders: []models.Order{}}
+	for _, o := range resp.GetOrders() {
+		export.Orders = append(export.Orders, orderconv.FromProto(o))
+	}
+	if *out != "" {
+		raw, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, append(raw, '\n'), 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d orders of %s written to %s\n", len(export.Orders), customerID, *out)
+		return nil
+	}
+	return a.out.print(export, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ORDER_UID\tCREATED\tDELIVERY\tRECIPIENT\tAMOUNT")
+		for _, o := range export.Orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d %s\n", o.OrderUID, o.DateCreated.UTC().Format(time.RFC3339),
+				o.DeliveryService, o.Delivery.Name, o.Payment.Amount, o.Payment.Currency)
+		}
+	})
+}
+
+func printOrder(tw *tabwriter.Writer, o models.Order) {
+	fmt.Fprintf(tw, "Order:\t%s\n", o.OrderUID)
+	fmt.Fprintf(tw, "Track number:\t%s\n", o.TrackNumber)
+	fmt.Fprintf(tw, "Created:\t%s\n", o.DateCreated.UTC().Format(time.RFC3339))
+	fmt.Fprintf(tw, "Customer:\t%s\n", o.CustomerID)
+	fmt.Fprintf(tw, "Entry / locale:\t%s / %s\n", o.Entry, o.Locale)
+	fmt.Fprintf(tw, "Delivery service:\t%s\n", o.DeliveryService)
+	fmt.Fprintf(tw, "Shard / sm_id / oof:\t%s / %d / %s\n", o.ShardKey, o.SmID, o.OofShard)
+	fmt.Fprintln(tw)
+	d := o.Delivery
+	fmt.Fprintf(tw, "Recipient:\t%s, %s, %s\n", d.Name, d.Phone, d.Email)
+	fmt.Fprintf(tw, "Address:\t%s\n", strings.Join([]string{d.Zip, d.Region, d.City, d.Address}, ", "))
+	fmt.Fprintln(tw)
+	p := o.Payment
+	fmt.Fprintf(tw, "Payment:\t%s via %s (%s)\n", p.Transaction, p.Provider, p.Bank)
+	fmt.Fprintf(tw, "Amount:\t%d %s = goods %d + delivery %d + fee %d\n", p.Amount, p.Currency, p.GoodsTotal, p.DeliveryCost, p.CustomFee)
+	if p.PaymentDT > 0 {
+		fmt.Fprintf(tw, "Paid at:\t%s\n", time.Unix(p.PaymentDT, 0).UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "CHRT_ID\tNAME\tBRAND\tSIZE\tPRICE\tSALE\tTOTAL\tSTATUS")
+	for _, it := range o.Items {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d%%\t%d\t%d\n", it.ChrtID, it.Name, it.Brand, it.Size, it.Price, it.Sale, it.TotalPrice, it.Status)
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("want RFC 3339 or YYYY-MM-DD")
+}
+
+func (a *app) cmdWatch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	customer := fs.String("customer", "", "only orders of this customer_id")
+	deliveryService := fs.String("delivery-service", "", "only orders of this delivery_service")
+	after := fs.Uint64("after", 0, "resume after this event id")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	st, err := client.WatchOrders(ctx, &orderpb.WatchOrdersRequest{
+		CustomerId:      *customer,
+		DeliveryService: *deliveryService,
+		LastEventId:     *after,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		ev, err := st.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		o := ev.GetOrder()
+		res := stream.Event{ID: ev.GetId(), Order: stream.Summary{
+			OrderUID:        o.GetOrderUid(),
+			TrackNumber:     o.GetTrackNumber(),
+			CustomerID:      o.GetCustomerId(),
+			DeliveryService: o.GetDeliveryService(),
+			Region:          o.GetRegion(),
+			Amount:          int(o.GetAmount()),
+			Currency:        o.GetCurrency(),
+			Items:           int(o.GetItems()),
+			DateCreated:     o.GetDateCreated().AsTime(),
+		}}
+		err = a.out.print(res, func(tw *tabwriter.Writer) {
+			s := res.Order
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d %s\n", res.ID, s.DateCreated.UTC().Format(time.RFC3339),
+				s.OrderUID, s.CustomerID, s.DeliveryService, s.Items, s.Amount, s.Currency)
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (a *app) cmdExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", "", "orders created at or after (RFC 3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "orders created before (RFC 3339 or YYYY-MM-DD)")
+	format := fs.String("format", "csv", "csv, ndjson or parquet")
+	columns := fs.String("columns", "", "comma-separated columns in output order (default all)")
+	out := fs.String("out", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if *from == "" || *to == "" {
+		return usageError("usage: ordersctl export -from A -to B [-format csv|ndjson|parquet] [-columns c1,c2] [-out file]")
+	}
+	start, err := parseTime(*from)
+	if err != nil {
+		return usageError("-from: " + err.Error())
+	}
+	end, err := parseTime(*to)
+	if err != nil {
+		return usageError("-to: " + err.Error())
+	}
+	req := &orderpb.ExportOrdersRequest{From: timestamppb.New(start), To: timestamppb.New(end), Format: *format}
+	if *columns != "" {
+		req.Columns = strings.Split(*columns, ",")
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	st, err := client.ExportOrders(ctx, req)
+	if err != nil {
+		return err
+	}
+	var (
+		w    io.Writer = os.Stdout
+		file *os.File
+	)
+	if *out != "" {
+		if file, err = os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+			return err
+		}
+		w = file
+	}
+	err = func() error {
+		for {
+			chunk, err := st.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(chunk.GetData()); err != nil {
+				return err
+			}
+		}
+	}()
+	if file != nil {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			// a partial export must not pass for a complete one
+			os.Remove(*out)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	trailer := st.Trailer()
+	fmt.Fprintf(os.Stderr, "%s rows, sha256 %s\n", strings.Join(trailer.Get("x-export-rows"), ""), strings.Join(trailer.Get("x-export-sha256"), ""))
+	return nil
+}
+
+func (a *app) cmdImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	batch := fs.Int("batch", importer.DefaultBatchSize, "orders per transaction")
+	checkpoint := fs.String("checkpoint", "import.checkpoint.json", "progress file to resume from; empty disables resuming")
+	rejects := fs.String("rejects", "import.rejects.ndjson", "file for rejected lines with the reason")
+	prime := fs.Duration("prime", 0, "cache imported orders created within this period, e.g. 72h")
+	progress := fs.Duration("progress", 10*time.Second, "progress log interval")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() == 0 {
+		return usageError("usage: ordersctl import [-batch N] [-checkpoint file] [-rejects file] [-prime 72h] <file|dir>...")
+	}
+	repo, rdb, err := a.stores(ctx)
+	if err != nil {
+		return err
+	}
+	cfg := importer.Config{
+		BatchSize:  *batch,
+		Checkpoint: *checkpoint,
+		Rejects:    *rejects,
+		Progress:   *progress,
+	}
+	if *prime > 0 {
+		cfg.Cache = redisrepo.NewOrderCache(rdb, otel.Tracer("ordersctl"))
+		cfg.CacheTTL = a.cfg.CacheTTL
+		cfg.PrimeSince = time.Now().Add(-*prime)
+	}
+	stats, err := importer.New(repo, cfg, a.logger).Run(ctx, fs.Args())
+	if perr := a.out.print(stats, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "READ\tIMPORTED\tDUPLICATE\tREJECTED\tPRIMED")
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\n", stats.Read, stats.Imported, stats.Duplicate, stats.Rejected, stats.Primed)
+	}); perr != nil {
+		return perr
+	}
+	if err == nil && stats.Rejected > 0 && *rejects != "" {
+		fmt.Fprintf(os.Stderr, "rejected lines are in %s\n", *rejects)
+	}
+	return err
+}
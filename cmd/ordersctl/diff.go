@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"orderservice/pkg/models"
+)
+
+// fieldDiff is one differing leaf field between two copies of an order.
+type fieldDiff struct {
+	Field string `json:"field"`
+	Cache string `json:"cache"`
+	DB    string `json:"db"`
+}
+
+// diffOrders compares two orders field by field using their JSON paths.
+func diffOrders(cache, db models.Order) ([]fieldDiff, error) {
+	a, err := flatten(cache)
+	if err != nil {
+		return nil, err
+	}
+	b, err := flatten(db)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	var diffs []fieldDiff
+	for k := range keys {
+		if a[k] != b[k] {
+			diffs = append(diffs, fieldDiff{Field: k, Cache: a[k], DB: b[k]})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs, nil
+}
+
+func flatten(o models.Order) (map[string]string, error) {
+	o.DateCreated = o.DateCreated.UTC()
+	raw, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if prefix == "" {
+					walk(k, child)
+				} else {
+					walk(prefix+"."+k, child)
+				}
+			}
+		case []any:
+			for i, child := range t {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+		case nil:
+			out[prefix] = "null"
+		default:
+			out[prefix] = fmt.Sprint(t)
+		}
+	}
+	walk("", generic)
+	return out, nil
+}
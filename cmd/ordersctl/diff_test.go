@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+)
+
+func TestDiffOrders(t *testing.T) {
+	a := fake.Order()
+	b := a
+	b.DateCreated = a.DateCreated.In(time.FixedZone("MSK", 3*3600))
+	b.Items = append([]models.Item(nil), a.Items...)
+	diffs, err := diffOrders(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("same order in another time zone differs: %+v", diffs)
+	}
+
+	b.Delivery.Phone = "+70000000000"
+	b.Items[0].Price++
+	diffs, err = diffOrders(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Field != "delivery.phone" || diffs[1].Field != "items[0].price" {
+		t.Fatalf("unexpected diffs %+v", diffs)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"orderservice/internal/db"
+	"orderservice/internal/keyring"
+	"orderservice/internal/repository/postgres"
+	redisrepo "orderservice/internal/repository/redis"
+	"orderservice/internal/service"
+	"orderservice/pkg/api/orderpb"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `ordersctl — operator tool for the order service
+
+Usage:
+  ordersctl [-o table|json|yaml] <command> [flags] [args]
+
+Commands:
+  get <order_uid>                 fetch an order through the gRPC API
+  list [filters]                  list orders through the gRPC API
+  lookup -track T | -transaction T | -rid R | -chrt-id N | -nm-id N
+                                  find orders by track number, payment or item
+  search [-limit N] <query>       full-text search, e.g. 'ivanov phone:4567 -service:cdek'
+  diff <order_uid>                compare the cached copy with the database copy
+  cache evict <order_uid>...      drop cache entries
+  cache warm <order_uid>...       reload cache entries from the database
+  replay -since T [-until T] | -partition N -from A -to B | -range P:A-B...
+         [-group G] [-rate N] [-decoder D] [-dry-run]
+                                  re-process Kafka messages through the save path
+  lag [-group G]                  show consumer group lag per partition
+  reencrypt [-batch N] [-decrypt]
+                                  move delivery PII to the active key (or back to plaintext)
+  reconcile -uid X | -from A -to B [-mode repair|evict|report]
+                                  check cached orders against the database
+  watch [-customer C] [-delivery-service D] [-after ID]
+                                  follow newly saved orders live
+  export -from A -to B [-format csv|ndjson|parquet] [-columns c1,c2] [-out file]
+                                  export orders with payments and items, one row per item
+  import [-batch N] [-checkpoint file] [-rejects file] [-prime 72h] <file|dir>...
+                                  bulk-load NDJSON dumps into the database, resumable
+  stats [-from A -to B] [-bucket day|week|month|total] [-group-by currency,provider,...]
+                                  order counts, basket size and revenue per period
+  top-items [-by brand|item] [-metric quantity|revenue -currency C] [-limit N]
+                                  best-selling brands or items
+  audit [filters]                 search the audit log of order reads and writes
+  gdpr export <customer_id> [-out file]
+                                  export every order of a customer (data subject request)
+  gdpr erase <customer_id> -confirm
+                                  anonymize a customer's personal data, keeping payments
+
+Environment: GRPC_ADDR, DATABASE_URL, REDIS_ADDR, REDIS_PASSWORD, KAFKA_BROKERS,
+KAFKA_TOPIC, KAFKA_GROUP_ID, CACHE_TTL, ENCRYPTION_KEYFILE; ORDERSCTL_TOKEN (bearer JWT) or
+ORDERSCTL_API_KEY authenticate gRPC calls.
+`
+
+type ctlConfig struct {
+	GRPCAddr      string        `env:"GRPC_ADDR" env-default:"localhost:9090"`
+	DatabaseURL   string        `env:"DATABASE_URL" env-default:""`
+	RedisAddr     string        `env:"REDIS_ADDR" env-default:"localhost:6379"`
+	RedisPassword string        `env:"REDIS_PASSWORD" env-default:""`
+	KafkaBrokers  []string      `env:"KAFKA_BROKERS" env-separator:"," env-default:"localhost:9092"`
+	KafkaTopic    string        `env:"KAFKA_TOPIC" env-default:"orders_topic"`
+	KafkaGroupID  string        `env:"KAFKA_GROUP_ID" env-default:"orders_consumer"`
+	CacheTTL      time.Duration `env:"CACHE_TTL" env-default:"5m"`
+	Keyfile       string        `env:"ENCRYPTION_KEYFILE" env-default:""`
+	Token         string        `env:"ORDERSCTL_TOKEN" env-default:""`
+	APIKey        string        `env:"ORDERSCTL_API_KEY" env-default:""`
+}
+
+// app lazily opens the connections a command needs.
+type app struct {
+	cfg    ctlConfig
+	out    printer
+	logger *slog.Logger
+
+	conn  *grpc.ClientConn
+	pool  *pgxpool.Pool
+	redis *redis.Client
+	keys  *keyring.Keyring
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	format := flag.String("o", "table", "output format: table, json or yaml")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	a := &app{out: out, logger: slog.New(slog.NewTextHandler(os.Stderr, nil))}
+	if err := cleanenv.ReadEnv(&a.cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(2)
+	}
+	defer a.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := a.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		var ue usageError
+		if errors.As(err, &ue) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func (a *app) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "get":
+		return a.cmdGet(ctx, args)
+	case "list":
+		return a.cmdList(ctx, args)
+	case "lookup":
+		return a.cmdLookup(ctx, args)
+	case "search":
+		return a.cmdSearch(ctx, args)
+	case "diff":
+		return a.cmdDiff(ctx, args)
+	case "cache":
+		return a.cmdCache(ctx, args)
+	case "replay":
+		return a.cmdReplay(ctx, args)
+	case "lag":
+		return a.cmdLag(ctx, args)
+	case "reencrypt":
+		return a.cmdReencrypt(ctx, args)
+	case "reconcile":
+		return a.cmdReconcile(ctx, args)
+	case "watch":
+		return a.cmdWatch(ctx, args)
+	case "export":
+		return a.cmdExport(ctx, args)
+	case "import":
+		return a.cmdImport(ctx, args)
+	case "stats":
+		return a.cmdStats(ctx, args)
+	case "top-items":
+		return a.cmdTopItems(ctx, args)
+	case "audit":
+		return a.cmdAudit(ctx, args)
+	case "gdpr":
+		return a.cmdGDPR(ctx, args)
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		return usageError(fmt.Sprintf("unknown command %q, see ordersctl help", cmd))
+	}
+}
+
+func (a *app) client() (orderpb.OrderServiceClient, error) {
+	if err := a.dial(); err != nil {
+		return nil, err
+	}
+	return orderpb.NewOrderServiceClient(a.conn), nil
+}
+
+func (a *app) analyticsClient() (orderpb.OrderAnalyticsClient, error) {
+	if err := a.dial(); err != nil {
+		return nil, err
+	}
+	return orderpb.NewOrderAnalyticsClient(a.conn), nil
+}
+
+func (a *app) dial() error {
+	if a.conn == nil {
+		conn, err := grpc.NewClient(a.cfg.GRPCAddr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(a.withCredentials),
+			grpc.WithStreamInterceptor(a.withStreamCredentials))
+		if err != nil {
+			return fmt.Errorf("grpc dial: %w", err)
+		}
+		a.conn = conn
+	}
+	return nil
+}
+
+// withCredentials attaches the configured token or API key to every call.
+func (a *app) withCredentials(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(a.credentials(ctx), method, req, reply, cc, opts...)
+}
+
+func (a *app) withStreamCredentials(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(a.credentials(ctx), desc, cc, method, opts...)
+}
+
+func (a *app) credentials(ctx context.Context) context.Context {
+	switch {
+	case a.cfg.Token != "":
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+a.cfg.Token)
+	case a.cfg.APIKey != "":
+		return metadata.AppendToOutgoingContext(ctx, "x-api-key", a.cfg.APIKey)
+	}
+	return ctx
+}
+
+func (a *app) stores(ctx context.Context) (*postgres.OrderRepository, *redis.Client, error) {
+	if a.cfg.DatabaseURL == "" {
+		return nil, nil, errors.New("DATABASE_URL is required for this command")
+	}
+	if a.pool == nil {
+		pool, err := db.ConnectDB(ctx, a.cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("db connect: %w", err)
+		}
+		a.pool = pool
+	}
+	if a.redis == nil {
+		a.redis = redis.NewClient(&redis.Options{Addr: a.cfg.RedisAddr, Password: a.cfg.RedisPassword})
+	}
+	var opts []postgres.Option
+	if a.cfg.Keyfile != "" && a.keys == nil {
+		kf, err := keyring.ReadKeyfile(a.cfg.Keyfile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.keys, err = keyring.Open(ctx, kf, postgres.NewDataKeyStore(a.pool)); err != nil {
+			return nil, nil, fmt.Errorf("keyring: %w", err)
+		}
+	}
+	if a.keys != nil {
+		opts = append(opts, postgres.WithKeyring(a.keys))
+	}
+	return postgres.NewOrderRepository(a.pool, otel.Tracer("ordersctl"), opts...), a.redis, nil
+}
+
+func (a *app) service(ctx context.Context) (*service.Service, error) {
+	repo, rdb, err := a.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tracer := otel.Tracer("ordersctl")
+	return service.New(repo, redisrepo.NewOrderCache(rdb, tracer), a.cfg.CacheTTL, a.logger, tracer), nil
+}
+
+func (a *app) close() {
+	if a.conn != nil {
+		a.conn.Close()
+	}
+	if a.redis != nil {
+		a.redis.Close()
+	}
+	if a.pool != nil {
+		a.pool.Close()
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printer renders command results as a table, JSON or YAML.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table", "json", "yaml":
+		return printer{format: format, w: w}, nil
+	default:
+		return printer{}, fmt.Errorf("unknown output format %q (want table, json or yaml)", format)
+	}
+}
+
+// print writes v as JSON/YAML, or calls table to render it as aligned columns.
+func (p printer) print(v any, table func(tw *tabwriter.Writer)) error {
+	switch p.format {
+	case "json":
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		// через JSON, чтобы ключи совпадали с json-тегами моделей
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic any
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		if err := enc.Encode(generic); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"orderservice/internal/consumer"
+	"orderservice/internal/repository"
+	"orderservice/internal/repository/postgres"
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+
+	"go.opentelemetry.io/otel"
+)
+
+// maxReplayChanges bounds the changed orders listed by a dry run.
+const maxReplayChanges = 100
+
+type replayResult struct {
+	consumer.ReplayStats
+	DryRun *replayReport `json:"dry_run,omitempty"`
+}
+
+// replayReport is what a dry run found: orders missing from the database,
+// stored as they are, stored differently, and invalid. A real replay saves
+// only the new ones; changed orders keep their stored version.
+type replayReport struct {
+	New       int            `json:"new"`
+	Unchanged int            `json:"unchanged"`
+	Changed   int            `json:"changed"`
+	Invalid   int            `json:"invalid"`
+	Changes   []replayChange `json:"changes,omitempty"`
+}
+
+type replayChange struct {
+	OrderUID string       `json:"order_uid"`
+	Diffs    []replayDiff `json:"diffs"`
+}
+
+type replayDiff struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	DB      string `json:"db"`
+}
+
+// replayCheck is the dry-run replacement of the save path: it validates an
+// order and compares it with the stored copy.
+type replayCheck struct {
+	repo *postgres.OrderRepository
+
+	mu     sync.Mutex
+	report replayReport
+}
+
+func (c *replayCheck) check(ctx context.Context, o models.Order) error {
+	if err := service.ValidateOrder(o); err != nil {
+		c.mu.Lock()
+		c.report.Invalid++
+		c.mu.Unlock()
+		return err
+	}
+	stored, err := c.repo.GetOrder(ctx, o.OrderUID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.mu.Lock()
+		c.report.New++
+		c.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	diffs, err := diffOrders(o, stored)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(diffs) == 0 {
+		c.report.Unchanged++
+		return nil
+	}
+	c.report.Changed++
+	if len(c.report.Changes) < maxReplayChanges {
+		change := replayChange{OrderUID: o.OrderUID}
+		for _, d := range diffs {
+			change.Diffs = append(change.Diffs, replayDiff{Field: d.Field, Message: d.Cache, DB: d.DB})
+		}
+		c.report.Changes = append(c.report.Changes, change)
+	}
+	return nil
+}
+
+// parseOffsetRange reads "P:A-B", offsets A to B of partition P.
+func parseOffsetRange(s string) (consumer.OffsetRange, error) {
+	var r consumer.OffsetRange
+	p, offsets, ok := strings.Cut(s, ":")
+	from, to, ok2 := strings.Cut(offsets, "-")
+	if !ok || !ok2 {
+		return r, fmt.Errorf("range %q: want PARTITION:FROM-TO", s)
+	}
+	var err error
+	if r.Partition, err = strconv.Atoi(p); err != nil {
+		return r, fmt.Errorf("range %q: partition: %w", s, err)
+	}
+	if r.From, err = strconv.ParseInt(from, 10, 64); err != nil {
+		return r, fmt.Errorf("range %q: from: %w", s, err)
+	}
+	if r.To, err = strconv.ParseInt(to, 10, 64); err != nil {
+		return r, fmt.Errorf("range %q: to: %w", s, err)
+	}
+	if r.From < 0 || r.To < r.From {
+		return r, fmt.Errorf("range %q: want 0 <= FROM <= TO", s)
+	}
+	return r, nil
+}
+
+func (a *app) cmdReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	topic := fs.String("topic", a.cfg.KafkaTopic, "topic to replay")
+	group := fs.String("group", a.cfg.KafkaGroupID+"-replay", "consumer group the replay commits its progress to")
+	partition := fs.Int("partition", -1, "partition of -from/-to, or the only partition of -since")
+	from := fs.Int64("from", -1, "first offset (inclusive)")
+	to := fs.Int64("to", -1, "last offset (inclusive)")
+	since := fs.String("since", "", "replay every partition from this time (RFC 3339 or YYYY-MM-DD)")
+	until := fs.String("until", "", "with -since: stop at messages written after this time")
+	rate := fs.Float64("rate", 200, "messages per second over all partitions; 0 for no limit")
+	dryRun := fs.Bool("dry-run", false, "validate and diff against the database instead of saving")
+	decoder := fs.String("decoder", "json", "message format: json, json-strict, protobuf or protojson")
+	var ranges []consumer.OffsetRange
+	fs.Func("range", "offsets FROM-TO of a partition as P:FROM-TO; repeatable", func(s string) error {
+		r, err := parseOffsetRange(s)
+		ranges = append(ranges, r)
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	const usageLine = "usage: ordersctl replay -since T [-until T] [-partition N] | -partition N -from A -to B | -range P:A-B... [-group G] [-rate N] [-decoder D] [-dry-run]"
+	if *from >= 0 || *to >= 0 {
+		if *partition < 0 || *from < 0 || *to < *from {
+			return usageError(usageLine + " (A <= B)")
+		}
+		ranges = append(ranges, consumer.OffsetRange{Partition: *partition, From: *from, To: *to})
+	}
+	if (*since == "") == (len(ranges) == 0) || (*until != "" && *since == "") {
+		return usageError(usageLine)
+	}
+	if *group == a.cfg.KafkaGroupID {
+		return usageError("the replay group must differ from the live consumer group " + a.cfg.KafkaGroupID)
+	}
+
+	cfg := consumer.ReplayConfig{
+		Brokers: a.cfg.KafkaBrokers,
+		Topic:   *topic,
+		GroupID: *group,
+		Ranges:  ranges,
+		Rate:    *rate,
+		DryRun:  *dryRun,
+		Decoder: *decoder,
+	}
+	if *since != "" {
+		t, err := parseTime(*since)
+		if err != nil {
+			return usageError("-since: " + err.Error())
+		}
+		cfg.Since = t
+		if *until != "" {
+			if cfg.Until, err = parseTime(*until); err != nil {
+				return usageError("-until: " + err.Error())
+			}
+		}
+		if *partition >= 0 {
+			cfg.Partitions = []int{*partition}
+		}
+	}
+
+	var (
+		save  func(context.Context, models.Order) error
+		check *replayCheck
+	)
+	if *dryRun {
+		repo, _, err := a.stores(ctx)
+		if err != nil {
+			return err
+		}
+		check = &replayCheck{repo: repo}
+		save = check.check
+	} else {
+		svc, err := a.service(ctx)
+		if err != nil {
+			return err
+		}
+		save = svc.SaveOrder
+	}
+
+	stats, err := consumer.Replay(ctx, cfg, save, a.logger, otel.Tracer("ordersctl"))
+	res := replayResult{ReplayStats: stats}
+	if check != nil {
+		res.DryRun = &check.report
+	}
+	if perr := a.out.print(res, func(tw *tabwriter.Writer) {
+		if check != nil {
+			fmt.Fprintln(tw, "PARTITION\tFROM\tTO\tNEXT\tREAD\tCHECKED\tSKIPPED\tFAILED")
+		} else {
+			fmt.Fprintln(tw, "PARTITION\tFROM\tTO\tNEXT\tREAD\tSAVED\tSKIPPED\tFAILED")
+		}
+		for _, p := range stats.Partitions {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", p.Partition, p.From, p.To, p.Next, p.Read, p.Saved, p.Skipped, p.Failed)
+		}
+		fmt.Fprintf(tw, "total\t\t\t\t%d\t%d\t%d\t%d\n", stats.Read, stats.Saved, stats.Skipped, stats.Failed)
+		if check == nil {
+			if stats.Skipped > 0 {
+				fmt.Fprintf(tw, "\n%d orders were already stored and left unchanged\n", stats.Skipped)
+			}
+			return
+		}
+		r := check.report
+		fmt.Fprintf(tw, "\nnew: %d\tunchanged: %d\tchanged: %d\tinvalid: %d\n", r.New, r.Unchanged, r.Changed, r.Invalid)
+		if len(r.Changes) > 0 {
+			fmt.Fprintln(tw, "\nORDER_UID\tFIELD\tMESSAGE\tDB")
+			for _, c := range r.Changes {
+				for _, d := range c.Diffs {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.OrderUID, d.Field, d.Message, d.DB)
+				}
+			}
+			if r.Changed > len(r.Changes) {
+				fmt.Fprintf(tw, "... %d more changed orders\n", r.Changed-len(r.Changes))
+			}
+			fmt.Fprintln(tw, "\nchanged orders are already stored: a replay skips them and does not rewrite them")
+		}
+	}); perr != nil {
+		return perr
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+// Package analytics describes aggregated order statistics served from daily
+// rollups: orders, items and revenue per time bucket and dimension, and the
+// best-selling brands and items.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Time buckets of OrderQuery.
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week" // ISO weeks, starting on Monday
+	BucketMonth = "month"
+	BucketTotal = "total" // the whole range as one bucket
+)
+
+// Dimensions orders can be grouped by.
+const (
+	DimCurrency        = "currency"
+	DimProvider        = "provider"
+	DimDeliveryService = "delivery_service"
+)
+
+// Groupings and rankings of ItemQuery.
+const (
+	ByBrand = "brand"
+	ByItem  = "item"
+
+	MetricQuantity = "quantity"
+	MetricRevenue  = "revenue"
+)
+
+// MaxRange bounds the date range of a query.
+const MaxRange = 366 * 24 * time.Hour
+
+const maxTopItems = 100
+
+// ErrInvalidQuery wraps every validation error.
+var ErrInvalidQuery = errors.New("invalid analytics query")
+
+// OrderQuery selects order statistics of the days in [From, To) (UTC).
+type OrderQuery struct {
+	From, To time.Time
+	Bucket   string   // empty means BucketDay
+	GroupBy  []string // Dim* values
+}
+
+// Revenue is the payment total of the orders paid in one currency.
+type Revenue struct {
+	Currency string
+	Amount   int64
+	Orders   int64
+}
+
+// AvgOrderValue is the mean payment of the orders.
+func (r Revenue) AvgOrderValue() float64 {
+	if r.Orders == 0 {
+		return 0
+	}
+	return float64(r.Amount) / float64(r.Orders)
+}
+
+// OrderStats aggregates the orders of one bucket and group. Dimensions not
+// grouped by are empty; revenue is always split by currency.
+type OrderStats struct {
+	Start           time.Time
+	Currency        string
+	Provider        string
+	DeliveryService string
+	Orders          int64
+	Items           int64
+	Revenue         []Revenue
+}
+
+// AvgBasketSize is the mean number of items per order.
+func (s OrderStats) AvgBasketSize() float64 {
+	if s.Orders == 0 {
+		return 0
+	}
+	return float64(s.Items) / float64(s.Orders)
+}
+
+// ItemQuery ranks brands or items sold in the days [From, To) (UTC).
+type ItemQuery struct {
+	From, To time.Time
+	By       string // empty means ByBrand
+	Metric   string // empty means MetricQuantity
+	// Currency restricts the ranking to orders paid in it; revenue is only
+	// reported, and can only be ranked by, within one currency.
+	Currency string
+	Limit    int // 0 means 10, at most 100
+}
+
+// ItemStats is one entry of a ranking. NmID and Name are set when ranking items.
+type ItemStats struct {
+	Brand    string
+	NmID     int64
+	Name     string
+	Quantity int64
+	Orders   int64
+	Revenue  int64
+}
+
+// Store reads the rollups.
+type Store interface {
+	OrderStats(ctx context.Context, q OrderQuery) ([]OrderStats, error)
+	TopItems(ctx context.Context, q ItemQuery) ([]ItemStats, error)
+}
+
+// Normalize validates q, fills in the defaults and truncates the range to days.
+func (q *OrderQuery) Normalize() error {
+	if err := normalizeRange(&q.From, &q.To); err != nil {
+		return err
+	}
+	if q.Bucket == "" {
+		q.Bucket = BucketDay
+	}
+	if !slices.Contains([]string{BucketDay, BucketWeek, BucketMonth, BucketTotal}, q.Bucket) {
+		return fmt.Errorf("%w: unknown bucket %q", ErrInvalidQuery, q.Bucket)
+	}
+	seen := map[string]bool{}
+	for _, d := range q.GroupBy {
+		if !slices.Contains([]string{DimCurrency, DimProvider, DimDeliveryService}, d) {
+			return fmt.Errorf("%w: unknown dimension %q", ErrInvalidQuery, d)
+		}
+		if seen[d] {
+			return fmt.Errorf("%w: dimension %q repeated", ErrInvalidQuery, d)
+		}
+		seen[d] = true
+	}
+	return nil
+}
+
+// Normalize validates q, fills in the defaults and truncates the range to days.
+func (q *ItemQuery) Normalize() error {
+	if err := normalizeRange(&q.From, &q.To); err != nil {
+		return err
+	}
+	if q.By == "" {
+		q.By = ByBrand
+	}
+	if q.By != ByBrand && q.By != ByItem {
+		return fmt.Errorf("%w: unknown grouping %q", ErrInvalidQuery, q.By)
+	}
+	if q.Metric == "" {
+		q.Metric = MetricQuantity
+	}
+	switch q.Metric {
+	case MetricQuantity:
+	case MetricRevenue:
+		if q.Currency == "" {
+			return fmt.Errorf("%w: ranking by revenue needs a currency", ErrInvalidQuery)
+		}
+	default:
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidQuery, q.Metric)
+	}
+	switch {
+	case q.Limit < 0:
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit == 0:
+		q.Limit = 10
+	case q.Limit > maxTopItems:
+		q.Limit = maxTopItems
+	}
+	return nil
+}
+
+func normalizeRange(from, to *time.Time) error {
+	if from.IsZero() || to.IsZero() {
+		return fmt.Errorf("%w: from and to are required", ErrInvalidQuery)
+	}
+	*from = from.UTC().Truncate(24 * time.Hour)
+	// a partial last day is included
+	if t := to.UTC(); !t.Equal(t.Truncate(24 * time.Hour)) {
+		*to = t.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	} else {
+		*to = t
+	}
+	if !from.Before(*to) {
+		return fmt.Errorf("%w: empty range", ErrInvalidQuery)
+	}
+	if to.Sub(*from) > MaxRange {
+		return fmt.Errorf("%w: range longer than %d days", ErrInvalidQuery, int(MaxRange.Hours()/24))
+	}
+	return nil
+}
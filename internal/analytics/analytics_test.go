@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderQueryNormalize(t *testing.T) {
+	q := OrderQuery{
+		From:    time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC),
+		To:      time.Date(2025, 1, 31, 10, 0, 0, 0, time.FixedZone("MSK", 3*3600)),
+		GroupBy: []string{DimCurrency, DimProvider},
+	}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if !q.From.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.To.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("range %s..%s", q.From, q.To)
+	}
+	if q.Bucket != BucketDay {
+		t.Fatalf("default bucket %q", q.Bucket)
+	}
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, bad := range []OrderQuery{
+		{From: day},
+		{From: day, To: day},
+		{From: day, To: day.AddDate(2, 0, 0)},
+		{From: day, To: day.AddDate(0, 1, 0), Bucket: "hour"},
+		{From: day, To: day.AddDate(0, 1, 0), GroupBy: []string{"brand"}},
+		{From: day, To: day.AddDate(0, 1, 0), GroupBy: []string{DimProvider, DimProvider}},
+	} {
+		if err := bad.Normalize(); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%+v: %v", bad, err)
+		}
+	}
+}
+
+func TestItemQueryNormalize(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := ItemQuery{From: day, To: day.AddDate(0, 0, 7), Limit: 1000}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if q.By != ByBrand || q.Metric != MetricQuantity || q.Limit != maxTopItems {
+		t.Fatalf("defaults %+v", q)
+	}
+	for _, bad := range []ItemQuery{
+		{From: day, To: day.AddDate(0, 0, 7), Metric: MetricRevenue},
+		{From: day, To: day.AddDate(0, 0, 7), By: "size"},
+		{From: day, To: day.AddDate(0, 0, 7), Limit: -1},
+	} {
+		if err := bad.Normalize(); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%+v: %v", bad, err)
+		}
+	}
+	if got := (OrderStats{Orders: 4, Items: 10}).AvgBasketSize(); got != 2.5 {
+		t.Fatalf("basket size %v", got)
+	}
+}
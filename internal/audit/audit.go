@@ -0,0 +1,230 @@
+// Package audit records who did what with which order. Events are buffered
+// and written to the sinks in batches by a background loop; when the buffer is
+// full new events are dropped and counted, so recording never blocks a request.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/observability"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Actions.
+const (
+	ActionRead   = "read"
+	ActionCreate = "create"
+	// ActionUpdateStatus is reserved for order status changes.
+	ActionUpdateStatus = "update-status"
+	ActionErase        = "erase"
+	// ActionExport is a bulk export of orders; it carries no order uid.
+	ActionExport = "export"
+)
+
+// Sources an action came through.
+const (
+	SourceHTTP  = "http"
+	SourceGRPC  = "grpc"
+	SourceKafka = "kafka"
+)
+
+// Outcomes.
+const (
+	OutcomeOK       = "ok"
+	OutcomeDenied   = "denied"
+	OutcomeNotFound = "not_found"
+	OutcomeInvalid  = "invalid"
+	OutcomeError    = "error"
+)
+
+// Actors recorded when the context carries no identity.
+const (
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system" // Kafka ingestion
+)
+
+// Event is one audited action.
+type Event struct {
+	ID        int64     `json:"id,omitempty"`
+	At        time.Time `json:"at"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	OrderUID  string    `json:"order_uid,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Source    string    `json:"source"`
+	Outcome   string    `json:"outcome"`
+}
+
+// Filter narrows QueryEvents. Zero values mean "no condition".
+type Filter struct {
+	Actor    string
+	Action   string
+	OrderUID string
+	Source   string
+	Outcome  string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// Sink persists batches of events.
+type Sink interface {
+	Name() string
+	WriteEvents(ctx context.Context, events []Event) error
+}
+
+// Store searches persisted events, newest first.
+type Store interface {
+	QueryEvents(ctx context.Context, f Filter) ([]Event, error)
+}
+
+type sourceKey struct{}
+
+// WithSource marks ctx with the transport the action came through.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+func SourceFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(sourceKey{}).(string)
+	return s
+}
+
+var (
+	droppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "audit_events_dropped_total",
+		Help: "Audit events dropped because the buffer was full.",
+	})
+	writtenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_events_written_total",
+		Help: "Audit events written, by sink.",
+	}, []string{"sink"})
+	failedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_events_failed_total",
+		Help: "Audit events lost to sink write errors, by sink.",
+	}, []string{"sink"})
+)
+
+func init() {
+	prometheus.MustRegister(droppedTotal, writtenTotal, failedTotal)
+}
+
+type Config struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Recorder buffers events and writes them to its sinks.
+type Recorder struct {
+	cfg    Config
+	sinks  []Sink
+	events chan Event
+	logger *slog.Logger
+}
+
+func NewRecorder(cfg Config, logger *slog.Logger, sinks ...Sink) *Recorder {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 200
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	return &Recorder{cfg: cfg, sinks: sinks, events: make(chan Event, cfg.BufferSize), logger: logger}
+}
+
+// Record fills in the time, actor, request id, trace id and source of e from
+// ctx where they are empty and enqueues it. It never blocks.
+func (r *Recorder) Record(ctx context.Context, e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	if e.Source == "" {
+		e.Source = SourceFromContext(ctx)
+	}
+	if e.Actor == "" {
+		switch id := auth.FromContext(ctx); {
+		case id != nil:
+			e.Actor = id.Subject
+		case e.Source == SourceKafka:
+			e.Actor = ActorSystem
+		default:
+			e.Actor = ActorAnonymous
+		}
+	}
+	if e.RequestID == "" {
+		e.RequestID = observability.RequestIDFromContext(ctx)
+	}
+	if sc := trace.SpanContextFromContext(ctx); e.TraceID == "" && sc.HasTraceID() {
+		e.TraceID = sc.TraceID().String()
+	}
+	select {
+	case r.events <- e:
+	default:
+		droppedTotal.Inc()
+	}
+}
+
+// Run writes buffered events until ctx is cancelled, then flushes what is
+// left in the buffer.
+func (r *Recorder) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, r.cfg.BatchSize)
+	for {
+		select {
+		case e := <-r.events:
+			batch = append(batch, e)
+			if len(batch) >= r.cfg.BatchSize {
+				r.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ctx.Done():
+			// ctx is done: give the final flush its own deadline
+			drainCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case e := <-r.events:
+					batch = append(batch, e)
+					if len(batch) >= r.cfg.BatchSize {
+						r.flush(drainCtx, batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						r.flush(drainCtx, batch)
+					}
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(ctx context.Context, batch []Event) {
+	for _, s := range r.sinks {
+		if err := s.WriteEvents(ctx, batch); err != nil {
+			failedTotal.WithLabelValues(s.Name()).Add(float64(len(batch)))
+			r.logger.Error("audit write failed", "sink", s.Name(), "events", len(batch), "err", err)
+			continue
+		}
+		writtenTotal.WithLabelValues(s.Name()).Add(float64(len(batch)))
+	}
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/observability"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type memSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *memSink) Name() string { return "mem" }
+
+func (s *memSink) WriteEvents(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestRecorderEnrichesAndFlushesOnStop(t *testing.T) {
+	sink := &memSink{}
+	r := NewRecorder(Config{BatchSize: 10, FlushInterval: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)), sink)
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice"})
+	ctx = observability.WithRequestID(WithSource(ctx, SourceHTTP), "req-1")
+	r.Record(ctx, Event{Action: ActionRead, OrderUID: "o1", Outcome: OutcomeOK})
+	r.Record(WithSource(context.Background(), SourceKafka), Event{Action: ActionCreate, OrderUID: "o2", Outcome: OutcomeOK})
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Run(runCtx); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.events) != 2 {
+		t.Fatalf("buffered events not flushed: %+v", sink.events)
+	}
+	read := sink.events[0]
+	if read.Actor != "alice" || read.Source != SourceHTTP || read.RequestID != "req-1" || read.At.IsZero() {
+		t.Fatalf("event not enriched: %+v", read)
+	}
+	if sink.events[1].Actor != ActorSystem {
+		t.Fatalf("kafka event actor %q", sink.events[1].Actor)
+	}
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	r := NewRecorder(Config{BufferSize: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	before := testutil.ToFloat64(droppedTotal)
+	for i := 0; i < 5; i++ {
+		r.Record(context.Background(), Event{Action: ActionRead, Outcome: OutcomeOK})
+	}
+	if got := testutil.ToFloat64(droppedTotal) - before; got != 3 {
+		t.Fatalf("dropped %v events, want 3", got)
+	}
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink publishes events as JSON keyed by order uid.
+type KafkaSink struct {
+	w *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{w: &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+	}}
+}
+
+func (k *KafkaSink) Name() string { return "kafka" }
+
+func (k *KafkaSink) WriteEvents(ctx context.Context, events []Event) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		msgs[i] = kafka.Message{Key: []byte(e.OrderUID), Value: b, Time: e.At}
+	}
+	if err := k.w.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("kafka write: %w", err)
+	}
+	return nil
+}
+
+func (k *KafkaSink) Close() error {
+	return k.w.Close()
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type apiKey struct {
+	name string
+	hash []byte
+}
+
+// APIKeyVerifier accepts keys whose SHA-256 matches a configured hash, so the
+// service config never holds plaintext keys.
+type APIKeyVerifier struct {
+	keys []apiKey
+}
+
+// NewAPIKeyVerifier parses "name:sha256hex" entries.
+func NewAPIKeyVerifier(entries []string) (*APIKeyVerifier, error) {
+	v := &APIKeyVerifier{}
+	for _, e := range entries {
+		name, digest, ok := strings.Cut(strings.TrimSpace(e), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("entry %q: want name:sha256hex", e)
+		}
+		hash, err := hex.DecodeString(digest)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("entry %q: hash is not a hex SHA-256", name)
+		}
+		v.keys = append(v.keys, apiKey{name: name, hash: hash})
+	}
+	return v, nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key, the form API keys are configured in.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (v *APIKeyVerifier) Verify(ctx context.Context, creds Credentials) (*Identity, error) {
+	if creds.APIKey == "" {
+		return nil, ErrNoCredentials
+	}
+	sum := sha256.Sum256([]byte(creds.APIKey))
+	match := ""
+	for _, k := range v.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			match = k.name
+		}
+	}
+	if match == "" {
+		return nil, errors.New("unknown api key")
+	}
+	return &Identity{Subject: match, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,140 @@
+// Package auth authenticates API callers by JWT or API key and carries the
+// resulting Identity through the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoCredentials means the request carried nothing a verifier understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrUnauthenticated wraps every rejected credential.
+	ErrUnauthenticated = errors.New("unauthenticated")
+)
+
+// Authentication methods reported in Identity.Method.
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject string
+	Method  string
+	Roles   []string
+	// Attributes holds the token's string claims (customer_id, region, ...).
+	Attributes map[string]string
+}
+
+// HasRole reports whether the identity was granted role.
+func (id *Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the caller identity, or nil for anonymous requests.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(ctxKey{}).(*Identity)
+	return id
+}
+
+// Credentials are what a caller presented on one request.
+type Credentials struct {
+	Bearer string
+	APIKey string
+}
+
+// ParseAuthorization splits an Authorization header value into credentials.
+// "Bearer <jwt>" and "ApiKey <key>" are understood.
+func ParseAuthorization(header string) Credentials {
+	scheme, value, _ := strings.Cut(strings.TrimSpace(header), " ")
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		return Credentials{Bearer: value}
+	case strings.EqualFold(scheme, "ApiKey"):
+		return Credentials{APIKey: value}
+	}
+	return Credentials{}
+}
+
+// Verifier checks one kind of credential. It returns ErrNoCredentials when the
+// request does not carry that kind.
+type Verifier interface {
+	Verify(ctx context.Context, creds Credentials) (*Identity, error)
+}
+
+// Authenticator tries its verifiers in order.
+type Authenticator struct {
+	verifiers []Verifier
+}
+
+func NewAuthenticator(verifiers ...Verifier) *Authenticator {
+	return &Authenticator{verifiers: verifiers}
+}
+
+// Config describes the verifiers New builds.
+type Config struct {
+	JWKSFile   string
+	JWTKeys    []string // kid=path/to/public.pem
+	HMACSecret string
+	Issuer     string
+	Audience   string
+	Leeway     time.Duration
+	APIKeys    []string // name:sha256hex
+}
+
+// New builds an Authenticator with a JWT verifier when any key source is set
+// and an API key verifier when keys are configured.
+func New(cfg Config) (*Authenticator, error) {
+	var verifiers []Verifier
+	if cfg.JWKSFile != "" || len(cfg.JWTKeys) > 0 || cfg.HMACSecret != "" {
+		v, err := NewJWTVerifier(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		verifiers = append(verifiers, v)
+	}
+	if len(cfg.APIKeys) > 0 {
+		v, err := NewAPIKeyVerifier(cfg.APIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("api keys: %w", err)
+		}
+		verifiers = append(verifiers, v)
+	}
+	if len(verifiers) == 0 {
+		return nil, errors.New("auth enabled but no JWT keys or API keys configured")
+	}
+	return NewAuthenticator(verifiers...), nil
+}
+
+// Authenticate returns the identity of the first verifier that accepts creds.
+func (a *Authenticator) Authenticate(ctx context.Context, creds Credentials) (*Identity, error) {
+	for _, v := range a.verifiers {
+		id, err := v.Verify(ctx, creds)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+		return id, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, ErrNoCredentials)
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	raw, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a, err := New(Config{
+		JWKSFile:   writeJWKS(t, rsaKey, ecKey),
+		HMACSecret: "s3cret",
+		Issuer:     "https://idp.example",
+		Audience:   "orders",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(mut func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": "https://idp.example", "aud": "orders", "sub": "u1",
+			"exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"support"}, "region": "Moscow",
+		}
+		if mut != nil {
+			mut(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"rsa", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(nil)), true},
+		{"ecdsa", sign(t, jwt.SigningMethodES256, "ec1", ecKey, claims(nil)), true},
+		{"hmac", sign(t, jwt.SigningMethodHS256, "", []byte("s3cret"), claims(nil)), true},
+		{"wrong hmac secret", sign(t, jwt.SigningMethodHS256, "", []byte("nope"), claims(nil)), false},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "other", rsaKey, claims(nil)), false},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), false},
+		{"no exp", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { delete(c, "exp") })), false},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["iss"] = "evil" })), false},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["aud"] = "billing" })), false},
+		{"garbage", "not.a.jwt", false},
+	}
+	for _, tt := range tests {
+		id, err := a.Authenticate(context.Background(), Credentials{Bearer: tt.token})
+		if tt.ok != (err == nil) {
+			t.Fatalf("%s: err = %v", tt.name, err)
+		}
+		if err != nil && !errors.Is(err, ErrUnauthenticated) {
+			t.Fatalf("%s: want ErrUnauthenticated, got %v", tt.name, err)
+		}
+		if tt.ok && (id.Subject != "u1" || !id.HasRole("support") || id.Attributes["region"] != "Moscow") {
+			t.Fatalf("%s: identity %+v", tt.name, id)
+		}
+	}
+}
+
+func TestAPIKeyVerifier(t *testing.T) {
+	a, err := New(Config{APIKeys: []string{"ops:" + HashAPIKey("k-123")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := a.Authenticate(context.Background(), ParseAuthorization("ApiKey k-123"))
+	if err != nil || id.Subject != "ops" || id.Method != MethodAPIKey {
+		t.Fatalf("valid key: %+v, %v", id, err)
+	}
+	if _, err := a.Authenticate(context.Background(), Credentials{APIKey: "k-124"}); err == nil {
+		t.Fatalf("wrong key accepted")
+	}
+	if _, err := a.Authenticate(context.Background(), Credentials{}); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("missing credentials: %v", err)
+	}
+	if _, err := NewAPIKeyVerifier([]string{"ops:plaintext"}); err == nil {
+		t.Fatalf("unhashed key accepted")
+	}
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTVerifier checks bearer tokens signed with a key from a JWKS file, a
+// static PEM key or a shared HMAC secret. Tokens must carry exp; iss and aud
+// are enforced when configured.
+type JWTVerifier struct {
+	keys   map[string]crypto.PublicKey
+	secret []byte
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(cfg Config) (*JWTVerifier, error) {
+	v := &JWTVerifier{keys: make(map[string]crypto.PublicKey)}
+	if cfg.HMACSecret != "" {
+		v.secret = []byte(cfg.HMACSecret)
+	}
+	if cfg.JWKSFile != "" {
+		raw, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwks: %w", err)
+		}
+		keys, err := ParseJWKS(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse jwks %s: %w", cfg.JWKSFile, err)
+		}
+		for kid, k := range keys {
+			v.keys[kid] = k
+		}
+	}
+	for _, e := range cfg.JWTKeys {
+		kid, path, ok := strings.Cut(strings.TrimSpace(e), "=")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("key %q: want kid=path/to/public.pem", e)
+		}
+		k, err := readPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+		v.keys[kid] = k
+	}
+	if len(v.keys) == 0 && v.secret == nil {
+		return nil, errors.New("no verification keys")
+	}
+
+	methods := []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+	if v.secret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, creds Credentials) (*Identity, error) {
+	if creds.Bearer == "" {
+		return nil, ErrNoCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(creds.Bearer, claims, v.key); err != nil {
+		return nil, err
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, errors.New("token has no sub")
+	}
+	id := &Identity{Subject: sub, Method: MethodJWT, Roles: roles(claims), Attributes: make(map[string]string)}
+	for k, val := range claims {
+		if s, ok := val.(string); ok && !registeredClaim[k] {
+			id.Attributes[k] = s
+		}
+	}
+	return id, nil
+}
+
+var registeredClaim = map[string]bool{"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true, "scope": true}
+
+// roles reads the "roles" claim (string or array), falling back to the
+// space-separated "scope" claim.
+func roles(claims jwt.MapClaims) []string {
+	switch r := claims["roles"].(type) {
+	case string:
+		return strings.Fields(r)
+	case []any:
+		out := make([]string, 0, len(r))
+		for _, x := range r {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	if s, ok := claims["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+	return nil
+}
+
+// key picks the verification key for a token. HMAC tokens only ever get the
+// shared secret and asymmetric tokens only public keys, so a public key can't
+// be abused as an HMAC secret.
+func (v *JWTVerifier) key(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if len(v.keys) == 1 {
+			for _, k := range v.keys {
+				return k, nil
+			}
+		}
+		return nil, errors.New("token has no kid")
+	}
+	k, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return k, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS returns the public signing keys of a JWK set by kid. RSA, EC
+// (P-256/384/521) and Ed25519 keys are supported; encryption keys are skipped.
+func ParseJWKS(raw []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for i, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%s): %w", i, k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64int(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64int(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("bad exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64int(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64int(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func b64int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("bad base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -1,28 +1,86 @@
 package config
 
 import (
+	"fmt"
+	"log/slog"
 	"time"
-
-	"github.com/ilyakaznacheev/cleanenv"
 )
 
+// Config is read from defaults, then an optional YAML or TOML file, then
+// environment variables. Keys of the file are the lowercased variable names.
+// Fields tagged reload are applied without a restart; fields tagged secret
+// are hidden by Redacted. Config holds plain values only: the settings of
+// each component are built from them by the binary that runs it.
 type Config struct {
-	HTTPAddr       string        `env:"HTTP_ADDR" env-default:":8081"`
-	GRPCAddr       string        `env:"GRPC_ADDR" env-default:":9090"`
-	KafkaBrokers   []string      `env:"KAFKA_BROKERS" env-separator:"," env-required:"true"`
-	KafkaTopic     string        `env:"KAFKA_TOPIC" env-default:"orders_topic"`
-	DatabaseURL    string        `env:"DATABASE_URL" env-required:"true"`
-	RedisAddr      string        `env:"REDIS_ADDR" env-default:"localhost:6379"`
-	RedisPassword  string        `env:"REDIS_PASSWORD" env-default:""`
-	CacheTTL       time.Duration `env:"CACHE_TTL" env-default:"5m"`
-	JaegerEndpoint string        `env:"JAEGER_ENDPOINT" env-default:"http://localhost:14268/api/traces"`
-	ServiceName    string        `env:"SERVICE_NAME" env-default:"orders-service"`
+	HTTPAddr       string        `yaml:"http_addr" env:"HTTP_ADDR" env-default:":8081"`
+	GRPCAddr       string        `yaml:"grpc_addr" env:"GRPC_ADDR" env-default:":9090"`
+	KafkaBrokers   []string      `yaml:"kafka_brokers" env:"KAFKA_BROKERS" env-separator:"," env-required:"true"`
+	KafkaTopic     string        `yaml:"kafka_topic" env:"KAFKA_TOPIC" env-default:"orders_topic"`
+	KafkaGroupID   string        `yaml:"kafka_group_id" env:"KAFKA_GROUP_ID" env-default:"orders_consumer"`
+	ConsumersFile  string        `yaml:"kafka_consumers_file" env:"KAFKA_CONSUMERS_FILE" env-default:""`
+	DatabaseURL    string        `yaml:"database_url" env:"DATABASE_URL" env-required:"true" secret:"true"`
+	MigrateOnStart bool          `yaml:"migrate_on_start" env:"MIGRATE_ON_START" env-default:"false"`
+	RedisAddr      string        `yaml:"redis_addr" env:"REDIS_ADDR" env-default:"localhost:6379"`
+	RedisPassword  string        `yaml:"redis_password" env:"REDIS_PASSWORD" env-default:"" secret:"true"`
+	CacheTTL       time.Duration `yaml:"cache_ttl" env:"CACHE_TTL" env-default:"5m" reload:"true"`
+	OrderMaxAge    time.Duration `yaml:"order_cache_max_age" env:"ORDER_CACHE_MAX_AGE" env-default:"60s"`
+	ReconcileEvery time.Duration `yaml:"reconcile_interval" env:"RECONCILE_INTERVAL" env-default:"10m"`
+	ReconcileBatch int           `yaml:"reconcile_batch" env:"RECONCILE_BATCH" env-default:"1000"`
+	ReconcileMode  string        `yaml:"reconcile_mode" env:"RECONCILE_MODE" env-default:"repair"`
+	EncryptionKeys string        `yaml:"encryption_keyfile" env:"ENCRYPTION_KEYFILE" env-default:""`
+	ReencryptEvery time.Duration `yaml:"reencrypt_interval" env:"REENCRYPT_INTERVAL" env-default:"1m"`
+	ReencryptBatch int           `yaml:"reencrypt_batch" env:"REENCRYPT_BATCH" env-default:"500"`
+	AuthEnabled    bool          `yaml:"auth_enabled" env:"AUTH_ENABLED" env-default:"false"`
+	AuthJWKSFile   string        `yaml:"auth_jwks_file" env:"AUTH_JWKS_FILE" env-default:""`
+	AuthJWTKeys    []string      `yaml:"auth_jwt_keys" env:"AUTH_JWT_KEYS" env-separator:"," env-default:""`
+	AuthJWTSecret  string        `yaml:"auth_jwt_hmac_secret" env:"AUTH_JWT_HMAC_SECRET" env-default:"" secret:"true"`
+	AuthIssuer     string        `yaml:"auth_jwt_issuer" env:"AUTH_JWT_ISSUER" env-default:""`
+	AuthAudience   string        `yaml:"auth_jwt_audience" env:"AUTH_JWT_AUDIENCE" env-default:""`
+	AuthLeeway     time.Duration `yaml:"auth_jwt_leeway" env:"AUTH_JWT_LEEWAY" env-default:"30s"`
+	AuthAPIKeys    []string      `yaml:"auth_api_keys" env:"AUTH_API_KEYS" env-separator:"," env-default:"" secret:"true"`
+	PolicyFile     string        `yaml:"authz_policy_file" env:"AUTHZ_POLICY_FILE" env-default:""`
+	AdminSubjects  []string      `yaml:"authz_admin_subjects" env:"AUTHZ_ADMIN_SUBJECTS" env-separator:"," env-default:""`
+	RateLimitOn    bool          `yaml:"rate_limit_enabled" env:"RATE_LIMIT_ENABLED" env-default:"true"`
+	RateLimit      string        `yaml:"rate_limit" env:"RATE_LIMIT" env-default:"50:100" reload:"true"`
+	RateLimitRoute []string      `yaml:"rate_limit_routes" env:"RATE_LIMIT_ROUTES" env-separator:"," env-default:"" reload:"true"`
+	AuditEnabled   bool          `yaml:"audit_enabled" env:"AUDIT_ENABLED" env-default:"true"`
+	AuditBuffer    int           `yaml:"audit_buffer" env:"AUDIT_BUFFER" env-default:"10000"`
+	AuditBatch     int           `yaml:"audit_batch" env:"AUDIT_BATCH" env-default:"200"`
+	AuditFlush     time.Duration `yaml:"audit_flush_interval" env:"AUDIT_FLUSH_INTERVAL" env-default:"1s"`
+	AuditTopic     string        `yaml:"audit_kafka_topic" env:"AUDIT_KAFKA_TOPIC" env-default:""`
+	StreamReplay   int           `yaml:"stream_replay" env:"STREAM_REPLAY" env-default:"1000"`
+	StreamBuffer   int           `yaml:"stream_subscriber_buffer" env:"STREAM_SUBSCRIBER_BUFFER" env-default:"256"`
+	JaegerEndpoint string        `yaml:"jaeger_endpoint" env:"JAEGER_ENDPOINT" env-default:"http://localhost:14268/api/traces"`
+	ServiceName    string        `yaml:"service_name" env:"SERVICE_NAME" env-default:"orders-service"`
+	LogLevel       string        `yaml:"log_level" env:"LOG_LEVEL" env-default:"info" reload:"true"`
+	ReplicaName    string        `yaml:"replica_name" env:"REPLICA_NAME" env-default:""`
+	FlagsRedisKey  string        `yaml:"feature_flags_redis_key" env:"FEATURE_FLAGS_REDIS_KEY" env-default:""`
+	FlagsRedisPoll time.Duration `yaml:"feature_flags_redis_interval" env:"FEATURE_FLAGS_REDIS_INTERVAL" env-default:"10s"`
+	// KafkaConsumers are the consumer definitions of a config file, left
+	// undecoded: their keys are those of consumer.Definition.
+	KafkaConsumers []map[string]any `yaml:"kafka_consumers"`
+	// FeatureFlags are the feature flags of a config file by name, left
+	// undecoded: their keys are those of features.Flag.
+	FeatureFlags map[string]map[string]any `yaml:"feature_flags" reload:"true"`
 }
 
-func Load() (Config, error) {
-	var cfg Config
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		return cfg, err
+// Level parses LogLevel: debug, info, warn or error.
+func (c Config) Level() (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return l, fmt.Errorf("log_level: %w", err)
+	}
+	return l, nil
+}
+
+// Validate checks the settings config itself parses. The component
+// settings, and the files they name, are checked where they are built.
+func (c Config) Validate() error {
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+	if c.FlagsRedisKey != "" && c.FlagsRedisPoll <= 0 {
+		return fmt.Errorf("feature_flags_redis_interval: must be positive")
 	}
-	return cfg, nil
+	return nil
 }
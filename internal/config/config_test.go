@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFileLayers(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "k1:9092,k2:9092")
+	t.Setenv("CACHE_TTL", "3m")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `
+database_url: postgres://orders:secret@db:5432/orders
+cache_ttl: 10m
+rate_limit_enabled: false
+reconcile_batch: 0
+kafka_brokers: [file:9092]
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTPAddr != ":8081" || cfg.ReconcileEvery != 10*time.Minute {
+		t.Errorf("defaults not applied: %q %s", cfg.HTTPAddr, cfg.ReconcileEvery)
+	}
+	// false and 0 from the file must not fall back to the defaults
+	if cfg.RateLimitOn || cfg.ReconcileBatch != 0 {
+		t.Errorf("file values lost: rate_limit_enabled=%t reconcile_batch=%d", cfg.RateLimitOn, cfg.ReconcileBatch)
+	}
+	if cfg.CacheTTL != 3*time.Minute || !slices.Equal(cfg.KafkaBrokers, []string{"k1:9092", "k2:9092"}) {
+		t.Errorf("env does not override the file: %s %v", cfg.CacheTTL, cfg.KafkaBrokers)
+	}
+	if cfg.DatabaseURL != "postgres://orders:secret@db:5432/orders" {
+		t.Errorf("database_url %q", cfg.DatabaseURL)
+	}
+}
+
+func TestLoadFileTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, `
+kafka_brokers = ["k1:9092"]
+database_url = "postgres://db/orders"
+audit_batch = 50
+audit_flush_interval = "2s"
+
+[[kafka_consumers]]
+name = "orders"
+topics = ["orders_topic"]
+group_id = "orders_consumer"
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AuditBatch != 50 || cfg.AuditFlush != 2*time.Second {
+		t.Errorf("audit %d %s", cfg.AuditBatch, cfg.AuditFlush)
+	}
+	if defs := cfg.KafkaConsumers; len(defs) != 1 || defs[0]["group_id"] != "orders_consumer" {
+		t.Errorf("consumers %+v", defs)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct{ name, content, err string }{
+		{"unknown.yaml", "kafka_brokers: [k]\ndatabase_url: x\ncache_tll: 1m\n", "cache_tll not found"},
+		{"unknown.toml", "kafka_brokers = [\"k\"]\ndatabase_url = \"x\"\nverbose = true\n", "verbose not found"},
+		{"required.yaml", "kafka_brokers: [k]\n", "DATABASE_URL is required"},
+		{"type.yaml", "kafka_brokers: [k]\ndatabase_url: x\ncache_ttl: soon\n", "`soon` into time.Duration"},
+	} {
+		path := filepath.Join(dir, tc.name)
+		writeFile(t, path, tc.content)
+		if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: %v, want %q", tc.name, err, tc.err)
+		}
+	}
+	t.Setenv("KAFKA_BROKERS", "k")
+	t.Setenv("DATABASE_URL", "x")
+	t.Setenv("AUDIT_BATCH", "many")
+	if _, err := LoadFile(""); err == nil || !strings.Contains(err.Error(), "AUDIT_BATCH") {
+		t.Errorf("bad env value: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "k")
+	t.Setenv("DATABASE_URL", "x")
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	for name, bad := range map[string]func(*Config){
+		"log_level":                    func(c *Config) { c.LogLevel = "loud" },
+		"feature_flags_redis_interval": func(c *Config) { c.FlagsRedisKey, c.FlagsRedisPoll = "flags", 0 },
+	} {
+		c := cfg
+		bad(&c)
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Config{
+		DatabaseURL:   "postgres://orders:secret@db:5432/orders",
+		RedisPassword: "redis-secret",
+		AuthAPIKeys:   []string{"k1", "k2"},
+		RedisAddr:     "redis:6379",
+	}
+	r := cfg.Redacted()
+	if r.DatabaseURL != "postgres://orders:xxxxx@db:5432/orders" || r.RedisPassword != "REDACTED" ||
+		!slices.Equal(r.AuthAPIKeys, []string{"REDACTED", "REDACTED"}) || r.AuthJWTSecret != "" || r.RedisAddr != "redis:6379" {
+		t.Fatalf("redacted %+v", r)
+	}
+	if cfg.AuthAPIKeys[0] != "k1" {
+		t.Fatal("Redacted changed the original")
+	}
+	if r := (Config{DatabaseURL: "host=db password=secret"}).Redacted(); r.DatabaseURL != "REDACTED" {
+		t.Fatalf("key=value dsn %q", r.DatabaseURL)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cur := Config{CacheTTL: time.Minute, LogLevel: "info", HTTPAddr: ":8081"}
+	next := Config{CacheTTL: 2 * time.Minute, LogLevel: "info", HTTPAddr: ":8082",
+		FeatureFlags: map[string]map[string]any{"lazy_cache": {"enabled": true}}}
+	merged, applied, ignored := cur.Merge(next)
+	if merged.CacheTTL != 2*time.Minute || merged.HTTPAddr != ":8081" || merged.FeatureFlags["lazy_cache"]["enabled"] != true {
+		t.Errorf("merged %s %q %v", merged.CacheTTL, merged.HTTPAddr, merged.FeatureFlags)
+	}
+	if !slices.Equal(applied, []string{"cache_ttl", "feature_flags"}) || !slices.Equal(ignored, []string{"http_addr"}) {
+		t.Errorf("applied %v, ignored %v", applied, ignored)
+	}
+}
+
+func TestWatchAppliesFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	base := "kafka_brokers: [k]\ndatabase_url: x\n"
+	writeFile(t, path, base+"log_level: info\n")
+	cur, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := make(chan Config, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- Watch(ctx, path, cur, nil, func(c Config) { applied <- c }, discard) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// an invalid file is skipped, the next valid one is applied
+	writeFile(t, path, base+"log_level: loud\n")
+	time.Sleep(pollInterval + 500*time.Millisecond)
+	writeFile(t, path, base+"log_level: debug\nhttp_addr: :9999\n")
+	select {
+	case c := <-applied:
+		if c.LogLevel != "debug" || c.HTTPAddr != ":8081" {
+			t.Fatalf("applied log_level %q http_addr %q", c.LogLevel, c.HTTPAddr)
+		}
+	case <-time.After(2*pollInterval + time.Second):
+		t.Fatal("change not applied")
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file read by Load.
+const FileEnv = "CONFIG_FILE"
+
+// Load reads the configuration with the file named by CONFIG_FILE, if any.
+func Load() (Config, error) {
+	return LoadFile(os.Getenv(FileEnv))
+}
+
+// LoadFile reads the configuration: defaults, then path (YAML, or TOML by
+// the .toml extension; "" for none), then environment variables. Unknown
+// keys of the file are errors.
+func LoadFile(path string) (Config, error) {
+	var cfg Config
+	fields := envFields(&cfg)
+	for _, f := range fields {
+		if def, ok := f.field.Tag.Lookup("env-default"); ok {
+			if err := f.set(def); err != nil {
+				return cfg, fmt.Errorf("default of %s: %w", f.env, err)
+			}
+		}
+	}
+	if path != "" {
+		if err := decodeFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	for _, f := range fields {
+		if raw, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(raw); err != nil {
+				return cfg, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+		if f.field.Tag.Get("env-required") == "true" && f.value.IsZero() {
+			return cfg, fmt.Errorf("%s is required", f.env)
+		}
+	}
+	return cfg, nil
+}
+
+func decodeFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		// TOML goes through YAML, so both formats share the field tags and
+		// the unknown key check
+		var doc map[string]any
+		if err := toml.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("parse config %s: %w", path, err)
+		}
+		if raw, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// envField is a Config field read from an environment variable.
+type envField struct {
+	field reflect.StructField
+	value reflect.Value
+	env   string
+}
+
+func envFields(cfg *Config) []envField {
+	v := reflect.ValueOf(cfg).Elem()
+	var fields []envField
+	for i := range v.NumField() {
+		sf := v.Type().Field(i)
+		if env := sf.Tag.Get("env"); env != "" {
+			fields = append(fields, envField{field: sf, value: v.Field(i), env: env})
+		}
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into the field.
+func (f envField) set(raw string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		if raw != "" {
+			items = strings.Split(raw, f.field.Tag.Get("env-separator"))
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns c with the fields tagged secret hidden; the database URL
+// keeps everything but its password.
+func (c Config) Redacted() Config {
+	dsn := c.DatabaseURL
+	v := reflect.ValueOf(&c).Elem()
+	for i := range v.NumField() {
+		if v.Type().Field(i).Tag.Get("secret") != "true" {
+			continue
+		}
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			if f.String() != "" {
+				f.SetString("REDACTED")
+			}
+		case reflect.Slice:
+			hidden := make([]string, f.Len())
+			for j := range hidden {
+				hidden[j] = "REDACTED"
+			}
+			f.Set(reflect.ValueOf(hidden))
+		}
+	}
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		c.DatabaseURL = u.Redacted()
+	}
+	return c
+}
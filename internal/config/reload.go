@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often Watch checks the config file for changes.
+const pollInterval = 2 * time.Second
+
+// Merge returns c with the reload fields taken from next. applied names the
+// keys that changed, ignored those that changed but need a restart.
+func (c Config) Merge(next Config) (merged Config, applied, ignored []string) {
+	merged = c
+	cur, nv, mv := reflect.ValueOf(c), reflect.ValueOf(next), reflect.ValueOf(&merged).Elem()
+	for i := range cur.NumField() {
+		sf := cur.Type().Field(i)
+		if reflect.DeepEqual(cur.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		key := sf.Tag.Get("yaml")
+		if sf.Tag.Get("reload") != "true" {
+			ignored = append(ignored, key)
+			continue
+		}
+		mv.Field(i).Set(nv.Field(i))
+		applied = append(applied, key)
+	}
+	return merged, applied, ignored
+}
+
+// Watch reloads path on SIGHUP or when its content changes, until ctx is
+// cancelled. A config that fails to load, Validate or check is logged and
+// skipped; otherwise the reload fields are merged into current and the
+// result passed to apply. check may be nil.
+func Watch(ctx context.Context, path string, current Config, check func(Config) error, apply func(Config), logger *slog.Logger) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+
+	sum, _ := fileSum(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			logger.Info("reloading config", "path", path, "reason", "SIGHUP")
+		case <-t.C:
+			s, err := fileSum(path)
+			if err != nil || s == sum {
+				// a file being replaced may be missing for a moment
+				continue
+			}
+			sum = s
+			logger.Info("reloading config", "path", path, "reason", "file changed")
+		}
+		current = reload(path, current, check, apply, logger)
+	}
+}
+
+func reload(path string, current Config, check func(Config) error, apply func(Config), logger *slog.Logger) Config {
+	next, err := LoadFile(path)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err == nil && check != nil {
+		err = check(next)
+	}
+	if err != nil {
+		logger.Error("config not reloaded", "path", path, "err", err)
+		return current
+	}
+	merged, applied, ignored := current.Merge(next)
+	if len(ignored) > 0 {
+		logger.Warn("config changes need a restart", "keys", ignored)
+	}
+	if len(applied) == 0 {
+		logger.Info("config reloaded, nothing to apply")
+		return current
+	}
+	apply(merged)
+	logger.Info("config reloaded", "applied", applied)
+	return merged
+}
+
+func fileSum(path string) ([sha256.Size]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(raw), nil
+}
@@ -0,0 +1,76 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"orderservice/internal/orderconv"
+	"orderservice/pkg/api/orderpb"
+	"orderservice/pkg/models"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Decoder turns a message value into an order.
+type Decoder func(value []byte) (models.Order, error)
+
+// decoders are the message formats a Definition can name.
+var decoders = map[string]Decoder{
+	// json is the format of orders-producer; unknown fields are ignored.
+	"json": func(value []byte) (models.Order, error) {
+		var o models.Order
+		err := json.Unmarshal(value, &o)
+		return o, err
+	},
+	// json-strict rejects unknown fields and trailing data.
+	"json-strict": func(value []byte) (models.Order, error) {
+		var o models.Order
+		dec := json.NewDecoder(bytes.NewReader(value))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			return o, err
+		}
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return o, errors.New("data after the order")
+		}
+		return o, nil
+	},
+	// protobuf is the binary encoding of orderpb.Order.
+	"protobuf": func(value []byte) (models.Order, error) {
+		var pb orderpb.Order
+		if err := proto.Unmarshal(value, &pb); err != nil {
+			return models.Order{}, err
+		}
+		return orderconv.FromProto(&pb), nil
+	},
+	// protojson is orderpb.Order in the JSON mapping of the gRPC gateway.
+	"protojson": func(value []byte) (models.Order, error) {
+		var pb orderpb.Order
+		if err := protojson.Unmarshal(value, &pb); err != nil {
+			return models.Order{}, err
+		}
+		return orderconv.FromProto(&pb), nil
+	},
+}
+
+func decoderByName(name string) (Decoder, error) {
+	if name == "" {
+		name = "json"
+	}
+	d, ok := decoders[name]
+	if !ok {
+		names := make([]string, 0, len(decoders))
+		for n := range decoders {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown decoder %q (want %s)", name, strings.Join(names, ", "))
+	}
+	return d, nil
+}
@@ -0,0 +1,174 @@
+package consumer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"orderservice/internal/importer"
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition describes one consumer: what it reads, how messages are decoded
+// and checked, and what happens to those that cannot be saved.
+//
+//	consumers:
+//	  - name: orders
+//	    topics: [orders_topic]
+//	    group_id: orders_consumer
+//	  - name: marketplace
+//	    topic_regex: ^marketplace\..+\.orders$
+//	    group_id: marketplace_orders
+//	    decoder: protobuf
+//	    validation: strict
+//	    concurrency: 4
+//	    dlq: marketplace_orders_dlq
+//	    retry: {attempts: 5, backoff: 500ms, max_backoff: 30s}
+type Definition struct {
+	// Name labels the logs and metrics of the consumer.
+	Name string `yaml:"name"`
+	// Topics or TopicRegex, not both. Topics matching TopicRegex are looked
+	// up every minute; a change restarts the consumer.
+	Topics     []string `yaml:"topics"`
+	TopicRegex string   `yaml:"topic_regex"`
+	GroupID    string   `yaml:"group_id"`
+	// Decoder is json (default), json-strict, protobuf or protojson.
+	Decoder string `yaml:"decoder"`
+	// Validation is basic (default), the checks of the save path, or strict,
+	// which adds the business rules of the archive import.
+	Validation string `yaml:"validation"`
+	// Concurrency is the number of group members, at most one per partition
+	// being useful. Defaults to 1.
+	Concurrency int `yaml:"concurrency"`
+	// DLQ is a topic receiving the messages that failed for good, with the
+	// reason in headers; they are committed afterwards. Without it they are
+	// logged and skipped.
+	DLQ   string      `yaml:"dlq"`
+	Retry RetryPolicy `yaml:"retry"`
+}
+
+// RetryPolicy governs retries of failed saves. Undecodable and invalid orders
+// are never retried.
+type RetryPolicy struct {
+	// Attempts counts the first try. Defaults to 3.
+	Attempts int `yaml:"attempts"`
+	// Backoff is the first pause, doubled up to MaxBackoff. Default 200ms and 5s.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// validations are the validation levels a Definition can name.
+var validations = map[string]func(models.Order) error{
+	"basic": service.ValidateOrder,
+	"strict": func(o models.Order) error {
+		return importer.Validate(o, time.Now())
+	},
+}
+
+// LoadDefinitions reads consumer definitions from a YAML file.
+func LoadDefinitions(path string) ([]Definition, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read consumers: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var file struct {
+		Consumers []Definition `yaml:"consumers"`
+	}
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse consumers %s: %w", path, err)
+	}
+	if err := CheckDefinitions(file.Consumers); err != nil {
+		return nil, fmt.Errorf("consumers %s: %w", path, err)
+	}
+	return file.Consumers, nil
+}
+
+// CheckDefinitions fills in defaults and rejects definitions that cannot run
+// side by side.
+func CheckDefinitions(defs []Definition) error {
+	if len(defs) == 0 {
+		return errors.New("no consumers defined")
+	}
+	var names, groups []string
+	for i := range defs {
+		d := &defs[i]
+		if d.Name == "" {
+			return fmt.Errorf("consumer %d: name is required", i+1)
+		}
+		if slices.Contains(names, d.Name) {
+			return fmt.Errorf("consumer %s: duplicate name", d.Name)
+		}
+		names = append(names, d.Name)
+		if err := d.check(); err != nil {
+			return fmt.Errorf("consumer %s: %w", d.Name, err)
+		}
+		// members of one group have to subscribe to the same topics
+		if slices.Contains(groups, d.GroupID) {
+			return fmt.Errorf("consumer %s: group %s is used by another consumer", d.Name, d.GroupID)
+		}
+		groups = append(groups, d.GroupID)
+	}
+	return nil
+}
+
+func (d *Definition) check() error {
+	if (len(d.Topics) == 0) == (d.TopicRegex == "") {
+		return errors.New("want either topics or topic_regex")
+	}
+	if d.TopicRegex != "" {
+		re, err := regexp.Compile(d.TopicRegex)
+		if err != nil {
+			return fmt.Errorf("topic_regex: %w", err)
+		}
+		if d.DLQ != "" && re.MatchString(d.DLQ) {
+			return fmt.Errorf("dlq %s matches topic_regex", d.DLQ)
+		}
+	}
+	if slices.Contains(d.Topics, d.DLQ) {
+		return fmt.Errorf("dlq %s is also consumed", d.DLQ)
+	}
+	if d.GroupID == "" {
+		return errors.New("group_id is required")
+	}
+	if d.Decoder == "" {
+		d.Decoder = "json"
+	}
+	if _, err := decoderByName(d.Decoder); err != nil {
+		return err
+	}
+	if d.Validation == "" {
+		d.Validation = "basic"
+	}
+	if validations[d.Validation] == nil {
+		return fmt.Errorf("unknown validation %q (want basic or strict)", d.Validation)
+	}
+	if d.Concurrency == 0 {
+		d.Concurrency = 1
+	}
+	if d.Concurrency < 0 {
+		return errors.New("concurrency must be positive")
+	}
+	r := &d.Retry
+	if r.Attempts == 0 {
+		r.Attempts = 3
+	}
+	if r.Backoff == 0 {
+		r.Backoff = 200 * time.Millisecond
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = max(5*time.Second, r.Backoff)
+	}
+	if r.Attempts < 0 || r.Backoff < 0 || r.MaxBackoff < r.Backoff {
+		return errors.New("retry: want attempts >= 1 and 0 < backoff <= max_backoff")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package consumer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/internal/orderconv"
+	"orderservice/pkg/models/fake"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoadDefinitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "consumers.yaml")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+consumers:
+  - name: orders
+    topics: [orders_topic]
+    group_id: orders_consumer
+  - name: marketplace
+    topic_regex: ^marketplace\..+\.orders$
+    group_id: marketplace_orders
+    decoder: protobuf
+    validation: strict
+    concurrency: 4
+    dlq: marketplace_orders_dlq
+    retry: {attempts: 5, backoff: 500ms, max_backoff: 30s}
+`)
+	defs, err := LoadDefinitions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Definition{
+		{
+			Name: "orders", Topics: []string{"orders_topic"}, GroupID: "orders_consumer",
+			Decoder: "json", Validation: "basic", Concurrency: 1,
+			Retry: RetryPolicy{Attempts: 3, Backoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second},
+		},
+		{
+			Name: "marketplace", TopicRegex: `^marketplace\..+\.orders$`, GroupID: "marketplace_orders",
+			Decoder: "protobuf", Validation: "strict", Concurrency: 4, DLQ: "marketplace_orders_dlq",
+			Retry: RetryPolicy{Attempts: 5, Backoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second},
+		},
+	}
+	if len(defs) != len(want) {
+		t.Fatalf("got %d definitions", len(defs))
+	}
+	for i := range want {
+		if !slices.Equal(defs[i].Topics, want[i].Topics) {
+			t.Errorf("%s: topics %v", want[i].Name, defs[i].Topics)
+		}
+		defs[i].Topics, want[i].Topics = nil, nil
+		if defs[i].Name != want[i].Name || defs[i].TopicRegex != want[i].TopicRegex || defs[i].GroupID != want[i].GroupID ||
+			defs[i].Decoder != want[i].Decoder || defs[i].Validation != want[i].Validation ||
+			defs[i].Concurrency != want[i].Concurrency || defs[i].DLQ != want[i].DLQ || defs[i].Retry != want[i].Retry {
+			t.Errorf("got %+v\nwant %+v", defs[i], want[i])
+		}
+	}
+
+	for _, tc := range []struct{ yaml, err string }{
+		{"consumers: []", "no consumers"},
+		{"consumers: [{name: a, topics: [t], group_id: g, unknown: 1}]", "not found"},
+		{"consumers: [{name: a, group_id: g}]", "topics or topic_regex"},
+		{"consumers: [{name: a, topics: [t], topic_regex: t, group_id: g}]", "topics or topic_regex"},
+		{"consumers: [{name: a, topics: [t]}]", "group_id"},
+		{"consumers: [{name: a, topics: [t], group_id: g, decoder: avro}]", "unknown decoder"},
+		{"consumers: [{name: a, topics: [t], group_id: g, validation: lax}]", "unknown validation"},
+		{"consumers: [{name: a, topic_regex: '^o.*', group_id: g, dlq: orders_dlq}]", "matches topic_regex"},
+		{"consumers: [{name: a, topics: [t], group_id: g}, {name: a, topics: [u], group_id: h}]", "duplicate name"},
+		{"consumers: [{name: a, topics: [t], group_id: g}, {name: b, topics: [u], group_id: g}]", "used by another"},
+		{"consumers: [{name: a, topics: [t], group_id: g, retry: {backoff: 1s, max_backoff: 1ms}}]", "retry"},
+	} {
+		write(tc.yaml)
+		if _, err := LoadDefinitions(path); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: %v, want %q", tc.yaml, err, tc.err)
+		}
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	o := fake.New(fake.WithSeed(4)).Order()
+	pb := orderconv.ToProto(o)
+	bin, err := proto.Marshal(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pj, err := protojson.Marshal(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := mustJSON(t, o)
+	for name, value := range map[string][]byte{"json": js, "json-strict": js, "protobuf": bin, "protojson": pj} {
+		got, err := decoders[name](value)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got.OrderUID != o.OrderUID || len(got.Items) != len(o.Items) || !got.DateCreated.Equal(o.DateCreated) {
+			t.Errorf("%s: decoded %+v", name, got)
+		}
+	}
+
+	extra := append(js[:len(js)-1:len(js)-1], []byte(`,"source":"legacy"}`)...)
+	if _, err := decoders["json"](extra); err != nil {
+		t.Errorf("json rejected an unknown field: %v", err)
+	}
+	if _, err := decoders["json-strict"](extra); err == nil {
+		t.Error("json-strict accepted an unknown field")
+	}
+	if _, err := decoders["json-strict"](append(js, js...)); err == nil {
+		t.Error("json-strict accepted trailing data")
+	}
+}
+
+func TestGroupTopics(t *testing.T) {
+	g := &Group{listTopics: func(context.Context) ([]string, error) {
+		return []string{"marketplace.eu.orders", "__consumer_offsets", "marketplace.us.orders", "orders_topic"}, nil
+	}}
+	def := Definition{TopicRegex: `^marketplace\..+\.orders$`}
+	topics, err := g.topics(context.Background(), def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(topics, []string{"marketplace.eu.orders", "marketplace.us.orders"}) {
+		t.Fatalf("topics %v", topics)
+	}
+	if _, err := g.topics(context.Background(), Definition{TopicRegex: "^none$"}); err == nil {
+		t.Fatal("no error without a matching topic")
+	}
+}
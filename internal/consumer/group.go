@@ -0,0 +1,228 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"orderservice/internal/features"
+	"orderservice/pkg/models"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// restartBackoff is the first pause before restarting a failed consumer,
+	// doubled up to maxRestartBackoff. A consumer that ran for longer than
+	// maxRestartBackoff starts over from restartBackoff.
+	restartBackoff    = time.Second
+	maxRestartBackoff = time.Minute
+	// topicRefresh is how often topic_regex is matched again.
+	topicRefresh = time.Minute
+)
+
+// errTopicsChanged stops a consumer whose topic_regex matches other topics.
+var errTopicsChanged = errors.New("matched topics changed")
+
+// Group runs consumer definitions, each under its own supervisor: a failing
+// consumer is restarted with backoff and does not stop the others.
+type Group struct {
+	brokers []string
+	defs    []Definition
+	save    func(context.Context, models.Order) error
+	logger  *slog.Logger
+	tracer  trace.Tracer
+	flags   *features.Flags
+
+	listTopics func(ctx context.Context) ([]string, error)
+}
+
+// GroupOption configures optional Group behaviour.
+type GroupOption func(*Group)
+
+// WithFlags evaluates the consumer feature flags, FlagStrictValidation, in
+// flags; without it they are off.
+func WithFlags(flags *features.Flags) GroupOption {
+	return func(g *Group) { g.flags = flags }
+}
+
+// NewGroup checks defs and prepares their consumers. save is the save path
+// shared by all of them.
+func NewGroup(brokers []string, defs []Definition, save func(context.Context, models.Order) error, logger *slog.Logger, tracer trace.Tracer, opts ...GroupOption) (*Group, error) {
+	defs = slices.Clone(defs)
+	if err := CheckDefinitions(defs); err != nil {
+		return nil, err
+	}
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	g := &Group{
+		brokers: brokers,
+		defs:    defs,
+		save:    save,
+		logger:  logger,
+		tracer:  tracer,
+		listTopics: func(ctx context.Context) ([]string, error) {
+			meta, err := client.Metadata(ctx, &kafka.MetadataRequest{})
+			if err != nil {
+				return nil, fmt.Errorf("metadata: %w", err)
+			}
+			topics := make([]string, 0, len(meta.Topics))
+			for _, t := range meta.Topics {
+				if !t.Internal {
+					topics = append(topics, t.Name)
+				}
+			}
+			return topics, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
+}
+
+// Run runs every consumer until ctx is cancelled.
+func (g *Group) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, def := range g.defs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.supervise(ctx, def)
+		}()
+	}
+	wg.Wait()
+}
+
+func (g *Group) supervise(ctx context.Context, def Definition) {
+	logger := g.logger.With("consumer", def.Name, "group", def.GroupID)
+	pause := restartBackoff
+	for {
+		started := time.Now()
+		runningGauge.WithLabelValues(def.Name).Set(1)
+		err := g.runDefinition(ctx, def, logger)
+		runningGauge.WithLabelValues(def.Name).Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errTopicsChanged) {
+			restartsTotal.WithLabelValues(def.Name, "topics").Inc()
+			logger.Info("restarting consumer", "reason", err)
+			continue
+		}
+		restartsTotal.WithLabelValues(def.Name, "error").Inc()
+		if time.Since(started) > maxRestartBackoff {
+			pause = restartBackoff
+		}
+		logger.Error("consumer failed, restarting", "err", err, "in", pause)
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return
+		}
+		pause = min(2*pause, maxRestartBackoff)
+	}
+}
+
+// runDefinition runs the workers of def until ctx is cancelled or one of
+// them fails, which stops the others.
+func (g *Group) runDefinition(ctx context.Context, def Definition, logger *slog.Logger) error {
+	topics, err := g.topics(ctx, def)
+	if err != nil {
+		return err
+	}
+	decode, err := decoderByName(def.Decoder)
+	if err != nil {
+		return err
+	}
+	logger.Info("consumer started", "topics", topics, "concurrency", def.Concurrency)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var dlq messageWriter
+	if def.DLQ != "" {
+		dlq = newWriter(g.brokers, def.DLQ)
+		defer dlq.Close()
+	}
+
+	var wg sync.WaitGroup
+	for i := range def.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if p := recover(); p != nil {
+					cancel(fmt.Errorf("worker %d panicked: %v", i, p))
+				}
+			}()
+			r := newReader(g.brokers, topics, def.GroupID)
+			defer r.Close()
+			w := &worker{def: def, r: r, dlq: dlq, decode: decode, save: g.save, flags: g.flags, logger: logger, tracer: g.tracer}
+			if err := w.run(ctx); err != nil {
+				cancel(fmt.Errorf("worker %d: %w", i, err))
+			}
+		}()
+	}
+	if def.TopicRegex != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.watchTopics(ctx, def, topics, cancel, logger)
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// topics resolves the topics def reads.
+func (g *Group) topics(ctx context.Context, def Definition) ([]string, error) {
+	if def.TopicRegex == "" {
+		return def.Topics, nil
+	}
+	all, err := g.listTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	re := regexp.MustCompile(def.TopicRegex) // checked by CheckDefinitions
+	var topics []string
+	for _, t := range all {
+		if re.MatchString(t) && !strings.HasPrefix(t, "__") {
+			topics = append(topics, t)
+		}
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topic matches %s", def.TopicRegex)
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// watchTopics cancels the run when topic_regex starts matching other topics.
+func (g *Group) watchTopics(ctx context.Context, def Definition, current []string, cancel context.CancelCauseFunc, logger *slog.Logger) {
+	t := time.NewTicker(topicRefresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		topics, err := g.topics(ctx, def)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("refresh topics", "err", err)
+			}
+			continue
+		}
+		if !slices.Equal(topics, current) {
+			cancel(fmt.Errorf("%w: %v", errTopicsChanged, topics))
+			return
+		}
+	}
+}
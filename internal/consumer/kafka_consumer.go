@@ -2,12 +2,17 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"orderservice/internal/features"
 	"orderservice/internal/observability"
+	"orderservice/internal/service"
 	"orderservice/pkg/models"
 
 	"github.com/segmentio/kafka-go"
@@ -21,61 +26,167 @@ type Reader interface {
 	Close() error
 }
 
-type readerFactory func(brokers []string, topic, groupID string) Reader
+type readerFactory func(brokers, topics []string, groupID string) Reader
 
-var newReader readerFactory = func(brokers []string, topic, groupID string) Reader {
-	return kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, GroupID: groupID})
+var newReader readerFactory = func(brokers, topics []string, groupID string) Reader {
+	return kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupTopics: topics, GroupID: groupID})
 }
 
+// messageWriter publishes to the dead letter topic.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+var newWriter = func(brokers []string, topic string) messageWriter {
+	return &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: topic, RequiredAcks: kafka.RequireAll}
+}
+
+// StartKafkaConsumer consumes JSON orders of one topic as groupID until ctx
+// is cancelled.
 func StartKafkaConsumer(ctx context.Context, brokers []string, topic, groupID string, save func(ctx context.Context, o models.Order) error, logger *slog.Logger, tracer trace.Tracer) error {
-	r := newReader(brokers, topic, groupID)
-	defer r.Close()
-	return consume(ctx, r, save, logger, tracer)
+	g, err := NewGroup(brokers, []Definition{{Name: topic, Topics: []string{topic}, GroupID: groupID}}, save, logger, tracer)
+	if err != nil {
+		return err
+	}
+	g.Run(ctx)
+	return nil
+}
+
+// FlagStrictValidation, when on for an order, checks it with the strict
+// validation whatever the validation of its consumer.
+const FlagStrictValidation = "strict_validation"
+
+// worker is one group member of a consumer.
+type worker struct {
+	def    Definition
+	r      Reader
+	dlq    messageWriter // nil: failed messages are skipped
+	decode Decoder
+	save   func(context.Context, models.Order) error
+	flags  *features.Flags
+	logger *slog.Logger
+	tracer trace.Tracer
 }
 
-func consume(ctx context.Context, r Reader, save func(context.Context, models.Order) error, logger *slog.Logger, tracer trace.Tracer) error {
+// run handles messages until ctx is cancelled. It returns an error when
+// reading fails or a message can be neither saved nor dead-lettered; the
+// message is then left uncommitted for the next run.
+func (w *worker) run(ctx context.Context) error {
 	for {
-		msg, err := r.ReadMessage(ctx)
+		msg, err := w.r.ReadMessage(ctx)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
+			if ctx.Err() != nil {
 				return nil
 			}
-			logger.Error("read", "err", err)
-			continue
+			return fmt.Errorf("read: %w", err)
 		}
 
-		carrier := kafkaHeaderCarrier{headers: &msg.Headers}
-		msgCtx := otel.GetTextMapPropagator().Extract(ctx, carrier)
-		if reqID := carrier.Get("x-request-id"); reqID != "" {
-			msgCtx = observability.WithRequestID(msgCtx, reqID)
+		start := time.Now()
+		o, msgCtx, err := handleMessage(ctx, msg, w.decode, w.saveWithRetry(msg.Topic), w.tracer)
+		handleSeconds.WithLabelValues(w.def.Name).Observe(time.Since(start).Seconds())
+		l := w.logger.With("topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+		if reqID := observability.RequestIDFromContext(msgCtx); reqID != "" {
+			l = l.With("req_id", reqID)
 		}
-		msgCtx, span := tracer.Start(msgCtx, "consumer.consume")
-		var o models.Order
-		if err := json.Unmarshal(msg.Value, &o); err != nil {
-			logger.Error("json", "err", err)
-			span.RecordError(err)
-			span.End()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil
+		case errors.Is(err, service.ErrDuplicate):
+			messagesTotal.WithLabelValues(w.def.Name, msg.Topic, "duplicate").Inc()
+			l.Info("order already stored", "uid", o.OrderUID)
+		case err != nil && w.dlq == nil:
+			messagesTotal.WithLabelValues(w.def.Name, msg.Topic, "failed").Inc()
+			l.Error("handle", "uid", o.OrderUID, "err", err)
 			continue
+		case err != nil:
+			if derr := w.deadLetter(msgCtx, msg, err); derr != nil {
+				return fmt.Errorf("offset %d of %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, derr)
+			}
+			messagesTotal.WithLabelValues(w.def.Name, msg.Topic, "dead_lettered").Inc()
+			l.Warn("dead-lettered", "uid", o.OrderUID, "dlq", w.def.DLQ, "err", err)
+		default:
+			messagesTotal.WithLabelValues(w.def.Name, msg.Topic, "saved").Inc()
+			l.Info("order saved", "uid", o.OrderUID, "trace_id", trace.SpanContextFromContext(msgCtx).TraceID().String())
 		}
-		if err := save(msgCtx, o); err != nil {
-			logger.Error("save", "err", err)
-			span.RecordError(err)
-			span.End()
-			continue
+		if err := w.r.CommitMessages(msgCtx, msg); err != nil {
+			l.Error("commit", "err", err)
 		}
-		if err := r.CommitMessages(msgCtx, msg); err != nil {
-			logger.Error("commit", "err", err)
-			span.RecordError(err)
+	}
+}
+
+// saveWithRetry validates an order and saves it under the retry policy.
+// Invalid orders are not retried.
+func (w *worker) saveWithRetry(topic string) func(context.Context, models.Order) error {
+	configured := validations[w.def.Validation]
+	return func(ctx context.Context, o models.Order) error {
+		validate := configured
+		if w.flags.Enabled(FlagStrictValidation, features.ForOrder(o)) {
+			validate = validations["strict"]
 		}
-		span.End()
-		l := logger
-		if reqID := observability.RequestIDFromContext(msgCtx); reqID != "" {
-			l = l.With("req_id", reqID)
+		if err := validate(o); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+		p := w.def.Retry
+		pause := p.Backoff
+		for attempt := 1; ; attempt++ {
+			err := w.save(ctx, o)
+			if err == nil {
+				return nil
+			}
+			if errors.Is(err, service.ErrValidation) || errors.Is(err, service.ErrDuplicate) || attempt >= p.Attempts {
+				return err
+			}
+			retriesTotal.WithLabelValues(w.def.Name, topic).Inc()
+			w.logger.Warn("save failed, retrying", "uid", o.OrderUID, "attempt", attempt, "in", pause, "err", err)
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			pause = min(2*pause, p.MaxBackoff)
 		}
-		l.Info("order saved", "uid", o.OrderUID, "trace_id", trace.SpanContextFromContext(msgCtx).TraceID().String())
 	}
 }
 
+// deadLetter copies msg to the DLQ with where it came from and why it failed.
+func (w *worker) deadLetter(ctx context.Context, msg kafka.Message, cause error) error {
+	headers := append(slices.Clip(msg.Headers),
+		kafka.Header{Key: "x-dlq-consumer", Value: []byte(w.def.Name)},
+		kafka.Header{Key: "x-dlq-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "x-dlq-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "x-dlq-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: "x-dlq-error", Value: []byte(cause.Error())},
+	)
+	if err := w.dlq.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers}); err != nil {
+		return fmt.Errorf("dlq %s: %w", w.def.DLQ, err)
+	}
+	return nil
+}
+
+// handleMessage decodes msg within its propagated trace/request context and
+// passes the order to save.
+func handleMessage(ctx context.Context, msg kafka.Message, decode Decoder, save func(context.Context, models.Order) error, tracer trace.Tracer) (models.Order, context.Context, error) {
+	carrier := kafkaHeaderCarrier{headers: &msg.Headers}
+	msgCtx := otel.GetTextMapPropagator().Extract(ctx, carrier)
+	if reqID := carrier.Get("x-request-id"); reqID != "" {
+		msgCtx = observability.WithRequestID(msgCtx, reqID)
+	}
+	msgCtx, span := tracer.Start(msgCtx, "consumer.consume")
+	defer span.End()
+
+	o, err := decode(msg.Value)
+	if err != nil {
+		span.RecordError(err)
+		return o, msgCtx, fmt.Errorf("decode: %w", err)
+	}
+	if err := save(msgCtx, o); err != nil {
+		span.RecordError(err)
+		return o, msgCtx, fmt.Errorf("save: %w", err)
+	}
+	return o, msgCtx, nil
+}
+
 type kafkaHeaderCarrier struct {
 	headers *[]kafka.Header
 }
@@ -3,20 +3,29 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/brianvoe/gofakeit/v7"
 	"github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/otel"
+	"orderservice/internal/features"
+	"orderservice/internal/service"
 	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
 )
 
 type fakeReader struct {
 	msgs []kafka.Message
 	idx  int
+
+	mu        sync.Mutex
+	committed []int64
 }
 
 func (f *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
@@ -29,14 +38,62 @@ func (f *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
 	return m, nil
 }
 
-func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error { return nil }
-func (f *fakeReader) Close() error                                                    { return nil }
+func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range msgs {
+		f.committed = append(f.committed, m.Offset)
+	}
+	return nil
+}
+
+func (f *fakeReader) Close() error { return nil }
+
+func (f *fakeReader) commits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.committed)
+}
+
+type fakeWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error { return nil }
+
+func testWorker(t *testing.T, r Reader, def Definition, save func(context.Context, models.Order) error) *worker {
+	t.Helper()
+	if err := def.check(); err != nil {
+		t.Fatal(err)
+	}
+	decode, err := decoderByName(def.Decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &worker{
+		def:    def,
+		r:      r,
+		decode: decode,
+		save:   save,
+		logger: slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		tracer: otel.Tracer("test"),
+	}
+}
 
 func TestConsume(t *testing.T) {
 	var msgs []kafka.Message
 	var want []models.Order
 	for i := 0; i < 3; i++ {
-		o := fakeOrder()
+		o := fake.Order()
 		want = append(want, o)
 		b, _ := json.Marshal(o)
 		msgs = append(msgs, kafka.Message{Value: b})
@@ -44,16 +101,15 @@ func TestConsume(t *testing.T) {
 	r := &fakeReader{msgs: msgs}
 	var saved []models.Order
 	ctx, cancel := context.WithCancel(context.Background())
-	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-	go func() {
-		_ = consume(ctx, r, func(ctx context.Context, o models.Order) error {
-			saved = append(saved, o)
-			if len(saved) == len(want) {
-				cancel()
-			}
-			return nil
-		}, logger, otel.Tracer("test"))
-	}()
+	def := Definition{Name: "orders", Topics: []string{"orders"}, GroupID: "g"}
+	w := testWorker(t, r, def, func(ctx context.Context, o models.Order) error {
+		saved = append(saved, o)
+		if len(saved) == len(want) {
+			cancel()
+		}
+		return nil
+	})
+	go func() { _ = w.run(ctx) }()
 	<-ctx.Done()
 	if len(saved) != len(want) {
 		t.Fatalf("saved %d, want %d", len(saved), len(want))
@@ -67,20 +123,115 @@ func TestConsume(t *testing.T) {
 	}
 }
 
-func fakeOrder() models.Order {
-	return models.Order{
-		OrderUID:        gofakeit.UUID(),
-		TrackNumber:     "tn",
-		Entry:           "entry",
-		Locale:          "en",
-		CustomerID:      "c",
-		DeliveryService: "d",
-		ShardKey:        "1",
-		SmID:            1,
-		DateCreated:     time.Now(),
-		OofShard:        "1",
-		Items:           []models.Item{{ChrtID: 1, TrackNumber: "tn", Price: 1, Rid: "1", Name: "n"}},
-		Delivery:        models.Delivery{Name: "n", Phone: "p", Zip: "z", City: "c", Address: "a", Region: "r", Email: "e"},
-		Payment:         models.Payment{Transaction: "t", Currency: "c", Provider: "p", Amount: 1},
+func TestWorkerRetriesAndDeadLetters(t *testing.T) {
+	gen := fake.New(fake.WithSeed(3))
+	flaky, invalid, down := gen.Order(), gen.Order(), gen.Order()
+	invalid.Items = nil
+	var msgs []kafka.Message
+	for i, v := range [][]byte{mustJSON(t, flaky), []byte("{"), mustJSON(t, invalid), mustJSON(t, down)} {
+		msgs = append(msgs, kafka.Message{Topic: "orders", Offset: int64(i), Value: v})
+	}
+	r := &fakeReader{msgs: msgs}
+	calls := map[string]int{}
+	def := Definition{
+		Name: "orders", Topics: []string{"orders"}, GroupID: "g", DLQ: "orders_dlq",
+		Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+	}
+	w := testWorker(t, r, def, func(ctx context.Context, o models.Order) error {
+		calls[o.OrderUID]++
+		switch {
+		case o.OrderUID == flaky.OrderUID && calls[o.OrderUID] < 2:
+			return errors.New("connection reset")
+		case o.OrderUID == down.OrderUID:
+			return errors.New("database is down")
+		}
+		return nil
+	})
+	dlq := &fakeWriter{}
+	w.dlq = dlq
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx) }()
+	for deadline := time.Now().Add(5 * time.Second); r.commits() < len(msgs); {
+		if time.Now().After(deadline) {
+			t.Fatalf("committed %v", r.committed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if calls[flaky.OrderUID] != 2 || calls[invalid.OrderUID] != 0 || calls[down.OrderUID] != 3 {
+		t.Fatalf("save calls %v", calls)
+	}
+	if len(dlq.msgs) != 3 {
+		t.Fatalf("dead-lettered %d messages, want 3", len(dlq.msgs))
+	}
+	for i, m := range dlq.msgs {
+		headers := map[string]string{}
+		for _, h := range m.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		if headers["x-dlq-topic"] != "orders" || headers["x-dlq-offset"] != fmt.Sprint(i+1) || headers["x-dlq-error"] == "" {
+			t.Errorf("dlq message %d headers %v", i, headers)
+		}
+	}
+}
+
+func TestWorkerStopsWhenDeadLetterFails(t *testing.T) {
+	r := &fakeReader{msgs: []kafka.Message{{Value: []byte("not json")}}}
+	def := Definition{Name: "orders", Topics: []string{"orders"}, GroupID: "g", DLQ: "orders_dlq"}
+	w := testWorker(t, r, def, func(context.Context, models.Order) error { return nil })
+	w.dlq = &fakeWriter{err: errors.New("leader not available")}
+	if err := w.run(context.Background()); err == nil {
+		t.Fatal("worker went on without dead-lettering")
+	}
+	if len(r.committed) != 0 {
+		t.Fatalf("committed %v", r.committed)
+	}
+}
+
+func TestSaveWithRetryStopsOnValidation(t *testing.T) {
+	calls := 0
+	def := Definition{Name: "orders", Topics: []string{"orders"}, GroupID: "g", Retry: RetryPolicy{Backoff: time.Millisecond}}
+	w := testWorker(t, &fakeReader{}, def, func(context.Context, models.Order) error {
+		calls++
+		return fmt.Errorf("%w: duplicate item", service.ErrValidation)
+	})
+	if err := w.saveWithRetry("orders")(context.Background(), fake.Order()); !errors.Is(err, service.ErrValidation) || calls != 1 {
+		t.Fatalf("err %v after %d calls", err, calls)
+	}
+}
+
+func TestStrictValidationFlag(t *testing.T) {
+	def := Definition{Name: "orders", Topics: []string{"orders"}, GroupID: "g"}
+	w := testWorker(t, &fakeReader{}, def, func(context.Context, models.Order) error { return nil })
+	o := fake.Order()
+	o.Payment.Amount++ // breaks a business rule only strict validation checks
+	if err := w.saveWithRetry("orders")(context.Background(), o); err != nil {
+		t.Fatalf("basic validation: %v", err)
+	}
+
+	w.flags = features.New(map[string]features.Flag{
+		FlagStrictValidation: {Enabled: true, DeliveryServices: []string{o.DeliveryService}},
+	}, "orders-0")
+	if err := w.saveWithRetry("orders")(context.Background(), o); err == nil || !strings.Contains(err.Error(), "amount") {
+		t.Fatalf("flagged order: %v", err)
+	}
+	o.DeliveryService += "-other"
+	if err := w.saveWithRetry("orders")(context.Background(), o); err != nil {
+		t.Fatalf("order of another delivery service: %v", err)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return b
 }
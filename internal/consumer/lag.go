@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// PartitionLag is the consumer group position on one partition.
+type PartitionLag struct {
+	Partition int   `json:"partition"`
+	Committed int64 `json:"committed"`
+	End       int64 `json:"end"`
+	Lag       int64 `json:"lag"`
+}
+
+// Lag reports, per partition of topic, how far groupID is behind the log end.
+// Partitions without a committed offset report Committed = -1 and the whole
+// partition as lag.
+func Lag(ctx context.Context, brokers []string, topic, groupID string) ([]PartitionLag, error) {
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+
+	partitions, err := topicPartitions(ctx, client, topic)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("offset fetch: %w", err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("offset fetch: %w", committed.Error)
+	}
+	ends, err := endOffsets(ctx, client, topic, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	byPartition := make(map[int]*PartitionLag, len(partitions))
+	for _, p := range partitions {
+		byPartition[p] = &PartitionLag{Partition: p, Committed: -1}
+	}
+	for p, end := range ends {
+		if pl, ok := byPartition[p]; ok {
+			pl.End = end
+		}
+	}
+	for _, p := range committed.Topics[topic] {
+		if pl, ok := byPartition[p.Partition]; ok && p.Error == nil && p.CommittedOffset >= 0 {
+			pl.Committed = p.CommittedOffset
+		}
+	}
+
+	result := make([]PartitionLag, 0, len(byPartition))
+	for _, pl := range byPartition {
+		if pl.Committed < 0 {
+			pl.Lag = pl.End
+		} else {
+			pl.Lag = pl.End - pl.Committed
+		}
+		result = append(result, *pl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result, nil
+}
+
+func topicPartitions(ctx context.Context, client *kafka.Client, topic string) ([]int, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	if len(meta.Topics) == 0 || meta.Topics[0].Error != nil {
+		return nil, fmt.Errorf("topic %s not found", topic)
+	}
+	partitions := make([]int, 0, len(meta.Topics[0].Partitions))
+	for _, p := range meta.Topics[0].Partitions {
+		partitions = append(partitions, p.ID)
+	}
+	return partitions, nil
+}
+
+// endOffsets returns the offset the next message of each partition will get.
+func endOffsets(ctx context.Context, client *kafka.Client, topic string, partitions []int) (map[int]int64, error) {
+	reqs := make([]kafka.OffsetRequest, len(partitions))
+	for i, p := range partitions {
+		reqs[i] = kafka.LastOffsetOf(p)
+	}
+	res, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: reqs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list offsets: %w", err)
+	}
+	ends := make(map[int]int64, len(partitions))
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d end offset: %w", p.Partition, p.Error)
+		}
+		ends[p.Partition] = p.LastOffset
+	}
+	for _, p := range partitions {
+		if _, ok := ends[p]; !ok {
+			return nil, fmt.Errorf("partition %d of %s not found", p, topic)
+		}
+	}
+	return ends, nil
+}
@@ -0,0 +1,31 @@
+package consumer
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	messagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_messages_total",
+		Help: "Kafka messages handled, by consumer, topic and result (saved, duplicate, failed, dead_lettered).",
+	}, []string{"consumer", "topic", "result"})
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_retries_total",
+		Help: "Retried order saves.",
+	}, []string{"consumer", "topic"})
+	handleSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consumer_message_duration_seconds",
+		Help:    "Time to decode and save a message, retries included.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"consumer"})
+	restartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_restarts_total",
+		Help: "Consumer restarts after a failure or a change of matched topics.",
+	}, []string{"consumer", "reason"})
+	runningGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consumer_running",
+		Help: "1 while the consumer is reading, 0 while it waits to restart.",
+	}, []string{"consumer"})
+)
+
+func init() {
+	prometheus.MustRegister(messagesTotal, retriesTotal, handleSeconds, restartsTotal, runningGauge)
+}
@@ -0,0 +1,247 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// replayCommitEvery is the number of messages between progress commits.
+const replayCommitEvery = 100
+
+// OffsetRange is an inclusive range of offsets of one partition.
+type OffsetRange struct {
+	Partition int
+	From, To  int64
+}
+
+// ReplayConfig selects what Replay reprocesses: the offset Ranges, or every
+// message of Partitions (all when empty) written from Since up to Until.
+type ReplayConfig struct {
+	Brokers []string
+	Topic   string
+	// GroupID receives the progress of the replay. It must not be the live
+	// consumer group: the live offsets are never touched.
+	GroupID string
+
+	Ranges []OffsetRange
+
+	Since      time.Time
+	Until      time.Time // zero means up to the end of the partition at start
+	Partitions []int
+
+	// Rate caps the messages per second over all partitions, so live
+	// ingestion keeps its share of the database; 0 disables throttling.
+	Rate float64
+	// DryRun commits nothing to GroupID.
+	DryRun bool
+	// Decoder names the message format, json by default.
+	Decoder string
+}
+
+// PartitionReplay is the outcome on one partition: offsets From..To were
+// selected, Next is the first offset not processed. Skipped counts orders
+// that were already stored; saving leaves them as they are.
+type PartitionReplay struct {
+	Partition int   `json:"partition"`
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+	Next      int64 `json:"next"`
+	Read      int   `json:"read"`
+	Saved     int   `json:"saved"`
+	Skipped   int   `json:"skipped"`
+	Failed    int   `json:"failed"`
+}
+
+// ReplayStats summarises a replay run.
+type ReplayStats struct {
+	Read       int               `json:"read"`
+	Saved      int               `json:"saved"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Partitions []PartitionReplay `json:"partitions"`
+}
+
+// Replay re-reads the selected messages with one reader per partition and
+// passes every order to save. The readers are not group members, so the
+// live consumers are not rebalanced; the progress is committed to
+// cfg.GroupID. The end of each partition is fixed when the replay starts, so
+// it finishes even while the topic is written to.
+func Replay(ctx context.Context, cfg ReplayConfig, save func(context.Context, models.Order) error, logger *slog.Logger, tracer trace.Tracer) (ReplayStats, error) {
+	if (len(cfg.Ranges) == 0) == cfg.Since.IsZero() {
+		return ReplayStats{}, errors.New("replay needs either offset ranges or a start time")
+	}
+	if cfg.GroupID == "" && !cfg.DryRun {
+		return ReplayStats{}, errors.New("replay needs a consumer group for its progress")
+	}
+	decode, err := decoderByName(cfg.Decoder)
+	if err != nil {
+		return ReplayStats{}, err
+	}
+	client := &kafka.Client{Addr: kafka.TCP(cfg.Brokers...)}
+
+	partitions := cfg.Partitions
+	if len(cfg.Ranges) > 0 {
+		partitions = make([]int, 0, len(cfg.Ranges))
+		for _, r := range cfg.Ranges {
+			if r.From < 0 || r.To < r.From {
+				return ReplayStats{}, fmt.Errorf("invalid offset range %d..%d of partition %d", r.From, r.To, r.Partition)
+			}
+			if slices.Contains(partitions, r.Partition) {
+				return ReplayStats{}, fmt.Errorf("partition %d has more than one range", r.Partition)
+			}
+			partitions = append(partitions, r.Partition)
+		}
+	}
+	if len(partitions) == 0 {
+		if partitions, err = topicPartitions(ctx, client, cfg.Topic); err != nil {
+			return ReplayStats{}, err
+		}
+	}
+	ends, err := endOffsets(ctx, client, cfg.Topic, partitions)
+	if err != nil {
+		return ReplayStats{}, err
+	}
+
+	stats := ReplayStats{Partitions: make([]PartitionReplay, len(partitions))}
+	for i, p := range partitions {
+		pr := PartitionReplay{Partition: p, To: ends[p] - 1}
+		if len(cfg.Ranges) > 0 {
+			pr.From, pr.To = cfg.Ranges[i].From, min(cfg.Ranges[i].To, pr.To)
+		}
+		stats.Partitions[i] = pr
+	}
+
+	var tick <-chan time.Time
+	if cfg.Rate > 0 {
+		// above 1e9/s the interval rounds to 0, which NewTicker rejects
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/cfg.Rate), time.Nanosecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(partitions))
+	)
+	for i := range stats.Partitions {
+		pr := &stats.Partitions[i]
+		var commit func(next int64) error
+		if !cfg.DryRun {
+			commit = func(next int64) error {
+				return commitOffset(ctx, client, cfg.GroupID, cfg.Topic, pr.Partition, next)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := kafka.NewReader(kafka.ReaderConfig{Brokers: cfg.Brokers, Topic: cfg.Topic, Partition: pr.Partition})
+			defer r.Close()
+			if !cfg.Since.IsZero() {
+				if err := r.SetOffsetAt(ctx, cfg.Since); err != nil {
+					errs[i] = fmt.Errorf("partition %d: offset at %s: %w", pr.Partition, cfg.Since.Format(time.RFC3339), err)
+					return
+				}
+				// nothing written since: the reader points past the end
+				if pr.From = r.Offset(); pr.From < 0 {
+					pr.From = pr.To + 1
+				}
+			} else if err := r.SetOffset(pr.From); err != nil {
+				errs[i] = fmt.Errorf("partition %d: set offset: %w", pr.Partition, err)
+				return
+			}
+			errs[i] = replayPartition(ctx, r, pr, cfg.Until, tick, decode, save, commit, logger, tracer)
+			logger.Info("replay partition done", "partition", pr.Partition, "from", pr.From, "next", pr.Next,
+				"read", pr.Read, "saved", pr.Saved, "skipped", pr.Skipped, "failed", pr.Failed)
+		}()
+	}
+	wg.Wait()
+
+	for _, pr := range stats.Partitions {
+		stats.Read += pr.Read
+		stats.Saved += pr.Saved
+		stats.Skipped += pr.Skipped
+		stats.Failed += pr.Failed
+	}
+	return stats, errors.Join(errs...)
+}
+
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+}
+
+// replayPartition processes pr.From..pr.To from r, which is positioned at
+// pr.From, stopping early at the first message written after until. commit,
+// when set, records the progress every replayCommitEvery messages and at
+// the end.
+func replayPartition(ctx context.Context, r messageReader, pr *PartitionReplay, until time.Time, tick <-chan time.Time, decode Decoder, save func(context.Context, models.Order) error, commit func(next int64) error, logger *slog.Logger, tracer trace.Tracer) error {
+	pr.Next = pr.From
+	for pr.Next <= pr.To {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		msg, err := r.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			return fmt.Errorf("partition %d: read: %w", pr.Partition, err)
+		}
+		// compaction may have removed To itself
+		if msg.Offset > pr.To || (!until.IsZero() && msg.Time.After(until)) {
+			break
+		}
+		pr.Read++
+		if o, _, err := handleMessage(ctx, msg, decode, save, tracer); errors.Is(err, service.ErrDuplicate) {
+			pr.Skipped++
+		} else if err != nil {
+			pr.Failed++
+			logger.Error("replay", "partition", pr.Partition, "offset", msg.Offset, "uid", o.OrderUID, "err", err)
+		} else {
+			pr.Saved++
+		}
+		pr.Next = msg.Offset + 1
+		if commit != nil && pr.Read%replayCommitEvery == 0 {
+			if err := commit(pr.Next); err != nil {
+				return err
+			}
+		}
+	}
+	if commit != nil && pr.Read%replayCommitEvery != 0 {
+		return commit(pr.Next)
+	}
+	return nil
+}
+
+// commitOffset records next as the position of groupID on a partition. The
+// group has no members, so the commit goes without a generation.
+func commitOffset(ctx context.Context, client *kafka.Client, groupID, topic string, partition int, next int64) error {
+	res, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: {{Partition: partition, Offset: next}}},
+	})
+	if err != nil {
+		return fmt.Errorf("partition %d: commit: %w", partition, err)
+	}
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return fmt.Errorf("partition %d: commit: %w", partition, p.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+)
+
+func replayMessages(t *testing.T, start time.Time) []kafka.Message {
+	t.Helper()
+	var msgs []kafka.Message
+	for i := 0; i < 6; i++ {
+		b, err := json.Marshal(fake.Order())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			b = []byte("{broken")
+		}
+		msgs = append(msgs, kafka.Message{Offset: int64(3 + i), Value: b, Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+	return msgs
+}
+
+func TestReplayPartition(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var saved []string
+	save := func(_ context.Context, o models.Order) error {
+		saved = append(saved, o.OrderUID)
+		return nil
+	}
+	var commits []int64
+	commit := func(next int64) error {
+		commits = append(commits, next)
+		return nil
+	}
+
+	pr := &PartitionReplay{Partition: 2, From: 3, To: 6}
+	r := &fakeReader{msgs: replayMessages(t, start)}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := replayPartition(ctx, r, pr, time.Time{}, nil, decoders["json"], save, commit, logger, otel.Tracer("test")); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Read != 4 || pr.Saved != 3 || pr.Failed != 1 || pr.Next != 7 || len(saved) != 3 {
+		t.Fatalf("range: %+v, saved %d", pr, len(saved))
+	}
+	if !slices.Equal(commits, []int64{7}) {
+		t.Fatalf("commits %v", commits)
+	}
+
+	// messages written after until end the replay before To
+	pr, commits = &PartitionReplay{Partition: 2, From: 3, To: 8}, nil
+	r = &fakeReader{msgs: replayMessages(t, start)}
+	if err := replayPartition(ctx, r, pr, start.Add(2*time.Minute), nil, decoders["json"], save, commit, logger, otel.Tracer("test")); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Read != 3 || pr.Next != 6 || !slices.Equal(commits, []int64{6}) {
+		t.Fatalf("until: %+v, commits %v", pr, commits)
+	}
+
+	// orders already stored are skipped, not counted as saved
+	pr, commits = &PartitionReplay{Partition: 2, From: 3, To: 6}, nil
+	r = &fakeReader{msgs: replayMessages(t, start)}
+	stored := func(context.Context, models.Order) error { return service.ErrDuplicate }
+	if err := replayPartition(ctx, r, pr, time.Time{}, nil, decoders["json"], stored, commit, logger, otel.Tracer("test")); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Read != 4 || pr.Saved != 0 || pr.Skipped != 3 || pr.Failed != 1 {
+		t.Fatalf("stored: %+v", pr)
+	}
+
+	// a dry run commits nothing; cancellation is reported
+	pr = &PartitionReplay{Partition: 2, From: 3, To: 20}
+	r = &fakeReader{msgs: replayMessages(t, start)}
+	short, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	if err := replayPartition(short, r, pr, time.Time{}, nil, decoders["json"], save, nil, logger, otel.Tracer("test")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cancelled replay: %v", err)
+	}
+	if pr.Read != 6 || pr.Next != 9 {
+		t.Fatalf("cancelled replay: %+v", pr)
+	}
+}
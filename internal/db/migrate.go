@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"orderservice/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrSchemaBehind возвращается, если версия схемы в БД ниже той, что ожидает бинарник.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migrator применяет встроенные миграции goose. Все изменяющие операции
+// выполняются под session advisory lock, чтобы реплики не накатывали схему параллельно.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator создаёт Migrator поверх существующего пула соединений.
+func NewMigrator(pool *pgxpool.Pool, logger *slog.Logger) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("migration locker: %w", err)
+	}
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	opts := []goose.ProviderOption{goose.WithSessionLocker(locker)}
+	if logger != nil {
+		opts = append(opts, goose.WithSlog(logger))
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, sqlDB, migrations.FS, opts...)
+	if err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("migration provider: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up накатывает все ожидающие миграции.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down откатывает последнюю применённую миграцию.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo откатывает и заново применяет последнюю миграцию.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.UpByOne(ctx)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status возвращает состояние каждой встроенной миграции.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// CheckVersion возвращает ErrSchemaBehind, если в БД применены не все встроенные миграции.
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	current, target, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("schema version: %w", err)
+	}
+	if current < target {
+		return fmt.Errorf("%w: db version %d, binary expects %d", ErrSchemaBehind, current, target)
+	}
+	return nil
+}
+
+// Close освобождает sql.DB-обёртку над пулом (сам пул не закрывается).
+func (m *Migrator) Close() error {
+	return m.provider.Close()
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"strconv"
+	"time"
+)
+
+// Kind is the type of a column's values.
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindInt
+	KindTime
+)
+
+// Column is one field of an exported row.
+type Column struct {
+	Name  string
+	Kind  Kind
+	value func(*Row) any // string, int64 or time.Time
+}
+
+func str(f func(*Row) string) func(*Row) any { return func(r *Row) any { return f(r) } }
+func num(f func(*Row) int64) func(*Row) any  { return func(r *Row) any { return f(r) } }
+
+var columns = []Column{
+	{"order_uid", KindString, str(func(r *Row) string { return r.Order.OrderUID })},
+	{"track_number", KindString, str(func(r *Row) string { return r.Order.TrackNumber })},
+	{"entry", KindString, str(func(r *Row) string { return r.Order.Entry })},
+	{"locale", KindString, str(func(r *Row) string { return r.Order.Locale })},
+	{"customer_id", KindString, str(func(r *Row) string { return r.Order.CustomerID })},
+	{"delivery_service", KindString, str(func(r *Row) string { return r.Order.DeliveryService })},
+	{"shardkey", KindString, str(func(r *Row) string { return r.Order.ShardKey })},
+	{"sm_id", KindInt, num(func(r *Row) int64 { return int64(r.Order.SmID) })},
+	{"date_created", KindTime, func(r *Row) any { return r.Order.DateCreated }},
+	{"oof_shard", KindString, str(func(r *Row) string { return r.Order.OofShard })},
+	{"city", KindString, str(func(r *Row) string { return r.Order.Delivery.City })},
+	{"region", KindString, str(func(r *Row) string { return r.Order.Delivery.Region })},
+	{"payment_transaction", KindString, str(func(r *Row) string { return r.Order.Payment.Transaction })},
+	{"payment_currency", KindString, str(func(r *Row) string { return r.Order.Payment.Currency })},
+	{"payment_provider", KindString, str(func(r *Row) string { return r.Order.Payment.Provider })},
+	{"payment_amount", KindInt, num(func(r *Row) int64 { return int64(r.Order.Payment.Amount) })},
+	{"payment_dt", KindInt, num(func(r *Row) int64 { return r.Order.Payment.PaymentDT })},
+	{"payment_bank", KindString, str(func(r *Row) string { return r.Order.Payment.Bank })},
+	{"payment_delivery_cost", KindInt, num(func(r *Row) int64 { return int64(r.Order.Payment.DeliveryCost) })},
+	{"payment_goods_total", KindInt, num(func(r *Row) int64 { return int64(r.Order.Payment.GoodsTotal) })},
+	{"payment_custom_fee", KindInt, num(func(r *Row) int64 { return int64(r.Order.Payment.CustomFee) })},
+	{"item_chrt_id", KindInt, num(func(r *Row) int64 { return r.Item.ChrtID })},
+	{"item_rid", KindString, str(func(r *Row) string { return r.Item.Rid })},
+	{"item_nm_id", KindInt, num(func(r *Row) int64 { return r.Item.NmID })},
+	{"item_name", KindString, str(func(r *Row) string { return r.Item.Name })},
+	{"item_brand", KindString, str(func(r *Row) string { return r.Item.Brand })},
+	{"item_size", KindString, str(func(r *Row) string { return r.Item.Size })},
+	{"item_price", KindInt, num(func(r *Row) int64 { return int64(r.Item.Price) })},
+	{"item_sale", KindInt, num(func(r *Row) int64 { return int64(r.Item.Sale) })},
+	{"item_total_price", KindInt, num(func(r *Row) int64 { return int64(r.Item.TotalPrice) })},
+	{"item_status", KindInt, num(func(r *Row) int64 { return int64(r.Item.Status) })},
+}
+
+var columnIndex = func() map[string]int {
+	m := make(map[string]int, len(columns))
+	for i, c := range columns {
+		m[c.Name] = i
+	}
+	return m
+}()
+
+// ColumnNames lists every column in the default order.
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// text renders a value the way CSV carries it: times in RFC 3339 UTC.
+func (c Column) text(r *Row) string {
+	switch v := c.value(r).(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return ""
+}
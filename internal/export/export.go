@@ -0,0 +1,108 @@
+// Package export writes orders flattened to one row per item (an order
+// without items gives one row with empty item columns) as CSV, NDJSON or
+// Parquet. Every export ends with a footer carrying the row count and a
+// checksum of its contents.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+)
+
+// Formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// ErrInvalidQuery wraps every validation error.
+var ErrInvalidQuery = errors.New("invalid export query")
+
+// Query selects the orders created in [From, To).
+type Query struct {
+	From, To time.Time
+	Format   string   // empty means FormatCSV
+	Columns  []string // column names in output order; empty means all
+}
+
+// Row is one exported line: an order with its payment and one of its items.
+// Delivery contacts are never exported.
+type Row struct {
+	Order models.Order // Items is not filled
+	Item  models.Item  // zero for an order without items
+}
+
+// Summary is what the footer of an export records.
+type Summary struct {
+	Rows int64
+	// SHA256 is the hex digest of the export rendered as CSV (header and
+	// rows, no footer), whatever the format: exports of the same rows in
+	// different formats carry the same checksum.
+	SHA256 string
+}
+
+// Source reads the rows of an export.
+type Source interface {
+	// ExportOrders calls fn for every row of the orders created in [from, to)
+	// and in any of scopes when there are some, oldest order first, without
+	// holding them all in memory.
+	ExportOrders(ctx context.Context, from, to time.Time, scopes []repository.Scope, fn func(*Row) error) error
+}
+
+// ContentType is the media type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Normalize validates q and fills in the defaults.
+func (q *Query) Normalize() error {
+	if q.From.IsZero() || q.To.IsZero() {
+		return fmt.Errorf("%w: from and to are required", ErrInvalidQuery)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("%w: empty range", ErrInvalidQuery)
+	}
+	if q.Format == "" {
+		q.Format = FormatCSV
+	}
+	if !slices.Contains([]string{FormatCSV, FormatNDJSON, FormatParquet}, q.Format) {
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidQuery, q.Format)
+	}
+	if len(q.Columns) == 0 {
+		q.Columns = ColumnNames()
+	}
+	seen := map[string]bool{}
+	for _, name := range q.Columns {
+		if _, ok := columnIndex[name]; !ok {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: column %q repeated", ErrInvalidQuery, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Selected returns the columns of a normalized query.
+func (q Query) Selected() []Column {
+	cols := make([]Column, len(q.Columns))
+	for i, name := range q.Columns {
+		cols[i] = columns[columnIndex[name]]
+	}
+	return cols
+}
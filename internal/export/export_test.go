@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/pkg/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func testRows() []*Row {
+	o := models.Order{
+		OrderUID:    "o1",
+		TrackNumber: "WB1",
+		DateCreated: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Payment:     models.Payment{Currency: "RUB", Amount: 1817},
+	}
+	return []*Row{
+		{Order: o, Item: models.Item{NmID: 11, Name: `Mascara, "long"`, TotalPrice: 317}},
+		{Order: o, Item: models.Item{NmID: 12, Name: "Brush", TotalPrice: 1500}},
+		{Order: models.Order{OrderUID: "o2", Payment: models.Payment{Currency: "USD"}}},
+	}
+}
+
+func export(t *testing.T, q Query) ([]byte, Summary) {
+	t.Helper()
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, q.Format, q.Selected())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range testRows() {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), s
+}
+
+func TestFormats(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	cols := []string{"order_uid", "date_created", "payment_amount", "item_name"}
+	q := Query{From: day, To: day.AddDate(0, 1, 0), Columns: cols}
+
+	raw, sum := export(t, q)
+	lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	if len(lines) != 5 || lines[0] != "order_uid,date_created,payment_amount,item_name" ||
+		lines[1] != `o1,2025-03-01T10:00:00Z,1817,"Mascara, ""long"""` || lines[3] != "o2,,0," {
+		t.Fatalf("csv %q", raw)
+	}
+	body := strings.Join(lines[:4], "\n") + "\n"
+	digest := sha256.Sum256([]byte(body))
+	if sum.Rows != 3 || sum.SHA256 != hex.EncodeToString(digest[:]) ||
+		lines[4] != "# rows=3 sha256="+sum.SHA256 {
+		t.Fatalf("csv footer %q, summary %+v", lines[4], sum)
+	}
+
+	q.Format = FormatNDJSON
+	raw, ndSum := export(t, q)
+	if ndSum != sum {
+		t.Fatalf("ndjson summary %+v, csv %+v", ndSum, sum)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	var objs []map[string]any
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		objs = append(objs, m)
+	}
+	if len(objs) != 4 || objs[0]["payment_amount"] != 1817.0 || objs[2]["date_created"] != nil ||
+		!strings.HasPrefix(string(raw), `{"order_uid":"o1","date_created":"2025-03-01T10:00:00Z",`) {
+		t.Fatalf("ndjson %s", raw)
+	}
+	if footer := objs[3]["_footer"].(map[string]any); footer["rows"] != 3.0 || footer["sha256"] != sum.SHA256 {
+		t.Fatalf("ndjson footer %v", footer)
+	}
+
+	q.Format = FormatParquet
+	raw, pqSum := export(t, q)
+	if pqSum != sum {
+		t.Fatalf("parquet summary %+v, csv %+v", pqSum, sum)
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ := f.Lookup(MetaRows); f.NumRows() != 3 || rows != "3" {
+		t.Fatalf("parquet rows %d, footer %q", f.NumRows(), rows)
+	}
+	if digest, _ := f.Lookup(MetaSHA256); digest != sum.SHA256 {
+		t.Fatalf("parquet checksum %q", digest)
+	}
+	type record struct {
+		OrderUID      string     `parquet:"order_uid"`
+		DateCreated   *time.Time `parquet:"date_created,optional"`
+		PaymentAmount int64      `parquet:"payment_amount"`
+		ItemName      string     `parquet:"item_name"`
+	}
+	records, err := parquet.Read[record](bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].ItemName != `Mascara, "long"` || records[0].PaymentAmount != 1817 ||
+		!records[0].DateCreated.Equal(testRows()[0].Order.DateCreated) || records[2].DateCreated != nil {
+		t.Fatalf("parquet records %+v", records)
+	}
+}
+
+func TestQueryNormalize(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	q := Query{From: day, To: day.AddDate(0, 1, 0)}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if q.Format != FormatCSV || len(q.Selected()) != len(columns) {
+		t.Fatalf("defaults %+v", q)
+	}
+	for _, bad := range []Query{
+		{From: day},
+		{From: day, To: day},
+		{From: day, To: day.AddDate(0, 1, 0), Format: "xlsx"},
+		{From: day, To: day.AddDate(0, 1, 0), Columns: []string{"delivery_phone"}},
+		{From: day, To: day.AddDate(0, 1, 0), Columns: []string{"order_uid", "order_uid"}},
+	} {
+		if err := bad.Normalize(); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%+v: %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Footer keys of Parquet exports (file key/value metadata).
+const (
+	MetaRows   = "export.rows"
+	MetaSHA256 = "export.sha256"
+)
+
+// parquetRowGroup bounds the rows a Parquet writer buffers before flushing.
+const parquetRowGroup = 50_000
+
+// Writer encodes rows in one format. Close writes the footer; it does not
+// close the underlying writer.
+type Writer interface {
+	Write(r *Row) error
+	Close() (Summary, error)
+}
+
+// NewWriter starts an export of cols to w in format.
+func NewWriter(w io.Writer, format string, cols []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatNDJSON:
+		return newNDJSONWriter(w, cols)
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	}
+	return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidQuery, format)
+}
+
+// checksum renders rows as CSV into a SHA-256.
+type checksum struct {
+	cols   []Column
+	sum    hash.Hash
+	csv    *csv.Writer
+	record []string
+	rows   int64
+}
+
+func newChecksum(cols []Column, out io.Writer) (*checksum, error) {
+	c := &checksum{cols: cols, sum: sha256.New(), record: make([]string, len(cols))}
+	w := io.Writer(c.sum)
+	if out != nil {
+		w = io.MultiWriter(out, c.sum)
+	}
+	c.csv = csv.NewWriter(w)
+	for i, col := range cols {
+		c.record[i] = col.Name
+	}
+	if err := c.csv.Write(c.record); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checksum) add(r *Row) error {
+	for i, col := range c.cols {
+		c.record[i] = col.text(r)
+	}
+	c.rows++
+	return c.csv.Write(c.record)
+}
+
+func (c *checksum) summary() (Summary, error) {
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return Summary{}, err
+	}
+	return Summary{Rows: c.rows, SHA256: hex.EncodeToString(c.sum.Sum(nil))}, nil
+}
+
+// csvWriter is RFC 4180 with a header line and a "# rows=N sha256=HEX"
+// footer line; the checksum covers everything before the footer.
+type csvWriter struct {
+	out *bufio.Writer
+	sum *checksum
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	out := bufio.NewWriter(w)
+	sum, err := newChecksum(cols, out)
+	if err != nil {
+		return nil, err
+	}
+	return &csvWriter{out: out, sum: sum}, nil
+}
+
+func (w *csvWriter) Write(r *Row) error { return w.sum.add(r) }
+
+func (w *csvWriter) Close() (Summary, error) {
+	s, err := w.sum.summary()
+	if err != nil {
+		return Summary{}, err
+	}
+	fmt.Fprintf(w.out, "# rows=%d sha256=%s\n", s.Rows, s.SHA256)
+	return s, w.out.Flush()
+}
+
+// ndjsonWriter writes an object per row with keys in column order and a
+// final {"_footer":{"rows":N,"sha256":"HEX"}} line.
+type ndjsonWriter struct {
+	out  *bufio.Writer
+	cols []Column
+	keys [][]byte
+	sum  *checksum
+	line []byte
+}
+
+func newNDJSONWriter(w io.Writer, cols []Column) (*ndjsonWriter, error) {
+	sum, err := newChecksum(cols, nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, len(cols))
+	for i, c := range cols {
+		keys[i], _ = json.Marshal(c.Name)
+	}
+	return &ndjsonWriter{out: bufio.NewWriter(w), cols: cols, keys: keys, sum: sum}, nil
+}
+
+func (w *ndjsonWriter) Write(r *Row) error {
+	if err := w.sum.add(r); err != nil {
+		return err
+	}
+	line := append(w.line[:0], '{')
+	for i, c := range w.cols {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(append(line, w.keys[i]...), ':')
+		switch v := c.value(r).(type) {
+		case int64:
+			line = strconv.AppendInt(line, v, 10)
+		case time.Time:
+			if v.IsZero() {
+				line = append(line, "null"...)
+			} else {
+				line = strconv.AppendQuote(line, v.UTC().Format(time.RFC3339Nano))
+			}
+		case string:
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			line = append(line, raw...)
+		}
+	}
+	w.line = append(line, '}', '\n')
+	_, err := w.out.Write(w.line)
+	return err
+}
+
+func (w *ndjsonWriter) Close() (Summary, error) {
+	s, err := w.sum.summary()
+	if err != nil {
+		return Summary{}, err
+	}
+	fmt.Fprintf(w.out, "{\"_footer\":{\"rows\":%d,\"sha256\":%q}}\n", s.Rows, s.SHA256)
+	return s, w.out.Flush()
+}
+
+// parquetWriter keeps the footer in the file metadata (MetaRows, MetaSHA256).
+// Times are optional millisecond timestamps, the rest required columns.
+type parquetWriter struct {
+	w     *parquet.Writer
+	cols  []Column
+	index []int // leaf column of cols[i]
+	sum   *checksum
+	row   parquet.Row
+}
+
+func newParquetWriter(w io.Writer, cols []Column) *parquetWriter {
+	group := parquet.Group{}
+	for _, c := range cols {
+		switch c.Kind {
+		case KindInt:
+			group[c.Name] = parquet.Int(64)
+		case KindTime:
+			group[c.Name] = parquet.Optional(parquet.Timestamp(parquet.Millisecond))
+		default:
+			group[c.Name] = parquet.String()
+		}
+	}
+	schema := parquet.NewSchema("order", group)
+	pw := &parquetWriter{
+		w: parquet.NewWriter(w, schema,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(parquetRowGroup)),
+		cols:  cols,
+		index: make([]int, len(cols)),
+		row:   make(parquet.Row, len(cols)),
+	}
+	for i, c := range cols {
+		leaf, _ := schema.Lookup(c.Name)
+		pw.index[i] = leaf.ColumnIndex
+	}
+	// the checksum cannot fail without an output
+	pw.sum, _ = newChecksum(cols, nil)
+	return pw
+}
+
+func (w *parquetWriter) Write(r *Row) error {
+	if err := w.sum.add(r); err != nil {
+		return err
+	}
+	for i, c := range w.cols {
+		idx := w.index[i]
+		var v parquet.Value
+		switch x := c.value(r).(type) {
+		case string:
+			v = parquet.ByteArrayValue([]byte(x)).Level(0, 0, idx)
+		case int64:
+			v = parquet.Int64Value(x).Level(0, 0, idx)
+		case time.Time:
+			if x.IsZero() {
+				v = parquet.NullValue().Level(0, 0, idx)
+			} else {
+				v = parquet.Int64Value(x.UnixMilli()).Level(0, 1, idx)
+			}
+		}
+		w.row[idx] = v
+	}
+	_, err := w.w.WriteRows([]parquet.Row{w.row})
+	return err
+}
+
+func (w *parquetWriter) Close() (Summary, error) {
+	s, err := w.sum.summary()
+	if err != nil {
+		return Summary{}, err
+	}
+	w.w.SetKeyValueMetadata(MetaRows, strconv.FormatInt(s.Rows, 10))
+	w.w.SetKeyValueMetadata(MetaSHA256, s.SHA256)
+	return s, w.w.Close()
+}
@@ -0,0 +1,215 @@
+// Package features evaluates runtime feature flags, so that risky behaviour
+// changes can be rolled out to some replicas, a share of orders or some
+// delivery services and locales without a redeploy. Flags come from the
+// config file and may be overridden from Redis.
+package features
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"orderservice/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Values of Flag.By.
+const (
+	ByKey     = "key"
+	ByReplica = "replica"
+)
+
+// Sources of a flag definition.
+const (
+	SourceConfig = "config"
+	SourceRedis  = "redis"
+)
+
+// Flag says for which subjects a feature is on.
+//
+//	feature_flags:
+//	  strict_validation:
+//	    enabled: true
+//	    percent: 10                  # of orders, by order_uid
+//	    delivery_services: [meest]
+//	  lazy_cache:
+//	    enabled: true
+//	    percent: 50
+//	    by: replica                  # half of the replicas, all their orders
+type Flag struct {
+	// Enabled turns the flag on for the subjects matching the rest; off, it
+	// is off for everyone.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Percent is the share of subjects, 0 to 100; unset means all. A subject
+	// is in or out for good, since it is picked by a hash of the flag name
+	// and the subject key, or the replica name when By is replica.
+	Percent *float64 `yaml:"percent,omitempty" json:"percent,omitempty"`
+	By      string   `yaml:"by,omitempty" json:"by,omitempty"`
+	// DeliveryServices, Locales and Replicas restrict the flag to the
+	// subjects having one of the values; empty lists allow any.
+	DeliveryServices []string `yaml:"delivery_services,omitempty" json:"delivery_services,omitempty"`
+	Locales          []string `yaml:"locales,omitempty" json:"locales,omitempty"`
+	Replicas         []string `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+}
+
+// Check reports a flag that cannot be evaluated.
+func (f Flag) Check() error {
+	if f.Percent != nil && (*f.Percent < 0 || *f.Percent > 100) {
+		return fmt.Errorf("percent %v: want 0 to 100", *f.Percent)
+	}
+	if f.By != "" && f.By != ByKey && f.By != ByReplica {
+		return fmt.Errorf("by %q: want %s or %s", f.By, ByKey, ByReplica)
+	}
+	return nil
+}
+
+// CheckFlags checks every flag of a set.
+func CheckFlags(flags map[string]Flag) error {
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if name == "" {
+			return errors.New("flag without a name")
+		}
+		if err := flags[name].Check(); err != nil {
+			return fmt.Errorf("flag %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Subject is what a flag is evaluated for.
+type Subject struct {
+	// Key picks the subject for a percentage rollout, e.g. an order_uid.
+	// Without it subjects are picked by replica.
+	Key             string
+	DeliveryService string
+	Locale          string
+}
+
+// ForOrder returns the subject of an order.
+func ForOrder(o models.Order) Subject {
+	return Subject{Key: o.OrderUID, DeliveryService: o.DeliveryService, Locale: o.Locale}
+}
+
+// Report is the outcome of every flag for a subject on a replica.
+type Report struct {
+	Replica string       `json:"replica"`
+	Flags   []Evaluation `json:"flags"`
+}
+
+// Evaluation is a flag with its outcome for a subject.
+type Evaluation struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Flag    Flag   `json:"flag"`
+	Enabled bool   `json:"enabled"`
+}
+
+var evaluationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "feature_flag_evaluations_total",
+	Help: "Feature flag evaluations by flag and result (on, off).",
+}, []string{"flag", "result"})
+
+func init() {
+	prometheus.MustRegister(evaluationsTotal)
+}
+
+// Flags holds the flags of a replica. The zero of *Flags, nil, has every
+// flag off, so code taking flags needs no special case without them.
+type Flags struct {
+	replica   string
+	config    atomic.Pointer[map[string]Flag]
+	overrides atomic.Pointer[map[string]Flag] // from Redis
+}
+
+// New returns the flags of the replica named replica, defined by config.
+func New(config map[string]Flag, replica string) *Flags {
+	f := &Flags{replica: replica}
+	f.SetConfig(config)
+	f.SetOverrides(nil)
+	return f
+}
+
+// SetConfig replaces the flags defined by the config.
+func (f *Flags) SetConfig(flags map[string]Flag) {
+	flags = maps.Clone(flags)
+	f.config.Store(&flags)
+}
+
+// SetOverrides replaces the flags that take precedence over the config ones.
+func (f *Flags) SetOverrides(flags map[string]Flag) {
+	flags = maps.Clone(flags)
+	f.overrides.Store(&flags)
+}
+
+// Enabled reports whether flag name is on for s. Unknown flags are off.
+func (f *Flags) Enabled(name string, s Subject) bool {
+	if f == nil {
+		return false
+	}
+	flag, _ := f.lookup(name)
+	on := f.eval(name, flag, s)
+	result := "off"
+	if on {
+		result = "on"
+	}
+	evaluationsTotal.WithLabelValues(name, result).Inc()
+	return on
+}
+
+// Evaluate returns every flag with its outcome for s, by name.
+func (f *Flags) Evaluate(s Subject) Report {
+	if f == nil {
+		return Report{}
+	}
+	names := slices.Collect(maps.Keys(*f.config.Load()))
+	names = append(names, slices.Collect(maps.Keys(*f.overrides.Load()))...)
+	slices.Sort(names)
+	evals := make([]Evaluation, 0, len(names))
+	for _, name := range slices.Compact(names) {
+		flag, source := f.lookup(name)
+		evals = append(evals, Evaluation{Name: name, Source: source, Flag: flag, Enabled: f.eval(name, flag, s)})
+	}
+	return Report{Replica: f.replica, Flags: evals}
+}
+
+// lookup returns flag name and where it is defined.
+func (f *Flags) lookup(name string) (Flag, string) {
+	if flag, ok := (*f.overrides.Load())[name]; ok {
+		return flag, SourceRedis
+	}
+	return (*f.config.Load())[name], SourceConfig
+}
+
+func (f *Flags) eval(name string, flag Flag, s Subject) bool {
+	if !flag.Enabled ||
+		!allows(flag.DeliveryServices, s.DeliveryService) ||
+		!allows(flag.Locales, s.Locale) ||
+		!allows(flag.Replicas, f.replica) {
+		return false
+	}
+	if flag.Percent == nil {
+		return true
+	}
+	key := s.Key
+	if key == "" || flag.By == ByReplica {
+		key = f.replica
+	}
+	return bucket(name, key) < *flag.Percent
+}
+
+func allows(values []string, v string) bool {
+	return len(values) == 0 || slices.Contains(values, v)
+}
+
+// bucket maps a flag and key to [0, 100) with a step of 0.01.
+func bucket(name, key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return float64(h.Sum64()%10000) / 100
+}
@@ -0,0 +1,140 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func percent(p float64) *float64 { return &p }
+
+func TestEnabled(t *testing.T) {
+	f := New(map[string]Flag{
+		"on":       {Enabled: true},
+		"off":      {Enabled: false, DeliveryServices: []string{"meest"}},
+		"meest":    {Enabled: true, DeliveryServices: []string{"meest"}, Locales: []string{"en", "ru"}},
+		"replica":  {Enabled: true, Replicas: []string{"orders-1"}},
+		"none":     {Enabled: true, Percent: percent(0)},
+		"everyone": {Enabled: true, Percent: percent(100)},
+	}, "orders-0")
+	s := Subject{Key: "b563feb7b2b84b6test", DeliveryService: "meest", Locale: "en"}
+	for name, want := range map[string]bool{
+		"on": true, "off": false, "meest": true, "replica": false, "none": false, "everyone": true, "unknown": false,
+	} {
+		if got := f.Enabled(name, s); got != want {
+			t.Errorf("%s: %t, want %t", name, got, want)
+		}
+	}
+	if f.Enabled("meest", Subject{DeliveryService: "meest", Locale: "de"}) {
+		t.Error("meest on for another locale")
+	}
+	var none *Flags
+	if none.Enabled("on", s) || none.Evaluate(s).Flags != nil {
+		t.Error("nil flags are not all off")
+	}
+}
+
+func TestPercent(t *testing.T) {
+	f := New(map[string]Flag{"ramp": {Enabled: true, Percent: percent(20)}}, "orders-0")
+	on := 0
+	for i := range 10000 {
+		s := Subject{Key: fmt.Sprintf("order-%d", i)}
+		got := f.Enabled("ramp", s)
+		if got != f.Enabled("ramp", s) {
+			t.Fatalf("%s flips", s.Key)
+		}
+		if got {
+			on++
+		}
+	}
+	if on < 1800 || on > 2200 {
+		t.Fatalf("%d of 10000 on at 20%%", on)
+	}
+
+	// by replica every order of a replica gets the same answer
+	f.SetConfig(map[string]Flag{"ramp": {Enabled: true, Percent: percent(50), By: ByReplica}})
+	first := f.Enabled("ramp", Subject{Key: "a"})
+	for i := range 100 {
+		if f.Enabled("ramp", Subject{Key: fmt.Sprint(i)}) != first {
+			t.Fatal("by replica depends on the key")
+		}
+	}
+}
+
+func TestOverridesAndEvaluate(t *testing.T) {
+	f := New(map[string]Flag{"lazy_cache": {Enabled: true}, "strict_validation": {Enabled: false}}, "orders-0")
+	f.SetOverrides(map[string]Flag{"strict_validation": {Enabled: true, Locales: []string{"en"}}, "new": {}})
+	r := f.Evaluate(Subject{Locale: "en"})
+	if r.Replica != "orders-0" {
+		t.Errorf("replica %q", r.Replica)
+	}
+	var got []string
+	for _, e := range r.Flags {
+		got = append(got, fmt.Sprintf("%s/%s/%t", e.Name, e.Source, e.Enabled))
+	}
+	want := "lazy_cache/config/true new/redis/false strict_validation/redis/true"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("evaluations %v, want %s", got, want)
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	for _, tc := range []struct {
+		flags map[string]Flag
+		err   string
+	}{
+		{map[string]Flag{"a": {Percent: percent(101)}}, "flag a: percent"},
+		{map[string]Flag{"a": {Percent: percent(-1)}}, "percent"},
+		{map[string]Flag{"a": {By: "order"}}, "by"},
+		{map[string]Flag{"": {}}, "without a name"},
+	} {
+		if err := CheckFlags(tc.flags); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: %v, want %q", tc.flags, err, tc.err)
+		}
+	}
+	if err := CheckFlags(map[string]Flag{"a": {Enabled: true, Percent: percent(0), By: ByReplica}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatchOverrides(t *testing.T) {
+	f := New(map[string]Flag{"strict_validation": {Enabled: false}}, "orders-0")
+	var calls atomic.Int32
+	fetch := func(context.Context) (map[string]string, error) {
+		switch calls.Add(1) {
+		case 1:
+			return map[string]string{
+				"strict_validation": `{"enabled":true,"delivery_services":["meest"]}`,
+				"broken":            `{"enabled":`,
+				"too_many":          `{"enabled":true,"percent":150}`,
+			}, nil
+		default:
+			return nil, errors.New("connection refused")
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.watch(ctx, fetch, time.Millisecond, discard) }()
+	for calls.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// the overrides of the last good read stay
+	if !f.Enabled("strict_validation", Subject{DeliveryService: "meest"}) {
+		t.Error("override not applied")
+	}
+	if r := f.Evaluate(Subject{}); len(r.Flags) != 1 {
+		t.Errorf("invalid overrides kept: %+v", r.Flags)
+	}
+}
@@ -0,0 +1,69 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WatchRedis reads flag overrides from the Redis hash key every interval
+// until ctx is cancelled. The fields of the hash are flag names, the values
+// JSON flags:
+//
+//	HSET feature_flags strict_validation '{"enabled":true,"percent":5}'
+//
+// Invalid values are logged and skipped. While Redis is unavailable the last
+// overrides stay in force.
+func (f *Flags) WatchRedis(ctx context.Context, client *redis.Client, key string, interval time.Duration, logger *slog.Logger) error {
+	return f.watch(ctx, func(ctx context.Context) (map[string]string, error) {
+		return client.HGetAll(ctx, key).Result()
+	}, interval, logger.With("key", key))
+}
+
+func (f *Flags) watch(ctx context.Context, fetch func(context.Context) (map[string]string, error), interval time.Duration, logger *slog.Logger) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	failing := false
+	for {
+		fields, err := fetch(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			if !failing {
+				logger.Warn("feature flags not read from redis, keeping the last ones", "err", err)
+			}
+			failing = true
+		case err == nil:
+			if failing {
+				logger.Info("feature flags read from redis again")
+			}
+			failing = false
+			f.SetOverrides(parseOverrides(fields, logger))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func parseOverrides(fields map[string]string, logger *slog.Logger) map[string]Flag {
+	flags := make(map[string]Flag, len(fields))
+	for name, raw := range fields {
+		var flag Flag
+		err := json.Unmarshal([]byte(raw), &flag)
+		if err == nil {
+			err = flag.Check()
+		}
+		if err != nil {
+			logger.Error("invalid feature flag in redis", "flag", name, "err", fmt.Errorf("%q: %w", raw, err))
+			continue
+		}
+		flags[name] = flag
+	}
+	return flags
+}
@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// checkpoint is the progress of a run as of its last committed batch.
+type checkpoint struct {
+	// Files are keyed by absolute path.
+	Files map[string]*fileState `json:"files"`
+	// Rejects is the size of the rejects file at the checkpoint: lines
+	// written after it belong to a batch that will be read again.
+	Rejects int64 `json:"rejects_size"`
+	Stats   Stats `json:"stats"`
+}
+
+// fileState locates the first line not yet committed.
+type fileState struct {
+	Offset int64 `json:"offset"`
+	Line   int64 `json:"line"`
+	Done   bool  `json:"done"`
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{Files: map[string]*fileState{}}
+	if path == "" {
+		return cp, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(raw, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	if cp.Files == nil {
+		cp.Files = map[string]*fileState{}
+	}
+	return cp, nil
+}
+
+// save replaces the checkpoint file atomically: a crash leaves either the
+// previous checkpoint or this one.
+func (cp *checkpoint) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if _, err = f.Write(raw); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
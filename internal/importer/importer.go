@@ -0,0 +1,392 @@
+// Package importer loads orders from NDJSON dumps straight into the database
+// in large batches, bypassing Kafka. After each batch commits, a checkpoint
+// file is rewritten so a crashed run resumes where it stopped; a batch that
+// committed before its checkpoint was written is read again and counted as
+// duplicates. Lines that fail to parse or validate go to a rejects file with
+// the reason.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+)
+
+// DefaultBatchSize is the number of orders per transaction.
+const DefaultBatchSize = 5000
+
+const defaultProgress = 10 * time.Second
+
+// Store saves a batch of orders in one transaction. Orders already stored
+// are skipped; the uids of the inserted ones are returned.
+type Store interface {
+	ImportOrders(ctx context.Context, orders []models.Order) ([]string, error)
+}
+
+// Cache receives the imported orders that are recent enough to be read soon.
+type Cache interface {
+	Set(ctx context.Context, key string, value models.Order, ttl time.Duration) error
+	AddRefs(ctx context.Context, m repository.OrderRefMatch, refs []repository.OrderRef, ttl time.Duration) error
+}
+
+type Config struct {
+	// BatchSize is the number of lines per batch; 0 means DefaultBatchSize.
+	BatchSize int
+	// Checkpoint is the file progress is saved to after every batch and
+	// resumed from. Empty disables resuming.
+	Checkpoint string
+	// Rejects is the NDJSON file rejected lines are written to, one Reject
+	// per line. Empty discards them; they are still counted.
+	Rejects string
+	// Cache, when set, gets the inserted orders created at or after
+	// PrimeSince, stored for CacheTTL.
+	Cache      Cache
+	CacheTTL   time.Duration
+	PrimeSince time.Time
+	// Progress is the interval of progress logs; 0 means 10s.
+	Progress time.Duration
+	// Now is the reference time of the future date rule; nil means time.Now.
+	Now func() time.Time
+}
+
+// Stats counts the lines of a run. A resumed run continues the counts saved
+// in its checkpoint.
+type Stats struct {
+	Read      int64 `json:"read"`      // non-blank lines
+	Imported  int64 `json:"imported"`  // orders inserted
+	Duplicate int64 `json:"duplicate"` // orders already stored or repeated in the batch
+	Rejected  int64 `json:"rejected"`
+	Primed    int64 `json:"primed"` // orders put into the cache
+}
+
+// Reject is a line of the rejects file.
+type Reject struct {
+	File   string `json:"file"`
+	Line   int64  `json:"line"`
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+}
+
+type Importer struct {
+	store  Store
+	cfg    Config
+	logger *slog.Logger
+}
+
+func New(store Store, cfg Config, logger *slog.Logger) *Importer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Progress <= 0 {
+		cfg.Progress = defaultProgress
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Importer{store: store, cfg: cfg, logger: logger}
+}
+
+// Files expands paths into the files to import: a directory contributes its
+// *.ndjson and *.jsonl files in lexical order.
+func Files(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("read dir: %w", err)
+		}
+		var names []string
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".ndjson", ".jsonl":
+				if !e.IsDir() {
+					names = append(names, filepath.Join(p, e.Name()))
+				}
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+// run is the state of one Run.
+type run struct {
+	*Importer
+	cp      *checkpoint
+	rejects *os.File
+
+	batch   []models.Order
+	uids    map[string]bool
+	pending []Reject
+
+	started   time.Time
+	startRead int64
+	logged    time.Time
+}
+
+// Run imports the files of paths (see Files), resuming from the checkpoint
+// when there is one. On error the checkpoint covers every committed batch.
+func (im *Importer) Run(ctx context.Context, paths []string) (Stats, error) {
+	files, err := Files(paths)
+	if err != nil {
+		return Stats{}, err
+	}
+	cp, err := loadCheckpoint(im.cfg.Checkpoint)
+	if err != nil {
+		return Stats{}, err
+	}
+	r := &run{Importer: im, cp: cp, uids: map[string]bool{}, started: time.Now(), startRead: cp.Stats.Read}
+	r.logged = r.started
+	if im.cfg.Rejects != "" {
+		if r.rejects, err = openRejects(im.cfg.Rejects, cp.Rejects); err != nil {
+			return cp.Stats, err
+		}
+		defer r.rejects.Close()
+	}
+	if cp.Stats.Read > 0 {
+		im.logger.Info("resuming import", "checkpoint", im.cfg.Checkpoint, "read", cp.Stats.Read)
+	}
+
+	var rejectsPath string
+	if im.cfg.Rejects != "" {
+		if rejectsPath, err = filepath.Abs(im.cfg.Rejects); err != nil {
+			return cp.Stats, err
+		}
+	}
+	for _, path := range files {
+		// the rejects file is NDJSON too and may sit next to the dumps
+		if abs, _ := filepath.Abs(path); abs == rejectsPath {
+			continue
+		}
+		if err := r.importFile(ctx, path); err != nil {
+			return cp.Stats, err
+		}
+	}
+	r.progress("import finished", "")
+	return cp.Stats, nil
+}
+
+// openRejects opens the rejects file, dropping whatever was written after
+// the checkpoint.
+func openRejects(path string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open rejects: %w", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("truncate rejects: %w", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("seek rejects: %w", err)
+	}
+	return f, nil
+}
+
+func (r *run) importFile(ctx context.Context, path string) error {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	st := r.cp.Files[key]
+	if st == nil {
+		st = &fileState{}
+		r.cp.Files[key] = st
+	}
+	if st.Done {
+		r.logger.Info("skipping imported file", "file", path)
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil {
+		return err
+	} else if info.Size() < st.Offset {
+		return fmt.Errorf("%s is shorter than its checkpoint offset %d", path, st.Offset)
+	}
+	if _, err := f.Seek(st.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", path, err)
+	}
+	if st.Offset > 0 {
+		r.logger.Info("resuming file", "file", path, "line", st.Line)
+	}
+
+	// the position is only advanced in the checkpoint once the lines before
+	// it are committed
+	offset, line := st.Offset, st.Line
+	br := bufio.NewReaderSize(f, 1<<20)
+	for {
+		raw, rerr := br.ReadBytes('\n')
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return fmt.Errorf("read %s: %w", path, rerr)
+		}
+		if len(raw) > 0 {
+			offset += int64(len(raw))
+			line++
+			r.add(path, line, raw)
+		}
+		eof := rerr != nil
+		if eof || len(r.batch)+len(r.pending) >= r.cfg.BatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := r.flush(ctx, path, st, fileState{Offset: offset, Line: line, Done: eof}); err != nil {
+				return err
+			}
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// add parses and validates a line into the batch or the pending rejects.
+func (r *run) add(path string, line int64, raw []byte) {
+	b := bytes.TrimSpace(raw)
+	if len(b) == 0 {
+		return
+	}
+	r.cp.Stats.Read++
+	reject := func(reason string) {
+		r.pending = append(r.pending, Reject{File: path, Line: line, Reason: reason, Raw: string(b)})
+	}
+	var o models.Order
+	if err := json.Unmarshal(b, &o); err != nil {
+		reject("parse: " + err.Error())
+		return
+	}
+	if err := Validate(o, r.cfg.Now()); err != nil {
+		reject(err.Error())
+		return
+	}
+	if r.uids[o.OrderUID] {
+		r.cp.Stats.Duplicate++
+		return
+	}
+	r.uids[o.OrderUID] = true
+	r.batch = append(r.batch, o)
+}
+
+// flush commits the batch, then writes its rejects, then moves the
+// checkpoint of path to next. A crash in between repeats the batch, which
+// the store skips as already imported.
+func (r *run) flush(ctx context.Context, path string, st *fileState, next fileState) error {
+	if len(r.batch) > 0 {
+		inserted, err := r.store.ImportOrders(ctx, r.batch)
+		if err != nil {
+			return fmt.Errorf("import batch ending at %s:%d: %w", path, next.Line, err)
+		}
+		r.cp.Stats.Imported += int64(len(inserted))
+		r.cp.Stats.Duplicate += int64(len(r.batch) - len(inserted))
+		r.prime(ctx, inserted)
+	}
+	if len(r.pending) > 0 {
+		if err := r.writeRejects(); err != nil {
+			return err
+		}
+	}
+	*st = next
+	if err := r.cp.save(r.cfg.Checkpoint); err != nil {
+		return err
+	}
+	r.batch, r.pending = r.batch[:0], r.pending[:0]
+	clear(r.uids)
+	if time.Since(r.logged) >= r.cfg.Progress {
+		r.progress("import progress", path)
+	}
+	return nil
+}
+
+func (r *run) writeRejects() error {
+	r.cp.Stats.Rejected += int64(len(r.pending))
+	if r.rejects == nil {
+		return nil
+	}
+	w := bufio.NewWriter(r.rejects)
+	enc := json.NewEncoder(w)
+	for _, rej := range r.pending {
+		if err := enc.Encode(rej); err != nil {
+			return fmt.Errorf("write rejects: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write rejects: %w", err)
+	}
+	if err := r.rejects.Sync(); err != nil {
+		return fmt.Errorf("write rejects: %w", err)
+	}
+	size, err := r.rejects.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("write rejects: %w", err)
+	}
+	r.cp.Rejects = size
+	return nil
+}
+
+// prime caches the inserted orders of the batch created at or after
+// PrimeSince. Cache failures are logged: the orders are in the database.
+func (r *run) prime(ctx context.Context, inserted []string) {
+	if r.cfg.Cache == nil || len(inserted) == 0 {
+		return
+	}
+	isNew := make(map[string]bool, len(inserted))
+	for _, uid := range inserted {
+		isNew[uid] = true
+	}
+	for _, o := range r.batch {
+		if !isNew[o.OrderUID] || o.DateCreated.Before(r.cfg.PrimeSince) {
+			continue
+		}
+		if err := r.cfg.Cache.Set(ctx, o.OrderUID, o, r.cfg.CacheTTL); err != nil {
+			r.logger.Warn("cache set failed", "err", err, "uid", o.OrderUID)
+			continue
+		}
+		match := repository.OrderRefMatch{OrderUID: o.OrderUID, DateCreated: o.DateCreated}
+		if err := r.cfg.Cache.AddRefs(ctx, match, repository.OrderRefs(o), r.cfg.CacheTTL); err != nil {
+			r.logger.Warn("cache add refs failed", "err", err, "uid", o.OrderUID)
+		}
+		r.cp.Stats.Primed++
+	}
+}
+
+func (r *run) progress(msg, path string) {
+	r.logged = time.Now()
+	elapsed := r.logged.Sub(r.started)
+	rate := float64(r.cp.Stats.Read-r.startRead) / max(elapsed.Seconds(), 0.001)
+	s := r.cp.Stats
+	attrs := []any{"read", s.Read, "imported", s.Imported, "duplicate", s.Duplicate, "rejected", s.Rejected,
+		"elapsed", elapsed.Round(time.Second), "lines_per_sec", int64(rate)}
+	if path != "" {
+		attrs = append(attrs, "file", path)
+	}
+	if r.cfg.Cache != nil {
+		attrs = append(attrs, "primed", s.Primed)
+	}
+	r.logger.Info(msg, attrs...)
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/internal/repository"
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+)
+
+var now = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestValidate(t *testing.T) {
+	gen := fake.New(fake.WithSeed(1), fake.WithNow(now))
+	if err := Validate(gen.Order(), now); err != nil {
+		t.Fatalf("valid order: %v", err)
+	}
+	for _, d := range fake.Defects {
+		if d == fake.BadLocale {
+			continue // locales are not registered anywhere
+		}
+		err := Validate(gen.Order(d), now)
+		want := ErrRule
+		if d == fake.MissingRequired || d == fake.NoItems {
+			want = service.ErrValidation
+		}
+		if !errors.Is(err, want) {
+			t.Errorf("%s: %v", d, err)
+		}
+	}
+}
+
+type memStore struct {
+	orders map[string]models.Order
+	calls  int
+	failOn int // 1-based call that fails, 0 for none
+}
+
+func (s *memStore) ImportOrders(_ context.Context, orders []models.Order) ([]string, error) {
+	s.calls++
+	if s.calls == s.failOn {
+		return nil, errors.New("connection reset")
+	}
+	var inserted []string
+	for _, o := range orders {
+		if _, ok := s.orders[o.OrderUID]; !ok {
+			s.orders[o.OrderUID] = o
+			inserted = append(inserted, o.OrderUID)
+		}
+	}
+	return inserted, nil
+}
+
+type memCache struct{ keys []string }
+
+func (c *memCache) Set(_ context.Context, key string, _ models.Order, _ time.Duration) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *memCache) AddRefs(context.Context, repository.OrderRefMatch, []repository.OrderRef, time.Duration) error {
+	return nil
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	gen := fake.New(fake.WithSeed(2), fake.WithNow(now))
+	line := func(o models.Order) string {
+		raw, err := json.Marshal(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+	o1, o2, o4, o5 := gen.Order(), gen.Order(), gen.Order(), gen.Order()
+	o4.DateCreated = now.Add(-time.Hour)
+	o5.DateCreated = now.AddDate(0, 0, -10)
+	dump := strings.Join([]string{
+		line(o1),
+		line(o2),
+		`{"order_uid": `,
+		line(gen.Order(fake.TotalsMismatch)),
+		line(o1),
+		"",
+		line(o4),
+		line(o5),
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "orders.ndjson"), []byte(dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memStore{orders: map[string]models.Order{}, failOn: 2}
+	cache := &memCache{}
+	cfg := Config{
+		BatchSize:  2,
+		Checkpoint: filepath.Join(dir, "checkpoint.json"),
+		Rejects:    filepath.Join(dir, "rejects.ndjson"),
+		Cache:      cache,
+		PrimeSince: now.AddDate(0, 0, -2),
+		Now:        func() time.Time { return now },
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := New(store, cfg, logger).Run(context.Background(), []string{dir}); err == nil {
+		t.Fatal("run survived a failed batch")
+	}
+	if len(store.orders) != 2 {
+		t.Fatalf("after the failure: %d orders", len(store.orders))
+	}
+	// a crash after writing rejects but before the checkpoint leaves lines
+	// the resumed run must drop
+	f, err := os.OpenFile(cfg.Rejects, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"file":"orders.ndjson","line":7}` + "\n")
+	f.Close()
+
+	store.failOn = 0
+	stats, err := New(store, cfg, logger).Run(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{Read: 7, Imported: 4, Duplicate: 1, Rejected: 2, Primed: 1}
+	if stats != want {
+		t.Fatalf("stats %+v, want %+v", stats, want)
+	}
+	if len(store.orders) != 4 || !slices.Equal(cache.keys, []string{o4.OrderUID}) {
+		t.Fatalf("stored %d, cached %v", len(store.orders), cache.keys)
+	}
+
+	f, err = os.Open(cfg.Rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var rejects []Reject
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Reject
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("reject %q: %v", sc.Text(), err)
+		}
+		rejects = append(rejects, r)
+	}
+	if len(rejects) != 2 || rejects[0].Line != 3 || !strings.HasPrefix(rejects[0].Reason, "parse:") ||
+		rejects[1].Line != 4 || !strings.Contains(rejects[1].Reason, "amount") {
+		t.Fatalf("rejects %+v", rejects)
+	}
+
+	// a finished file is not read again
+	calls := store.calls
+	if _, err := New(store, cfg, logger).Run(context.Background(), []string{dir}); err != nil || store.calls != calls {
+		t.Fatalf("rerun: %v, %d store calls", err, store.calls-calls)
+	}
+}
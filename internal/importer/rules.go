@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"orderservice/internal/service"
+	"orderservice/pkg/models"
+)
+
+// ErrRule wraps business rule violations.
+var ErrRule = errors.New("business rule violated")
+
+// clockSkew is how far ahead of the importer's clock date_created may be.
+const clockSkew = 5 * time.Minute
+
+// Validate checks o the way the save path does (service.ValidateOrder) and
+// against the business rules legacy dumps are known to break.
+func Validate(o models.Order, now time.Time) error {
+	if err := service.ValidateOrder(o); err != nil {
+		return err
+	}
+	if err := checkRules(o, now); err != nil {
+		return fmt.Errorf("%w: %v", ErrRule, err)
+	}
+	return nil
+}
+
+func checkRules(o models.Order, now time.Time) error {
+	if len(o.Items) == 0 {
+		return errors.New("order has no items")
+	}
+	goods := 0
+	for i, it := range o.Items {
+		if it.TrackNumber != o.TrackNumber {
+			return fmt.Errorf("item %d track_number %q differs from the order's %q", i, it.TrackNumber, o.TrackNumber)
+		}
+		goods += it.TotalPrice
+	}
+	p := o.Payment
+	if p.GoodsTotal != goods {
+		return fmt.Errorf("goods_total %d, items total %d", p.GoodsTotal, goods)
+	}
+	if p.Amount != p.GoodsTotal+p.DeliveryCost+p.CustomFee {
+		return fmt.Errorf("amount %d, goods_total + delivery_cost + custom_fee %d", p.Amount, p.GoodsTotal+p.DeliveryCost+p.CustomFee)
+	}
+	if !isCurrency(p.Currency) {
+		return fmt.Errorf("currency %q is not an ISO 4217 code", p.Currency)
+	}
+	if _, err := mail.ParseAddress(o.Delivery.Email); err != nil {
+		return fmt.Errorf("delivery email: %v", err)
+	}
+	if o.DateCreated.After(now.Add(clockSkew)) {
+		return fmt.Errorf("date_created %s is in the future", o.DateCreated.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// isCurrency accepts three-letter codes except XXX (no currency) and XTS
+// (reserved for testing).
+func isCurrency(code string) bool {
+	if len(code) != 3 || code == "XXX" || code == "XTS" {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
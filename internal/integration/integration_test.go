@@ -3,17 +3,19 @@
 package integration
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/require"
@@ -21,14 +23,24 @@ import (
 	tcPostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
 	tcRedis "github.com/testcontainers/testcontainers-go/modules/redis"
 	"go.opentelemetry.io/otel"
+	"orderservice/internal/analytics"
+	"orderservice/internal/audit"
 	"orderservice/internal/consumer"
 	"orderservice/internal/db"
+	"orderservice/internal/export"
+	"orderservice/internal/importer"
+	"orderservice/internal/keyring"
 	"orderservice/internal/observability"
 	"orderservice/internal/producer"
+	"orderservice/internal/ratelimit"
+	"orderservice/internal/repository"
 	"orderservice/internal/repository/postgres"
 	redisrepo "orderservice/internal/repository/redis"
+	"orderservice/internal/search"
 	"orderservice/internal/service"
+	"orderservice/internal/stream"
 	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
 )
 
 func TestKafkaToPostgresFlow(t *testing.T) {
@@ -49,7 +61,6 @@ func TestKafkaToPostgresFlow(t *testing.T) {
 
 	dsn, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
 	require.NoError(t, err)
-	applyMigrations(t, dsn)
 
 	redisContainer, err := tcRedis.RunContainer(ctx)
 	require.NoError(t, err)
@@ -80,6 +91,13 @@ func TestKafkaToPostgresFlow(t *testing.T) {
 	require.NoError(t, err)
 	defer pool.Close()
 
+	migrator, err := db.NewMigrator(pool, logger)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.NoError(t, migrator.CheckVersion(ctx))
+	migrator.Close()
+
 	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr})
 	defer redisClient.Close()
 
@@ -102,71 +120,285 @@ func TestKafkaToPostgresFlow(t *testing.T) {
 	})
 	defer writer.Close()
 
-	order := randomOrder()
+	order := fake.Order()
 	require.NoError(t, producer.Publish(ctx, writer, order))
 
 	require.Eventually(t, func() bool {
 		_, err := svc.GetOrder(context.Background(), order.OrderUID)
 		return err == nil
 	}, 30*time.Second, time.Second, "order should be saved to DB and cache")
-}
 
-func applyMigrations(t *testing.T, dsn string) {
-	t.Helper()
-	dbSQL, err := sql.Open("pgx", dsn)
+	// шифрование ПДн: старая строка в открытом виде, новая — зашифрована
+	legacy := fake.Order()
+	require.NoError(t, orderRepo.SaveOrder(ctx, legacy))
+
+	// повторное сохранение (redelivery из Kafka, replay) ничего не меняет
+	// и не дублирует позиции
+	redelivered := legacy
+	redelivered.TrackNumber = "CHANGED"
+	require.ErrorIs(t, orderRepo.SaveOrder(ctx, redelivered), repository.ErrDuplicate)
+	again, err := orderRepo.GetOrder(ctx, legacy.OrderUID)
 	require.NoError(t, err)
-	defer dbSQL.Close()
+	require.Equal(t, legacy.TrackNumber, again.TrackNumber)
+	require.Len(t, again.Items, len(legacy.Items))
 
-	require.NoError(t, goose.Up(dbSQL, "migrations"))
-}
+	key := func() string {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	keys, err := keyring.Open(ctx, keyring.Keyfile{
+		Active:     "v1",
+		MasterKeys: map[string]string{"v1": key()},
+		IndexKey:   key(),
+	}, postgres.NewDataKeyStore(pool))
+	require.NoError(t, err)
+	encRepo := postgres.NewOrderRepository(pool, tracer, postgres.WithKeyring(keys))
+
+	sealed := fake.Order()
+	require.NoError(t, encRepo.SaveOrder(ctx, sealed))
+	var storedPhone string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT phone FROM deliveries WHERE order_uid=$1`, sealed.OrderUID).Scan(&storedPhone))
+	require.NotEqual(t, sealed.Delivery.Phone, storedPhone)
+
+	got, err := encRepo.GetOrder(ctx, sealed.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, sealed.Delivery, got.Delivery)
+
+	for _, o := range []models.Order{legacy, sealed} {
+		found, err := encRepo.FindOrders(ctx, repository.OrderFilter{Email: o.Delivery.Email})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, o.OrderUID, found[0].OrderUID)
+	}
+	// незашифрованная строка тоже сравнивается с нормализованным значением
+	byUpper, err := encRepo.FindOrders(ctx, repository.OrderFilter{Email: " " + strings.ToUpper(legacy.Delivery.Email)})
+	require.NoError(t, err)
+	require.Len(t, byUpper, 1)
+	require.Equal(t, legacy.OrderUID, byUpper[0].OrderUID)
+
+	n, err := encRepo.Reencrypt(ctx, 100, false)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 2) // legacy и заказ из Kafka
+	found, err := encRepo.FindOrders(ctx, repository.OrderFilter{Phone: legacy.Delivery.Phone})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, legacy.Delivery, found[0].Delivery)
+
+	// удаление по запросу субъекта: контакты затираются, платёж остаётся
+	uids, err := encRepo.EraseCustomer(ctx, sealed.CustomerID, "erased:test", repository.PrivacyRecord{
+		Kind: "erase", SubjectHash: "test", Actor: "integration", At: time.Now(),
+	})
+	require.NoError(t, err)
+	require.Contains(t, uids, sealed.OrderUID)
+	erased, err := encRepo.GetOrder(ctx, sealed.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, "erased:test", erased.CustomerID)
+	require.Equal(t, repository.Erased, erased.Delivery.Phone)
+	require.Equal(t, sealed.Payment, erased.Payment)
+	var records int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM privacy_requests WHERE $1 = ANY(order_uids)`, sealed.OrderUID).Scan(&records))
+	require.Equal(t, 1, records)
+
+	// поиск: трек-номер по префиксу, ПДн зашифрованных строк — по слепым токенам
+	searchOrders := func(repo *postgres.OrderRepository, q string) []string {
+		expr, err := search.Parse(q)
+		require.NoError(t, err)
+		hits, err := repo.SearchOrders(ctx, repository.SearchQuery{Expr: expr})
+		require.NoError(t, err)
+		var uids []string
+		for _, h := range hits {
+			uids = append(uids, h.Order.OrderUID)
+		}
+		return uids
+	}
+	require.Contains(t, searchOrders(encRepo, strings.ToLower(legacy.TrackNumber[:len(legacy.TrackNumber)-2])), legacy.OrderUID)
+	require.Contains(t, searchOrders(encRepo, `track:`+legacy.TrackNumber[2:]), legacy.OrderUID)
+	phone := search.Digits(legacy.Delivery.Phone)
+	require.Contains(t, searchOrders(encRepo, `phone:`+phone[len(phone)-4:]), legacy.OrderUID)
+	require.Contains(t, searchOrders(encRepo, `name:"`+legacy.Delivery.Name+`"`), legacy.OrderUID)
+	require.NotContains(t, searchOrders(encRepo, `name:"`+sealed.Delivery.Name+`"`), sealed.OrderUID)
+	require.NotContains(t, searchOrders(encRepo, `-uid:`+legacy.OrderUID), legacy.OrderUID)
+
+	// вторичные ключи: заказ из Kafka находится через Redis, legacy — из БД с
+	// последующим кешированием
+	byTrack, err := svc.GetOrderByTrackNumber(ctx, order.TrackNumber)
+	require.NoError(t, err)
+	require.Equal(t, order.OrderUID, byTrack.OrderUID)
+	require.EqualValues(t, 1, redisClient.Exists(ctx, "order_ref:track:"+order.TrackNumber).Val())
+	byTx, err := svc.GetOrderByTransaction(ctx, legacy.Payment.Transaction)
+	require.NoError(t, err)
+	require.Equal(t, legacy.OrderUID, byTx.OrderUID)
+	require.EqualValues(t, 1, redisClient.Exists(ctx, "order_ref:transaction:"+legacy.Payment.Transaction).Val())
+	byItem, _, err := svc.FindOrdersByItem(ctx, repository.OrderRef{Kind: repository.RefItemRid, Value: legacy.Items[0].Rid}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, byItem, 1)
+	require.Equal(t, legacy.OrderUID, byItem[0].OrderUID)
 
-func randomOrder() models.Order {
-	now := time.Now().UTC()
-	return models.Order{
-		OrderUID:          uuid.NewString(),
-		TrackNumber:       "TRK123",
-		Entry:             "WBIL",
-		Locale:            "en",
-		InternalSignature: "sig",
-		CustomerID:        "customer1",
-		DeliveryService:   "delivery",
-		ShardKey:          "1",
-		SmID:              1,
-		DateCreated:       now,
-		OofShard:          "1",
-		Delivery: models.Delivery{
-			Name:    "John",
-			Phone:   "+1234567",
-			Zip:     "000000",
-			City:    "City",
-			Address: "Street 1",
-			Region:  "Region",
-			Email:   "a@example.com",
-		},
-		Payment: models.Payment{
-			Transaction:  uuid.NewString(),
-			RequestID:    "req",
-			Currency:     "USD",
-			Provider:     "visa",
-			Amount:       100,
-			PaymentDT:    now.Unix(),
-			Bank:         "bank",
-			DeliveryCost: 10,
-			GoodsTotal:   90,
-			CustomFee:    0,
-		},
-		Items: []models.Item{{
-			ChrtID:      1,
-			TrackNumber: "TRK123",
-			Price:       100,
-			Rid:         "RID1",
-			Name:        "Item",
-			Sale:        0,
-			Size:        "M",
-			TotalPrice:  100,
-			NmID:        1,
-			Brand:       "Brand",
-			Status:      1,
-		}},
+	// аналитика: агрегаты, обновлённые при сохранении, совпадают с сырыми таблицами
+	stats := postgres.NewAnalyticsStore(pool, tracer)
+	day := legacy.DateCreated.UTC().Truncate(24 * time.Hour)
+	q := analytics.OrderQuery{From: day, To: day.Add(24 * time.Hour), Bucket: analytics.BucketTotal, GroupBy: []string{analytics.DimCurrency}}
+	require.NoError(t, q.Normalize())
+	rows, err := stats.OrderStats(ctx, q)
+	require.NoError(t, err)
+	var rawOrders, rawRevenue int64
+	require.NoError(t, pool.QueryRow(ctx, `
+        SELECT count(*), coalesce(sum(p.amount), 0) FROM orders o JOIN payments p ON p.order_uid = o.order_uid
+        WHERE o.date_created >= $1 AND o.date_created < $2 AND p.currency = $3`,
+		q.From, q.To, legacy.Payment.Currency).Scan(&rawOrders, &rawRevenue))
+	var stat analytics.OrderStats
+	for _, r := range rows {
+		if r.Currency == legacy.Payment.Currency {
+			stat = r
+		}
 	}
+	require.Equal(t, rawOrders, stat.Orders)
+	require.Equal(t, []analytics.Revenue{{Currency: legacy.Payment.Currency, Amount: rawRevenue, Orders: rawOrders}}, stat.Revenue)
+	iq := analytics.ItemQuery{From: day, To: day.Add(24 * time.Hour), By: analytics.ByItem, Currency: legacy.Payment.Currency, Limit: 100}
+	require.NoError(t, iq.Normalize())
+	top, err := stats.TopItems(ctx, iq)
+	require.NoError(t, err)
+	require.Condition(t, func() bool {
+		for _, it := range top {
+			if it.NmID == legacy.Items[0].NmID && it.Quantity >= 1 && it.Revenue >= int64(legacy.Items[0].TotalPrice) {
+				return true
+			}
+		}
+		return false
+	}, "top items miss %d", legacy.Items[0].NmID)
+
+	// выгрузка: курсор отдаёт по строке на позицию, CSV закрывается футером
+	var exported bytes.Buffer
+	ew, err := export.NewWriter(&exported, export.FormatCSV, (&export.Query{Columns: []string{"order_uid", "item_rid"}}).Selected())
+	require.NoError(t, err)
+	var legacyRows int
+	require.NoError(t, postgres.NewExportStore(pool, tracer).ExportOrders(ctx, q.From, q.To, nil, func(r *export.Row) error {
+		if r.Order.OrderUID == legacy.OrderUID {
+			legacyRows++
+		}
+		return ew.Write(r)
+	}))
+	exportSum, err := ew.Close()
+	require.NoError(t, err)
+	var rawRows int64
+	require.NoError(t, pool.QueryRow(ctx, `
+        SELECT count(*) FROM orders o LEFT JOIN items i ON i.order_uid = o.order_uid
+        WHERE o.date_created >= $1 AND o.date_created < $2`, q.From, q.To).Scan(&rawRows))
+	require.Equal(t, rawRows, exportSum.Rows)
+	require.Equal(t, len(legacy.Items), legacyRows)
+	require.True(t, strings.HasSuffix(exported.String(), "# rows="+strconv.FormatInt(rawRows, 10)+" sha256="+exportSum.SHA256+"\n"))
+
+	// массовая загрузка: пачка через COPY, уже сохранённый заказ пропускается,
+	// новый зашифрован, ищется по слепым токенам и попадает в агрегаты
+	imported := fake.Order()
+	imported.DateCreated = legacy.DateCreated
+	dumpDir := t.TempDir()
+	var dump bytes.Buffer
+	for _, o := range []models.Order{legacy, imported, fake.Order(fake.TrackMismatch)} {
+		raw, err := json.Marshal(o)
+		require.NoError(t, err)
+		dump.Write(append(raw, '\n'))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, "orders.ndjson"), dump.Bytes(), 0o600))
+	importStats, err := importer.New(encRepo, importer.Config{
+		Checkpoint: filepath.Join(dumpDir, "checkpoint.json"),
+		Rejects:    filepath.Join(dumpDir, "rejects.ndjson"),
+		Cache:      redisrepo.NewOrderCache(redisClient, tracer),
+		CacheTTL:   time.Minute,
+	}, logger).Run(ctx, []string{dumpDir})
+	require.NoError(t, err)
+	require.Equal(t, importer.Stats{Read: 3, Imported: 1, Duplicate: 1, Rejected: 1, Primed: 1}, importStats)
+	got, err = encRepo.GetOrder(ctx, imported.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, imported.Delivery, got.Delivery)
+	require.Len(t, got.Items, len(imported.Items))
+	require.EqualValues(t, 1, redisClient.Exists(ctx, "order:"+imported.OrderUID).Val())
+	require.Contains(t, searchOrders(encRepo, `name:"`+imported.Delivery.Name+`"`), imported.OrderUID)
+	require.NoError(t, pool.QueryRow(ctx, `
+        SELECT count(*), coalesce(sum(p.amount), 0) FROM orders o JOIN payments p ON p.order_uid = o.order_uid
+        WHERE o.date_created >= $1 AND o.date_created < $2 AND p.currency = $3`,
+		q.From, q.To, imported.Payment.Currency).Scan(&rawOrders, &rawRevenue))
+	rows, err = stats.OrderStats(ctx, q)
+	require.NoError(t, err)
+	stat = analytics.OrderStats{}
+	for _, r := range rows {
+		if r.Currency == imported.Payment.Currency {
+			stat = r
+		}
+	}
+	require.Equal(t, rawOrders, stat.Orders)
+	require.Equal(t, []analytics.Revenue{{Currency: imported.Payment.Currency, Amount: rawRevenue, Orders: rawOrders}}, stat.Revenue)
+
+	// повторная обработка: сообщение с начала топика проходит путь сохранения
+	// ещё раз (заказ уже сохранён — пропускается), прогресс коммитится в
+	// отдельную группу
+	replayed, err := consumer.Replay(ctx, consumer.ReplayConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: "integration-replay",
+		Since:   time.Now().Add(-time.Hour),
+	}, svc.SaveOrder, logger, tracer)
+	require.NoError(t, err)
+	require.Equal(t, 1, replayed.Read)
+	require.Equal(t, 0, replayed.Saved)
+	require.Equal(t, 1, replayed.Skipped)
+	replayLag, err := consumer.Lag(ctx, brokers, topic, "integration-replay")
+	require.NoError(t, err)
+	for _, p := range replayLag {
+		require.Zero(t, p.Lag)
+	}
+
+	// журнал аудита: запись пачкой, поиск, запрет на изменение
+	auditStore := postgres.NewAuditStore(pool, tracer)
+	require.NoError(t, auditStore.WriteEvents(ctx, []audit.Event{
+		{At: time.Now(), Actor: "alice", Action: audit.ActionRead, OrderUID: sealed.OrderUID, Source: audit.SourceHTTP, Outcome: audit.OutcomeOK},
+		{At: time.Now(), Actor: "bob", Action: audit.ActionRead, OrderUID: sealed.OrderUID, Source: audit.SourceGRPC, Outcome: audit.OutcomeDenied},
+	}))
+	events, err := auditStore.QueryEvents(ctx, audit.Filter{OrderUID: sealed.OrderUID, Outcome: audit.OutcomeDenied})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "bob", events[0].Actor)
+	_, err = pool.Exec(ctx, `DELETE FROM audit_log`)
+	require.Error(t, err)
+
+	// общий для реплик лимит: два лимитера на одном Redis делят ведро
+	limits := ratelimit.Config{Default: ratelimit.Limit{Rate: 0.1, Burst: 2}}
+	replicaA := ratelimit.New(limits, ratelimit.NewRedisStore(redisClient), logger)
+	replicaB := ratelimit.New(limits, ratelimit.NewRedisStore(redisClient), logger)
+	require.True(t, replicaA.Allow(ctx, "GetOrder", "ip:1.2.3.4").Allowed)
+	require.True(t, replicaB.Allow(ctx, "GetOrder", "ip:1.2.3.4").Allowed)
+	rejected := replicaA.Allow(ctx, "GetOrder", "ip:1.2.3.4")
+	require.False(t, rejected.Allowed)
+	require.Greater(t, rejected.RetryAfter, time.Second)
+
+	// поток заказов: событие, опубликованное одной репликой, получают обе
+	// с одинаковым id
+	hubCtx, stopHubs := context.WithCancel(ctx)
+	defer stopHubs()
+	hubA := stream.NewHub(stream.Config{}, redisClient, logger)
+	hubB := stream.NewHub(stream.Config{}, redisClient, logger)
+	go hubA.Run(hubCtx)
+	go hubB.Run(hubCtx)
+	subA, subB := hubA.Subscribe(stream.Filter{}, 0), hubB.Subscribe(stream.Filter{}, 0)
+	defer subA.Close()
+	defer subB.Close()
+	require.Eventually(t, func() bool {
+		return redisClient.PubSubNumSub(ctx, "orders:events").Val()["orders:events"] == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	hubA.Publish(stream.Summarize(order))
+	var evA, evB stream.Event
+	require.Eventually(t, func() bool {
+		select {
+		case evA = <-subA.Events():
+		default:
+		}
+		select {
+		case evB = <-subB.Events():
+		default:
+		}
+		return evA.ID != 0 && evB.ID != 0
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, evA, evB)
+	require.Equal(t, order.OrderUID, evB.Order.OrderUID)
 }
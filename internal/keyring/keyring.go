@@ -0,0 +1,224 @@
+// Package keyring implements envelope encryption for personal data at rest.
+//
+// Master keys live in a local keyfile and never touch the database. Each
+// master key version wraps one random data key (DEK); wrapped DEKs are kept in
+// a DataKeyStore and fields are sealed with AES-256-GCM under the DEK of the
+// active version. Every ciphertext is stored next to its key id, so adding a
+// new version and re-encrypting old rows rotates keys without downtime.
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const keySize = 32
+
+// Keyfile is the on-disk format:
+//
+//	active: "2025-01"
+//	master_keys:
+//	  "2024-06": <base64, 32 bytes>
+//	  "2025-01": <base64, 32 bytes>
+//	index_key: <base64, 32 bytes>
+type Keyfile struct {
+	Active     string            `yaml:"active"`
+	MasterKeys map[string]string `yaml:"master_keys"`
+	// IndexKey keys the blind index. Changing it invalidates every index
+	// value, so it is not versioned.
+	IndexKey string `yaml:"index_key"`
+}
+
+// DataKeyStore persists wrapped data keys by key id.
+type DataKeyStore interface {
+	LoadDataKeys(ctx context.Context) (map[string][]byte, error)
+	// CreateDataKey stores a wrapped key unless one with the id exists.
+	CreateDataKey(ctx context.Context, id string, wrapped []byte) error
+}
+
+// Keyring seals and opens field values. It is safe for concurrent use.
+type Keyring struct {
+	active string
+	index  []byte
+	deks   map[string]cipher.AEAD
+}
+
+// ReadKeyfile parses and validates a keyfile.
+func ReadKeyfile(path string) (Keyfile, error) {
+	var kf Keyfile
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return kf, fmt.Errorf("read keyfile: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&kf); err != nil {
+		return kf, fmt.Errorf("parse keyfile %s: %w", path, err)
+	}
+	if _, ok := kf.MasterKeys[kf.Active]; !ok || kf.Active == "" {
+		return kf, fmt.Errorf("keyfile %s: active key %q is not in master_keys", path, kf.Active)
+	}
+	return kf, nil
+}
+
+// Open builds a Keyring from kf, unwrapping stored data keys and creating the
+// data key of the active version on first use.
+func Open(ctx context.Context, kf Keyfile, store DataKeyStore) (*Keyring, error) {
+	masters := make(map[string]cipher.AEAD, len(kf.MasterKeys))
+	for id, b64 := range kf.MasterKeys {
+		aead, err := decodeKey(b64)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", id, err)
+		}
+		masters[id] = aead
+	}
+	index, err := base64.StdEncoding.DecodeString(kf.IndexKey)
+	if err != nil || len(index) < keySize {
+		return nil, errors.New("index_key must be at least 32 base64-encoded bytes")
+	}
+
+	k := &Keyring{active: kf.Active, index: index, deks: make(map[string]cipher.AEAD)}
+	wrapped, err := store.LoadDataKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load data keys: %w", err)
+	}
+	if _, ok := wrapped[kf.Active]; !ok {
+		dek := make([]byte, keySize)
+		if _, err := rand.Read(dek); err != nil {
+			return nil, err
+		}
+		w, err := seal(masters[kf.Active], dek, "dek:"+kf.Active)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.CreateDataKey(ctx, kf.Active, w); err != nil {
+			return nil, fmt.Errorf("create data key: %w", err)
+		}
+		// another replica may have won the race, so use whatever got stored
+		if wrapped, err = store.LoadDataKeys(ctx); err != nil {
+			return nil, fmt.Errorf("load data keys: %w", err)
+		}
+	}
+	for id, w := range wrapped {
+		master, ok := masters[id]
+		if !ok {
+			return nil, fmt.Errorf("data key %s is in use but its master key is missing from the keyfile", id)
+		}
+		dek, err := open(master, w, "dek:"+id)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap data key %s: %w", id, err)
+		}
+		if k.deks[id], err = newAEAD(dek); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ActiveKeyID is the key id new values are sealed with.
+func (k *Keyring) ActiveKeyID() string { return k.active }
+
+// Encrypt seals value under the active data key. aad binds the ciphertext to
+// its context (row and column) so it can't be moved elsewhere.
+func (k *Keyring) Encrypt(value, aad string) (string, error) {
+	aead := k.deks[k.active]
+	ct, err := seal(aead, []byte(value), aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// Decrypt opens a value sealed under keyID.
+func (k *Keyring) Decrypt(keyID, value, aad string) (string, error) {
+	aead, ok := k.deks[keyID]
+	if !ok {
+		return "", fmt.Errorf("unknown data key %q", keyID)
+	}
+	ct, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext: %w", err)
+	}
+	pt, err := open(aead, ct, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// BlindIndex returns a keyed hash of the normalized value for exact-match
+// lookups on encrypted columns.
+func (k *Keyring) BlindIndex(field, value string) []byte {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(field + ":" + Normalize(field, value)))
+	return mac.Sum(nil)
+}
+
+// Normalize canonicalizes values before indexing: e-mails are lower-cased,
+// phones keep only digits and a leading plus.
+func Normalize(field, value string) string {
+	value = strings.TrimSpace(value)
+	switch field {
+	case "email":
+		return strings.ToLower(value)
+	case "phone":
+		var b strings.Builder
+		for i, r := range value {
+			if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+	return value
+}
+
+func decodeKey(b64 string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("want %d bytes, got %d", keySize, len(key))
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, aad string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(aad)), nil
+}
+
+func open(aead cipher.AEAD, ct []byte, aad string) ([]byte, error) {
+	if len(ct) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	pt, err := aead.Open(nil, ct[:aead.NonceSize()], ct[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return nil, errors.New("ciphertext does not authenticate")
+	}
+	return pt, nil
+}
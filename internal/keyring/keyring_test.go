@@ -0,0 +1,110 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type memStore struct {
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func (s *memStore) LoadDataKeys(context.Context) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string][]byte, len(s.keys))
+	for k, v := range s.keys {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *memStore) CreateDataKey(_ context.Context, id string, wrapped []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		s.keys[id] = wrapped
+	}
+	return nil
+}
+
+func randKey() string {
+	b := make([]byte, keySize)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{keys: map[string][]byte{}}
+	kf := Keyfile{Active: "v1", MasterKeys: map[string]string{"v1": randKey()}, IndexKey: randKey()}
+
+	k1, err := Open(ctx, kf, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := k1.Encrypt("+972501234000", "u1/deliveries.phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k1.Decrypt("v1", ct, "u1/deliveries.email"); err == nil {
+		t.Fatalf("ciphertext opened under a different aad")
+	}
+
+	kf.MasterKeys["v2"] = randKey()
+	kf.Active = "v2"
+	k2, err := Open(ctx, kf, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.keys) != 2 || k2.ActiveKeyID() != "v2" {
+		t.Fatalf("data key for v2 not created: %d keys", len(store.keys))
+	}
+	pt, err := k2.Decrypt("v1", ct, "u1/deliveries.phone")
+	if err != nil || pt != "+972501234000" {
+		t.Fatalf("old key after rotation: %q, %v", pt, err)
+	}
+
+	delete(kf.MasterKeys, "v1")
+	if _, err := Open(ctx, kf, store); err == nil {
+		t.Fatalf("opened without the master key of a data key in use")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	kf := Keyfile{Active: "v1", MasterKeys: map[string]string{"v1": randKey()}, IndexKey: randKey()}
+	k, err := Open(context.Background(), kf, &memStore{keys: map[string][]byte{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k.BlindIndex("email", "Test@Gmail.com "), k.BlindIndex("email", "test@gmail.com")) {
+		t.Fatalf("email index is not case-insensitive")
+	}
+	if !bytes.Equal(k.BlindIndex("phone", "+972 50-123-4000"), k.BlindIndex("phone", "+972501234000")) {
+		t.Fatalf("phone index does not normalize formatting")
+	}
+	if bytes.Equal(k.BlindIndex("phone", "x"), k.BlindIndex("email", "x")) {
+		t.Fatalf("index does not separate fields")
+	}
+}
+
+func TestReadKeyfile(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "keys.yaml")
+	os.WriteFile(good, []byte("active: v1\nmaster_keys:\n  v1: "+randKey()+"\nindex_key: "+randKey()+"\n"), 0o600)
+	if _, err := ReadKeyfile(good); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(bad, []byte("active: v2\nmaster_keys:\n  v1: "+randKey()+"\n"), 0o600)
+	if _, err := ReadKeyfile(bad); err == nil {
+		t.Fatalf("active key missing from master_keys accepted")
+	}
+}
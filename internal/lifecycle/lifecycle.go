@@ -0,0 +1,249 @@
+// Package lifecycle starts the long-running components of a process in
+// dependency order, restarts them by policy and stops them in reverse order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Defaults of Component and RestartPolicy.
+const (
+	DefaultStopTimeout = 10 * time.Second
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 30 * time.Second
+)
+
+// Component is a long-running part of the process.
+type Component struct {
+	Name string
+	// Run works until ctx is cancelled and then returns nil. It calls ready
+	// once it serves, e.g. after binding its listener: the components
+	// depending on it start only then. Returning nil earlier means the
+	// component is done, which also counts as ready.
+	Run func(ctx context.Context, ready func()) error
+	// DependsOn names the components started before this one and stopped
+	// after it.
+	DependsOn []string
+	// Critical components stop the process when they fail for good.
+	Critical bool
+	Restart  RestartPolicy
+	// StopTimeout bounds the wait for Run to return on shutdown.
+	StopTimeout time.Duration
+}
+
+// RestartPolicy says how often a failed component is run again.
+type RestartPolicy struct {
+	// MaxRestarts is the number of restarts after failures: 0 never
+	// restarts, -1 always does.
+	MaxRestarts int
+	// Backoff is the first pause before a restart, doubled up to MaxBackoff.
+	// A run longer than MaxBackoff starts over from Backoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Started adapts a run function that serves as soon as it is called.
+func Started(run func(ctx context.Context) error) func(context.Context, func()) error {
+	return func(ctx context.Context, ready func()) error {
+		ready()
+		return run(ctx)
+	}
+}
+
+var restartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "lifecycle_restarts_total",
+	Help: "Component restarts after a failure.",
+}, []string{"component"})
+
+func init() {
+	prometheus.MustRegister(restartsTotal)
+}
+
+// Supervisor runs a set of components.
+type Supervisor struct {
+	components []Component
+	logger     *slog.Logger
+}
+
+func New(logger *slog.Logger) *Supervisor {
+	return &Supervisor{logger: logger}
+}
+
+// Add registers c; components are started by Run.
+func (s *Supervisor) Add(c Component) {
+	if c.StopTimeout == 0 {
+		c.StopTimeout = DefaultStopTimeout
+	}
+	if c.Restart.Backoff == 0 {
+		c.Restart.Backoff = DefaultBackoff
+	}
+	if c.Restart.MaxBackoff == 0 {
+		c.Restart.MaxBackoff = max(DefaultMaxBackoff, c.Restart.Backoff)
+	}
+	s.components = append(s.components, c)
+}
+
+// unit is a component while the supervisor runs.
+type unit struct {
+	Component
+	deps   []*unit
+	ctx    context.Context
+	cancel context.CancelFunc
+	ready  chan struct{}
+	once   sync.Once
+	done   chan struct{}
+}
+
+func (u *unit) markReady() { u.once.Do(func() { close(u.ready) }) }
+
+// Run starts the components and blocks until ctx is cancelled or a critical
+// component fails for good; it then stops them, dependants first, and
+// returns the failure.
+func (s *Supervisor) Run(ctx context.Context) error {
+	units, err := s.order()
+	if err != nil {
+		return err
+	}
+	failed := make(chan error, len(units))
+	for _, u := range units {
+		u.ctx, u.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		go s.run(u, failed)
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		s.logger.Info("shutting down")
+	case cause = <-failed:
+		s.logger.Error("critical component failed, shutting down", "err", cause)
+	}
+	for _, u := range slices.Backward(units) {
+		u.cancel()
+		t := time.NewTimer(u.StopTimeout)
+		select {
+		case <-u.done:
+		case <-t.C:
+			s.logger.Error("component did not stop in time", "component", u.Name, "timeout", u.StopTimeout)
+		}
+		t.Stop()
+	}
+	return cause
+}
+
+// run starts u once its dependencies are ready and keeps it running by its
+// restart policy.
+func (s *Supervisor) run(u *unit, failed chan<- error) {
+	defer close(u.done)
+	logger := s.logger.With("component", u.Name)
+	for _, d := range u.deps {
+		select {
+		case <-d.ready:
+		case <-d.done:
+			logger.Error("not started: dependency stopped", "dependency", d.Name)
+			return
+		case <-u.ctx.Done():
+			return
+		}
+	}
+
+	pause := u.Restart.Backoff
+	for restarts := 0; ; restarts++ {
+		logger.Info("starting")
+		started := time.Now()
+		err := s.call(u)
+		if u.ctx.Err() != nil {
+			logger.Info("stopped")
+			return
+		}
+		if err == nil {
+			logger.Info("done")
+			u.markReady()
+			return
+		}
+		if u.Restart.MaxRestarts >= 0 && restarts >= u.Restart.MaxRestarts {
+			logger.Error("failed", "err", err, "restarts", restarts)
+			if u.Critical {
+				failed <- fmt.Errorf("%s: %w", u.Name, err)
+			}
+			return
+		}
+		if time.Since(started) > u.Restart.MaxBackoff {
+			pause = u.Restart.Backoff
+		}
+		logger.Warn("failed, restarting", "err", err, "in", pause)
+		restartsTotal.WithLabelValues(u.Name).Inc()
+		select {
+		case <-time.After(pause):
+		case <-u.ctx.Done():
+			return
+		}
+		pause = min(2*pause, u.Restart.MaxBackoff)
+	}
+}
+
+// call runs u once, turning a panic into an error.
+func (s *Supervisor) call(u *unit) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return u.Run(u.ctx, u.markReady)
+}
+
+// order returns the components so that each comes after its dependencies,
+// keeping the order of Add otherwise.
+func (s *Supervisor) order() ([]*unit, error) {
+	byName := make(map[string]*unit, len(s.components))
+	for _, c := range s.components {
+		if c.Name == "" || c.Run == nil {
+			return nil, errors.New("component without a name or run function")
+		}
+		if byName[c.Name] != nil {
+			return nil, fmt.Errorf("component %s added twice", c.Name)
+		}
+		byName[c.Name] = &unit{Component: c, ready: make(chan struct{}), done: make(chan struct{})}
+	}
+	for _, u := range byName {
+		for _, d := range u.DependsOn {
+			dep := byName[d]
+			if dep == nil {
+				return nil, fmt.Errorf("component %s depends on unknown %s", u.Name, d)
+			}
+			u.deps = append(u.deps, dep)
+		}
+	}
+
+	units := make([]*unit, 0, len(byName))
+	placed := make(map[*unit]bool, len(byName))
+	for len(units) < len(byName) {
+		progress := false
+		for _, c := range s.components {
+			u := byName[c.Name]
+			if placed[u] || slices.ContainsFunc(u.deps, func(d *unit) bool { return !placed[d] }) {
+				continue
+			}
+			units = append(units, u)
+			placed[u] = true
+			progress = true
+		}
+		if !progress {
+			var cycle []string
+			for _, c := range s.components {
+				if !placed[byName[c.Name]] {
+					cycle = append(cycle, c.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among %v", cycle)
+		}
+	}
+	return units, nil
+}
@@ -0,0 +1,155 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// journal records events from several goroutines.
+type journal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *journal) add(e string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, e)
+}
+
+func (j *journal) list() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Clone(j.events)
+}
+
+// server is ready after a delay and logs start and stop.
+func server(j *journal, name string, delay time.Duration) func(context.Context, func()) error {
+	return func(ctx context.Context, ready func()) error {
+		j.add("start " + name)
+		time.Sleep(delay)
+		ready()
+		<-ctx.Done()
+		j.add("stop " + name)
+		return nil
+	}
+}
+
+func TestStartAndStopOrder(t *testing.T) {
+	j := &journal{}
+	s := New(discard)
+	// added out of order on purpose
+	s.Add(Component{Name: "http", Run: server(j, "http", 0), DependsOn: []string{"grpc"}})
+	s.Add(Component{Name: "grpc", Run: server(j, "grpc", 20*time.Millisecond), DependsOn: []string{"audit"}})
+	s.Add(Component{Name: "audit", Run: server(j, "audit", 0)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start audit", "start grpc", "start http", "stop http", "stop grpc", "stop audit"}
+	if got := j.list(); !slices.Equal(got, want) {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+}
+
+func TestCriticalFailureStopsEverything(t *testing.T) {
+	j := &journal{}
+	runs := 0
+	s := New(discard)
+	s.Add(Component{Name: "consumer", Run: server(j, "consumer", 0)})
+	s.Add(Component{
+		Name: "grpc",
+		Run: func(ctx context.Context, ready func()) error {
+			runs++
+			return errors.New("listen tcp :9090: address already in use")
+		},
+		Critical: true,
+		Restart:  RestartPolicy{MaxRestarts: 2, Backoff: time.Millisecond},
+	})
+	s.Add(Component{Name: "http", Run: server(j, "http", 0), DependsOn: []string{"grpc"}})
+
+	err := s.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "grpc: listen") {
+		t.Fatalf("err %v", err)
+	}
+	if runs != 3 {
+		t.Fatalf("grpc ran %d times, want 3", runs)
+	}
+	// http never started, the consumer was stopped
+	if got := j.list(); !slices.Equal(got, []string{"start consumer", "stop consumer"}) {
+		t.Fatalf("events %v", got)
+	}
+}
+
+func TestRestartAfterPanic(t *testing.T) {
+	runs := 0
+	s := New(discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Add(Component{
+		Name: "warmer",
+		Run: Started(func(ctx context.Context) error {
+			runs++
+			if runs == 1 {
+				panic("nil map")
+			}
+			cancel()
+			return nil
+		}),
+		Restart: RestartPolicy{MaxRestarts: -1, Backoff: time.Millisecond},
+	})
+	if err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Fatalf("ran %d times", runs)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := New(discard)
+	s.Add(Component{
+		Name:        "stuck",
+		Run:         Started(func(context.Context) error { select {} }),
+		StopTimeout: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown took %s", d)
+	}
+}
+
+func TestInvalidGraph(t *testing.T) {
+	run := Started(func(context.Context) error { return nil })
+	for name, comps := range map[string][]Component{
+		"unknown":     {{Name: "a", Run: run, DependsOn: []string{"b"}}},
+		"cycle":       {{Name: "a", Run: run, DependsOn: []string{"b"}}, {Name: "b", Run: run, DependsOn: []string{"a"}}},
+		"added twice": {{Name: "a", Run: run}, {Name: "a", Run: run}},
+	} {
+		s := New(discard)
+		for _, c := range comps {
+			s.Add(c)
+		}
+		if err := s.Run(context.Background()); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
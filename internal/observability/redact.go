@@ -0,0 +1,59 @@
+package observability
+
+import (
+	"context"
+
+	"orderservice/pkg/redact"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// redactingExporter scrubs e-mails and phone numbers from span attributes,
+// events and status before spans leave the process.
+type redactingExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (e redactingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	out := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		out[i] = redactedSpan{s}
+	}
+	return e.SpanExporter.ExportSpans(ctx, out)
+}
+
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return scrubAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Attributes = scrubAttributes(e.Attributes)
+		out[i] = e
+	}
+	return out
+}
+
+func (s redactedSpan) Status() sdktrace.Status {
+	st := s.ReadOnlySpan.Status()
+	st.Description = redact.Scrub(st.Description)
+	return st
+}
+
+func scrubAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		if a.Value.Type() == attribute.STRING {
+			a.Value = attribute.StringValue(redact.Scrub(a.Value.AsString()))
+		}
+		out[i] = a
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRedactingExporter(t *testing.T) {
+	mem := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(redactingExporter{mem}))
+	_, span := tp.Tracer("test").Start(context.Background(), "op")
+	span.SetAttributes(attribute.String("delivery.email", "test@gmail.com"), attribute.Int("items", 2))
+	span.RecordError(errors.New("bad phone +972501234000"))
+	span.End()
+
+	spans := mem.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans", len(spans))
+	}
+	if got := spans[0].Attributes[0].Value.AsString(); got != "t***@gmail.com" {
+		t.Fatalf("attribute not scrubbed: %q", got)
+	}
+	for _, a := range spans[0].Events[0].Attributes {
+		if a.Key == "exception.message" && a.Value.AsString() != "bad phone +972****000" {
+			t.Fatalf("event not scrubbed: %q", a.Value.AsString())
+		}
+	}
+}
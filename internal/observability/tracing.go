@@ -28,7 +28,7 @@ func InitTracer(ctx context.Context, serviceName, endpoint string) (*sdktrace.Tr
 		return nil, err
 	}
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
+		sdktrace.WithBatcher(redactingExporter{exp}),
 		sdktrace.WithResource(res),
 	)
 	otel.SetTracerProvider(tp)
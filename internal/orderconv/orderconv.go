@@ -1,4 +1,5 @@
-package server
+// Package orderconv maps orders between the domain model and the protobuf API.
+package orderconv
 
 import (
 	"time"
@@ -9,7 +10,8 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-func modelToProto(o models.Order) *orderpb.Order {
+// ToProto converts a domain order to its API representation.
+func ToProto(o models.Order) *orderpb.Order {
 	items := make([]*orderpb.Item, 0, len(o.Items))
 	for _, it := range o.Items {
 		items = append(items, &orderpb.Item{
@@ -44,7 +46,11 @@ func modelToProto(o models.Order) *orderpb.Order {
 	}
 }
 
-func protoToModel(o *orderpb.Order) models.Order {
+// FromProto converts an API order to the domain model.
+func FromProto(o *orderpb.Order) models.Order {
+	if o == nil {
+		return models.Order{}
+	}
 	items := make([]models.Item, 0, len(o.Items))
 	for _, it := range o.Items {
 		items = append(items, models.Item{
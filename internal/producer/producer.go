@@ -20,7 +20,7 @@ func Publish(ctx context.Context, w Writer, o models.Order) error {
 	if err != nil {
 		return err
 	}
-	msg := kafka.Message{Value: data}
+	msg := kafka.Message{Key: []byte(o.OrderUID), Value: data}
 	carrier := kafkaHeaderCarrier{headers: &msg.Headers}
 	otel.GetTextMapPropagator().Inject(ctx, carrier)
 	if reqID := observability.RequestIDFromContext(ctx); reqID != "" {
@@ -4,11 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
-	"time"
 
-	"github.com/brianvoe/gofakeit/v7"
 	"github.com/segmentio/kafka-go"
 	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
 )
 
 type fakeWriter struct{ msgs []kafka.Message }
@@ -21,7 +20,7 @@ func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) e
 func TestPublishUniqueOrders(t *testing.T) {
 	w := &fakeWriter{}
 	for i := 0; i < 3; i++ {
-		o := fakeOrder()
+		o := fake.Order()
 		if err := Publish(context.Background(), w, o); err != nil {
 			t.Fatal(err)
 		}
@@ -38,24 +37,9 @@ func TestPublishUniqueOrders(t *testing.T) {
 		if _, ok := seen[o.OrderUID]; ok {
 			t.Fatalf("duplicate uid %s", o.OrderUID)
 		}
+		if string(m.Key) != o.OrderUID {
+			t.Fatalf("message key %q, want %q", m.Key, o.OrderUID)
+		}
 		seen[o.OrderUID] = struct{}{}
 	}
 }
-
-func fakeOrder() models.Order {
-	return models.Order{
-		OrderUID:        gofakeit.UUID(),
-		TrackNumber:     "tn",
-		Entry:           "entry",
-		Locale:          "en",
-		CustomerID:      "c",
-		DeliveryService: "d",
-		ShardKey:        "1",
-		SmID:            1,
-		DateCreated:     time.Now(),
-		OofShard:        "1",
-		Items:           []models.Item{{ChrtID: 1, TrackNumber: "tn", Price: 1, Rid: "1", Name: "n"}},
-		Delivery:        models.Delivery{Name: "n", Phone: "p", Zip: "z", City: "c", Address: "a", Region: "r", Email: "e"},
-		Payment:         models.Payment{Transaction: "t", Currency: "c", Provider: "p", Amount: 1},
-	}
-}
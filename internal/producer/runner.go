@@ -0,0 +1,133 @@
+package producer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"orderservice/internal/observability"
+	"orderservice/pkg/models"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RunConfig controls how Run drains a Source.
+type RunConfig struct {
+	// Workers is the number of concurrent publishers (at least 1).
+	Workers int
+	// Rate is the target number of messages per second; 0 disables throttling.
+	Rate float64
+	// Tracer starts a span per published message; nil uses the global tracer.
+	Tracer trace.Tracer
+	// OnError is called for every failed publish.
+	OnError func(o models.Order, err error)
+}
+
+// Stats summarises a Run.
+type Stats struct {
+	Sent      int
+	Failed    int
+	Elapsed   time.Duration
+	latencies []time.Duration
+}
+
+// Percentile returns the p-th (0..100) percentile of successful publish latency.
+func (s Stats) Percentile(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	idx := int(p/100*float64(len(s.latencies))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(s.latencies) {
+		idx = len(s.latencies) - 1
+	}
+	return s.latencies[idx]
+}
+
+// Run publishes every order from src using cfg.Workers concurrent writers,
+// throttled to cfg.Rate. It returns when src is exhausted and all in-flight
+// messages are done; the error is the one returned by src, if any.
+func Run(ctx context.Context, w Writer, src Source, cfg RunConfig) (Stats, error) {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	tracer := cfg.Tracer
+	if tracer == nil {
+		tracer = otel.Tracer("orders-producer")
+	}
+
+	var (
+		mu    sync.Mutex
+		stats Stats
+		wg    sync.WaitGroup
+	)
+	jobs := make(chan models.Order)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range jobs {
+				msgCtx := observability.WithRequestID(ctx, uuid.NewString())
+				msgCtx, span := tracer.Start(msgCtx, "producer.publish")
+				span.SetAttributes(attribute.String("order_uid", o.OrderUID))
+				start := time.Now()
+				err := Publish(msgCtx, w, o)
+				took := time.Since(start)
+				if err != nil {
+					span.RecordError(err)
+				}
+				span.End()
+
+				mu.Lock()
+				if err != nil {
+					stats.Failed++
+				} else {
+					stats.Sent++
+					stats.latencies = append(stats.latencies, took)
+				}
+				mu.Unlock()
+				if err != nil && cfg.OnError != nil {
+					cfg.OnError(o, err)
+				}
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if cfg.Rate > 0 {
+		// above 1e9/s the interval rounds to 0, which NewTicker rejects
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/cfg.Rate), time.Nanosecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	start := time.Now()
+	srcErr := src(ctx, func(o models.Order) error {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case jobs <- o:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	stats.Elapsed = time.Since(start)
+	sort.Slice(stats.latencies, func(i, j int) bool { return stats.latencies[i] < stats.latencies[j] })
+	return stats, srcErr
+}
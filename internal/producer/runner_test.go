@@ -0,0 +1,100 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+)
+
+type syncWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+	fail string
+}
+
+func (w *syncWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, m := range msgs {
+		if string(m.Key) == w.fail {
+			return errors.New("broker down")
+		}
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestRunNDJSON(t *testing.T) {
+	var sb strings.Builder
+	var uids []string
+	for i := 0; i < 5; i++ {
+		o := fake.Order()
+		uids = append(uids, o.OrderUID)
+		b, _ := json.Marshal(o)
+		sb.Write(b)
+		sb.WriteString("\n\n")
+	}
+	w := &syncWriter{fail: uids[2]}
+	var failed []string
+	stats, err := Run(context.Background(), w, NDJSONSource(strings.NewReader(sb.String())), RunConfig{
+		Workers: 3,
+		OnError: func(o models.Order, err error) { failed = append(failed, o.OrderUID) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 4 || stats.Failed != 1 {
+		t.Fatalf("sent=%d failed=%d, want 4/1", stats.Sent, stats.Failed)
+	}
+	if len(failed) != 1 || failed[0] != uids[2] {
+		t.Fatalf("unexpected failures %v", failed)
+	}
+	if stats.Percentile(50) <= 0 || stats.Percentile(99) < stats.Percentile(50) {
+		t.Fatalf("bad percentiles p50=%s p99=%s", stats.Percentile(50), stats.Percentile(99))
+	}
+}
+
+func TestRunRateLimit(t *testing.T) {
+	w := &syncWriter{}
+	stats, err := Run(context.Background(), w, GeneratedSource(5, func() models.Order { return fake.Order() }), RunConfig{Workers: 2, Rate: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 5 {
+		t.Fatalf("sent %d, want 5", stats.Sent)
+	}
+	if stats.Elapsed < 40*time.Millisecond {
+		t.Fatalf("rate limit not applied: %s", stats.Elapsed)
+	}
+}
+
+func TestRunRateAboveTickerResolution(t *testing.T) {
+	w := &syncWriter{}
+	stats, err := Run(context.Background(), w, GeneratedSource(3, func() models.Order { return fake.Order() }), RunConfig{Workers: 1, Rate: 5e9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 3 {
+		t.Fatalf("sent %d, want 3", stats.Sent)
+	}
+}
+
+func TestNDJSONSourceBadLine(t *testing.T) {
+	src := NDJSONSource(strings.NewReader("{\"order_uid\":\"a\"}\nnot-json\n"))
+	n := 0
+	err := src(context.Background(), func(models.Order) error { n++; return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected line 2 parse error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("emitted %d, want 1", n)
+	}
+}
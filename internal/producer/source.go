@@ -0,0 +1,135 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"orderservice/pkg/models"
+)
+
+// Source feeds orders to emit until it is exhausted, emit fails or ctx is done.
+type Source func(ctx context.Context, emit func(models.Order) error) error
+
+// FileSource reads a single order from a JSON file.
+func FileSource(path string) Source {
+	return func(ctx context.Context, emit func(models.Order) error) error {
+		o, err := readOrderFile(path)
+		if err != nil {
+			return err
+		}
+		return emit(o)
+	}
+}
+
+// DirSource publishes every *.json (one order per file) and *.ndjson file in dir,
+// in lexical order.
+func DirSource(dir string) Source {
+	return func(ctx context.Context, emit func(models.Order) error) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("read dir: %w", err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			switch strings.ToLower(filepath.Ext(name)) {
+			case ".json":
+				err = FileSource(path)(ctx, emit)
+			case ".ndjson", ".jsonl":
+				err = NDJSONFileSource(path)(ctx, emit)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// NDJSONFileSource reads one order per line from path; "-" means stdin.
+func NDJSONFileSource(path string) Source {
+	return func(ctx context.Context, emit func(models.Order) error) error {
+		if path == "-" {
+			return NDJSONSource(os.Stdin)(ctx, emit)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open ndjson: %w", err)
+		}
+		defer f.Close()
+		return NDJSONSource(f)(ctx, emit)
+	}
+}
+
+// NDJSONSource reads one order per line from r. Blank lines are skipped.
+func NDJSONSource(r io.Reader) Source {
+	return func(ctx context.Context, emit func(models.Order) error) error {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		line := 0
+		for sc.Scan() {
+			line++
+			b := bytes.TrimSpace(sc.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var o models.Order
+			if err := json.Unmarshal(b, &o); err != nil {
+				return fmt.Errorf("parse line %d: %w", line, err)
+			}
+			if err := emit(o); err != nil {
+				return err
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("read ndjson: %w", err)
+		}
+		return nil
+	}
+}
+
+// GeneratedSource emits n orders built by gen.
+func GeneratedSource(n int, gen func() models.Order) Source {
+	return func(ctx context.Context, emit func(models.Order) error) error {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := emit(gen()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func readOrderFile(path string) (models.Order, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("read file: %w", err)
+	}
+	var o models.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		return models.Order{}, fmt.Errorf("parse json %s: %w", path, err)
+	}
+	return o, nil
+}
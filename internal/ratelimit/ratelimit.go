@@ -0,0 +1,249 @@
+// Package ratelimit implements token-bucket limits per client and route. The
+// buckets live in Redis so replicas share them; while Redis is unavailable
+// each replica falls back to local buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Limit is a token bucket: Rate tokens per second, at most Burst at once.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses "rate:burst", e.g. "50:100" or "0.5:5".
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q: want rate:burst", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: bad rate", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return Limit{}, fmt.Errorf("limit %q: bad burst", s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// Config holds the default limit and per-route overrides.
+type Config struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// ParseConfig builds a Config from a default "rate:burst" and route overrides
+// "Route=rate:burst".
+func ParseConfig(def string, routes []string) (Config, error) {
+	d, err := ParseLimit(def)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := Config{Default: d, Routes: map[string]Limit{}}
+	for _, r := range routes {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		name, limit, ok := strings.Cut(r, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("route limit %q: want Route=rate:burst", r)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return Config{}, fmt.Errorf("route %s: %w", name, err)
+		}
+		cfg.Routes[strings.TrimSpace(name)] = l
+	}
+	return cfg, nil
+}
+
+func (c Config) limit(route string) Limit {
+	if l, ok := c.Routes[route]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// Decision is the outcome of one Allow call.
+type Decision struct {
+	Allowed   bool
+	Limit     int           // bucket size
+	Remaining int           // whole tokens left
+	Reset     time.Duration // until the bucket is full again
+	// RetryAfter is how long to wait for the next token when not allowed.
+	RetryAfter time.Duration
+}
+
+// store takes one token from the bucket at key.
+type store interface {
+	take(ctx context.Context, key string, l Limit) (tokens float64, allowed bool, err error)
+}
+
+var (
+	rejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_rejected_total",
+		Help: "Calls rejected by the rate limiter, by route.",
+	}, []string{"route"})
+	fallbackTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ratelimit_fallback_total",
+		Help: "Decisions taken with local buckets because Redis was unavailable.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(rejectedTotal, fallbackTotal)
+}
+
+// fallbackBackoff is how long the limiter stays on local buckets after a
+// Redis error before trying Redis again.
+const fallbackBackoff = 5 * time.Second
+
+type Limiter struct {
+	cfg    atomic.Pointer[Config]
+	shared store // nil: local only
+	local  *localStore
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// New returns a limiter sharing buckets through shared; pass nil to keep them
+// in process.
+func New(cfg Config, shared *RedisStore, logger *slog.Logger) *Limiter {
+	l := &Limiter{local: newLocalStore(), logger: logger}
+	l.cfg.Store(&cfg)
+	if shared != nil {
+		l.shared = shared
+	}
+	return l
+}
+
+// SetConfig replaces the limits; existing buckets keep their tokens.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.cfg.Store(&cfg)
+}
+
+// Allow takes a token for client on route.
+func (l *Limiter) Allow(ctx context.Context, route, client string) Decision {
+	limit := l.cfg.Load().limit(route)
+	key := route + ":" + client
+
+	var (
+		tokens  float64
+		allowed bool
+	)
+	shared := l.shared != nil && l.redisUp()
+	if shared {
+		var err error
+		if tokens, allowed, err = l.shared.take(ctx, key, limit); err != nil {
+			l.markDown(err)
+			shared = false
+		}
+	}
+	if !shared {
+		if l.shared != nil {
+			fallbackTotal.Inc()
+		}
+		tokens, allowed, _ = l.local.take(ctx, key, limit)
+	}
+
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / limit.Rate)
+		rejectedTotal.WithLabelValues(route).Inc()
+	}
+	return d
+}
+
+func (l *Limiter) redisUp() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().After(l.downUntil)
+}
+
+func (l *Limiter) markDown(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Now().After(l.downUntil) {
+		l.logger.Warn("rate limiter falls back to local buckets", "err", err, "for", fallbackBackoff)
+	}
+	l.downUntil = time.Now().Add(fallbackBackoff)
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// localStore keeps buckets in process memory.
+type localStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func newLocalStore() *localStore {
+	return &localStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *localStore) take(_ context.Context, key string, l Limit) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), at: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.at).Seconds()*l.Rate)
+	b.at = now
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+// sweep drops buckets idle for a minute; a refilled bucket equals a new one
+// for any limit refilling within that time.
+func (s *localStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for k, b := range s.buckets {
+		if now.Sub(b.at) > time.Minute {
+			delete(s.buckets, k)
+		}
+	}
+}
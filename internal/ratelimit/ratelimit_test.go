@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig("50:100", []string{"GetOrder=0.5:5", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.limit("GetOrder") != (Limit{Rate: 0.5, Burst: 5}) || cfg.limit("ListOrders") != (Limit{Rate: 50, Burst: 100}) {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	for _, bad := range []string{"50", "0:10", "5:0", "x:1"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Fatalf("%q accepted", bad)
+		}
+	}
+	if _, err := ParseConfig("1:1", []string{"GetOrder"}); err == nil {
+		t.Fatalf("route without limit accepted")
+	}
+}
+
+func TestLocalBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newLocalStore()
+	s.now = func() time.Time { return now }
+	l := Limit{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if _, ok, _ := s.take(context.Background(), "k", l); !ok {
+			t.Fatalf("call %d rejected within burst", i)
+		}
+	}
+	if _, ok, _ := s.take(context.Background(), "k", l); ok {
+		t.Fatalf("call over burst allowed")
+	}
+	if _, ok, _ := s.take(context.Background(), "other", l); !ok {
+		t.Fatalf("buckets are not per key")
+	}
+	now = now.Add(500 * time.Millisecond) // one token at 2/s
+	if _, ok, _ := s.take(context.Background(), "k", l); !ok {
+		t.Fatalf("bucket did not refill")
+	}
+}
+
+func TestLimiterFallsBackWhenRedisIsDown(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+	cfg := Config{Default: Limit{Rate: 1, Burst: 2}}
+	l := New(cfg, NewRedisStore(client), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var last Decision
+	for i := 0; i < 3; i++ {
+		last = l.Allow(context.Background(), "GetOrder", "ip:10.0.0.1")
+	}
+	if last.Allowed || last.RetryAfter <= 0 || last.Limit != 2 || last.Remaining != 0 {
+		t.Fatalf("third call over a burst of 2: %+v", last)
+	}
+	if l.redisUp() {
+		t.Fatalf("limiter did not mark redis down")
+	}
+}
+
+func TestLimiterSetConfig(t *testing.T) {
+	l := New(Config{Default: Limit{Rate: 1, Burst: 1}}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+	if !l.Allow(ctx, "GetOrder", "a").Allowed || l.Allow(ctx, "GetOrder", "a").Allowed {
+		t.Fatal("burst of 1 not enforced")
+	}
+	l.SetConfig(Config{Default: Limit{Rate: 1, Burst: 1}, Routes: map[string]Limit{"GetOrder": {Rate: 1, Burst: 5}}})
+	if d := l.Allow(ctx, "GetOrder", "b"); !d.Allowed || d.Limit != 5 {
+		t.Fatalf("new route limit not applied: %+v", d)
+	}
+}
@@ -4,4 +4,7 @@ import "errors"
 
 var (
 	ErrNotFound = errors.New("entity not found")
+	// ErrDuplicate is returned when saving an entity that is already stored;
+	// the stored one is left as it is.
+	ErrDuplicate = errors.New("entity already exists")
 )
@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"orderservice/internal/analytics"
+	"orderservice/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rollupOrders adds newly saved orders to the daily rollups. Rows are
+// upserted in a fixed order (order rows sorted by key, then item rows sorted
+// by key), so concurrent saves and imports wait for each other instead of
+// deadlocking.
+func rollupOrders(ctx context.Context, db execer, orders []models.Order) error {
+	type orderKey struct {
+		day                                 time.Time
+		currency, provider, deliveryService string
+	}
+	type orderSum struct{ orders, items, revenue int64 }
+	type itemKey struct {
+		day             time.Time
+		currency, brand string
+		nmID            int64
+		name            string
+	}
+	type itemSum struct{ quantity, orders, revenue int64 }
+	byOrder := map[orderKey]orderSum{}
+	byItem := map[itemKey]itemSum{}
+	for _, o := range orders {
+		day := o.DateCreated.UTC().Truncate(24 * time.Hour)
+		ok := orderKey{day, o.Payment.Currency, o.Payment.Provider, o.DeliveryService}
+		s := byOrder[ok]
+		s.orders++
+		s.items += int64(len(o.Items))
+		s.revenue += int64(o.Payment.Amount)
+		byOrder[ok] = s
+
+		seen := map[itemKey]bool{}
+		for _, it := range o.Items {
+			ik := itemKey{day, o.Payment.Currency, it.Brand, it.NmID, it.Name}
+			s := byItem[ik]
+			s.quantity++
+			s.revenue += int64(it.TotalPrice)
+			if !seen[ik] {
+				seen[ik] = true
+				s.orders++
+			}
+			byItem[ik] = s
+		}
+	}
+	if len(byOrder) == 0 {
+		return nil
+	}
+
+	orderKeys := slices.SortedFunc(maps.Keys(byOrder), func(a, b orderKey) int {
+		return cmp.Or(a.day.Compare(b.day), strings.Compare(a.currency, b.currency),
+			strings.Compare(a.provider, b.provider), strings.Compare(a.deliveryService, b.deliveryService))
+	})
+	var (
+		days                                  []time.Time
+		currencies, providers, services       []string
+		orderCounts, itemCounts, orderRevenue []int64
+	)
+	for _, k := range orderKeys {
+		s := byOrder[k]
+		days, currencies = append(days, k.day), append(currencies, k.currency)
+		providers, services = append(providers, k.provider), append(services, k.deliveryService)
+		orderCounts, itemCounts, orderRevenue = append(orderCounts, s.orders), append(itemCounts, s.items), append(orderRevenue, s.revenue)
+	}
+	if _, err := db.Exec(ctx, `
+        INSERT INTO order_rollup_daily (day, currency, provider, delivery_service, orders, items, revenue)
+        SELECT t.day, t.currency, t.provider, t.delivery_service, t.orders, t.items, t.revenue
+        FROM unnest($1::date[], $2::text[], $3::text[], $4::text[], $5::bigint[], $6::bigint[], $7::bigint[])
+            WITH ORDINALITY AS t(day, currency, provider, delivery_service, orders, items, revenue, n)
+        ORDER BY t.n
+        ON CONFLICT (day, currency, provider, delivery_service) DO UPDATE SET
+            orders = order_rollup_daily.orders + EXCLUDED.orders,
+            items = order_rollup_daily.items + EXCLUDED.items,
+            revenue = order_rollup_daily.revenue + EXCLUDED.revenue`,
+		days, currencies, providers, services, orderCounts, itemCounts, orderRevenue); err != nil {
+		return fmt.Errorf("update order rollup: %w", err)
+	}
+	if len(byItem) == 0 {
+		return nil
+	}
+
+	itemKeys := slices.SortedFunc(maps.Keys(byItem), func(a, b itemKey) int {
+		return cmp.Or(a.day.Compare(b.day), strings.Compare(a.currency, b.currency),
+			strings.Compare(a.brand, b.brand), cmp.Compare(a.nmID, b.nmID), strings.Compare(a.name, b.name))
+	})
+	var (
+		itemDays                      []time.Time
+		itemCurrencies, brands, names []string
+		nmIDs, qty, orderN, revenues  []int64
+	)
+	for _, k := range itemKeys {
+		s := byItem[k]
+		itemDays, itemCurrencies = append(itemDays, k.day), append(itemCurrencies, k.currency)
+		brands, nmIDs, names = append(brands, k.brand), append(nmIDs, k.nmID), append(names, k.name)
+		qty, orderN, revenues = append(qty, s.quantity), append(orderN, s.orders), append(revenues, s.revenue)
+	}
+	if _, err := db.Exec(ctx, `
+        INSERT INTO item_rollup_daily (day, currency, brand, nm_id, name, quantity, orders, revenue)
+        SELECT t.day, t.currency, t.brand, t.nm_id, t.name, t.quantity, t.orders, t.revenue
+        FROM unnest($1::date[], $2::text[], $3::text[], $4::bigint[], $5::text[], $6::bigint[], $7::bigint[], $8::bigint[])
+            WITH ORDINALITY AS t(day, currency, brand, nm_id, name, quantity, orders, revenue, n)
+        ORDER BY t.n
+        ON CONFLICT (day, currency, brand, nm_id, name) DO UPDATE SET
+            quantity = item_rollup_daily.quantity + EXCLUDED.quantity,
+            orders = item_rollup_daily.orders + EXCLUDED.orders,
+            revenue = item_rollup_daily.revenue + EXCLUDED.revenue`,
+		itemDays, itemCurrencies, brands, nmIDs, names, qty, orderN, revenues); err != nil {
+		return fmt.Errorf("update item rollup: %w", err)
+	}
+	return nil
+}
+
+// AnalyticsStore answers analytics queries from the rollup tables.
+type AnalyticsStore struct {
+	pool   *pgxpool.Pool
+	tracer trace.Tracer
+}
+
+func NewAnalyticsStore(pool *pgxpool.Pool, tracer trace.Tracer) *AnalyticsStore {
+	return &AnalyticsStore{pool: pool, tracer: tracer}
+}
+
+var bucketExprs = map[string]string{
+	analytics.BucketDay:   "day",
+	analytics.BucketWeek:  "date_trunc('week', day)::date",
+	analytics.BucketMonth: "date_trunc('month', day)::date",
+	analytics.BucketTotal: "$1::date",
+}
+
+// OrderStats expects a normalized query.
+func (s *AnalyticsStore) OrderStats(ctx context.Context, q analytics.OrderQuery) ([]analytics.OrderStats, error) {
+	ctx, span := s.tracer.Start(ctx, "postgres.OrderStats")
+	defer span.End()
+
+	dims := map[string]string{analytics.DimCurrency: "''", analytics.DimProvider: "''", analytics.DimDeliveryService: "''"}
+	for _, d := range q.GroupBy {
+		dims[d] = d
+	}
+	// grouped dimensions come first in GROUP BY so one group's currencies are
+	// adjacent and fold into a single OrderStats
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+bucketExprs[q.Bucket]+` AS start, `+dims[analytics.DimCurrency]+`, `+dims[analytics.DimProvider]+`, `+dims[analytics.DimDeliveryService]+`,
+               currency, sum(orders)::bigint, sum(items)::bigint, sum(revenue)::bigint
+        FROM order_rollup_daily
+        WHERE day >= $1 AND day < $2
+        GROUP BY 1, 2, 3, 4, 5
+        ORDER BY 1, 2, 3, 4, 5`, q.From, q.To)
+	if err != nil {
+		return nil, fmt.Errorf("query order rollup: %w", err)
+	}
+	defer rows.Close()
+
+	var out []analytics.OrderStats
+	for rows.Next() {
+		var (
+			st  analytics.OrderStats
+			rev analytics.Revenue
+		)
+		if err := rows.Scan(&st.Start, &st.Currency, &st.Provider, &st.DeliveryService,
+			&rev.Currency, &rev.Orders, &st.Items, &rev.Amount); err != nil {
+			return nil, fmt.Errorf("scan order rollup: %w", err)
+		}
+		if n := len(out); n > 0 && out[n-1].Start.Equal(st.Start) && out[n-1].Currency == st.Currency &&
+			out[n-1].Provider == st.Provider && out[n-1].DeliveryService == st.DeliveryService {
+			last := &out[n-1]
+			last.Orders += rev.Orders
+			last.Items += st.Items
+			last.Revenue = append(last.Revenue, rev)
+			continue
+		}
+		st.Orders = rev.Orders
+		st.Revenue = []analytics.Revenue{rev}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate order rollup: %w", err)
+	}
+	span.SetAttributes(attribute.String("bucket", q.Bucket), attribute.Int("rows", len(out)))
+	return out, nil
+}
+
+// TopItems expects a normalized query.
+func (s *AnalyticsStore) TopItems(ctx context.Context, q analytics.ItemQuery) ([]analytics.ItemStats, error) {
+	ctx, span := s.tracer.Start(ctx, "postgres.TopItems")
+	defer span.End()
+
+	cols, group := "brand, 0::bigint, ''", "brand"
+	if q.By == analytics.ByItem {
+		cols, group = "brand, nm_id, name", "brand, nm_id, name"
+	}
+	args := []any{q.From, q.To, q.Limit}
+	where, revenue := "", "0::bigint"
+	if q.Currency != "" {
+		args = append(args, q.Currency)
+		where, revenue = " AND currency = $4", "sum(revenue)::bigint"
+	}
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+cols+`, sum(quantity)::bigint AS quantity, sum(orders)::bigint, `+revenue+` AS revenue
+        FROM item_rollup_daily
+        WHERE day >= $1 AND day < $2`+where+`
+        GROUP BY `+group+`
+        ORDER BY `+q.Metric+` DESC, `+group+`
+        LIMIT $3`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query item rollup: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (analytics.ItemStats, error) {
+		var it analytics.ItemStats
+		err := row.Scan(&it.Brand, &it.NmID, &it.Name, &it.Quantity, &it.Orders, &it.Revenue)
+		return it, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan item rollup: %w", err)
+	}
+	span.SetAttributes(attribute.String("by", q.By), attribute.Int("rows", len(out)))
+	return out, nil
+}
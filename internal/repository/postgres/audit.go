@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"orderservice/internal/audit"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AuditStore keeps audit events in the append-only audit_log table.
+type AuditStore struct {
+	pool   *pgxpool.Pool
+	tracer trace.Tracer
+}
+
+func NewAuditStore(pool *pgxpool.Pool, tracer trace.Tracer) *AuditStore {
+	return &AuditStore{pool: pool, tracer: tracer}
+}
+
+func (s *AuditStore) Name() string { return "postgres" }
+
+var auditColumns = []string{"at", "actor", "action", "order_uid", "request_id", "trace_id", "source", "outcome"}
+
+func (s *AuditStore) WriteEvents(ctx context.Context, events []audit.Event) error {
+	rows := make([][]any, len(events))
+	for i, e := range events {
+		rows[i] = []any{e.At, e.Actor, e.Action, e.OrderUID, e.RequestID, e.TraceID, e.Source, e.Outcome}
+	}
+	if _, err := s.pool.CopyFrom(ctx, pgx.Identifier{"audit_log"}, auditColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("copy audit events: %w", err)
+	}
+	return nil
+}
+
+func (s *AuditStore) QueryEvents(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	ctx, span := s.tracer.Start(ctx, "postgres.QueryAuditEvents")
+	defer span.End()
+
+	var (
+		conds []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	for _, c := range []struct{ col, v string }{
+		{"actor", f.Actor}, {"action", f.Action}, {"order_uid", f.OrderUID}, {"source", f.Source}, {"outcome", f.Outcome},
+	} {
+		if c.v != "" {
+			add(c.col+" = $%d", c.v)
+		}
+	}
+	if !f.From.IsZero() {
+		add("at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("at < $%d", f.To)
+	}
+	query := "SELECT id, at, actor, action, order_uid, request_id, trace_id, source, outcome FROM audit_log"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY at DESC, id DESC"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (audit.Event, error) {
+		var e audit.Event
+		err := row.Scan(&e.ID, &e.At, &e.Actor, &e.Action, &e.OrderUID, &e.RequestID, &e.TraceID, &e.Source, &e.Outcome)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan audit event: %w", err)
+	}
+	span.SetAttributes(attribute.Int("events_count", len(events)))
+	return events, nil
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"orderservice/internal/export"
+	"orderservice/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exportFetch is the number of rows fetched from the cursor at a time.
+const exportFetch = 1000
+
+// ExportStore reads exports through a server-side cursor, so neither the
+// service nor the driver holds more than exportFetch rows.
+type ExportStore struct {
+	pool   *pgxpool.Pool
+	tracer trace.Tracer
+}
+
+func NewExportStore(pool *pgxpool.Pool, tracer trace.Tracer) *ExportStore {
+	return &ExportStore{pool: pool, tracer: tracer}
+}
+
+func (s *ExportStore) ExportOrders(ctx context.Context, from, to time.Time, scopes []repository.Scope, fn func(*export.Row) error) error {
+	ctx, span := s.tracer.Start(ctx, "postgres.ExportOrders")
+	defer span.End()
+
+	// a read-only snapshot: the export is consistent however long it runs
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin export: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	args := []any{from, to}
+	where := "o.date_created >= $1 AND o.date_created < $2"
+	if len(scopes) > 0 {
+		where += " AND " + scopeCondition(scopes, "o", func(v any) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		})
+	}
+	if _, err := tx.Exec(ctx, `
+        DECLARE export_rows NO SCROLL CURSOR FOR
+        SELECT o.order_uid, o.track_number, o.entry, coalesce(o.locale, ''), o.customer_id,
+               coalesce(o.delivery_service, ''), coalesce(o.shardkey, ''), coalesce(o.sm_id, 0),
+               o.date_created, coalesce(o.oof_shard, ''),
+               coalesce(d.city, ''), coalesce(d.region, ''),
+               coalesce(p.transaction_id, ''), coalesce(p.currency, ''), coalesce(p.provider, ''),
+               coalesce(p.amount, 0), coalesce(p.payment_dt, 0), coalesce(p.bank, ''),
+               coalesce(p.delivery_cost, 0), coalesce(p.goods_total, 0), coalesce(p.custom_fee, 0),
+               coalesce(i.chrt_id, 0), coalesce(i.rid, ''), coalesce(i.nm_id, 0), coalesce(i.name, ''),
+               coalesce(i.brand, ''), coalesce(i.size, ''), coalesce(i.price, 0), coalesce(i.sale, 0),
+               coalesce(i.total_price, 0), coalesce(i.status, 0)
+        FROM orders o
+        LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+        LEFT JOIN payments p ON p.order_uid = o.order_uid
+        LEFT JOIN items i ON i.order_uid = o.order_uid
+        WHERE `+where+`
+        ORDER BY o.date_created, o.order_uid, i.id`, args...); err != nil {
+		return fmt.Errorf("declare export cursor: %w", err)
+	}
+
+	var (
+		row   export.Row
+		total int
+	)
+	o, it := &row.Order, &row.Item
+	dest := []any{
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.CustomerID,
+		&o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+		&o.Delivery.City, &o.Delivery.Region,
+		&o.Payment.Transaction, &o.Payment.Currency, &o.Payment.Provider,
+		&o.Payment.Amount, &o.Payment.PaymentDT, &o.Payment.Bank,
+		&o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
+		&it.ChrtID, &it.Rid, &it.NmID, &it.Name,
+		&it.Brand, &it.Size, &it.Price, &it.Sale,
+		&it.TotalPrice, &it.Status,
+	}
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM export_rows", exportFetch))
+		if err != nil {
+			return fmt.Errorf("fetch export rows: %w", err)
+		}
+		n := 0
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan export row: %w", err)
+			}
+			if err := fn(&row); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("fetch export rows: %w", err)
+		}
+		total += n
+		if n < exportFetch {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("rows", total))
+	return nil
+}
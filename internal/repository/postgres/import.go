@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"orderservice/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Columns copied into the staging tables of an import, named as in the
+// order tables.
+var (
+	importOrderColumns = []string{
+		"order_uid", "track_number", "entry", "locale", "internal_signature",
+		"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	}
+	importDeliveryColumns = []string{
+		"order_uid", "name", "phone", "zip", "city", "address", "region", "email",
+		"key_id", "phone_bidx", "email_bidx",
+	}
+	importPaymentColumns = []string{
+		"order_uid", "transaction_id", "request_id", "currency", "provider",
+		"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+	}
+	importItemColumns = []string{
+		"order_uid", "chrt_id", "track_number", "price", "rid",
+		"name", "sale", "size", "total_price", "nm_id", "brand", "status",
+	}
+	importSearchColumns = []string{"order_uid", "tracks", "name", "goods", "address", "phone", "blind_terms"}
+)
+
+// Staging tables live until the end of the import transaction.
+var importStaging = []string{
+	`CREATE TEMP TABLE import_orders (LIKE orders) ON COMMIT DROP`,
+	`CREATE TEMP TABLE import_deliveries (LIKE deliveries) ON COMMIT DROP`,
+	`CREATE TEMP TABLE import_payments (LIKE payments) ON COMMIT DROP`,
+	`CREATE TEMP TABLE import_items ON COMMIT DROP AS
+        SELECT ` + strings.Join(importItemColumns, ", ") + `, 0 AS n FROM items WITH NO DATA`,
+	`CREATE TEMP TABLE import_search (
+        order_uid TEXT, tracks TEXT, name TEXT, goods TEXT, address TEXT, phone TEXT, blind_terms TEXT[]
+    ) ON COMMIT DROP`,
+}
+
+// ImportOrders saves a batch of orders in one transaction: the rows are
+// COPYed into staging tables and moved from there with the same effect as
+// SaveOrder for each order, search documents and rollups included. Orders
+// already stored are skipped; the uids of the inserted ones are returned.
+// order_uid must be unique within the batch.
+func (r *OrderRepository) ImportOrders(ctx context.Context, orders []models.Order) ([]string, error) {
+	ctx, span := r.tracer.Start(ctx, "postgres.ImportOrders")
+	defer span.End()
+
+	var (
+		orderRows    = make([][]any, 0, len(orders))
+		deliveryRows = make([][]any, 0, len(orders))
+		paymentRows  = make([][]any, 0, len(orders))
+		searchRows   = make([][]any, 0, len(orders))
+		itemRows     [][]any
+	)
+	for _, o := range orders {
+		d, err := r.seal(o.OrderUID, o.Delivery)
+		if err != nil {
+			return nil, err
+		}
+		orderRows = append(orderRows, []any{
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+			o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
+		})
+		deliveryRows = append(deliveryRows, []any{
+			o.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+			d.KeyID, d.PhoneBidx, d.EmailBidx,
+		})
+		p := o.Payment
+		paymentRows = append(paymentRows, []any{
+			o.OrderUID, p.Transaction, p.RequestID, p.Currency, p.Provider,
+			p.Amount, p.PaymentDT, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee,
+		})
+		for _, it := range o.Items {
+			itemRows = append(itemRows, []any{
+				o.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.Rid,
+				it.Name, it.Sale, it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status,
+				len(itemRows),
+			})
+		}
+		doc := r.searchDoc(o)
+		searchRows = append(searchRows, []any{o.OrderUID, doc.tracks, doc.name, doc.goods, doc.address, doc.phone, doc.blind})
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range importStaging {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("create import staging: %w", err)
+		}
+	}
+	for _, c := range []struct {
+		table string
+		cols  []string
+		rows  [][]any
+	}{
+		{"import_orders", importOrderColumns, orderRows},
+		{"import_deliveries", importDeliveryColumns, deliveryRows},
+		{"import_payments", importPaymentColumns, paymentRows},
+		{"import_items", append(slices.Clone(importItemColumns), "n"), itemRows},
+		{"import_search", importSearchColumns, searchRows},
+	} {
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.cols, pgx.CopyFromRows(c.rows)); err != nil {
+			return nil, fmt.Errorf("copy %s: %w", c.table, err)
+		}
+	}
+
+	cols := strings.Join(importOrderColumns, ", ")
+	rows, err := tx.Query(ctx, `
+        INSERT INTO orders (`+cols+`)
+        SELECT `+cols+` FROM import_orders
+        ORDER BY order_uid
+        ON CONFLICT (order_uid) DO NOTHING
+        RETURNING order_uid`)
+	if err != nil {
+		return nil, fmt.Errorf("insert orders failed: %w", err)
+	}
+	inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("insert orders failed: %w", err)
+	}
+	span.SetAttributes(attribute.Int("orders", len(orders)), attribute.Int("inserted", len(inserted)))
+	if len(inserted) == 0 {
+		return nil, tx.Commit(ctx)
+	}
+
+	// the rest only for orders that were not stored before
+	for _, m := range []struct {
+		table, cols, order string
+	}{
+		{"deliveries", strings.Join(importDeliveryColumns, ", "), ""},
+		{"payments", strings.Join(importPaymentColumns, ", "), ""},
+		{"items", strings.Join(importItemColumns, ", "), "ORDER BY n"},
+	} {
+		if _, err := tx.Exec(ctx, `
+            INSERT INTO `+m.table+` (`+m.cols+`)
+            SELECT `+m.cols+` FROM import_`+m.table+`
+            JOIN unnest($1::text[]) AS fresh(order_uid) USING (order_uid) `+m.order, inserted); err != nil {
+			return nil, fmt.Errorf("insert %s failed: %w", m.table, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO order_search (order_uid, document, phone, blind_terms)
+        SELECT order_uid,
+            setweight(to_tsvector('simple', tracks), 'A') ||
+            setweight(to_tsvector('simple', name), 'B') ||
+            setweight(to_tsvector('simple', goods), 'C') ||
+            setweight(to_tsvector('simple', address), 'D'),
+            phone, blind_terms
+        FROM import_search
+        JOIN unnest($1::text[]) AS fresh(order_uid) USING (order_uid)
+        ON CONFLICT (order_uid) DO NOTHING`, inserted); err != nil {
+		return nil, fmt.Errorf("insert order_search failed: %w", err)
+	}
+
+	isNew := make(map[string]bool, len(inserted))
+	for _, uid := range inserted {
+		isNew[uid] = true
+	}
+	fresh := make([]models.Order, 0, len(inserted))
+	for _, o := range orders {
+		if isNew[o.OrderUID] {
+			fresh = append(fresh, o)
+		}
+	}
+	if err := rollupOrders(ctx, tx, fresh); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction failed: %w", err)
+	}
+	return inserted, nil
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"orderservice/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// lookupConditions select the orders with a secondary key; each is backed by
+// an index from migration 0007.
+var lookupConditions = map[string]string{
+	repository.RefTrackNumber: "o.track_number = $1",
+	repository.RefTransaction: "EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = o.order_uid AND p.transaction_id = $1)",
+	repository.RefItemRid:     "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.rid = $1)",
+	repository.RefItemChrtID:  "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.chrt_id = $1)",
+	repository.RefItemNmID:    "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.nm_id = $1)",
+}
+
+func (r *OrderRepository) LookupOrders(ctx context.Context, ref repository.OrderRef, scopes []repository.Scope, offset, limit int) ([]repository.OrderRefMatch, error) {
+	ctx, span := r.tracer.Start(ctx, "postgres.LookupOrders")
+	defer span.End()
+
+	cond, ok := lookupConditions[ref.Kind]
+	if !ok {
+		return nil, fmt.Errorf("lookup orders: unknown key %q", ref.Kind)
+	}
+	var value any = ref.Value
+	if !ref.Unique() {
+		id, err := strconv.ParseInt(ref.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("lookup orders: %s: %w", ref.Kind, err)
+		}
+		value = id
+	}
+	args := []any{value}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if len(scopes) > 0 {
+		cond += " AND " + scopeCondition(scopes, "o", arg)
+	}
+	query := `
+        SELECT o.order_uid, o.date_created FROM orders o
+        WHERE ` + cond + `
+        ORDER BY o.date_created DESC, o.order_uid LIMIT ` + arg(limit)
+	if offset > 0 {
+		query += " OFFSET " + arg(offset)
+	}
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("lookup orders: %w", err)
+	}
+	matches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (repository.OrderRefMatch, error) {
+		var m repository.OrderRefMatch
+		err := row.Scan(&m.OrderUID, &m.DateCreated)
+		return m, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan order uid: %w", err)
+	}
+	span.SetAttributes(attribute.String("ref_kind", ref.Kind), attribute.Int("orders_count", len(matches)))
+	return matches, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
+	"orderservice/internal/keyring"
 	"orderservice/internal/repository"
 	"orderservice/pkg/models"
 
@@ -17,23 +19,36 @@ import (
 type OrderRepository struct {
 	pool   *pgxpool.Pool
 	tracer trace.Tracer
+	keys   *keyring.Keyring
 }
 
-func NewOrderRepository(pool *pgxpool.Pool, tracer trace.Tracer) *OrderRepository {
-	return &OrderRepository{pool: pool, tracer: tracer}
+func NewOrderRepository(pool *pgxpool.Pool, tracer trace.Tracer, opts ...Option) *OrderRepository {
+	r := &OrderRepository{pool: pool, tracer: tracer}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+// SaveOrder stores a new order. An order_uid that is already stored is left as
+// it is and reported as repository.ErrDuplicate, so redelivered messages are
+// not duplicated.
 func (r *OrderRepository) SaveOrder(ctx context.Context, order models.Order) error {
 	ctx, span := r.tracer.Start(ctx, "postgres.SaveOrder")
 	defer span.End()
 
+	delivery, err := r.seal(order.OrderUID, order.Delivery)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction failed: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err = tx.Exec(ctx, `
+	tag, err := tx.Exec(ctx, `
         INSERT INTO orders (
             order_uid, track_number, entry, locale, internal_signature,
             customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
@@ -41,20 +56,28 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order models.Order) err
         ON CONFLICT (order_uid) DO NOTHING
     `, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.ShardKey, order.SmID, order.DateCreated, order.OofShard); err != nil {
+		order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+	if err != nil {
 		return fmt.Errorf("insert orders failed: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		// заказ уже сохранён (повторная доставка из Kafka или replay) — ничего не дублируем
+		span.SetAttributes(attribute.String("order_uid", order.OrderUID), attribute.Bool("duplicate", true))
+		return repository.ErrDuplicate
+	}
 
 	if _, err = tx.Exec(ctx, `
         INSERT INTO deliveries (
-            order_uid, name, phone, zip, city, address, region, email
-        ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+            order_uid, name, phone, zip, city, address, region, email,
+            key_id, phone_bidx, email_bidx
+        ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
         ON CONFLICT (order_uid) DO NOTHING
     `, order.OrderUID,
-		order.Delivery.Name, order.Delivery.Phone,
-		order.Delivery.Zip, order.Delivery.City,
-		order.Delivery.Address, order.Delivery.Region,
-		order.Delivery.Email); err != nil {
+		delivery.Name, delivery.Phone,
+		delivery.Zip, delivery.City,
+		delivery.Address, delivery.Region,
+		delivery.Email, delivery.KeyID,
+		delivery.PhoneBidx, delivery.EmailBidx); err != nil {
 		return fmt.Errorf("insert deliveries failed: %w", err)
 	}
 
@@ -87,6 +110,13 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order models.Order) err
 		}
 	}
 
+	if err = r.indexOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	if err = rollupOrders(ctx, tx, []models.Order{order}); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit transaction failed: %w", err)
 	}
@@ -113,14 +143,16 @@ func (r *OrderRepository) GetOrder(ctx context.Context, uid string) (models.Orde
 		return models.Order{}, fmt.Errorf("orders select: %w", err)
 	}
 
-	if err := r.pool.QueryRow(ctx, `
-        SELECT name, phone, zip, city, address, region, email
-        FROM deliveries WHERE order_uid=$1`, uid).Scan(
-		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
-		&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
-	); err != nil {
+	var delivery sealedDelivery
+	if err := scanSealed(r.pool.QueryRow(ctx, `
+        SELECT name, phone, zip, city, address, region, email, key_id
+        FROM deliveries WHERE order_uid=$1`, uid), &delivery); err != nil {
 		return models.Order{}, fmt.Errorf("deliveries select: %w", err)
 	}
+	if err := r.open(uid, &delivery); err != nil {
+		return models.Order{}, err
+	}
+	o.Delivery = delivery.Delivery
 
 	if err := r.pool.QueryRow(ctx, `
         SELECT transaction_id, request_id, currency, provider, amount, payment_dt,
@@ -156,6 +188,104 @@ func (r *OrderRepository) GetOrder(ctx context.Context, uid string) (models.Orde
 	return o, nil
 }
 
+// loadOrders reads the orders with the given uids, in that order, with one
+// query per table. Uids not found are skipped.
+func (r *OrderRepository) loadOrders(ctx context.Context, uids []string) ([]models.Order, error) {
+	rows, err := r.pool.Query(ctx, `
+        SELECT order_uid, track_number, entry, locale, internal_signature,
+               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+        FROM orders WHERE order_uid = ANY($1)`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("orders select: %w", err)
+	}
+	found, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Order, error) {
+		var o models.Order
+		err := row.Scan(&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID,
+			&o.DateCreated, &o.OofShard)
+		return o, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("orders scan: %w", err)
+	}
+	byUID := make(map[string]*models.Order, len(found))
+	for i := range found {
+		byUID[found[i].OrderUID] = &found[i]
+	}
+
+	rows, err = r.pool.Query(ctx, `
+        SELECT order_uid, name, phone, zip, city, address, region, email, key_id
+        FROM deliveries WHERE order_uid = ANY($1)`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("deliveries select: %w", err)
+	}
+	var (
+		uid      string
+		delivery sealedDelivery
+	)
+	_, err = pgx.ForEachRow(rows, []any{&uid, &delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City,
+		&delivery.Address, &delivery.Region, &delivery.Email, &delivery.KeyID}, func() error {
+		o, ok := byUID[uid]
+		if !ok {
+			return nil
+		}
+		if err := r.open(uid, &delivery); err != nil {
+			return err
+		}
+		o.Delivery = delivery.Delivery
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deliveries scan: %w", err)
+	}
+
+	rows, err = r.pool.Query(ctx, `
+        SELECT order_uid, transaction_id, request_id, currency, provider, amount, payment_dt,
+               bank, delivery_cost, goods_total, custom_fee
+        FROM payments WHERE order_uid = ANY($1)`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("payments select: %w", err)
+	}
+	var p models.Payment
+	_, err = pgx.ForEachRow(rows, []any{&uid, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider,
+		&p.Amount, &p.PaymentDT, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee}, func() error {
+		if o, ok := byUID[uid]; ok {
+			o.Payment = p
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payments scan: %w", err)
+	}
+
+	rows, err = r.pool.Query(ctx, `
+        SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size,
+               total_price, nm_id, brand, status
+        FROM items WHERE order_uid = ANY($1) ORDER BY id`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("items select: %w", err)
+	}
+	var it models.Item
+	_, err = pgx.ForEachRow(rows, []any{&uid, &it.ChrtID, &it.TrackNumber, &it.Price, &it.Rid, &it.Name,
+		&it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status}, func() error {
+		if o, ok := byUID[uid]; ok {
+			o.Items = append(o.Items, it)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("items scan: %w", err)
+	}
+
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		if o, ok := byUID[uid]; ok {
+			orders = append(orders, *o)
+		}
+	}
+	return orders, nil
+}
+
 func (r *OrderRepository) ListOrders(ctx context.Context) ([]models.Order, error) {
 	ctx, span := r.tracer.Start(ctx, "postgres.ListOrders")
 	defer span.End()
@@ -187,3 +317,96 @@ func (r *OrderRepository) ListOrders(ctx context.Context) ([]models.Order, error
 	span.SetAttributes(attribute.Int("orders_count", len(orders)))
 	return orders, nil
 }
+
+func (r *OrderRepository) FindOrders(ctx context.Context, f repository.OrderFilter) ([]models.Order, error) {
+	ctx, span := r.tracer.Start(ctx, "postgres.FindOrders")
+	defer span.End()
+
+	var (
+		conds []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.CustomerID != "" {
+		add("customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Region != "" {
+		add("EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = orders.order_uid AND d.region = $%d)", f.Region)
+	}
+	if f.Email != "" {
+		conds = append(conds, r.contactCondition("email", f.Email, &args))
+	}
+	if f.Phone != "" {
+		conds = append(conds, r.contactCondition("phone", f.Phone, &args))
+	}
+	if len(f.Scopes) > 0 {
+		conds = append(conds, scopeCondition(f.Scopes, "orders", func(v any) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		}))
+	}
+	if !f.From.IsZero() {
+		add("date_created >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("date_created < $%d", f.To)
+	}
+	query := "SELECT order_uid FROM orders"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY date_created DESC, order_uid"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find orders: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan order uid: %w", err)
+	}
+
+	orders, err := r.loadOrders(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("orders_count", len(orders)))
+	return orders, nil
+}
+
+// scopeCondition matches the rows of table in any of the scopes; arg binds a
+// value and returns its placeholder.
+func scopeCondition(scopes []repository.Scope, table string, arg func(any) string) string {
+	alts := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		var conds []string
+		if sc.CustomerID != "" {
+			conds = append(conds, table+".customer_id = "+arg(sc.CustomerID))
+		}
+		if sc.DeliveryService != "" {
+			conds = append(conds, table+".delivery_service = "+arg(sc.DeliveryService))
+		}
+		if sc.Region != "" {
+			conds = append(conds, "EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = "+table+".order_uid AND d.region = "+arg(sc.Region)+")")
+		}
+		if len(conds) == 0 {
+			return "TRUE"
+		}
+		alts = append(alts, "("+strings.Join(conds, " AND ")+")")
+	}
+	return "(" + strings.Join(alts, " OR ") + ")"
+}
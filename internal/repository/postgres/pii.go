@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"orderservice/internal/keyring"
+	"orderservice/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Option configures an OrderRepository.
+type Option func(*OrderRepository)
+
+// WithKeyring encrypts delivery name, phone, address and e-mail at rest.
+func WithKeyring(k *keyring.Keyring) Option {
+	return func(r *OrderRepository) { r.keys = k }
+}
+
+// DataKeyStore keeps wrapped data keys in the data_keys table.
+type DataKeyStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewDataKeyStore(pool *pgxpool.Pool) *DataKeyStore {
+	return &DataKeyStore{pool: pool}
+}
+
+func (s *DataKeyStore) LoadDataKeys(ctx context.Context) (map[string][]byte, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, wrapped FROM data_keys`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make(map[string][]byte)
+	for rows.Next() {
+		var id string
+		var wrapped []byte
+		if err := rows.Scan(&id, &wrapped); err != nil {
+			return nil, err
+		}
+		keys[id] = wrapped
+	}
+	return keys, rows.Err()
+}
+
+func (s *DataKeyStore) CreateDataKey(ctx context.Context, id string, wrapped []byte) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO data_keys (id, wrapped) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, id, wrapped)
+	return err
+}
+
+// sealedDelivery is a deliveries row as stored: PII columns hold ciphertext
+// when KeyID is set.
+type sealedDelivery struct {
+	models.Delivery
+	KeyID     *string
+	PhoneBidx []byte
+	EmailBidx []byte
+}
+
+// piiFields are the encrypted columns, addressed by column name.
+func piiFields(d *models.Delivery) map[string]*string {
+	return map[string]*string{"name": &d.Name, "phone": &d.Phone, "address": &d.Address, "email": &d.Email}
+}
+
+func piiAAD(uid, column string) string { return uid + "/deliveries." + column }
+
+// seal encrypts d for order uid with the active key; without a keyring d is
+// stored as is.
+func (r *OrderRepository) seal(uid string, d models.Delivery) (sealedDelivery, error) {
+	s := sealedDelivery{Delivery: d}
+	if r.keys == nil {
+		return s, nil
+	}
+	s.PhoneBidx = r.keys.BlindIndex("phone", d.Phone)
+	s.EmailBidx = r.keys.BlindIndex("email", d.Email)
+	for col, v := range piiFields(&s.Delivery) {
+		ct, err := r.keys.Encrypt(*v, piiAAD(uid, col))
+		if err != nil {
+			return s, fmt.Errorf("encrypt %s: %w", col, err)
+		}
+		*v = ct
+	}
+	keyID := r.keys.ActiveKeyID()
+	s.KeyID = &keyID
+	return s, nil
+}
+
+// open decrypts a stored delivery in place.
+func (r *OrderRepository) open(uid string, s *sealedDelivery) error {
+	if s.KeyID == nil {
+		return nil
+	}
+	if r.keys == nil {
+		return fmt.Errorf("delivery of %s is encrypted with key %s but no keyring is configured", uid, *s.KeyID)
+	}
+	for col, v := range piiFields(&s.Delivery) {
+		pt, err := r.keys.Decrypt(*s.KeyID, *v, piiAAD(uid, col))
+		if err != nil {
+			return fmt.Errorf("decrypt %s: %w", col, err)
+		}
+		*v = pt
+	}
+	return nil
+}
+
+// normalizedContacts are keyring.Normalize in SQL, for the contact columns of
+// rows not yet encrypted.
+var normalizedContacts = map[string]string{
+	"email": "lower(btrim(d.email))",
+	"phone": "(CASE WHEN left(btrim(d.phone), 1) = '+' THEN '+' ELSE '' END || regexp_replace(d.phone, '[^0-9]', '', 'g'))",
+}
+
+// contactCondition matches orders by exact e-mail or phone: through the blind
+// index for encrypted rows and by value for rows not yet encrypted. Both
+// sides are normalized like the blind index, so the paths agree.
+func (r *OrderRepository) contactCondition(column, value string, args *[]any) string {
+	plain := normalizedContacts[column]
+	if r.keys == nil {
+		*args = append(*args, keyring.Normalize(column, value))
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = orders.order_uid AND %s = $%d)", plain, len(*args))
+	}
+	*args = append(*args, r.keys.BlindIndex(column, value), keyring.Normalize(column, value))
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = orders.order_uid AND (d.%s_bidx = $%d OR (d.key_id IS NULL AND %s = $%d)))",
+		column, len(*args)-1, plain, len(*args))
+}
+
+func scanSealed(row pgx.Row, s *sealedDelivery) error {
+	return row.Scan(&s.Name, &s.Phone, &s.Zip, &s.City, &s.Address, &s.Region, &s.Email, &s.KeyID)
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"orderservice/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func (r *OrderRepository) EraseCustomer(ctx context.Context, customerID, pseudonym string, rec repository.PrivacyRecord) ([]string, error) {
+	ctx, span := r.tracer.Start(ctx, "postgres.EraseCustomer")
+	defer span.End()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        UPDATE orders SET customer_id = $2 WHERE customer_id = $1
+        RETURNING order_uid`, customerID, pseudonym)
+	if err != nil {
+		return nil, fmt.Errorf("update orders: %w", err)
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("update orders: %w", err)
+	}
+
+	// город и регион оставляем: без остальных полей они не указывают на человека,
+	// а нужны для отчётности по доставке
+	if _, err := tx.Exec(ctx, `
+        UPDATE deliveries SET name=$2, phone=$2, zip=$2, address=$2, email=$2,
+               key_id=NULL, phone_bidx=NULL, email_bidx=NULL
+        WHERE order_uid = ANY($1)`, uids, repository.Erased); err != nil {
+		return nil, fmt.Errorf("update deliveries: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE order_search s SET
+            document = ts_filter(s.document, '{a,c}') ||
+                setweight(to_tsvector('simple', concat_ws(' ', d.city, d.region)), 'D'),
+            phone = NULL, blind_terms = '{}', pii_indexed = true
+        FROM deliveries d
+        WHERE d.order_uid = s.order_uid AND s.order_uid = ANY($1)`, uids); err != nil {
+		return nil, fmt.Errorf("update order_search: %w", err)
+	}
+
+	rec.OrderUIDs = uids
+	if err := insertPrivacyRecord(ctx, tx, rec); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction failed: %w", err)
+	}
+	span.SetAttributes(attribute.Int("orders_count", len(uids)))
+	return uids, nil
+}
+
+func (r *OrderRepository) RecordPrivacyRequest(ctx context.Context, rec repository.PrivacyRecord) error {
+	return insertPrivacyRecord(ctx, r.pool, rec)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertPrivacyRecord(ctx context.Context, db execer, rec repository.PrivacyRecord) error {
+	uids := rec.OrderUIDs
+	if uids == nil {
+		uids = []string{}
+	}
+	if _, err := db.Exec(ctx, `
+        INSERT INTO privacy_requests (kind, subject_hash, actor, request_id, order_uids, created_at)
+        VALUES ($1,$2,$3,$4,$5,$6)`,
+		rec.Kind, rec.SubjectHash, rec.Actor, rec.RequestID, uids, rec.At); err != nil {
+		return fmt.Errorf("insert privacy request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var reencryptedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "pii_reencrypted_rows_total",
+	Help: "Delivery rows moved to the active encryption key.",
+})
+
+func init() {
+	prometheus.MustRegister(reencryptedCounter)
+}
+
+// Reencrypt moves up to batch delivery rows that are plaintext or sealed with
+// an older key to the active key and returns how many it rewrote. With
+// decrypt set it instead turns encrypted rows back into plaintext, which is
+// needed before rolling the encryption migration back.
+func (r *OrderRepository) Reencrypt(ctx context.Context, batch int, decrypt bool) (int, error) {
+	if r.keys == nil {
+		return 0, errors.New("encryption is not configured")
+	}
+	ctx, span := r.tracer.Start(ctx, "postgres.Reencrypt")
+	defer span.End()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cond, args := "key_id IS DISTINCT FROM $2", []any{batch, r.keys.ActiveKeyID()}
+	if decrypt {
+		cond, args = "key_id IS NOT NULL", []any{batch}
+	}
+	// rows encrypted before order_search existed still lack their blind tokens
+	cond = "(" + cond + ` OR EXISTS (SELECT 1 FROM order_search s
+            WHERE s.order_uid = deliveries.order_uid AND NOT s.pii_indexed))`
+	rows, err := tx.Query(ctx, `
+        SELECT order_uid, name, phone, zip, city, address, region, email, key_id
+        FROM deliveries WHERE `+cond+`
+        ORDER BY order_uid LIMIT $1 FOR UPDATE SKIP LOCKED`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("select deliveries: %w", err)
+	}
+	type row struct {
+		uid string
+		d   sealedDelivery
+	}
+	var pending []row
+	for rows.Next() {
+		var x row
+		if err := rows.Scan(&x.uid, &x.d.Name, &x.d.Phone, &x.d.Zip, &x.d.City, &x.d.Address, &x.d.Region, &x.d.Email, &x.d.KeyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan delivery: %w", err)
+		}
+		pending = append(pending, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate deliveries: %w", err)
+	}
+
+	for _, x := range pending {
+		if err := r.open(x.uid, &x.d); err != nil {
+			return 0, err
+		}
+		next := sealedDelivery{Delivery: x.d.Delivery}
+		if !decrypt {
+			if next, err = r.seal(x.uid, x.d.Delivery); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec(ctx, `
+            UPDATE deliveries SET name=$2, phone=$3, address=$4, email=$5,
+                   key_id=$6, phone_bidx=$7, email_bidx=$8
+            WHERE order_uid=$1`,
+			x.uid, next.Name, next.Phone, next.Address, next.Email,
+			next.KeyID, next.PhoneBidx, next.EmailBidx); err != nil {
+			return 0, fmt.Errorf("update delivery %s: %w", x.uid, err)
+		}
+		if err := r.reindexPII(ctx, tx, x.uid, x.d.Delivery, !decrypt); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit transaction failed: %w", err)
+	}
+	reencryptedCounter.Add(float64(len(pending)))
+	span.SetAttributes(attribute.Int("rows", len(pending)))
+	return len(pending), nil
+}
+
+// RunReencryption re-encrypts stale rows in batches every interval until ctx
+// is done.
+func (r *OrderRepository) RunReencryption(ctx context.Context, interval time.Duration, batch int, logger *slog.Logger) error {
+	if r.keys == nil || interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			total := 0
+			for ctx.Err() == nil {
+				n, err := r.Reencrypt(ctx, batch, false)
+				if err != nil {
+					logger.Error("reencrypt", "err", err)
+					break
+				}
+				total += n
+				if n < batch {
+					break
+				}
+			}
+			if total > 0 {
+				logger.Info("reencrypt", "rows", total, "key_id", r.keys.ActiveKeyID())
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package postgres
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"orderservice/internal/repository"
+	"orderservice/internal/search"
+	"orderservice/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Weights of the field groups in order_search.document.
+var textWeights = map[string]string{
+	search.FieldTrack:   "A",
+	search.FieldName:    "B",
+	search.FieldItem:    "C",
+	search.FieldAddress: "D",
+}
+
+// Blind index fields of the searchable personal data.
+const (
+	blindNameWord    = "name_word"
+	blindAddressWord = "address_word"
+	blindPhoneGram   = "phone_gram"
+)
+
+// searchPII is the part of the search document made of delivery personal
+// data: plain text while the row is not encrypted, blind tokens otherwise.
+type searchPII struct {
+	name, address string
+	phone         *string
+	blind         []string
+}
+
+func (r *OrderRepository) searchPII(d models.Delivery, sealed bool) searchPII {
+	clean := func(v string) string {
+		if v == repository.Erased {
+			return ""
+		}
+		return v
+	}
+	name, address, phone := clean(d.Name), clean(d.Address), search.Digits(clean(d.Phone))
+	if !sealed {
+		p := searchPII{name: name, address: address}
+		if phone != "" {
+			p.phone = &phone
+		}
+		return p
+	}
+	p := searchPII{blind: []string{}}
+	for _, w := range search.Words(name) {
+		p.blind = append(p.blind, r.blindToken(blindNameWord, w))
+	}
+	for _, w := range search.Words(address) {
+		p.blind = append(p.blind, r.blindToken(blindAddressWord, w))
+	}
+	// every fragment of at least MinPhoneDigits digits, so partial numbers match
+	for n := search.MinPhoneDigits; n <= len(phone); n++ {
+		for i := 0; i+n <= len(phone); i++ {
+			p.blind = append(p.blind, r.blindToken(blindPhoneGram, phone[i:i+n]))
+		}
+	}
+	slices.Sort(p.blind)
+	p.blind = slices.Compact(p.blind)
+	return p
+}
+
+func (r *OrderRepository) blindToken(field, value string) string {
+	return hex.EncodeToString(r.keys.BlindIndex(field, value)[:16])
+}
+
+// searchDoc holds the parts of an order's search document, one per weight.
+type searchDoc struct {
+	tracks, name, goods, address string
+	phone                        *string
+	blind                        []string
+}
+
+func (r *OrderRepository) searchDoc(o models.Order) searchDoc {
+	tracks := []string{o.TrackNumber}
+	var goods []string
+	for _, it := range o.Items {
+		if !slices.Contains(tracks, it.TrackNumber) {
+			tracks = append(tracks, it.TrackNumber)
+		}
+		goods = append(goods, it.Name, it.Brand)
+	}
+	pii := r.searchPII(o.Delivery, r.keys != nil)
+	if pii.blind == nil {
+		pii.blind = []string{}
+	}
+	return searchDoc{
+		tracks:  strings.Join(tracks, " "),
+		name:    pii.name,
+		goods:   strings.Join(goods, " "),
+		address: strings.Join([]string{o.Delivery.City, o.Delivery.Region, pii.address}, " "),
+		phone:   pii.phone,
+		blind:   pii.blind,
+	}
+}
+
+// indexOrder writes the search document of a newly saved order.
+func (r *OrderRepository) indexOrder(ctx context.Context, db execer, o models.Order) error {
+	doc := r.searchDoc(o)
+	_, err := db.Exec(ctx, `
+        INSERT INTO order_search (order_uid, document, phone, blind_terms)
+        VALUES ($1,
+            setweight(to_tsvector('simple', $2), 'A') ||
+            setweight(to_tsvector('simple', $3), 'B') ||
+            setweight(to_tsvector('simple', $4), 'C') ||
+            setweight(to_tsvector('simple', $5), 'D'),
+            $6, $7)
+        ON CONFLICT (order_uid) DO NOTHING`,
+		o.OrderUID, doc.tracks, doc.name, doc.goods, doc.address, doc.phone, doc.blind)
+	if err != nil {
+		return fmt.Errorf("insert order_search failed: %w", err)
+	}
+	return nil
+}
+
+// reindexPII replaces the personal-data part of a search document after the
+// delivery row was encrypted, decrypted or erased.
+func (r *OrderRepository) reindexPII(ctx context.Context, db execer, uid string, d models.Delivery, sealed bool) error {
+	pii := r.searchPII(d, sealed)
+	if pii.blind == nil {
+		pii.blind = []string{}
+	}
+	_, err := db.Exec(ctx, `
+        UPDATE order_search SET
+            document = ts_filter(document, '{a,c}') ||
+                setweight(to_tsvector('simple', $2), 'B') ||
+                setweight(to_tsvector('simple', $3), 'D'),
+            phone = $4, blind_terms = $5, pii_indexed = true
+        WHERE order_uid = $1`,
+		uid, pii.name, strings.Join([]string{d.City, d.Region, pii.address}, " "), pii.phone, pii.blind)
+	if err != nil {
+		return fmt.Errorf("update order_search %s: %w", uid, err)
+	}
+	return nil
+}
+
+// SearchOrders runs a parsed query over order_search: text terms through the
+// tsvector (prefix matches, ranked with ts_rank_cd) or the blind tokens of
+// encrypted rows, phone and track number fragments through trigram indexes,
+// the other fields on the order tables.
+func (r *OrderRepository) SearchOrders(ctx context.Context, q repository.SearchQuery) ([]repository.SearchHit, error) {
+	ctx, span := r.tracer.Start(ctx, "postgres.SearchOrders")
+	defer span.End()
+
+	b := &searchBuilder{r: r, text: q.TextFields}
+	if b.text == nil {
+		b.text = search.TextFields
+	}
+	cond, err := b.expr(q.Expr, false)
+	if err != nil {
+		return nil, err
+	}
+	conds := []string{cond}
+	f := q.Scope
+	if f.CustomerID != "" {
+		conds = append(conds, "o.customer_id = "+b.arg(f.CustomerID))
+	}
+	if f.DeliveryService != "" {
+		conds = append(conds, "o.delivery_service = "+b.arg(f.DeliveryService))
+	}
+	if f.Region != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = o.order_uid AND d.region = "+b.arg(f.Region)+")")
+	}
+	if len(f.Scopes) > 0 {
+		conds = append(conds, scopeCondition(f.Scopes, "o", b.arg))
+	}
+
+	rank := "0::real"
+	if len(b.rank) > 0 {
+		rank = "coalesce(ts_rank_cd(s.document, to_tsquery('simple', " + b.arg(strings.Join(b.rank, " | ")) + ")), 0)"
+	}
+	query := `SELECT o.order_uid, ` + rank + ` AS rank
+        FROM orders o LEFT JOIN order_search s ON s.order_uid = o.order_uid
+        WHERE ` + strings.Join(conds, " AND ") + `
+        ORDER BY rank DESC, o.date_created DESC, o.order_uid`
+	if f.Limit > 0 {
+		query += " LIMIT " + b.arg(f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET " + b.arg(f.Offset)
+	}
+
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+	type found struct {
+		uid  string
+		rank float32
+	}
+	matches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (found, error) {
+		var m found
+		err := row.Scan(&m.uid, &m.rank)
+		return m, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan search hit: %w", err)
+	}
+
+	uids := make([]string, len(matches))
+	for i, m := range matches {
+		uids[i] = m.uid
+	}
+	orders, err := r.loadOrders(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+	ranks := make(map[string]float32, len(matches))
+	for _, m := range matches {
+		ranks[m.uid] = m.rank
+	}
+	hits := make([]repository.SearchHit, len(orders))
+	for i, o := range orders {
+		hits[i] = repository.SearchHit{Order: o, Rank: float64(ranks[o.OrderUID])}
+	}
+	span.SetAttributes(attribute.StringSlice("fields", search.Fields(q.Expr)), attribute.Int("orders_count", len(hits)))
+	return hits, nil
+}
+
+type searchBuilder struct {
+	r    *OrderRepository
+	text []string // fields free text may match
+	args []any
+	rank []string // tsqueries of the positive text terms
+}
+
+func (b *searchBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *searchBuilder) expr(e search.Expr, negated bool) (string, error) {
+	switch e := e.(type) {
+	case search.And:
+		return b.join(e.Args, " AND ", negated)
+	case search.Or:
+		return b.join(e.Args, " OR ", negated)
+	case search.Not:
+		c, err := b.expr(e.Arg, !negated)
+		if err != nil {
+			return "", err
+		}
+		// NULL (no search row) must not turn NOT into a match-nothing
+		return "NOT coalesce(" + c + ", false)", nil
+	case search.Term:
+		return b.term(e, negated)
+	case search.Range:
+		return b.intRange(e), nil
+	case search.Period:
+		var conds []string
+		if !e.From.IsZero() {
+			conds = append(conds, "o.date_created >= "+b.arg(e.From))
+		}
+		if !e.To.IsZero() {
+			conds = append(conds, "o.date_created < "+b.arg(e.To))
+		}
+		return "(" + strings.Join(conds, " AND ") + ")", nil
+	}
+	return "", fmt.Errorf("search: unexpected %T", e)
+}
+
+func (b *searchBuilder) join(args []search.Expr, op string, negated bool) (string, error) {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		c, err := b.expr(a, negated)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = c
+	}
+	return "(" + strings.Join(parts, op) + ")", nil
+}
+
+func (b *searchBuilder) term(t search.Term, negated bool) (string, error) {
+	switch t.Field {
+	case search.FieldText:
+		return b.words(t, b.text, negated), nil
+	case search.FieldName, search.FieldAddress, search.FieldItem:
+		return b.words(t, []string{t.Field}, negated), nil
+	case search.FieldPhone:
+		cond := "s.phone LIKE " + b.arg("%"+t.Value+"%")
+		if b.r.keys != nil {
+			cond = "(" + cond + " OR s.blind_terms @> ARRAY[" + b.arg(b.r.blindToken(blindPhoneGram, t.Value)) + "])"
+		}
+		return cond, nil
+	case search.FieldTrack:
+		return "o.track_number ILIKE " + b.arg("%"+escapeLike(t.Value)+"%"), nil
+	case search.FieldBrand:
+		return "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND lower(i.brand) = lower(" + b.arg(t.Value) + "))", nil
+	case search.FieldCity, search.FieldRegion:
+		return "EXISTS (SELECT 1 FROM deliveries d WHERE d.order_uid = o.order_uid AND lower(d." + t.Field + ") = lower(" + b.arg(t.Value) + "))", nil
+	case search.FieldCustomer:
+		return "o.customer_id = " + b.arg(t.Value), nil
+	case search.FieldService:
+		return "o.delivery_service = " + b.arg(t.Value), nil
+	case search.FieldUID:
+		return "o.order_uid = " + b.arg(t.Value), nil
+	}
+	return "", fmt.Errorf("search: field %q is not a term", t.Field)
+}
+
+// words matches the words of t in the given field groups: as a phrase for a
+// quoted value, otherwise every word with the last one as a prefix. On
+// encrypted rows names and addresses match whole words via blind tokens.
+func (b *searchBuilder) words(t search.Term, groups []string, negated bool) string {
+	var labels string
+	for _, g := range groups {
+		labels += textWeights[g]
+	}
+	if labels == "" {
+		return "false"
+	}
+	words := search.Words(t.Value)
+	lexemes := make([]string, len(words))
+	for i, w := range words {
+		// words are letters and digits only, safe inside tsquery syntax
+		lexemes[i] = w + ":" + labels
+		if !t.Phrase && i == len(words)-1 {
+			lexemes[i] = w + ":*" + labels
+		}
+	}
+	sep := " & "
+	if t.Phrase {
+		sep = " <-> "
+	}
+	tsq := "(" + strings.Join(lexemes, sep) + ")"
+	if !negated {
+		b.rank = append(b.rank, tsq)
+	}
+	conds := []string{"s.document @@ to_tsquery('simple', " + b.arg(tsq) + ")"}
+
+	if b.r.keys != nil {
+		for _, g := range groups {
+			field := map[string]string{search.FieldName: blindNameWord, search.FieldAddress: blindAddressWord}[g]
+			if field == "" {
+				continue
+			}
+			tokens := make([]string, len(words))
+			for i, w := range words {
+				tokens[i] = b.r.blindToken(field, w)
+			}
+			conds = append(conds, "s.blind_terms @> "+b.arg(tokens))
+		}
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+func (b *searchBuilder) intRange(r search.Range) string {
+	var bounds []string
+	column := "p.amount"
+	if r.Field == search.FieldItems {
+		column = "(SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid)"
+	}
+	if r.Min != nil {
+		bounds = append(bounds, column+" >= "+b.arg(*r.Min))
+	}
+	if r.Max != nil {
+		bounds = append(bounds, column+" <= "+b.arg(*r.Max))
+	}
+	cond := strings.Join(bounds, " AND ")
+	if r.Field == search.FieldAmount {
+		return "EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = o.order_uid AND " + cond + ")"
+	}
+	return "(" + cond + ")"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
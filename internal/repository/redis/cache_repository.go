@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"orderservice/internal/repository"
@@ -15,6 +16,30 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// keyPrefix отделяет заказы от прочих ключей в Redis.
+const keyPrefix = "order:"
+
+func orderKey(key string) string { return keyPrefix + key }
+
+// refPrefix не совпадает с шаблоном keyPrefix+"*": сверка кеша и Scan не
+// видят вторичные ключи.
+const refPrefix = "order_ref:"
+
+func refKey(ref repository.OrderRef) string { return refPrefix + ref.String() }
+
+// addRefsScript добавляет uid (ARGV[2]) со временем создания (ARGV[1]) в каждый
+// уже закешированный или уникальный (ARGV[3+i] == "1") вторичный ключ и
+// продлевает его TTL (ARGV[3], мс).
+var addRefsScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+  if ARGV[3 + i] == '1' or redis.call('EXISTS', key) == 1 then
+    redis.call('ZADD', key, ARGV[1], ARGV[2])
+    redis.call('PEXPIRE', key, ARGV[3])
+  end
+end
+return 0
+`)
+
 type OrderCache struct {
 	client *redis.Client
 	tracer trace.Tracer
@@ -28,7 +53,7 @@ func (c *OrderCache) Get(ctx context.Context, key string) (models.Order, bool, e
 	ctx, span := c.tracer.Start(ctx, "redis.GetOrder")
 	defer span.End()
 
-	raw, err := c.client.Get(ctx, key).Bytes()
+	raw, err := c.client.Get(ctx, orderKey(key)).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return models.Order{}, false, nil
@@ -51,9 +76,108 @@ func (c *OrderCache) Set(ctx context.Context, key string, value models.Order, tt
 	if err != nil {
 		return fmt.Errorf("marshal cache: %w", err)
 	}
-	if err := c.client.Set(ctx, key, raw, ttl).Err(); err != nil {
+	if err := c.client.Set(ctx, orderKey(key), raw, ttl).Err(); err != nil {
 		return fmt.Errorf("redis set: %w", err)
 	}
 	span.SetAttributes(attribute.String("order_uid", value.OrderUID))
 	return nil
 }
+
+func (c *OrderCache) Delete(ctx context.Context, keys ...string) error {
+	ctx, span := c.tracer.Start(ctx, "redis.DeleteOrders")
+	defer span.End()
+
+	if len(keys) == 0 {
+		return nil
+	}
+	full := make([]string, len(keys))
+	for i, k := range keys {
+		full[i] = orderKey(k)
+	}
+	if err := c.client.Del(ctx, full...).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", err)
+	}
+	span.SetAttributes(attribute.Int("keys", len(keys)))
+	return nil
+}
+
+func (c *OrderCache) Scan(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	ctx, span := c.tracer.Start(ctx, "redis.ScanOrders")
+	defer span.End()
+
+	keys, next, err := c.client.Scan(ctx, cursor, keyPrefix+"*", count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("redis scan: %w", err)
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, keyPrefix)
+	}
+	span.SetAttributes(attribute.Int("keys", len(keys)))
+	return keys, next, nil
+}
+
+// Вторичный ключ — sorted set из uid заказов со временем создания в score.
+func (c *OrderCache) GetRef(ctx context.Context, ref repository.OrderRef, offset, limit int) ([]string, bool, error) {
+	ctx, span := c.tracer.Start(ctx, "redis.GetRef")
+	defer span.End()
+
+	key := refKey(ref)
+	pipe := c.client.Pipeline()
+	exists := pipe.Exists(ctx, key)
+	members := pipe.ZRevRange(ctx, key, int64(offset), int64(offset+limit-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, fmt.Errorf("redis get ref: %w", err)
+	}
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+	span.SetAttributes(attribute.String("ref_kind", ref.Kind), attribute.Int("orders_count", len(members.Val())))
+	return members.Val(), true, nil
+}
+
+func (c *OrderCache) SetRef(ctx context.Context, ref repository.OrderRef, matches []repository.OrderRefMatch, ttl time.Duration) error {
+	ctx, span := c.tracer.Start(ctx, "redis.SetRef")
+	defer span.End()
+
+	if len(matches) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, len(matches))
+	for i, m := range matches {
+		members[i] = redis.Z{Score: float64(m.DateCreated.Unix()), Member: m.OrderUID}
+	}
+	key := refKey(ref)
+	pipe := c.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.ZAdd(ctx, key, members...)
+	pipe.PExpire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis set ref: %w", err)
+	}
+	span.SetAttributes(attribute.String("ref_kind", ref.Kind), attribute.Int("orders_count", len(matches)))
+	return nil
+}
+
+func (c *OrderCache) AddRefs(ctx context.Context, m repository.OrderRefMatch, refs []repository.OrderRef, ttl time.Duration) error {
+	ctx, span := c.tracer.Start(ctx, "redis.AddRefs")
+	defer span.End()
+
+	if len(refs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(refs))
+	args := []any{m.DateCreated.Unix(), m.OrderUID, ttl.Milliseconds()}
+	for i, ref := range refs {
+		keys[i] = refKey(ref)
+		unique := "0"
+		if ref.Unique() {
+			unique = "1"
+		}
+		args = append(args, unique)
+	}
+	if err := addRefsScript.Run(ctx, c.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("redis add refs: %w", err)
+	}
+	span.SetAttributes(attribute.String("order_uid", m.OrderUID), attribute.Int("refs", len(refs)))
+	return nil
+}
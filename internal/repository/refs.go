@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"orderservice/pkg/models"
+)
+
+// Kinds of secondary order keys.
+const (
+	RefTrackNumber = "track"
+	RefTransaction = "transaction"
+	RefItemRid     = "rid"
+	RefItemChrtID  = "chrt_id"
+	RefItemNmID    = "nm_id"
+)
+
+// OrderRef is a secondary key orders are looked up by instead of order_uid.
+type OrderRef struct {
+	Kind  string
+	Value string
+}
+
+func (r OrderRef) String() string { return r.Kind + ":" + r.Value }
+
+// Unique reports whether the key normally belongs to a single order. Product
+// ids (chrt_id, nm_id) are shared by every order of the product.
+func (r OrderRef) Unique() bool {
+	return r.Kind != RefItemChrtID && r.Kind != RefItemNmID
+}
+
+// OrderRefs lists the secondary keys of o.
+func OrderRefs(o models.Order) []OrderRef {
+	refs := []OrderRef{
+		{RefTrackNumber, o.TrackNumber},
+		{RefTransaction, o.Payment.Transaction},
+	}
+	seen := map[OrderRef]bool{}
+	for _, it := range o.Items {
+		for _, r := range []OrderRef{
+			{RefItemRid, it.Rid},
+			{RefItemChrtID, strconv.FormatInt(it.ChrtID, 10)},
+			{RefItemNmID, strconv.FormatInt(it.NmID, 10)},
+		} {
+			if r.Value != "" && r.Value != "0" && !seen[r] {
+				seen[r] = true
+				refs = append(refs, r)
+			}
+		}
+	}
+	return refs
+}
+
+// OrderRefMatch is an order found by a secondary key; DateCreated orders the
+// matches newest first.
+type OrderRefMatch struct {
+	OrderUID    string
+	DateCreated time.Time
+}
@@ -4,16 +4,97 @@ import (
 	"context"
 	"time"
 
+	"orderservice/internal/search"
 	"orderservice/pkg/models"
 )
 
+// OrderFilter narrows FindOrders. Zero values mean "no condition".
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Region          string
+	// Email and Phone match exactly; on encrypted rows through the blind index.
+	Email  string
+	Phone  string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+	// Scopes, when not empty, keeps only the orders in at least one of them.
+	Scopes []Scope
+}
+
+// Scope is an alternative of OrderFilter.Scopes: the orders equal to each of
+// its non-empty fields.
+type Scope struct {
+	CustomerID      string
+	DeliveryService string
+	Region          string
+}
+
+// SearchQuery is a parsed search narrowed to what the caller may see.
+type SearchQuery struct {
+	Expr search.Expr
+	// TextFields are the fields free text matches; nil means search.TextFields.
+	TextFields []string
+	// Scope restricts the results like a FindOrders filter; its Limit and
+	// Offset page them.
+	Scope OrderFilter
+}
+
+// SearchHit is an order found by SearchOrders with its text relevance.
+type SearchHit struct {
+	Order models.Order
+	Rank  float64
+}
+
+// Erased replaces personal data removed on a data subject's request.
+const Erased = "[erased]"
+
+// PrivacyRecord is the audit trail of a data subject request.
+type PrivacyRecord struct {
+	Kind string // "export" or "erase"
+	// SubjectHash is the sha256 of the customer id: after erasure the id
+	// itself must not be kept.
+	SubjectHash string
+	Actor       string
+	RequestID   string
+	OrderUIDs   []string
+	At          time.Time
+}
+
 type OrderRepository interface {
 	SaveOrder(ctx context.Context, o models.Order) error
 	GetOrder(ctx context.Context, uid string) (models.Order, error)
 	ListOrders(ctx context.Context) ([]models.Order, error)
+	FindOrders(ctx context.Context, f OrderFilter) ([]models.Order, error)
+	// LookupOrders returns up to limit orders with the secondary key ref from
+	// offset, newest first, in any of scopes when there are some. Every kind
+	// of key is indexed.
+	LookupOrders(ctx context.Context, ref OrderRef, scopes []Scope, offset, limit int) ([]OrderRefMatch, error)
+	// SearchOrders returns orders matching q, most relevant first.
+	SearchOrders(ctx context.Context, q SearchQuery) ([]SearchHit, error)
+	// EraseCustomer anonymizes delivery data of every order of customerID and
+	// replaces the customer id with pseudonym; payments and items are kept.
+	// rec, completed with the affected uids, is stored in the same transaction.
+	EraseCustomer(ctx context.Context, customerID, pseudonym string, rec PrivacyRecord) ([]string, error)
+	RecordPrivacyRequest(ctx context.Context, rec PrivacyRecord) error
 }
 
 type CacheRepository interface {
 	Get(ctx context.Context, key string) (models.Order, bool, error)
 	Set(ctx context.Context, key string, value models.Order, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Scan iterates cached keys like Redis SCAN: pass cursor 0 to start,
+	// iteration is complete when the returned cursor is 0.
+	Scan(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error)
+	// GetRef returns limit order uids from offset of the orders cached under
+	// ref, newest first; ok is false when ref is not cached.
+	GetRef(ctx context.Context, ref OrderRef, offset, limit int) (uids []string, ok bool, err error)
+	// SetRef caches the complete list of orders with ref.
+	SetRef(ctx context.Context, ref OrderRef, matches []OrderRefMatch, ttl time.Duration) error
+	// AddRefs records a saved order under its refs. Unique refs are created
+	// when missing, the others only extended when cached: a partial list
+	// would hide the orders it misses.
+	AddRefs(ctx context.Context, m OrderRefMatch, refs []OrderRef, ttl time.Duration) error
 }
@@ -0,0 +1,588 @@
+// Package search parses the structured order search language:
+//
+//	ivanov brand:nike amount:1000..5000 created:>=2025-01-01
+//	(phone:4567 OR track:WBIL) -service:cdek
+//
+// Terms are free words or field:value pairs; adjacent terms are ANDed, OR
+// binds looser than AND, parentheses group and a leading "-" or NOT negates.
+// Quoted values ("Ivan Petrov") match as a phrase.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Fields. Text fields match words (the last one as a prefix), the others
+// match attribute values or ranges.
+const (
+	FieldText     = ""         // free text: every text field
+	FieldName     = "name"     // recipient name, words
+	FieldAddress  = "address"  // delivery address, city and region, words
+	FieldItem     = "item"     // item names and brands, words
+	FieldPhone    = "phone"    // part of the recipient phone, digits
+	FieldTrack    = "track"    // part of the track number
+	FieldBrand    = "brand"    // item brand, whole value
+	FieldCustomer = "customer" // customer_id
+	FieldService  = "service"  // delivery_service
+	FieldCity     = "city"     // whole value
+	FieldRegion   = "region"   // whole value
+	FieldUID      = "uid"      // order_uid
+	FieldAmount   = "amount"   // payment amount, range
+	FieldItems    = "items"    // number of items, range
+	FieldCreated  = "created"  // creation date or time, range
+)
+
+type fieldKind int
+
+const (
+	kindWords fieldKind = iota
+	kindValue
+	kindDigits
+	kindInt
+	kindTime
+)
+
+var fields = map[string]fieldKind{
+	FieldName:     kindWords,
+	FieldAddress:  kindWords,
+	FieldItem:     kindWords,
+	FieldPhone:    kindDigits,
+	FieldTrack:    kindValue,
+	FieldBrand:    kindValue,
+	FieldCustomer: kindValue,
+	FieldService:  kindValue,
+	FieldCity:     kindValue,
+	FieldRegion:   kindValue,
+	FieldUID:      kindValue,
+	FieldAmount:   kindInt,
+	FieldItems:    kindInt,
+	FieldCreated:  kindTime,
+}
+
+// TextFields are the fields free text searches.
+var TextFields = []string{FieldTrack, FieldName, FieldItem, FieldAddress}
+
+const (
+	// MinPhoneDigits keeps partial phone matches selective.
+	MinPhoneDigits = 4
+	maxQueryLen    = 512
+	maxTerms       = 20
+)
+
+// ErrSyntax wraps every parse error.
+var ErrSyntax = errors.New("search query")
+
+// Expr is a node of a parsed query. String renders it canonically.
+type Expr interface {
+	String() string
+}
+
+type And struct{ Args []Expr }
+
+type Or struct{ Args []Expr }
+
+type Not struct{ Arg Expr }
+
+// Term matches a text or attribute field. Phrase is set for quoted values.
+type Term struct {
+	Field  string
+	Value  string
+	Phrase bool
+}
+
+// Range matches an integer field between Min and Max, both inclusive; nil is
+// open.
+type Range struct {
+	Field    string
+	Min, Max *int64
+}
+
+// Period matches a time field in [From, To); a zero bound is open.
+type Period struct {
+	Field    string
+	From, To time.Time
+}
+
+func (e And) String() string { return join(e.Args, " AND ") }
+func (e Or) String() string  { return join(e.Args, " OR ") }
+func (e Not) String() string { return "NOT " + e.Arg.String() }
+
+func (e Term) String() string {
+	v := e.Value
+	if e.Phrase {
+		v = strconv.Quote(v)
+	}
+	if e.Field == FieldText {
+		return v
+	}
+	return e.Field + ":" + v
+}
+
+func (e Range) String() string {
+	bound := func(p *int64) string {
+		if p == nil {
+			return ""
+		}
+		return strconv.FormatInt(*p, 10)
+	}
+	return e.Field + ":" + bound(e.Min) + ".." + bound(e.Max)
+}
+
+func (e Period) String() string {
+	bound := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return e.Field + ":[" + bound(e.From) + "," + bound(e.To) + ")"
+}
+
+func join(args []Expr, sep string) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// Words splits s into lower-cased words of letters and digits, the way the
+// index tokenizes text.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Digits keeps only the digits of s.
+func Digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// Fields walks e and returns every field it references; free text is
+// reported as FieldText.
+func Fields(e Expr) []string {
+	seen := map[string]bool{}
+	var out []string
+	var walk func(Expr)
+	walk = func(e Expr) {
+		var f string
+		switch e := e.(type) {
+		case And:
+			for _, a := range e.Args {
+				walk(a)
+			}
+			return
+		case Or:
+			for _, a := range e.Args {
+				walk(a)
+			}
+			return
+		case Not:
+			walk(e.Arg)
+			return
+		case Term:
+			f = e.Field
+		case Range:
+			f = e.Field
+		case Period:
+			f = e.Field
+		}
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	walk(e)
+	return out
+}
+
+// Parse parses a query.
+func Parse(q string) (Expr, error) {
+	if len(q) > maxQueryLen {
+		return nil, fmt.Errorf("%w: longer than %d bytes", ErrSyntax, maxQueryLen)
+	}
+	toks, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrSyntax)
+	}
+	p := &parser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrSyntax, p.toks[p.pos])
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokTerm tokKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind   tokKind
+	field  string
+	value  string
+	quoted bool
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokLParen:
+		return `"("`
+	case tokRParen:
+		return `")"`
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	}
+	return strconv.Quote(t.value)
+}
+
+func tokenize(q string) ([]token, error) {
+	var toks []token
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{kind: tokLParen})
+			i++
+		case r == ')':
+			toks = append(toks, token{kind: tokRParen})
+			i++
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) && rs[i+1] != ')':
+			toks = append(toks, token{kind: tokNot})
+			i++
+		case r == '"':
+			v, n, err := quoted(rs[i:])
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokTerm, value: v, quoted: true})
+			i += n
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+				i++
+			}
+			word := string(rs[start:i])
+			switch word {
+			case "AND":
+				toks = append(toks, token{kind: tokAnd})
+				continue
+			case "OR":
+				toks = append(toks, token{kind: tokOr})
+				continue
+			case "NOT":
+				toks = append(toks, token{kind: tokNot})
+				continue
+			}
+			t := token{kind: tokTerm, value: word}
+			if name, value, ok := strings.Cut(word, ":"); ok && isFieldName(name) {
+				t.field, t.value = strings.ToLower(name), value
+				if _, known := fields[t.field]; !known {
+					return nil, fmt.Errorf("%w: unknown field %q", ErrSyntax, name)
+				}
+				if value == "" && i < len(rs) && rs[i] == '"' {
+					v, n, err := quoted(rs[i:])
+					if err != nil {
+						return nil, err
+					}
+					t.value, t.quoted = v, true
+					i += n
+				}
+			}
+			toks = append(toks, t)
+		}
+	}
+	return toks, nil
+}
+
+// quoted reads a double-quoted string at the start of rs; \" and \\ escape.
+func quoted(rs []rune) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+				b.WriteRune(rs[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(rs[i])
+		}
+	}
+	return "", 0, fmt.Errorf("%w: unterminated quote", ErrSyntax)
+}
+
+func isFieldName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+type parser struct {
+	toks  []token
+	pos   int
+	terms int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *parser) or() (Expr, error) {
+	var args []Expr
+	for {
+		e, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+		if t, ok := p.peek(); !ok || t.kind != tokOr {
+			break
+		}
+		p.pos++
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return Or{Args: args}, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	var args []Expr
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokOr || t.kind == tokRParen {
+			break
+		}
+		if t.kind == tokAnd {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%w: AND without a left operand", ErrSyntax)
+			}
+			p.pos++
+			continue
+		}
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	switch len(args) {
+	case 0:
+		if t, ok := p.peek(); ok {
+			return nil, fmt.Errorf("%w: expected a term before %s", ErrSyntax, t)
+		}
+		return nil, fmt.Errorf("%w: expected a term at the end", ErrSyntax)
+	case 1:
+		return args[0], nil
+	}
+	return And{Args: args}, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	t, _ := p.peek()
+	switch t.kind {
+	case tokNot:
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Arg: e}, nil
+	case tokLParen:
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokRParen {
+			return nil, fmt.Errorf("%w: missing \")\"", ErrSyntax)
+		}
+		p.pos++
+		return e, nil
+	case tokTerm:
+		p.pos++
+		if p.terms++; p.terms > maxTerms {
+			return nil, fmt.Errorf("%w: more than %d terms", ErrSyntax, maxTerms)
+		}
+		return term(t)
+	}
+	return nil, fmt.Errorf("%w: unexpected %s", ErrSyntax, t)
+}
+
+func term(t token) (Expr, error) {
+	kind := kindWords
+	if t.field != FieldText {
+		kind = fields[t.field]
+	}
+	label := t.field
+	if label == FieldText {
+		label = "text"
+	}
+	switch kind {
+	case kindWords:
+		if len(Words(t.value)) == 0 {
+			return nil, fmt.Errorf("%w: %s: no words in %q", ErrSyntax, label, t.value)
+		}
+	case kindDigits:
+		d := Digits(t.value)
+		if len(d) < MinPhoneDigits {
+			return nil, fmt.Errorf("%w: %s: at least %d digits", ErrSyntax, label, MinPhoneDigits)
+		}
+		return Term{Field: t.field, Value: d}, nil
+	case kindValue:
+		if strings.TrimSpace(t.value) == "" {
+			return nil, fmt.Errorf("%w: %s: empty value", ErrSyntax, label)
+		}
+	case kindInt:
+		return intRange(t.field, t.value)
+	case kindTime:
+		return period(t.field, t.value)
+	}
+	return Term{Field: t.field, Value: t.value, Phrase: t.quoted}, nil
+}
+
+// bounds splits a range value: "a..b", "a..", "..b", ">a", ">=a", "<b",
+// "<=b" or a single "a". Exclusive bounds are reported so the caller can
+// adjust them to its step.
+func bounds(v string) (lo, hi string, loExcl, hiExcl bool) {
+	switch {
+	case strings.HasPrefix(v, ">="):
+		return v[2:], "", false, false
+	case strings.HasPrefix(v, ">"):
+		return v[1:], "", true, false
+	case strings.HasPrefix(v, "<="):
+		return "", v[2:], false, false
+	case strings.HasPrefix(v, "<"):
+		return "", v[1:], false, true
+	}
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		return lo, hi, false, false
+	}
+	return v, v, false, false
+}
+
+func intRange(field, v string) (Expr, error) {
+	lo, hi, loExcl, hiExcl := bounds(v)
+	if lo == "" && hi == "" {
+		return nil, fmt.Errorf("%w: %s: empty range", ErrSyntax, field)
+	}
+	r := Range{Field: field}
+	parse := func(s string, shift int64) (*int64, error) {
+		if s == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %q is not a number", ErrSyntax, field, s)
+		}
+		n += shift
+		return &n, nil
+	}
+	var err error
+	var shift int64
+	if loExcl {
+		shift = 1
+	}
+	if r.Min, err = parse(lo, shift); err != nil {
+		return nil, err
+	}
+	shift = 0
+	if hiExcl {
+		shift = -1
+	}
+	if r.Max, err = parse(hi, shift); err != nil {
+		return nil, err
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return nil, fmt.Errorf("%w: %s: empty range %s", ErrSyntax, field, v)
+	}
+	return r, nil
+}
+
+// period turns a date or RFC 3339 range into [From, To). A date covers its
+// whole day (UTC).
+func period(field, v string) (Expr, error) {
+	lo, hi, loExcl, hiExcl := bounds(v)
+	if lo == "" && hi == "" {
+		return nil, fmt.Errorf("%w: %s: empty range", ErrSyntax, field)
+	}
+	// start and end of the instant or day s names
+	parse := func(s string) (start, end time.Time, err error) {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t, t.AddDate(0, 0, 1), nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: %s: %q is not a date (YYYY-MM-DD) or RFC 3339 time", ErrSyntax, field, s)
+		}
+		return t, t.Add(time.Nanosecond), nil
+	}
+	p := Period{Field: field}
+	if lo != "" {
+		start, end, err := parse(lo)
+		if err != nil {
+			return nil, err
+		}
+		p.From = start
+		if loExcl {
+			p.From = end
+		}
+	}
+	if hi != "" {
+		start, end, err := parse(hi)
+		if err != nil {
+			return nil, err
+		}
+		p.To = end
+		if hiExcl {
+			p.To = start
+		}
+	}
+	if !p.From.IsZero() && !p.To.IsZero() && !p.From.Before(p.To) {
+		return nil, fmt.Errorf("%w: %s: empty range %s", ErrSyntax, field, v)
+	}
+	return p, nil
+}
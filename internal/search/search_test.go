@@ -0,0 +1,79 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		`ivanov`:                         `ivanov`,
+		`ivan petrov`:                    `(ivan AND petrov)`,
+		`"Ivan Petrov" brand:nike`:       `("Ivan Petrov" AND brand:nike)`,
+		`name:"Ivan P" OR track:WBIL`:    `(name:"Ivan P" OR track:WBIL)`,
+		`a b OR c`:                       `((a AND b) OR c)`,
+		`a AND (b OR c)`:                 `(a AND (b OR c))`,
+		`-service:cdek phone:4567`:       `(NOT service:cdek AND phone:4567)`,
+		`NOT item:bag`:                   `NOT item:bag`,
+		`amount:100..500`:                `amount:100..500`,
+		`amount:>100 items:<=3`:          `(amount:101.. AND items:..3)`,
+		`amount:<1000`:                   `amount:..999`,
+		`created:2025-01-01..2025-01-31`: `created:[2025-01-01T00:00:00Z,2025-02-01T00:00:00Z)`,
+		`created:>2025-01-01`:            `created:[2025-01-02T00:00:00Z,)`,
+		`created:2025-03-01`:             `created:[2025-03-01T00:00:00Z,2025-03-02T00:00:00Z)`,
+		`10:30`:                          `10:30`,
+		`City:Kazan`:                     `city:Kazan`,
+	}
+	for q, want := range cases {
+		e, err := Parse(q)
+		if err != nil {
+			t.Fatalf("%q: %v", q, err)
+		}
+		if got := e.String(); got != want {
+			t.Fatalf("%q parsed as %s, want %s", q, got, want)
+		}
+	}
+}
+
+func TestParsePhone(t *testing.T) {
+	e, err := Parse(`phone:"+7 (999) 12-34"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.(Term).Value != "79991234" {
+		t.Fatalf("phone digits %q", e.(Term).Value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, q := range []string{
+		``, `   `, `(a`, `a)`, `OR a`, `a OR`, `AND a`, `()`,
+		`colour:red`, `phone:123`, `phone:+7`, `amount:abc`, `amount:5..1`, `created:yesterday`,
+		`created:2025-02-01..2025-01-01`, `"unterminated`, `name:...`, `brand:`,
+	} {
+		if _, err := Parse(q); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("%q: %v", q, err)
+		}
+	}
+	long := ""
+	for range maxTerms + 1 {
+		long += "x "
+	}
+	if _, err := Parse(long); !errors.Is(err, ErrSyntax) {
+		t.Fatalf("too many terms accepted")
+	}
+}
+
+func TestFields(t *testing.T) {
+	e, err := Parse(`ivan (phone:4567 OR -name:petrov) amount:>1 name:x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Fields(e); !slices.Equal(got, []string{FieldText, FieldPhone, FieldName, FieldAmount}) {
+		t.Fatalf("fields %q", got)
+	}
+	if w := Words("Ivan-Petrov, д.5"); !slices.Equal(w, []string{"ivan", "petrov", "д", "5"}) {
+		t.Fatalf("words %q", w)
+	}
+}
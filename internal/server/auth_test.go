@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orderservice/internal/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func testAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	a, err := auth.New(auth.Config{APIKeys: []string{"ops:" + auth.HashAPIKey("k-123")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var subject string
+	h := authMiddleware(testAuthenticator(t), logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = auth.FromContext(r.Context()).Subject
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/order/1", nil))
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("anonymous request: %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	req.Header.Set("X-API-Key", "k-123")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || subject != "ops" {
+		t.Fatalf("api key request: %d, subject %q", rr.Code, subject)
+	}
+}
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	intercept := authUnaryInterceptor(testAuthenticator(t), logger)
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.v1.OrderService/GetOrder"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return auth.FromContext(ctx).Subject, nil
+	}
+
+	if _, err := intercept(context.Background(), nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("anonymous call: %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "ApiKey k-123"))
+	got, err := intercept(ctx, nil, info, handler)
+	if err != nil || got != "ops" {
+		t.Fatalf("api key call: %v, %v", got, err)
+	}
+	if h, ok := gatewayHeaderMatcher("X-Api-Key"); !ok || h != "x-api-key" {
+		t.Fatalf("gateway does not forward the api key header")
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"orderservice/pkg/models"
+)
+
+// orderETag is the strong validator of an order as returned to the caller:
+// the hash covers the masked view, so callers with different views never
+// share a validator.
+func orderETag(o models.Order) string {
+	return `"` + o.ContentHash()[:32] + `"`
+}
+
+// conditionalGET adds caching headers to successful responses that carry an
+// ETag and turns them into 304 Not Modified when If-None-Match matches. The
+// body is still produced upstream, but never sent.
+func conditionalGET(maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, ifNoneMatch: r.Header.Get("If-None-Match"), maxAge: maxAge}, r)
+	})
+}
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	maxAge      time.Duration
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if etag := h.Get("ETag"); code == http.StatusOK && etag != "" {
+		// ответ зависит от прав вызывающего и содержит ПДн: только private
+		h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(w.maxAge.Seconds())))
+		h.Add("Vary", "Authorization, X-Api-Key")
+		if etagMatches(w.ifNoneMatch, etag) {
+			w.notModified = true
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			code = http.StatusNotModified
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// etagMatches implements the weak comparison If-None-Match uses.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orderservice/pkg/models/fake"
+)
+
+func TestConditionalGET(t *testing.T) {
+	etag := orderETag(fake.New(fake.WithSeed(1)).Order())
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order_uid":"x"}`))
+	})
+	h := conditionalGET(time.Minute, upstream)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/order/x", nil))
+	if rr.Code != http.StatusOK || rr.Body.Len() == 0 || rr.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("first GET: %d %q cache-control %q", rr.Code, rr.Body.String(), rr.Header().Get("Cache-Control"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/order/x", nil)
+	req.Header.Set("If-None-Match", `"stale", W/`+etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag {
+		t.Fatalf("revalidation: %d %q etag %q", rr.Code, rr.Body.String(), rr.Header().Get("ETag"))
+	}
+
+	req.Header.Set("If-None-Match", `"stale"`)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+		t.Fatalf("changed order: %d", rr.Code)
+	}
+}
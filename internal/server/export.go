@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"orderservice/internal/export"
+	"orderservice/pkg/api/orderpb"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportWriteTimeout bounds each write of an export instead of the server's
+// WriteTimeout, which a large export would exceed: a client that stops
+// reading is still cut off.
+const exportWriteTimeout = 30 * time.Second
+
+// handleOrderExport relays ExportOrders as a file download.
+//
+//	@Summary		Export orders
+//	@Description	Streams the orders created in [from, to) with payments and items, one row per item. CSV ends with a "# rows=N sha256=HEX" line, NDJSON with a {"_footer":{"rows":N,"sha256":"HEX"}} line, Parquet keeps them in the file metadata (export.rows, export.sha256); the same values come as the X-Export-Rows and X-Export-SHA256 trailers. The checksum is the SHA-256 of the rows rendered as CSV with the header, whatever the format.
+//	@Tags			orders
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.apache.parquet
+//	@Param			from	query		string	true	"Created at or after (RFC 3339 or YYYY-MM-DD)"
+//	@Param			to		query		string	true	"Created before (RFC 3339 or YYYY-MM-DD)"
+//	@Param			format	query		string	false	"csv (default), ndjson or parquet"
+//	@Param			columns	query		string	false	"Comma-separated columns in output order (default all)"
+//	@Success		200		{file}		file
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		403		{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/export [get]
+func (s *HTTPServer) handleOrderExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &orderpb.ExportOrdersRequest{Format: query.Get("format")}
+	for _, v := range query["columns"] {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				req.Columns = append(req.Columns, c)
+			}
+		}
+	}
+	for name, dst := range map[string]**timestamppb.Timestamp{"from": &req.From, "to": &req.To} {
+		if v := query.Get(name); v != "" {
+			t, err := parseExportTime(v)
+			if err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = timestamppb.New(t)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(r.Context(), streamMetadata(r)))
+	defer cancel()
+	st, err := s.client.ExportOrders(ctx, req)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	// the status is only known with the first chunk: a rejected export has none
+	chunk, err := st.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeStatusError(w, err)
+		return
+	}
+	md, _ := st.Header()
+	forwardHeaders(w, md)
+
+	format := req.GetFormat()
+	if format == "" {
+		format = export.FormatCSV
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders_%s_%s.%s"`,
+		req.GetFrom().AsTime().Format("20060102"), req.GetTo().AsTime().Format("20060102"), format))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Trailer", "X-Export-Rows, X-Export-SHA256")
+	_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	w.WriteHeader(http.StatusOK)
+
+	for err == nil {
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		if _, werr := w.Write(chunk.GetData()); werr != nil {
+			return
+		}
+		_ = rc.Flush()
+		chunk, err = st.Recv()
+	}
+	if !errors.Is(err, io.EOF) {
+		// the status line is gone; a reset tells the client the file is cut short
+		panic(http.ErrAbortHandler)
+	}
+	trailer := st.Trailer()
+	if v := trailer.Get("x-export-rows"); len(v) > 0 {
+		w.Header().Set("X-Export-Rows", v[0])
+	}
+	if v := trailer.Get("x-export-sha256"); len(v) > 0 {
+		w.Header().Set("X-Export-SHA256", v[0])
+	}
+}
+
+func parseExportTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orderservice/pkg/api/orderpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeExportClient struct {
+	orderpb.OrderServiceClient
+	req    *orderpb.ExportOrdersRequest
+	stream *fakeExportStream
+}
+
+func (c *fakeExportClient) ExportOrders(ctx context.Context, req *orderpb.ExportOrdersRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[orderpb.ExportChunk], error) {
+	c.req = req
+	return c.stream, nil
+}
+
+type fakeExportStream struct {
+	grpc.ClientStream
+	chunks  []string
+	delay   time.Duration
+	err     error
+	trailer metadata.MD
+}
+
+func (s *fakeExportStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *fakeExportStream) Trailer() metadata.MD         { return s.trailer }
+
+func (s *fakeExportStream) Recv() (*orderpb.ExportChunk, error) {
+	time.Sleep(s.delay)
+	if len(s.chunks) == 0 {
+		return nil, s.err
+	}
+	c := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return &orderpb.ExportChunk{Data: []byte(c)}, nil
+}
+
+func serveExport(t *testing.T, client *fakeExportClient) *httptest.Server {
+	t.Helper()
+	srv := &HTTPServer{client: client}
+	ts := httptest.NewUnstartedServer(chainMiddlewares(http.HandlerFunc(srv.handleOrderExport), slog.New(slog.NewTextHandler(io.Discard, nil))))
+	ts.Config.WriteTimeout = 50 * time.Millisecond
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestOrderExportOutlivesWriteTimeout(t *testing.T) {
+	client := &fakeExportClient{stream: &fakeExportStream{
+		chunks:  []string{"order_uid\n", "o1\n", "o2\n", "# rows=2 sha256=ab\n"},
+		delay:   30 * time.Millisecond,
+		err:     io.EOF,
+		trailer: metadata.Pairs("x-export-rows", "2", "x-export-sha256", "ab"),
+	}}
+	ts := serveExport(t, client)
+
+	resp, err := http.Get(ts.URL + "/orders/export?from=2025-03-01&to=2025-04-01T00:00:00Z&format=csv&columns=order_uid,amount&columns=city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "order_uid\no1\no2\n# rows=2 sha256=ab\n" {
+		t.Fatalf("body %q", body)
+	}
+	if resp.Header.Get("Content-Disposition") != `attachment; filename="orders_20250301_20250401.csv"` ||
+		resp.Trailer.Get("X-Export-Rows") != "2" || resp.Trailer.Get("X-Export-SHA256") != "ab" {
+		t.Fatalf("headers %v, trailers %v", resp.Header, resp.Trailer)
+	}
+	if got := client.req.GetColumns(); len(got) != 3 || got[2] != "city" || client.req.GetFrom().AsTime().Day() != 1 {
+		t.Fatalf("request %+v", client.req)
+	}
+}
+
+func TestOrderExportErrors(t *testing.T) {
+	srv := &HTTPServer{client: &fakeExportClient{stream: &fakeExportStream{err: status.Error(codes.PermissionDenied, "denied")}}}
+	rr := httptest.NewRecorder()
+	srv.handleOrderExport(rr, httptest.NewRequest(http.MethodGet, "/orders/export?from=2025-03-01&to=2025-04-01", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("denied export: %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	srv.handleOrderExport(rr, httptest.NewRequest(http.MethodGet, "/orders/export?from=march", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad from: %d", rr.Code)
+	}
+
+	// a failure mid-stream must not look like a complete file
+	ts := serveExport(t, &fakeExportClient{stream: &fakeExportStream{
+		chunks: []string{"order_uid\n", "o1\n"},
+		err:    status.Error(codes.Internal, "internal error"),
+	}})
+	resp, err := http.Get(ts.URL + "/orders/export?from=2025-03-01&to=2025-04-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatal("truncated export read without an error")
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"orderservice/internal/features"
+)
+
+// FlagReader evaluates the feature flags shown by GET /admin/flags.
+type FlagReader interface {
+	FeatureFlags(ctx context.Context, subj features.Subject) (features.Report, error)
+}
+
+// handleFlags evaluates the feature flags of this replica for a subject.
+//
+//	@Summary		Evaluate feature flags
+//	@Description	Returns every feature flag of the replica serving the call, where it is defined (config or redis) and whether it is on for the subject given by the query. Without key, percentage rollouts are evaluated by replica.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			key					query		string	false	"Rollout key, e.g. an order_uid"
+//	@Param			delivery_service	query		string	false	"Delivery service"
+//	@Param			locale				query		string	false	"Locale"
+//	@Success		200					{object}	features.Report
+//	@Failure		401					{string}	string
+//	@Failure		403					{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/admin/flags [get]
+func handleFlags(flags FlagReader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		report, err := flags.FeatureFlags(r.Context(), features.Subject{
+			Key:             q.Get("key"),
+			DeliveryService: q.Get("delivery_service"),
+			Locale:          q.Get("locale"),
+		})
+		if err != nil {
+			writeStatusError(w, toStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orderservice/internal/features"
+	"orderservice/internal/service"
+)
+
+type fakeFlags struct{ subj features.Subject }
+
+func (f *fakeFlags) FeatureFlags(ctx context.Context, subj features.Subject) (features.Report, error) {
+	f.subj = subj
+	if subj.Key == "denied" {
+		return features.Report{}, service.ErrPermissionDenied
+	}
+	return features.New(map[string]features.Flag{"lazy_cache": {Enabled: true}}, "orders-0").Evaluate(subj), nil
+}
+
+func TestHandleFlags(t *testing.T) {
+	flags := &fakeFlags{}
+	rr := httptest.NewRecorder()
+	handleFlags(flags).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/flags?key=o1&delivery_service=meest&locale=en", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body)
+	}
+	if flags.subj != (features.Subject{Key: "o1", DeliveryService: "meest", Locale: "en"}) {
+		t.Fatalf("subject %+v", flags.subj)
+	}
+	var report features.Report
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Replica != "orders-0" || len(report.Flags) != 1 || !report.Flags[0].Enabled || report.Flags[0].Source != features.SourceConfig {
+		t.Fatalf("report %+v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	handleFlags(flags).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/flags?key=denied", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("denied: status %d", rr.Code)
+	}
+}
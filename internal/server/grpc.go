@@ -1,14 +1,26 @@
 package server
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
+	"time"
 
+	"orderservice/internal/analytics"
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
 	"orderservice/internal/observability"
+	"orderservice/internal/orderconv"
+	"orderservice/internal/ratelimit"
+	"orderservice/internal/repository"
 	"orderservice/internal/service"
+	"orderservice/internal/stream"
 	"orderservice/pkg/api/orderpb"
 
 	"github.com/google/uuid"
@@ -18,6 +30,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type orderGRPCServer struct {
@@ -27,24 +40,50 @@ type orderGRPCServer struct {
 	tracer trace.Tracer
 }
 
-func StartGRPCServer(ctx context.Context, addr string, svc *service.Service, logger *slog.Logger, tracer trace.Tracer) error {
+// StartGRPCServer serves the order API. authn may be nil to allow anonymous access;
+// limiter may be nil to accept unlimited traffic.
+func StartGRPCServer(ctx context.Context, addr string, svc *service.Service, authn *auth.Authenticator, limiter *ratelimit.Limiter, logger *slog.Logger, tracer trace.Tracer) error {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("listen: %w", err)
 	}
+	return ServeGRPC(ctx, lis, svc, authn, limiter, logger, tracer)
+}
 
+// ServeGRPC is StartGRPCServer on a bound listener. On cancellation it waits
+// up to 5 seconds for running calls, streams included, then cuts them off.
+func ServeGRPC(ctx context.Context, lis net.Listener, svc *service.Service, authn *auth.Authenticator, limiter *ratelimit.Limiter, logger *slog.Logger, tracer trace.Tracer) error {
+	interceptors := []grpc.UnaryServerInterceptor{
+		requestIDUnaryInterceptor(logger),
+		sourceUnaryInterceptor(),
+		authUnaryInterceptor(authn, logger),
+		rateLimitUnaryInterceptor(limiter, logger),
+	}
+	streamInterceptors := make([]grpc.StreamServerInterceptor, len(interceptors))
+	for i, u := range interceptors {
+		streamInterceptors[i] = streamFromUnary(u)
+	}
 	grpcServer := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			requestIDUnaryInterceptor(logger),
-		),
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
 	)
 
 	orderpb.RegisterOrderServiceServer(grpcServer, &orderGRPCServer{svc: svc, logger: logger, tracer: tracer})
+	orderpb.RegisterOrderAnalyticsServer(grpcServer, &analyticsGRPCServer{svc: svc, tracer: tracer})
 
 	go func() {
 		<-ctx.Done()
-		grpcServer.GracefulStop()
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			grpcServer.Stop()
+		}
 	}()
 	if err := grpcServer.Serve(lis); err != nil {
 		return fmt.Errorf("grpc serve: %w", err)
@@ -58,18 +97,435 @@ func (s *orderGRPCServer) GetOrder(ctx context.Context, req *orderpb.GetOrderReq
 
 	order, err := s.svc.GetOrder(ctx, req.GetOrderUid())
 	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrNotFound):
-			return nil, status.Error(codes.NotFound, err.Error())
-		case errors.Is(err, service.ErrValidation):
-			return nil, status.Error(codes.InvalidArgument, err.Error())
-		default:
-			return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
+	}
+	// the same validator the HTTP gateway sends as ETag
+	_ = grpc.SetHeader(ctx, metadata.Pairs("etag", orderETag(order)))
+	return &orderpb.GetOrderResponse{Order: orderconv.ToProto(order)}, nil
+}
+
+func (s *orderGRPCServer) GetOrderByTrackNumber(ctx context.Context, req *orderpb.GetOrderByTrackNumberRequest) (*orderpb.GetOrderResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.GetOrderByTrackNumber")
+	defer span.End()
+
+	order, err := s.svc.GetOrderByTrackNumber(ctx, req.GetTrackNumber())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("etag", orderETag(order)))
+	return &orderpb.GetOrderResponse{Order: orderconv.ToProto(order)}, nil
+}
+
+func (s *orderGRPCServer) GetOrderByTransaction(ctx context.Context, req *orderpb.GetOrderByTransactionRequest) (*orderpb.GetOrderResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.GetOrderByTransaction")
+	defer span.End()
+
+	order, err := s.svc.GetOrderByTransaction(ctx, req.GetTransaction())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("etag", orderETag(order)))
+	return &orderpb.GetOrderResponse{Order: orderconv.ToProto(order)}, nil
+}
+
+func (s *orderGRPCServer) FindOrdersByItem(ctx context.Context, req *orderpb.FindOrdersByItemRequest) (*orderpb.FindOrdersByItemResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.FindOrdersByItem")
+	defer span.End()
+
+	var refs []repository.OrderRef
+	if req.GetRid() != "" {
+		refs = append(refs, repository.OrderRef{Kind: repository.RefItemRid, Value: req.GetRid()})
+	}
+	if req.GetChrtId() != 0 {
+		refs = append(refs, repository.OrderRef{Kind: repository.RefItemChrtID, Value: strconv.FormatInt(req.GetChrtId(), 10)})
+	}
+	if req.GetNmId() != 0 {
+		refs = append(refs, repository.OrderRef{Kind: repository.RefItemNmID, Value: strconv.FormatInt(req.GetNmId(), 10)})
+	}
+	if len(refs) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of rid, chrt_id and nm_id is required")
+	}
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	orders, next, err := s.svc.FindOrdersByItem(ctx, refs[0], int(req.GetPageSize()), offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.FindOrdersByItemResponse{Orders: make([]*orderpb.Order, 0, len(orders))}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, orderconv.ToProto(o))
+	}
+	if next > 0 {
+		resp.NextPageToken = encodePageToken(next)
+	}
+	return resp, nil
+}
+
+func (s *orderGRPCServer) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.ListOrders")
+	defer span.End()
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	filter := repository.OrderFilter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+		Email:           req.GetEmail(),
+		Phone:           req.GetPhone(),
+		Limit:           int(req.GetPageSize()),
+		Offset:          offset,
+	}
+	if req.GetFrom() != nil {
+		filter.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		filter.To = req.GetTo().AsTime()
+	}
+	orders, err := s.svc.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.ListOrdersResponse{Orders: make([]*orderpb.Order, 0, len(orders))}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, orderconv.ToProto(o))
+	}
+	if filter.Limit > 0 && len(orders) == filter.Limit {
+		resp.NextPageToken = encodePageToken(offset + len(orders))
+	}
+	return resp, nil
+}
+
+func (s *orderGRPCServer) SearchOrders(ctx context.Context, req *orderpb.SearchOrdersRequest) (*orderpb.SearchOrdersResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.SearchOrders")
+	defer span.End()
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	limit := int(req.GetPageSize())
+	hits, err := s.svc.SearchOrders(ctx, req.GetQuery(), limit, offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.SearchOrdersResponse{Hits: make([]*orderpb.SearchHit, 0, len(hits))}
+	for _, h := range hits {
+		resp.Hits = append(resp.Hits, &orderpb.SearchHit{Order: orderconv.ToProto(h.Order), Rank: float32(h.Rank)})
+	}
+	if limit > 0 && len(hits) == limit {
+		resp.NextPageToken = encodePageToken(offset + len(hits))
+	}
+	return resp, nil
+}
+
+func (s *orderGRPCServer) ExportCustomerData(ctx context.Context, req *orderpb.ExportCustomerDataRequest) (*orderpb.ExportCustomerDataResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.ExportCustomerData")
+	defer span.End()
+
+	export, err := s.svc.ExportCustomer(ctx, req.GetCustomerId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.ExportCustomerDataResponse{
+		CustomerId: export.CustomerID,
+		ExportedAt: timestamppb.New(export.ExportedAt),
+		Orders:     make([]*orderpb.Order, 0, len(export.Orders)),
+	}
+	for _, o := range export.Orders {
+		resp.Orders = append(resp.Orders, orderconv.ToProto(o))
+	}
+	return resp, nil
+}
+
+func (s *orderGRPCServer) EraseCustomerData(ctx context.Context, req *orderpb.EraseCustomerDataRequest) (*orderpb.EraseCustomerDataResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.EraseCustomerData")
+	defer span.End()
+
+	res, err := s.svc.EraseCustomer(ctx, req.GetCustomerId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &orderpb.EraseCustomerDataResponse{OrderUids: res.OrderUIDs, ErasedAt: timestamppb.New(res.ErasedAt)}, nil
+}
+
+func (s *orderGRPCServer) QueryAuditLog(ctx context.Context, req *orderpb.QueryAuditLogRequest) (*orderpb.QueryAuditLogResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.QueryAuditLog")
+	defer span.End()
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	filter := audit.Filter{
+		Actor:    req.GetActor(),
+		Action:   req.GetAction(),
+		OrderUID: req.GetOrderUid(),
+		Source:   req.GetSource(),
+		Outcome:  req.GetOutcome(),
+		Limit:    int(req.GetPageSize()),
+		Offset:   offset,
+	}
+	if req.GetFrom() != nil {
+		filter.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		filter.To = req.GetTo().AsTime()
+	}
+	events, err := s.svc.QueryAudit(ctx, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.QueryAuditLogResponse{Events: make([]*orderpb.AuditEvent, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &orderpb.AuditEvent{
+			Id:        e.ID,
+			At:        timestamppb.New(e.At),
+			Actor:     e.Actor,
+			Action:    e.Action,
+			OrderUid:  e.OrderUID,
+			RequestId: e.RequestID,
+			TraceId:   e.TraceID,
+			Source:    e.Source,
+			Outcome:   e.Outcome,
+		})
+	}
+	if filter.Limit > 0 && len(events) == filter.Limit {
+		resp.NextPageToken = encodePageToken(offset + len(events))
+	}
+	return resp, nil
+}
+
+func (s *orderGRPCServer) WatchOrders(req *orderpb.WatchOrdersRequest, srv grpc.ServerStreamingServer[orderpb.OrderEvent]) error {
+	ctx := srv.Context()
+	sub, err := s.svc.WatchOrders(ctx, stream.Filter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+	}, req.GetLastEventId())
+	if err != nil {
+		return toStatus(err)
+	}
+	defer sub.Close()
+	// headers go out now, so clients see the stream open before the first order
+	if err := srv.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if errors.Is(sub.Err(), stream.ErrSlowSubscriber) {
+					return status.Error(codes.ResourceExhausted, sub.Err().Error())
+				}
+				return status.Error(codes.Unavailable, "order stream closed")
+			}
+			if err := srv.Send(toOrderEvent(ev)); err != nil {
+				return err
+			}
+			s.svc.OrderWatched(ctx, ev)
 		}
 	}
-	return &orderpb.GetOrderResponse{Order: modelToProto(order)}, nil
 }
 
+// exportChunkSize is the payload of one ExportChunk.
+const exportChunkSize = 64 << 10
+
+func (s *orderGRPCServer) ExportOrders(req *orderpb.ExportOrdersRequest, srv grpc.ServerStreamingServer[orderpb.ExportChunk]) error {
+	ctx, span := s.tracer.Start(srv.Context(), "grpc.ExportOrders")
+	defer span.End()
+
+	q := export.Query{Format: req.GetFormat(), Columns: req.GetColumns()}
+	if req.GetFrom() != nil {
+		q.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		q.To = req.GetTo().AsTime()
+	}
+	out := bufio.NewWriterSize(chunkWriter{srv}, exportChunkSize)
+	sum, err := s.svc.ExportOrders(ctx, q, out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		return toStatus(err)
+	}
+	srv.SetTrailer(metadata.Pairs("x-export-rows", strconv.FormatInt(sum.Rows, 10), "x-export-sha256", sum.SHA256))
+	return nil
+}
+
+// chunkWriter sends every write as an ExportChunk.
+type chunkWriter struct {
+	srv grpc.ServerStreamingServer[orderpb.ExportChunk]
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w.srv.Send(&orderpb.ExportChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func toOrderEvent(ev stream.Event) *orderpb.OrderEvent {
+	o := ev.Order
+	return &orderpb.OrderEvent{Id: ev.ID, Order: &orderpb.OrderSummary{
+		OrderUid:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerId:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Region:          o.Region,
+		Amount:          int32(o.Amount),
+		Currency:        o.Currency,
+		Items:           int32(o.Items),
+		DateCreated:     timestamppb.New(o.DateCreated),
+	}}
+}
+
+type analyticsGRPCServer struct {
+	orderpb.UnimplementedOrderAnalyticsServer
+	svc    *service.Service
+	tracer trace.Tracer
+}
+
+func (s *analyticsGRPCServer) OrderStats(ctx context.Context, req *orderpb.OrderStatsRequest) (*orderpb.OrderStatsResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.OrderStats")
+	defer span.End()
+
+	q := analytics.OrderQuery{Bucket: req.GetBucket(), GroupBy: req.GetGroupBy()}
+	if req.GetFrom() != nil {
+		q.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		q.To = req.GetTo().AsTime()
+	}
+	stats, err := s.svc.OrderStats(ctx, q)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.OrderStatsResponse{Rows: make([]*orderpb.OrderStatsRow, 0, len(stats))}
+	for _, st := range stats {
+		row := &orderpb.OrderStatsRow{
+			Start:           timestamppb.New(st.Start),
+			Currency:        st.Currency,
+			Provider:        st.Provider,
+			DeliveryService: st.DeliveryService,
+			Orders:          st.Orders,
+			Items:           st.Items,
+			AvgBasketSize:   st.AvgBasketSize(),
+		}
+		for _, r := range st.Revenue {
+			row.Revenue = append(row.Revenue, &orderpb.Revenue{
+				Currency:      r.Currency,
+				Amount:        r.Amount,
+				Orders:        r.Orders,
+				AvgOrderValue: r.AvgOrderValue(),
+			})
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+	return resp, nil
+}
+
+func (s *analyticsGRPCServer) TopItems(ctx context.Context, req *orderpb.TopItemsRequest) (*orderpb.TopItemsResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "grpc.TopItems")
+	defer span.End()
+
+	q := analytics.ItemQuery{By: req.GetBy(), Metric: req.GetMetric(), Currency: req.GetCurrency(), Limit: int(req.GetLimit())}
+	if req.GetFrom() != nil {
+		q.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		q.To = req.GetTo().AsTime()
+	}
+	items, err := s.svc.TopItems(ctx, q)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderpb.TopItemsResponse{Rows: make([]*orderpb.ItemStatsRow, 0, len(items))}
+	for _, it := range items {
+		resp.Rows = append(resp.Rows, &orderpb.ItemStatsRow{
+			Brand:    it.Brand,
+			NmId:     it.NmID,
+			Name:     it.Name,
+			Quantity: it.Quantity,
+			Orders:   it.Orders,
+			Revenue:  it.Revenue,
+		})
+	}
+	return resp, nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrAuditDisabled), errors.Is(err, service.ErrStreamDisabled),
+		errors.Is(err, service.ErrAnalyticsDisabled), errors.Is(err, service.ErrExportDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("bad offset %q", raw)
+	}
+	return offset, nil
+}
+
+// sourceUnaryInterceptor tags the context with the transport for the audit
+// log: calls relayed by the HTTP gateway carry gatewaySourceKey.
+func sourceUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		source := audit.SourceGRPC
+		if md, _ := metadata.FromIncomingContext(ctx); viaGateway(md) {
+			source = audit.SourceHTTP
+		}
+		return handler(audit.WithSource(ctx, source), req)
+	}
+}
+
+// streamFromUnary runs a unary interceptor once when a stream opens; the
+// stream handler sees the context the interceptor established.
+func streamFromUnary(u grpc.UnaryServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		unaryInfo := &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod}
+		_, err := u(ss.Context(), nil, unaryInfo, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			return nil, handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		})
+		return err
+	}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
 func requestIDUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		md, _ := metadata.FromIncomingContext(ctx)
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/ratelimit"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestPageToken(t *testing.T) {
+	off, err := decodePageToken(encodePageToken(150))
+	if err != nil || off != 150 {
+		t.Fatalf("round trip: %d, %v", off, err)
+	}
+	if off, err := decodePageToken(""); err != nil || off != 0 {
+		t.Fatalf("empty token: %d, %v", off, err)
+	}
+	if _, err := decodePageToken("!!"); err == nil {
+		t.Fatalf("expected error for garbage token")
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	md := rateLimitHeaders(ratelimit.Decision{Limit: 10, Remaining: 0, Reset: 4500 * time.Millisecond, RetryAfter: 200 * time.Millisecond})
+	want := map[string]string{"ratelimit-limit": "10", "ratelimit-remaining": "0", "ratelimit-reset": "5", "retry-after": "1"}
+	for k, v := range want {
+		if got := md.Get(k); len(got) != 1 || got[0] != v {
+			t.Fatalf("%s = %v, want %s", k, got, v)
+		}
+	}
+	if h, ok := gatewayOutgoingHeaderMatcher("retry-after"); !ok || h != "Retry-After" {
+		t.Fatalf("retry-after forwarded as %q", h)
+	}
+	if h, _ := gatewayOutgoingHeaderMatcher("x-trace"); h != "Grpc-Metadata-x-trace" {
+		t.Fatalf("other metadata forwarded as %q", h)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	direct := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 5000}})
+	ctx := metadata.NewIncomingContext(direct, metadata.Pairs("x-forwarded-for", "1.1.1.1, 10.0.0.7"))
+	if got := clientKey(ctx); got != "ip:10.0.0.9" {
+		t.Fatalf("direct caller keyed by its own x-forwarded-for: %q", got)
+	}
+	ctx = metadata.NewIncomingContext(direct, metadata.Pairs("x-forwarded-for", "1.1.1.1, 10.0.0.7", gatewaySourceKey, "http"))
+	if got := clientKey(ctx); got != "ip:10.0.0.9" {
+		t.Fatalf("forged gateway marker trusted: %q", got)
+	}
+	ctx = metadata.NewIncomingContext(direct, metadata.Pairs("x-forwarded-for", "1.1.1.1, 10.0.0.7", gatewaySourceKey, gatewayToken))
+	if got := clientKey(ctx); got != "ip:10.0.0.7" {
+		t.Fatalf("gateway client key %q", got)
+	}
+	ctx = auth.WithIdentity(ctx, &auth.Identity{Subject: "alice"})
+	if got := clientKey(ctx); got != "sub:alice" {
+		t.Fatalf("authenticated client key %q", got)
+	}
+}
@@ -2,14 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"orderservice/internal/auth"
 	"orderservice/pkg/api/orderpb"
 	"orderservice/pkg/models"
+	"orderservice/static"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,34 +25,362 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// gatewaySourceKey marks gRPC calls relayed by the HTTP gateway with
+// gatewayToken. The token is random per process, so direct gRPC callers
+// cannot pass for the gateway.
+const gatewaySourceKey = "x-gateway-source"
+
+var gatewayToken = rand.Text()
+
 type HTTPServer struct {
-	gateway *runtime.ServeMux
+	gateway     *runtime.ServeMux
+	client      orderpb.OrderServiceClient // streaming calls the gateway cannot relay (SSE, exports)
+	orderMaxAge time.Duration
 }
 
 var _ models.Order
 
+// listOrdersResponse documents the ListOrders gateway response for Swagger.
+type listOrdersResponse struct {
+	Orders        []models.Order `json:"orders"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+// searchHitDoc documents a search result for Swagger.
+type searchHitDoc struct {
+	Order models.Order `json:"order"`
+	Rank  float32      `json:"rank"`
+}
+
+// searchOrdersResponse documents the SearchOrders gateway response for Swagger.
+type searchOrdersResponse struct {
+	Hits          []searchHitDoc `json:"hits"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+// auditEventDoc documents an audit log entry for Swagger.
+type auditEventDoc struct {
+	ID        string `json:"id"`
+	At        string `json:"at"`
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	OrderUID  string `json:"orderUid"`
+	RequestID string `json:"requestId"`
+	TraceID   string `json:"traceId"`
+	Source    string `json:"source"`
+	Outcome   string `json:"outcome"`
+}
+
+// auditLogResponse documents the QueryAuditLog gateway response for Swagger.
+type auditLogResponse struct {
+	Events        []auditEventDoc `json:"events"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+// customerExportResponse documents the ExportCustomerData gateway response for Swagger.
+type customerExportResponse struct {
+	CustomerID string         `json:"customerId"`
+	ExportedAt string         `json:"exportedAt"`
+	Orders     []models.Order `json:"orders"`
+}
+
+// customerErasureResponse documents the EraseCustomerData gateway response for Swagger.
+type customerErasureResponse struct {
+	OrderUIDs []string `json:"orderUids"`
+	ErasedAt  string   `json:"erasedAt"`
+}
+
+// revenueDoc documents the revenue of one currency for Swagger.
+type revenueDoc struct {
+	Currency      string  `json:"currency"`
+	Amount        string  `json:"amount"`
+	Orders        string  `json:"orders"`
+	AvgOrderValue float64 `json:"avgOrderValue"`
+}
+
+// orderStatsRowDoc documents one bucket and group of order statistics for Swagger.
+type orderStatsRowDoc struct {
+	Start           string       `json:"start"`
+	Currency        string       `json:"currency"`
+	Provider        string       `json:"provider"`
+	DeliveryService string       `json:"deliveryService"`
+	Orders          string       `json:"orders"`
+	Items           string       `json:"items"`
+	AvgBasketSize   float64      `json:"avgBasketSize"`
+	Revenue         []revenueDoc `json:"revenue"`
+}
+
+// orderStatsResponse documents the OrderStats gateway response for Swagger.
+type orderStatsResponse struct {
+	Rows []orderStatsRowDoc `json:"rows"`
+}
+
+// itemStatsRowDoc documents a ranked brand or item for Swagger.
+type itemStatsRowDoc struct {
+	Brand    string `json:"brand"`
+	NmID     string `json:"nmId"`
+	Name     string `json:"name"`
+	Quantity string `json:"quantity"`
+	Orders   string `json:"orders"`
+	Revenue  string `json:"revenue"`
+}
+
+// topItemsResponse documents the TopItems gateway response for Swagger.
+type topItemsResponse struct {
+	Rows []itemStatsRowDoc `json:"rows"`
+}
+
 // handleOrder proxies HTTP calls to gRPC gateway.
 //
 //	@Summary		Get order by UID
-//	@Description	Returns order by uid from cache or DB
+//	@Description	Returns order by uid from cache or DB. Responses carry an ETag; a matching If-None-Match yields 304.
 //	@Tags			orders
-//	@Param			order_uid	path		string	true	"Order UID"
-//	@Success		200			{object}	models.Order
-//	@Failure		400			{string}	string
-//	@Failure		404			{string}	string
+//	@Param			order_uid		path		string	true	"Order UID"
+//	@Param			If-None-Match	header		string	false	"ETag of a copy the client already has"
+//	@Success		200				{object}	models.Order
+//	@Success		304				{string}	string	"Not Modified"
+//	@Failure		400				{string}	string
+//	@Failure		401				{string}	string
+//	@Failure		403				{string}	string
+//	@Failure		404				{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
 //	@Router			/order/{order_uid} [get]
 func (s *HTTPServer) handleOrder(w http.ResponseWriter, r *http.Request) {
+	conditionalGET(s.orderMaxAge, s.gateway).ServeHTTP(w, r)
+}
+
+// handleOrderByTrack proxies a track number lookup to the gRPC gateway.
+//
+//	@Summary		Get order by track number
+//	@Description	Returns the newest order with the track number; carries an ETag like GET /order/{order_uid}
+//	@Tags			orders
+//	@Param			track_number	path		string	true	"Track number"
+//	@Param			If-None-Match	header		string	false	"ETag of a copy the client already has"
+//	@Success		200				{object}	models.Order
+//	@Success		304				{string}	string	"Not Modified"
+//	@Failure		401				{string}	string
+//	@Failure		403				{string}	string
+//	@Failure		404				{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/by-track/{track_number} [get]
+func (s *HTTPServer) handleOrderByTrack(w http.ResponseWriter, r *http.Request) {
+	conditionalGET(s.orderMaxAge, s.gateway).ServeHTTP(w, r)
+}
+
+// handleOrderByTransaction proxies a payment transaction lookup to the gRPC gateway.
+//
+//	@Summary		Get order by payment transaction
+//	@Description	Returns the newest order paid by the transaction; carries an ETag like GET /order/{order_uid}
+//	@Tags			orders
+//	@Param			transaction		path		string	true	"Payment transaction"
+//	@Param			If-None-Match	header		string	false	"ETag of a copy the client already has"
+//	@Success		200				{object}	models.Order
+//	@Success		304				{string}	string	"Not Modified"
+//	@Failure		401				{string}	string
+//	@Failure		403				{string}	string
+//	@Failure		404				{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/by-transaction/{transaction} [get]
+func (s *HTTPServer) handleOrderByTransaction(w http.ResponseWriter, r *http.Request) {
+	conditionalGET(s.orderMaxAge, s.gateway).ServeHTTP(w, r)
+}
+
+// handleOrdersByItem proxies an item lookup to the gRPC gateway.
+//
+//	@Summary		Find orders by item
+//	@Description	Returns a page of the orders containing an item, newest first; exactly one of rid, chrt_id and nm_id is required
+//	@Tags			orders
+//	@Param			rid			query		string	false	"Item rid"
+//	@Param			chrt_id		query		int		false	"Item chrt_id"
+//	@Param			nm_id		query		int		false	"Item nm_id"
+//	@Param			page_size	query		int		false	"Page size (default 50, max 500)"
+//	@Param			page_token	query		string	false	"Token from the previous page"
+//	@Success		200			{object}	listOrdersResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/by-item [get]
+func (s *HTTPServer) handleOrdersByItem(w http.ResponseWriter, r *http.Request) {
 	s.gateway.ServeHTTP(w, r)
 }
 
-func StartHTTPServer(ctx context.Context, addr string, grpcAddr string, logger *slog.Logger) error {
+// handleListOrders proxies order listing to the gRPC gateway.
+//
+//	@Summary		List orders
+//	@Description	Returns a page of orders filtered by customer, delivery service and creation date
+//	@Tags			orders
+//	@Param			customer_id			query		string	false	"Customer ID"
+//	@Param			delivery_service	query		string	false	"Delivery service"
+//	@Param			email				query		string	false	"Delivery e-mail (exact match)"
+//	@Param			phone				query		string	false	"Delivery phone (exact match)"
+//	@Param			from				query		string	false	"Created at or after (RFC 3339)"
+//	@Param			to					query		string	false	"Created before (RFC 3339)"
+//	@Param			page_size			query		int		false	"Page size (default 50, max 500)"
+//	@Param			page_token			query		string	false	"Token from the previous page"
+//	@Success		200					{object}	listOrdersResponse
+//	@Failure		400					{string}	string
+//	@Failure		401					{string}	string
+//	@Failure		403					{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders [get]
+func (s *HTTPServer) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleSearchOrders proxies order search to the gRPC gateway.
+//
+//	@Summary		Search orders
+//	@Description	Full-text search over track numbers, recipient, items and address, most relevant first.
+//	@Description	Words match by prefix, "quoted phrases" exactly; field:value narrows a term (name, address, item, phone, track, brand, customer, service, city, region, uid, amount, items, created); terms combine with AND, OR, NOT or -, and parentheses.
+//	@Tags			orders
+//	@Param			query		query		string	true	"Search query, e.g. ivanov phone:4567 -service:cdek"
+//	@Param			page_size	query		int		false	"Page size (default 50, max 500)"
+//	@Param			page_token	query		string	false	"Token from the previous page"
+//	@Success		200			{object}	searchOrdersResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/search [get]
+func (s *HTTPServer) handleSearchOrders(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleExportCustomer proxies a data subject export to the gRPC gateway.
+//
+//	@Summary		Export customer data
+//	@Description	Returns every order of the customer with personal data unmasked; the export is audited
+//	@Tags			privacy
+//	@Param			customer_id	path		string	true	"Customer ID"
+//	@Success		200			{object}	customerExportResponse
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/admin/customers/{customer_id}/export [get]
+func (s *HTTPServer) handleExportCustomer(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleEraseCustomer proxies a data subject erasure to the gRPC gateway.
+//
+//	@Summary		Erase customer data
+//	@Description	Anonymizes delivery data and the customer id of every order, keeping payments and items; the erasure is audited
+//	@Tags			privacy
+//	@Param			customer_id	path		string	true	"Customer ID"
+//	@Success		200			{object}	customerErasureResponse
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/admin/customers/{customer_id}/erase [post]
+func (s *HTTPServer) handleEraseCustomer(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleAuditLog proxies audit log search to the gRPC gateway.
+//
+//	@Summary		Search the audit log
+//	@Description	Returns audit events, newest first
+//	@Tags			audit
+//	@Param			actor		query		string	false	"Actor (subject)"
+//	@Param			action		query		string	false	"read, create, update-status or erase"
+//	@Param			order_uid	query		string	false	"Order UID"
+//	@Param			source		query		string	false	"http, grpc or kafka"
+//	@Param			outcome		query		string	false	"ok, denied, not_found, invalid or error"
+//	@Param			from		query		string	false	"At or after (RFC 3339)"
+//	@Param			to			query		string	false	"Before (RFC 3339)"
+//	@Param			page_size	query		int		false	"Page size (default 100, max 1000)"
+//	@Param			page_token	query		string	false	"Token from the previous page"
+//	@Success		200			{object}	auditLogResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (s *HTTPServer) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleOrderStats proxies order statistics to the gRPC gateway.
+//
+//	@Summary		Order statistics
+//	@Description	Orders, items, average basket size and revenue per currency for each time bucket and group, oldest bucket first. Served from daily rollups (UTC days).
+//	@Tags			analytics
+//	@Param			from		query		string		true	"First day (RFC 3339)"
+//	@Param			to			query		string		true	"End of the range, exclusive; a partial last day is included (RFC 3339)"
+//	@Param			bucket		query		string		false	"day (default), week, month or total"
+//	@Param			group_by	query		[]string	false	"currency, provider, delivery_service"	collectionFormat(multi)
+//	@Success		200			{object}	orderStatsResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/orders [get]
+func (s *HTTPServer) handleOrderStats(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// handleTopItems proxies the best-selling brands or items to the gRPC gateway.
+//
+//	@Summary		Top brands and items
+//	@Description	Ranks brands or items by quantity sold, or by revenue within one currency.
+//	@Tags			analytics
+//	@Param			from		query		string	true	"First day (RFC 3339)"
+//	@Param			to			query		string	true	"End of the range, exclusive; a partial last day is included (RFC 3339)"
+//	@Param			by			query		string	false	"brand (default) or item"
+//	@Param			metric		query		string	false	"quantity (default) or revenue"
+//	@Param			currency	query		string	false	"Only orders paid in this currency; required to rank by revenue"
+//	@Param			limit		query		int		false	"Entries (default 10, max 100)"
+//	@Success		200			{object}	topItemsResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/top-items [get]
+func (s *HTTPServer) handleTopItems(w http.ResponseWriter, r *http.Request) {
+	s.gateway.ServeHTTP(w, r)
+}
+
+// consoleHandler serves the operator console embedded in the binary. The page
+// itself is public; its API calls carry the operator's credentials.
+func consoleHandler() http.Handler {
+	return http.FileServerFS(static.FS)
+}
+
+// StartHTTPServer serves the gateway, Swagger, metrics and the console. API
+// routes require credentials when authn is non-nil; orderMaxAge is the
+// Cache-Control max-age of order responses. GET /admin/flags is served from
+// flags unless it is nil.
+func StartHTTPServer(ctx context.Context, addr string, grpcAddr string, orderMaxAge time.Duration, authn *auth.Authenticator, flags FlagReader, logger *slog.Logger) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	return ServeHTTP(ctx, lis, grpcAddr, orderMaxAge, authn, flags, logger)
+}
+
+// ServeHTTP is StartHTTPServer on a bound listener.
+func ServeHTTP(ctx context.Context, lis net.Listener, grpcAddr string, orderMaxAge time.Duration, authn *auth.Authenticator, flags FlagReader, logger *slog.Logger) error {
 	gatewayMux := runtime.NewServeMux(
 		runtime.WithErrorHandler(runtime.DefaultHTTPErrorHandler),
+		runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(gatewayOutgoingHeaderMatcher),
 		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			md := metadata.Pairs(gatewaySourceKey, gatewayToken)
 			if reqID := r.Header.Get("X-Request-ID"); reqID != "" {
-				return metadata.Pairs("x-request-id", reqID)
+				md.Set("x-request-id", reqID)
 			}
-			return metadata.MD{}
+			return md
 		}),
 	)
 
@@ -59,25 +391,54 @@ func StartHTTPServer(ctx context.Context, addr string, grpcAddr string, logger *
 	if err := orderpb.RegisterOrderServiceHandlerFromEndpoint(ctx, gatewayMux, grpcAddr, dialOpts); err != nil {
 		return fmt.Errorf("register gateway: %w", err)
 	}
+	if err := orderpb.RegisterOrderAnalyticsHandlerFromEndpoint(ctx, gatewayMux, grpcAddr, dialOpts); err != nil {
+		return fmt.Errorf("register analytics gateway: %w", err)
+	}
 
-	srv := &HTTPServer{gateway: gatewayMux}
+	conn, err := grpc.NewClient(grpcAddr, dialOpts...)
+	if err != nil {
+		return fmt.Errorf("dial grpc: %w", err)
+	}
+	defer conn.Close()
+
+	srv := &HTTPServer{gateway: gatewayMux, client: orderpb.NewOrderServiceClient(conn), orderMaxAge: orderMaxAge}
+	requireAuth := authMiddleware(authn, logger)
 	mux := http.NewServeMux()
-	mux.Handle("/order/", http.HandlerFunc(srv.handleOrder))
+	mux.Handle("/order/", requireAuth(http.HandlerFunc(srv.handleOrder)))
+	mux.Handle("/orders", requireAuth(http.HandlerFunc(srv.handleListOrders)))
+	mux.Handle("GET /orders/search", requireAuth(http.HandlerFunc(srv.handleSearchOrders)))
+	mux.Handle("GET /orders/by-track/{track_number}", requireAuth(http.HandlerFunc(srv.handleOrderByTrack)))
+	mux.Handle("GET /orders/by-transaction/{transaction}", requireAuth(http.HandlerFunc(srv.handleOrderByTransaction)))
+	mux.Handle("GET /orders/by-item", requireAuth(http.HandlerFunc(srv.handleOrdersByItem)))
+	mux.Handle("GET /admin/customers/{customer_id}/export", requireAuth(http.HandlerFunc(srv.handleExportCustomer)))
+	mux.Handle("POST /admin/customers/{customer_id}/erase", requireAuth(http.HandlerFunc(srv.handleEraseCustomer)))
+	mux.Handle("GET /admin/audit", requireAuth(http.HandlerFunc(srv.handleAuditLog)))
+	if flags != nil {
+		mux.Handle("GET /admin/flags", requireAuth(handleFlags(flags)))
+	}
+	mux.Handle("GET /analytics/orders", requireAuth(http.HandlerFunc(srv.handleOrderStats)))
+	mux.Handle("GET /analytics/top-items", requireAuth(http.HandlerFunc(srv.handleTopItems)))
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 	mux.Handle("/metrics", promhttp.Handler())
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	console := consoleHandler()
+	mux.Handle("/static/", http.StripPrefix("/static/", console))
 	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(r.URL.Path, "/order/") {
-			srv.handleOrder(w, r)
+			requireAuth(http.HandlerFunc(srv.handleOrder)).ServeHTTP(w, r)
 			return
 		}
-		http.FileServer(http.Dir("static")).ServeHTTP(w, r)
+		console.ServeHTTP(w, r)
 	}))
 
-	handler := chainMiddlewares(otelhttp.NewHandler(mux, "http.server"), logger)
+	// the event stream and exports bypass otelhttp: its writer cannot lift
+	// the write timeout, and a span as long as the connection is of no use
+	root := http.NewServeMux()
+	root.Handle("GET /orders/stream", requireAuth(http.HandlerFunc(srv.handleOrderStream)))
+	root.Handle("GET /orders/export", requireAuth(http.HandlerFunc(srv.handleOrderExport)))
+	root.Handle("/", otelhttp.NewHandler(mux, "http.server"))
+	handler := chainMiddlewares(root, logger)
 
 	server := &http.Server{
-		Addr:         addr,
 		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -90,7 +451,7 @@ func StartHTTPServer(ctx context.Context, addr string, grpcAddr string, logger *
 		_ = server.Shutdown(shutCtx)
 	}()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
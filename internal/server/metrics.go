@@ -40,6 +40,9 @@ func (r *statusRecorder) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+// Unwrap lets http.ResponseController reach the connection (flushes, deadlines).
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
 func observeMetrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
@@ -60,8 +63,17 @@ func normalizePath(path string) string {
 	if strings.HasPrefix(path, "/order/") {
 		return "/order/{order_uid}"
 	}
+	if strings.HasPrefix(path, "/orders/by-track/") {
+		return "/orders/by-track/{track_number}"
+	}
+	if strings.HasPrefix(path, "/orders/by-transaction/") {
+		return "/orders/by-transaction/{transaction}"
+	}
 	if strings.HasPrefix(path, "/swagger") {
 		return "/swagger"
 	}
+	if strings.HasPrefix(path, "/static/") {
+		return "/static"
+	}
 	return path
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
@@ -29,14 +30,18 @@ func loggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			slot := &identitySlot{}
 			start := time.Now()
-			next.ServeHTTP(rec, r)
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), identitySlotKey{}, slot)))
 
 			reqID := observability.RequestIDFromContext(r.Context())
 			l := logger
 			if reqID != "" {
 				l = l.With("req_id", reqID)
 			}
+			if id := slot.id; id != nil {
+				l = l.With("subject", id.Subject)
+			}
 			l.Info("request",
 				"method", r.Method,
 				"path", r.URL.Path,
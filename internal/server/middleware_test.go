@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"orderservice/internal/observability"
@@ -17,6 +18,12 @@ func TestNormalizePath(t *testing.T) {
 	if got := normalizePath("/swagger/index.html"); got != "/swagger" {
 		t.Fatalf("normalize swagger path: %s", got)
 	}
+	if got := normalizePath("/static/console.js"); got != "/static" {
+		t.Fatalf("normalize console asset path: %s", got)
+	}
+	if got := normalizePath("/orders/by-track/WBILMTESTTRACK"); got != "/orders/by-track/{track_number}" {
+		t.Fatalf("normalize track lookup path: %s", got)
+	}
 }
 
 func TestRequestIDMiddleware(t *testing.T) {
@@ -37,3 +44,18 @@ func TestRequestIDMiddleware(t *testing.T) {
 		t.Fatalf("request id not in context")
 	}
 }
+
+func TestConsoleEmbedded(t *testing.T) {
+	// tests run from internal/server, where no static directory exists
+	h := consoleHandler()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<title>Orders Console</title>") {
+		t.Fatalf("index: %d %.80q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	http.StripPrefix("/static/", h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/static/console.js", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatalf("console.js: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/ratelimit"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// rateLimitUnaryInterceptor applies per-client limits to every RPC, keyed by
+// method name. It runs after authentication, so authenticated callers are
+// limited by subject and anonymous ones by address. A nil limiter disables it.
+func rateLimitUnaryInterceptor(l *ratelimit.Limiter, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l == nil {
+			return handler(ctx, req)
+		}
+		route, client := path.Base(info.FullMethod), clientKey(ctx)
+		d := l.Allow(ctx, route, client)
+		_ = grpc.SetHeader(ctx, rateLimitHeaders(d))
+		if !d.Allowed {
+			logger.Warn("rate limited", "method", info.FullMethod, "client", client)
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", d.RetryAfter.Round(time.Millisecond))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// clientKey identifies the caller: the subject when authenticated, otherwise
+// the address. Calls relayed by the gateway are keyed by the last
+// X-Forwarded-For hop, the one the gateway saw itself; other callers could
+// set any X-Forwarded-For, so their peer address is used.
+func clientKey(ctx context.Context) string {
+	if id := auth.FromContext(ctx); id != nil {
+		return "sub:" + id.Subject
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get("x-forwarded-for"); len(vals) > 0 && viaGateway(md) {
+		hops := strings.Split(vals[len(vals)-1], ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+			return "ip:" + ip
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "ip:unknown"
+}
+
+// viaGateway reports whether the call was relayed by the HTTP gateway.
+func viaGateway(md metadata.MD) bool {
+	vals := md.Get(gatewaySourceKey)
+	return len(vals) > 0 && vals[0] == gatewayToken
+}
+
+// rateLimitHeaders renders d as RateLimit-* (IETF draft) and Retry-After.
+func rateLimitHeaders(d ratelimit.Decision) metadata.MD {
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(d.Limit),
+		"ratelimit-remaining", strconv.Itoa(d.Remaining),
+		"ratelimit-reset", strconv.Itoa(ceilSeconds(d.Reset)),
+	)
+	if !d.Allowed {
+		md.Set("retry-after", strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+	}
+	return md
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// gatewayOutgoingHeaderMatcher passes rate limit metadata and the ETag to
+// HTTP clients as plain headers; other metadata keeps the gateway's
+// Grpc-Metadata- prefix.
+func gatewayOutgoingHeaderMatcher(key string) (string, bool) {
+	switch k := strings.ToLower(key); {
+	case k == "etag":
+		return "ETag", true
+	case k == "retry-after", strings.HasPrefix(k, "ratelimit-"):
+		return textproto.CanonicalMIMEHeaderKey(k), true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
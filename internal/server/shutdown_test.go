@@ -32,7 +32,7 @@ func TestServerShutdown(t *testing.T) {
 		cancel()
 	}()
 
-	if err := StartHTTPServer(ctx, "127.0.0.1:0", grpcLis.Addr().String(), logger); err != nil {
+	if err := StartHTTPServer(ctx, "127.0.0.1:0", grpcLis.Addr().String(), time.Minute, nil, nil, logger); err != nil {
 		t.Fatalf("server error: %v", err)
 	}
 }
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"orderservice/internal/observability"
+	"orderservice/internal/stream"
+	"orderservice/pkg/api/orderpb"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const sseHeartbeat = 15 * time.Second
+
+// handleOrderStream relays WatchOrders as Server-Sent Events.
+//
+//	@Summary		Stream new orders
+//	@Description	Server-Sent Events: one "order" event per saved order, with the event id usable as Last-Event-ID to resume.
+//	@Tags			orders
+//	@Produce		text/event-stream
+//	@Param			customer_id			query		string	false	"Customer ID"
+//	@Param			delivery_service	query		string	false	"Delivery service"
+//	@Param			last_event_id		query		int		false	"Resume after this event id (or the Last-Event-ID header)"
+//	@Success		200					{object}	stream.Event
+//	@Failure		400					{string}	string
+//	@Failure		401					{string}	string
+//	@Failure		403					{string}	string
+//	@Failure		429					{string}	string
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/orders/stream [get]
+func (s *HTTPServer) handleOrderStream(w http.ResponseWriter, r *http.Request) {
+	req := &orderpb.WatchOrdersRequest{
+		CustomerId:      r.URL.Query().Get("customer_id"),
+		DeliveryService: r.URL.Query().Get("delivery_service"),
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid last event id", http.StatusBadRequest)
+			return
+		}
+		req.LastEventId = id
+	}
+
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(r.Context(), streamMetadata(r)))
+	defer cancel()
+	st, err := s.client.WatchOrders(ctx, req)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	// a nil header means the call failed before streaming; Recv has the status
+	md, _ := st.Header()
+	forwardHeaders(w, md)
+	if md == nil {
+		_, err := st.Recv()
+		writeStatusError(w, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{}) // the stream outlives the server write timeout
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	events := make(chan *orderpb.OrderEvent)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			ev, err := st.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errc:
+			// the client reconnects with Last-Event-ID; tell it why we stopped
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", jsonString(status.Convert(err).Message()))
+			_ = rc.Flush()
+			return
+		case ev := <-events:
+			raw, _ := json.Marshal(fromOrderEvent(ev))
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", ev.GetId(), raw); err != nil {
+				return
+			}
+			_ = rc.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			_ = rc.Flush()
+		}
+	}
+}
+
+// streamMetadata forwards what the gateway would: credentials, request id,
+// the gateway source marker and the client address.
+func streamMetadata(r *http.Request) metadata.MD {
+	md := metadata.Pairs(gatewaySourceKey, gatewayToken)
+	if v := r.Header.Get("Authorization"); v != "" {
+		md.Set("authorization", v)
+	}
+	if v := r.Header.Get(apiKeyHeader); v != "" {
+		md.Set("x-api-key", v)
+	}
+	if reqID := observability.RequestIDFromContext(r.Context()); reqID != "" {
+		md.Set("x-request-id", reqID)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		fwd := host
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			fwd = prior + ", " + host
+		}
+		md.Set("x-forwarded-for", fwd)
+	}
+	return md
+}
+
+func forwardHeaders(w http.ResponseWriter, md metadata.MD) {
+	for k, vals := range md {
+		if h, _ := gatewayOutgoingHeaderMatcher(k); h != runtime.MetadataHeaderPrefix+k {
+			for _, v := range vals {
+				w.Header().Add(h, v)
+			}
+		}
+	}
+}
+
+func writeStatusError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(runtime.HTTPStatusFromCode(st.Code()))
+	fmt.Fprintf(w, `{"code":%d,"message":%s,"details":[]}`, st.Code(), jsonString(st.Message()))
+}
+
+func jsonString(s string) string {
+	raw, _ := json.Marshal(s)
+	return string(raw)
+}
+
+func fromOrderEvent(ev *orderpb.OrderEvent) stream.Event {
+	o := ev.GetOrder()
+	return stream.Event{ID: ev.GetId(), Order: stream.Summary{
+		OrderUID:        o.GetOrderUid(),
+		TrackNumber:     o.GetTrackNumber(),
+		CustomerID:      o.GetCustomerId(),
+		DeliveryService: o.GetDeliveryService(),
+		Region:          o.GetRegion(),
+		Amount:          int(o.GetAmount()),
+		Currency:        o.GetCurrency(),
+		Items:           int(o.GetItems()),
+		DateCreated:     o.GetDateCreated().AsTime(),
+	}}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/pkg/api/orderpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeWatchClient struct {
+	orderpb.OrderServiceClient
+	req    *orderpb.WatchOrdersRequest
+	md     metadata.MD
+	stream *fakeWatchStream
+}
+
+func (c *fakeWatchClient) WatchOrders(ctx context.Context, req *orderpb.WatchOrdersRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[orderpb.OrderEvent], error) {
+	c.req = req
+	c.md, _ = metadata.FromOutgoingContext(ctx)
+	return c.stream, nil
+}
+
+type fakeWatchStream struct {
+	grpc.ClientStream
+	header metadata.MD
+	events []*orderpb.OrderEvent
+	delay  time.Duration
+	err    error
+}
+
+func (s *fakeWatchStream) Header() (metadata.MD, error) { return s.header, nil }
+
+func (s *fakeWatchStream) Recv() (*orderpb.OrderEvent, error) {
+	time.Sleep(s.delay)
+	if len(s.events) == 0 {
+		return nil, s.err
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev, nil
+}
+
+func TestOrderStreamSSE(t *testing.T) {
+	client := &fakeWatchClient{stream: &fakeWatchStream{
+		header: metadata.Pairs("ratelimit-remaining", "9"),
+		events: []*orderpb.OrderEvent{
+			{Id: 7, Order: &orderpb.OrderSummary{OrderUid: "o7", CustomerId: "alice"}},
+			{Id: 8, Order: &orderpb.OrderSummary{OrderUid: "o8", CustomerId: "alice"}},
+		},
+		err: status.Error(codes.Unavailable, "order stream closed"),
+	}}
+	srv := &HTTPServer{client: client}
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/stream?customer_id=alice", nil)
+	req.Header.Set("Last-Event-ID", "6")
+	req.Header.Set("Authorization", "Bearer t")
+	rr := httptest.NewRecorder()
+	srv.handleOrderStream(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/event-stream" || rr.Header().Get("RateLimit-Remaining") != "9" {
+		t.Fatalf("response %d, headers %v", rr.Code, rr.Header())
+	}
+	if client.req.GetCustomerId() != "alice" || client.req.GetLastEventId() != 6 {
+		t.Fatalf("request %+v", client.req)
+	}
+	if client.md.Get("authorization")[0] != "Bearer t" || client.md.Get(gatewaySourceKey)[0] != gatewayToken {
+		t.Fatalf("metadata %v", client.md)
+	}
+	body, _ := io.ReadAll(rr.Body)
+	for _, want := range []string{
+		"id: 7\nevent: order\ndata: {\"id\":7,\"order\":{\"order_uid\":\"o7\"",
+		"id: 8\nevent: order\n",
+		"event: error\ndata: \"order stream closed\"\n\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("body %q lacks %q", body, want)
+		}
+	}
+}
+
+func TestOrderStreamErrors(t *testing.T) {
+	srv := &HTTPServer{client: &fakeWatchClient{stream: &fakeWatchStream{err: status.Error(codes.PermissionDenied, "denied")}}}
+	rr := httptest.NewRecorder()
+	srv.handleOrderStream(rr, httptest.NewRequest(http.MethodGet, "/orders/stream?customer_id=bob", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("denied watch: %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.handleOrderStream(rr, httptest.NewRequest(http.MethodGet, "/orders/stream?last_event_id=x", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad last event id: %d", rr.Code)
+	}
+}
+
+func TestOrderStreamOutlivesWriteTimeout(t *testing.T) {
+	srv := &HTTPServer{client: &fakeWatchClient{stream: &fakeWatchStream{
+		header: metadata.MD{},
+		events: []*orderpb.OrderEvent{{Id: 1, Order: &orderpb.OrderSummary{OrderUid: "late"}}},
+		delay:  200 * time.Millisecond,
+		err:    io.EOF,
+	}}}
+	ts := httptest.NewUnstartedServer(chainMiddlewares(http.HandlerFunc(srv.handleOrderStream), slog.New(slog.NewTextHandler(io.Discard, nil))))
+	ts.Config.WriteTimeout = 50 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/orders/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"order_uid":"late"`) {
+		t.Fatalf("event after the write timeout lost: %q", body)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"orderservice/internal/analytics"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrderStats returns order counts, items and revenue per time bucket and
+// group, oldest bucket first.
+func (s *Service) OrderStats(ctx context.Context, q analytics.OrderQuery) ([]analytics.OrderStats, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	if s.stats == nil {
+		return nil, ErrAnalyticsDisabled
+	}
+	ctx, span := s.tracer.Start(ctx, "service.OrderStats")
+	defer span.End()
+
+	if err := s.permit(ctx, OpAnalyticsRead); err != nil {
+		return nil, err
+	}
+	stats, err := s.stats.OrderStats(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("order stats: %w", err)
+	}
+	span.SetAttributes(attribute.String("bucket", q.Bucket), attribute.Int("rows", len(stats)))
+	return stats, nil
+}
+
+// TopItems ranks the brands or items sold in the range.
+func (s *Service) TopItems(ctx context.Context, q analytics.ItemQuery) ([]analytics.ItemStats, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	if s.stats == nil {
+		return nil, ErrAnalyticsDisabled
+	}
+	ctx, span := s.tracer.Start(ctx, "service.TopItems")
+	defer span.End()
+
+	if err := s.permit(ctx, OpAnalyticsRead); err != nil {
+		return nil, err
+	}
+	items, err := s.stats.TopItems(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("top items: %w", err)
+	}
+	span.SetAttributes(attribute.String("by", q.By), attribute.Int("rows", len(items)))
+	return items, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestServiceAuditsOrderAccess(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(5))
+	mine, other := g.Order(), g.Order()
+	mine.CustomerID, other.CustomerID = "alice", "bob"
+
+	auditor := &memAuditor{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(mine), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy), WithAudit(auditor, nil))
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if err := svc.SaveOrder(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	svc.GetOrder(ctx, mine.OrderUID)
+	svc.GetOrder(ctx, other.OrderUID)
+	svc.GetOrder(ctx, "missing")
+
+	want := []struct{ action, uid, outcome string }{
+		{audit.ActionCreate, other.OrderUID, audit.OutcomeOK},
+		{audit.ActionRead, mine.OrderUID, audit.OutcomeOK},
+		{audit.ActionRead, other.OrderUID, audit.OutcomeDenied},
+		{audit.ActionRead, "missing", audit.OutcomeNotFound},
+	}
+	if len(auditor.events) != len(want) {
+		t.Fatalf("got %d events: %+v", len(auditor.events), auditor.events)
+	}
+	for i, w := range want {
+		e := auditor.events[i]
+		if e.Action != w.action || e.OrderUID != w.uid || e.Outcome != w.outcome {
+			t.Fatalf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+
+	if _, err := svc.QueryAudit(ctx, audit.Filter{}); !errors.Is(err, ErrAuditDisabled) {
+		t.Fatalf("query without a store: %v", err)
+	}
+}
+
+func TestSaveDuplicateChangesNothing(t *testing.T) {
+	stored := fake.New(fake.WithSeed(6)).Order()
+	redelivered := stored
+	redelivered.TrackNumber = "CHANGED"
+
+	auditor := &memAuditor{}
+	cache := newMemCache()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(stored), cache, time.Minute, logger, otel.Tracer("test"), WithAudit(auditor, nil))
+
+	if err := svc.SaveOrder(context.Background(), redelivered); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("redelivered order: %v", err)
+	}
+	if _, ok := cache.items[stored.OrderUID]; ok || len(cache.refs) != 0 {
+		t.Fatalf("duplicate cached: %v, %d refs", ok, len(cache.refs))
+	}
+	if len(auditor.events) != 0 {
+		t.Fatalf("duplicate audited: %+v", auditor.events)
+	}
+}
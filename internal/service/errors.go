@@ -5,4 +5,17 @@ import "errors"
 var (
 	ErrNotFound   = errors.New("order not found")
 	ErrValidation = errors.New("validation failed")
+	// ErrDuplicate is returned by SaveOrder when the order is already stored;
+	// nothing is changed, cached, published or audited.
+	ErrDuplicate = errors.New("order already stored")
+	// ErrPermissionDenied is returned when the access policy hides an order.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrAuditDisabled is returned by QueryAudit when no audit store is configured.
+	ErrAuditDisabled = errors.New("audit log is disabled")
+	// ErrStreamDisabled is returned by WatchOrders when no stream hub is configured.
+	ErrStreamDisabled = errors.New("order stream is disabled")
+	// ErrAnalyticsDisabled is returned by the analytics queries when no store is configured.
+	ErrAnalyticsDisabled = errors.New("analytics are disabled")
+	// ErrExportDisabled is returned by ExportOrders when no export source is configured.
+	ErrExportDisabled = errors.New("order export is disabled")
 )
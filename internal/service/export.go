@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExportOrders writes the orders created in q's range to w, flattened to one
+// row per item, and returns what the export's footer records. It needs
+// OpOrderExport; the orders are narrowed to what the caller may list and
+// shown as ListOrders shows them. The export is audited as a single event.
+func (s *Service) ExportOrders(ctx context.Context, q export.Query, w io.Writer) (export.Summary, error) {
+	if err := q.Normalize(); err != nil {
+		return export.Summary{}, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	if s.exports == nil {
+		return export.Summary{}, ErrExportDisabled
+	}
+	ctx, span := s.tracer.Start(ctx, "service.ExportOrders")
+	defer span.End()
+
+	if err := s.permit(ctx, OpOrderExport); err != nil {
+		s.recordAudit(ctx, audit.ActionExport, "", err)
+		return export.Summary{}, err
+	}
+	ew, err := export.NewWriter(w, q.Format, q.Selected())
+	if err != nil {
+		return export.Summary{}, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	var scopes []repository.Scope
+	write := ew.Write
+	if s.policy != nil {
+		id := auth.FromContext(ctx)
+		scoped, _, ok := s.policy.scope(id, repository.OrderFilter{})
+		if !ok {
+			err := s.deny(ctx, "export")
+			s.recordAudit(ctx, audit.ActionExport, "", err)
+			return export.Summary{}, err
+		}
+		scopes = scoped.Scopes
+		write = func(r *export.Row) error {
+			ok, v := s.policy.visible(id, r.Order)
+			if !ok {
+				return nil
+			}
+			return ew.Write(exportView(r, v))
+		}
+	}
+	if err := s.exports.ExportOrders(ctx, q.From, q.To, scopes, write); err != nil {
+		s.recordAudit(ctx, audit.ActionExport, "", err)
+		return export.Summary{}, fmt.Errorf("export orders: %w", err)
+	}
+	sum, err := ew.Close()
+	if err != nil {
+		s.recordAudit(ctx, audit.ActionExport, "", err)
+		return export.Summary{}, fmt.Errorf("finish export: %w", err)
+	}
+	s.recordAudit(ctx, audit.ActionExport, "", nil)
+	span.SetAttributes(attribute.String("format", q.Format), attribute.Int64("rows", sum.Rows))
+	return sum, nil
+}
+
+// exportView returns the row as v shows it: the item goes through the view
+// as part of the order.
+func exportView(r *export.Row, v view) *export.Row {
+	o := r.Order
+	o.Items = []models.Item{r.Item}
+	o = v.apply(o)
+	out := &export.Row{Order: o}
+	if len(o.Items) > 0 {
+		out.Item = o.Items[0]
+	}
+	out.Order.Items = nil
+	return out
+}
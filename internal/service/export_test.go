@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+type memExports struct{ rows []export.Row }
+
+func (m *memExports) ExportOrders(ctx context.Context, from, to time.Time, scopes []repository.Scope, fn func(*export.Row) error) error {
+	for i := range m.rows {
+		if !inScopes(scopes, m.rows[i].Order) {
+			continue
+		}
+		if err := fn(&m.rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportOrders(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := fake.New(fake.WithSeed(9)).Order()
+	src := &memExports{rows: []export.Row{{Order: o, Item: o.Items[0]}}}
+	auditor := &memAuditor{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test"),
+		WithPolicy(policy), WithAudit(auditor, nil), WithExport(src))
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	q := export.Query{From: day, To: day.AddDate(0, 1, 0), Columns: []string{"order_uid", "item_rid"}}
+	customer := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if _, err := svc.ExportOrders(customer, q, io.Discard); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("customer export: %v", err)
+	}
+
+	ops := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	var buf bytes.Buffer
+	sum, err := svc.ExportOrders(ops, q, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "order_uid,item_rid\n" + o.OrderUID + "," + o.Items[0].Rid + "\n# rows=1 sha256=" + sum.SHA256 + "\n"
+	if sum.Rows != 1 || buf.String() != want {
+		t.Fatalf("export %q, summary %+v", buf.String(), sum)
+	}
+	if len(auditor.events) != 2 || auditor.events[0].Outcome != audit.OutcomeDenied ||
+		auditor.events[1].Action != audit.ActionExport || auditor.events[1].Outcome != audit.OutcomeOK {
+		t.Fatalf("audit %+v", auditor.events)
+	}
+
+	q.Format = "xlsx"
+	if _, err := svc.ExportOrders(ops, q, io.Discard); !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "xlsx") {
+		t.Fatalf("bad format: %v", err)
+	}
+}
+
+func TestExportOrdersScopedByPolicy(t *testing.T) {
+	policy := &Policy{Roles: map[string]RolePolicy{"exporter": {
+		Match:      []map[string]string{{"delivery_service": "$delivery_service"}},
+		Fields:     []string{"order_uid", "delivery_service", "items.rid"},
+		Operations: []string{OpOrderExport},
+	}}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(10))
+	own, foreign := g.Order(), g.Order()
+	own.DeliveryService, foreign.DeliveryService = "dhl", "cdek"
+	src := &memExports{rows: []export.Row{{Order: foreign, Item: foreign.Items[0]}, {Order: own, Item: own.Items[0]}}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy), WithExport(src))
+
+	exporter := auth.WithIdentity(context.Background(), &auth.Identity{
+		Subject: "bi", Roles: []string{"exporter"}, Attributes: map[string]string{"delivery_service": "dhl"},
+	})
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	q := export.Query{From: day, To: day.AddDate(0, 1, 0), Columns: []string{"order_uid", "payment_amount", "item_rid", "item_price"}}
+	var buf bytes.Buffer
+	sum, err := svc.ExportOrders(exporter, q, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "order_uid,payment_amount,item_rid,item_price\n" + own.OrderUID + ",0," + own.Items[0].Rid + ",0\n"
+	if sum.Rows != 1 || !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("export %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+)
+
+type memRepo struct {
+	mu      sync.Mutex
+	orders  map[string]models.Order
+	privacy []repository.PrivacyRecord
+	queries []repository.SearchQuery
+	lookups int
+}
+
+func newMemRepo(orders ...models.Order) *memRepo {
+	r := &memRepo{orders: make(map[string]models.Order)}
+	for _, o := range orders {
+		r.orders[o.OrderUID] = o
+	}
+	return r
+}
+
+func (r *memRepo) SaveOrder(ctx context.Context, o models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[o.OrderUID]; ok {
+		return repository.ErrDuplicate
+	}
+	r.orders[o.OrderUID] = o
+	return nil
+}
+
+func (r *memRepo) GetOrder(ctx context.Context, uid string) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[uid]
+	if !ok {
+		return models.Order{}, repository.ErrNotFound
+	}
+	return o, nil
+}
+
+func (r *memRepo) ListOrders(ctx context.Context) ([]models.Order, error) {
+	return r.FindOrders(ctx, repository.OrderFilter{})
+}
+
+func (r *memRepo) FindOrders(ctx context.Context, f repository.OrderFilter) ([]models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.Order
+	for _, o := range r.orders {
+		if f.CustomerID != "" && o.CustomerID != f.CustomerID ||
+			f.DeliveryService != "" && o.DeliveryService != f.DeliveryService ||
+			f.Region != "" && o.Delivery.Region != f.Region ||
+			f.Email != "" && o.Delivery.Email != f.Email ||
+			f.Phone != "" && o.Delivery.Phone != f.Phone ||
+			!f.From.IsZero() && o.DateCreated.Before(f.From) ||
+			!f.To.IsZero() && !o.DateCreated.Before(f.To) ||
+			!inScopes(f.Scopes, o) {
+			continue
+		}
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OrderUID < out[j].OrderUID })
+	if f.Offset >= len(out) {
+		return nil, nil
+	}
+	out = out[f.Offset:]
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func inScopes(scopes []repository.Scope, o models.Order) bool {
+	for _, sc := range scopes {
+		if (sc.CustomerID == "" || sc.CustomerID == o.CustomerID) &&
+			(sc.DeliveryService == "" || sc.DeliveryService == o.DeliveryService) &&
+			(sc.Region == "" || sc.Region == o.Delivery.Region) {
+			return true
+		}
+	}
+	return len(scopes) == 0
+}
+
+func (r *memRepo) LookupOrders(ctx context.Context, ref repository.OrderRef, scopes []repository.Scope, offset, limit int) ([]repository.OrderRefMatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	var out []repository.OrderRefMatch
+	for _, o := range r.orders {
+		if slices.Contains(repository.OrderRefs(o), ref) && inScopes(scopes, o) {
+			out = append(out, repository.OrderRefMatch{OrderUID: o.OrderUID, DateCreated: o.DateCreated})
+		}
+	}
+	sortMatches(out)
+	out = out[min(offset, len(out)):]
+	return out[:min(len(out), limit)], nil
+}
+
+// sortMatches orders matches newest first, like the database.
+func sortMatches(m []repository.OrderRefMatch) {
+	sort.Slice(m, func(i, j int) bool {
+		if !m[i].DateCreated.Equal(m[j].DateCreated) {
+			return m[i].DateCreated.After(m[j].DateCreated)
+		}
+		return m[i].OrderUID < m[j].OrderUID
+	})
+}
+
+// SearchOrders records q and returns every order in its scope: matching the
+// expression is the database's job.
+func (r *memRepo) SearchOrders(ctx context.Context, q repository.SearchQuery) ([]repository.SearchHit, error) {
+	r.mu.Lock()
+	r.queries = append(r.queries, q)
+	r.mu.Unlock()
+	orders, _ := r.FindOrders(ctx, q.Scope)
+	hits := make([]repository.SearchHit, len(orders))
+	for i, o := range orders {
+		hits[i] = repository.SearchHit{Order: o, Rank: 1}
+	}
+	return hits, nil
+}
+
+func (r *memRepo) EraseCustomer(ctx context.Context, customerID, pseudonym string, rec repository.PrivacyRecord) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var uids []string
+	for uid, o := range r.orders {
+		if o.CustomerID != customerID {
+			continue
+		}
+		o.CustomerID = pseudonym
+		o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip = repository.Erased, repository.Erased, repository.Erased
+		o.Delivery.Address, o.Delivery.Email = repository.Erased, repository.Erased
+		r.orders[uid] = o
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	rec.OrderUIDs = uids
+	r.privacy = append(r.privacy, rec)
+	return uids, nil
+}
+
+func (r *memRepo) RecordPrivacyRequest(ctx context.Context, rec repository.PrivacyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.privacy = append(r.privacy, rec)
+	return nil
+}
+
+type memCache struct {
+	mu    sync.Mutex
+	items map[string]models.Order
+	refs  map[repository.OrderRef][]repository.OrderRefMatch
+}
+
+func newMemCache() *memCache {
+	return &memCache{items: make(map[string]models.Order), refs: make(map[repository.OrderRef][]repository.OrderRefMatch)}
+}
+
+func (c *memCache) Get(ctx context.Context, key string) (models.Order, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.items[key]
+	return o, ok, nil
+}
+
+func (c *memCache) Set(ctx context.Context, key string, value models.Order, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+	return nil
+}
+
+func (c *memCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		delete(c.items, k)
+	}
+	return nil
+}
+
+// Scan returns all keys in one page, in order.
+func (c *memCache) Scan(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.items))
+	for k := range c.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, 0, nil
+}
+
+func (c *memCache) GetRef(ctx context.Context, ref repository.OrderRef, offset, limit int) ([]string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	matches, ok := c.refs[ref]
+	var uids []string
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		uids = append(uids, matches[i].OrderUID)
+	}
+	return uids, ok, nil
+}
+
+func (c *memCache) SetRef(ctx context.Context, ref repository.OrderRef, matches []repository.OrderRefMatch, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(matches) > 0 {
+		c.refs[ref] = append([]repository.OrderRefMatch(nil), matches...)
+	}
+	return nil
+}
+
+func (c *memCache) AddRefs(ctx context.Context, m repository.OrderRefMatch, refs []repository.OrderRef, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ref := range refs {
+		matches, ok := c.refs[ref]
+		if !ok && !ref.Unique() || slices.Contains(matches, m) {
+			continue
+		}
+		matches = append(matches, m)
+		sortMatches(matches)
+		c.refs[ref] = matches
+	}
+	return nil
+}
+
+func (c *memCache) has(key string) bool {
+	_, ok, _ := c.Get(context.Background(), key)
+	return ok
+}
+
+type memAuditor struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (a *memAuditor) Record(ctx context.Context, e audit.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+}
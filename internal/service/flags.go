@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"orderservice/internal/features"
+)
+
+// FlagLazyCache, when on for an order, leaves it out of the cache on save:
+// it is cached by the first read instead.
+const FlagLazyCache = "lazy_cache"
+
+// WithFlags evaluates the service feature flags, FlagLazyCache, in flags and
+// serves FeatureFlags from them; without it the flags are off.
+func WithFlags(flags *features.Flags) Option {
+	return func(s *Service) { s.flags = flags }
+}
+
+// FeatureFlags evaluates every flag for subj. Callers need OpFlagsRead.
+func (s *Service) FeatureFlags(ctx context.Context, subj features.Subject) (features.Report, error) {
+	if err := s.permit(ctx, OpFlagsRead); err != nil {
+		return features.Report{}, err
+	}
+	return s.flags.Evaluate(subj), nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/features"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestLazyCacheFlag(t *testing.T) {
+	gen := fake.New(fake.WithSeed(11))
+	eager, lazy := gen.Order(), gen.Order()
+	lazy.DeliveryService = "lazy-ds"
+	flags := features.New(map[string]features.Flag{
+		FlagLazyCache: {Enabled: true, DeliveryServices: []string{"lazy-ds"}},
+	}, "orders-0")
+	cache := newMemCache()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(), cache, time.Minute, logger, otel.Tracer("test"), WithFlags(flags))
+
+	ctx := context.Background()
+	if err := svc.SaveOrder(ctx, eager); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SaveOrder(ctx, lazy); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.has(eager.OrderUID) || cache.has(lazy.OrderUID) {
+		t.Fatalf("cached eager %t, lazy %t", cache.has(eager.OrderUID), cache.has(lazy.OrderUID))
+	}
+	if _, err := svc.GetOrder(ctx, lazy.OrderUID); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.has(lazy.OrderUID) {
+		t.Fatal("lazy order not cached by the first read")
+	}
+}
+
+func TestFeatureFlagsNeedsOperation(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := features.New(map[string]features.Flag{FlagLazyCache: {Enabled: true, Locales: []string{"en"}}}, "orders-0")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy), WithFlags(flags))
+
+	customer := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if _, err := svc.FeatureFlags(customer, features.Subject{}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("customer: %v", err)
+	}
+	ops := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	r, err := svc.FeatureFlags(ops, features.Subject{Locale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Flags) != 1 || r.Flags[0].Name != FlagLazyCache || !r.Flags[0].Enabled {
+		t.Fatalf("evaluations %+v", r.Flags)
+	}
+}
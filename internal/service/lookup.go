@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxRefOrders bounds the orders of a secondary key that are cached; longer
+// lists are paged in the database.
+const maxRefOrders = 1000
+
+// GetOrderByTrackNumber returns the newest order with the track number.
+func (s *Service) GetOrderByTrackNumber(ctx context.Context, track string) (models.Order, error) {
+	return s.getOrderByRef(ctx, repository.OrderRef{Kind: repository.RefTrackNumber, Value: track})
+}
+
+// GetOrderByTransaction returns the newest order paid by the transaction.
+func (s *Service) GetOrderByTransaction(ctx context.Context, transaction string) (models.Order, error) {
+	return s.getOrderByRef(ctx, repository.OrderRef{Kind: repository.RefTransaction, Value: transaction})
+}
+
+func (s *Service) getOrderByRef(ctx context.Context, ref repository.OrderRef) (models.Order, error) {
+	if ref.Value == "" {
+		s.recordAudit(ctx, audit.ActionRead, "", ErrValidation)
+		return models.Order{}, ErrValidation
+	}
+	ctx, span := s.tracer.Start(ctx, "service.GetOrderByRef")
+	defer span.End()
+	span.SetAttributes(attribute.String("ref_kind", ref.Kind))
+
+	uids, _, err := s.lookup(ctx, ref, nil, 0, 1)
+	if err == nil && len(uids) == 0 {
+		err = ErrNotFound
+	}
+	if err != nil {
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return models.Order{}, err
+	}
+	return s.GetOrder(ctx, uids[0])
+}
+
+// FindOrdersByItem returns a page of the orders containing an item with the
+// given rid, chrt_id or nm_id, newest first, and the offset of the next page
+// (0 on the last one). The orders are narrowed to what the caller may list.
+func (s *Service) FindOrdersByItem(ctx context.Context, ref repository.OrderRef, limit, offset int) ([]models.Order, int, error) {
+	switch ref.Kind {
+	case repository.RefItemRid:
+		if ref.Value == "" {
+			return nil, 0, ErrValidation
+		}
+	case repository.RefItemChrtID, repository.RefItemNmID:
+		if id, err := strconv.ParseInt(ref.Value, 10, 64); err != nil || id <= 0 {
+			return nil, 0, fmt.Errorf("%w: %s must be a positive number", ErrValidation, ref.Kind)
+		}
+	default:
+		return nil, 0, ErrValidation
+	}
+	if limit < 0 || offset < 0 {
+		return nil, 0, ErrValidation
+	}
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	ctx, span := s.tracer.Start(ctx, "service.FindOrdersByItem")
+	defer span.End()
+
+	var scopes []repository.Scope
+	if s.policy != nil {
+		scoped, _, ok := s.policy.scope(auth.FromContext(ctx), repository.OrderFilter{})
+		if !ok {
+			err := s.deny(ctx, "list", "ref_kind", ref.Kind)
+			s.recordAudit(ctx, audit.ActionRead, "", err)
+			return nil, 0, err
+		}
+		scopes = scoped.Scopes
+	}
+	uids, more, err := s.lookup(ctx, ref, scopes, offset, limit)
+	if err != nil {
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return nil, 0, err
+	}
+	var orders []models.Order
+	for _, uid := range uids {
+		o, err := s.loadOrder(ctx, uid)
+		if err != nil {
+			s.recordAudit(ctx, audit.ActionRead, uid, err)
+			return nil, 0, err
+		}
+		ok, v := s.visible(auth.FromContext(ctx), o)
+		if !ok {
+			continue
+		}
+		o = v.apply(o)
+		orders = append(orders, o)
+		s.recordAudit(ctx, audit.ActionRead, uid, nil)
+	}
+	next := 0
+	if more {
+		next = offset + len(uids)
+	}
+	span.SetAttributes(attribute.String("ref_kind", ref.Kind), attribute.Int("orders_count", len(orders)))
+	return orders, next, nil
+}
+
+// lookup resolves ref to limit order uids from offset, newest first, in any
+// of scopes, and reports whether more follow. The cache only holds complete
+// unscoped lists: it serves them when present and the first page of one
+// reads the whole list to cache it. Other pages are read from the database.
+func (s *Service) lookup(ctx context.Context, ref repository.OrderRef, scopes []repository.Scope, offset, limit int) ([]string, bool, error) {
+	cacheable := s.cache != nil && len(scopes) == 0
+	if cacheable {
+		// one extra uid tells whether another page exists
+		uids, ok, err := s.cache.GetRef(ctx, ref, offset, limit+1)
+		if err == nil && ok {
+			more := len(uids) > limit
+			return uids[:min(len(uids), limit)], more, nil
+		} else if err != nil {
+			s.logger.Error("cache get ref failed", "err", err, "ref", ref.Kind)
+		}
+	}
+
+	if cacheable && offset == 0 && limit < maxRefOrders {
+		matches, err := s.repo.LookupOrders(ctx, ref, nil, 0, maxRefOrders)
+		if err != nil {
+			return nil, false, fmt.Errorf("lookup orders: %w", err)
+		}
+		if len(matches) < maxRefOrders {
+			if err := s.cache.SetRef(ctx, ref, matches, s.ttl()); err != nil {
+				s.logger.Error("cache set ref failed", "err", err, "ref", ref.Kind)
+			}
+		}
+		uids, more := refPage(matches, limit)
+		return uids, more, nil
+	}
+	matches, err := s.repo.LookupOrders(ctx, ref, scopes, offset, limit+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("lookup orders: %w", err)
+	}
+	uids, more := refPage(matches, limit)
+	return uids, more, nil
+}
+
+// refPage returns the uids of the first limit matches and whether more follow.
+func refPage(matches []repository.OrderRefMatch, limit int) ([]string, bool) {
+	var uids []string
+	for _, m := range matches[:min(limit, len(matches))] {
+		uids = append(uids, m.OrderUID)
+	}
+	return uids, len(matches) > limit
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestLookupOrders(t *testing.T) {
+	g := fake.New(fake.WithSeed(3))
+	old, stored := g.Order(), g.Order()
+	stored.DateCreated = old.DateCreated.Add(time.Hour)
+	stored.Items[0].NmID = old.Items[0].NmID
+	nm := repository.OrderRef{Kind: repository.RefItemNmID, Value: strconv.FormatInt(old.Items[0].NmID, 10)}
+
+	repo := newMemRepo(old, stored)
+	cache := newMemCache()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(repo, cache, time.Minute, logger, otel.Tracer("test"))
+	ctx := context.Background()
+
+	got, err := svc.GetOrderByTrackNumber(ctx, old.TrackNumber)
+	if err != nil || got.OrderUID != old.OrderUID {
+		t.Fatalf("by track: %q, %v", got.OrderUID, err)
+	}
+	if got, err := svc.GetOrderByTransaction(ctx, stored.Payment.Transaction); err != nil || got.OrderUID != stored.OrderUID {
+		t.Fatalf("by transaction: %q, %v", got.OrderUID, err)
+	}
+	if _, err := svc.GetOrderByTrackNumber(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing track: %v", err)
+	}
+
+	orders, next, err := svc.FindOrdersByItem(ctx, nm, 1, 0)
+	if err != nil || len(orders) != 1 || orders[0].OrderUID != stored.OrderUID || next != 1 {
+		t.Fatalf("first page: %d orders, next %d, %v", len(orders), next, err)
+	}
+	lookups := repo.lookups
+	orders, next, err = svc.FindOrdersByItem(ctx, nm, 1, 1)
+	if err != nil || len(orders) != 1 || orders[0].OrderUID != old.OrderUID || next != 0 {
+		t.Fatalf("second page: %d orders, next %d, %v", len(orders), next, err)
+	}
+	if repo.lookups != lookups {
+		t.Fatalf("second page not served from the cache")
+	}
+
+	// a new order joins the cached product list and gets its own track
+	newer := g.Order()
+	newer.DateCreated = stored.DateCreated.Add(time.Hour)
+	newer.Items[0].NmID = old.Items[0].NmID
+	if err := svc.SaveOrder(ctx, newer); err != nil {
+		t.Fatal(err)
+	}
+	orders, _, err = svc.FindOrdersByItem(ctx, nm, 10, 0)
+	if err != nil || len(orders) != 3 || orders[0].OrderUID != newer.OrderUID {
+		t.Fatalf("after save: %d orders, %v", len(orders), err)
+	}
+	if got, err := svc.GetOrderByTrackNumber(ctx, newer.TrackNumber); err != nil || got.OrderUID != newer.OrderUID {
+		t.Fatalf("new track: %q, %v", got.OrderUID, err)
+	}
+	if repo.lookups != lookups {
+		t.Fatalf("lookups after save hit the repository")
+	}
+
+	for _, ref := range []repository.OrderRef{
+		{Kind: repository.RefItemChrtID, Value: "abc"},
+		{Kind: repository.RefItemRid},
+		{Kind: repository.RefTrackNumber, Value: old.TrackNumber},
+	} {
+		if _, _, err := svc.FindOrdersByItem(ctx, ref, 0, 0); !errors.Is(err, ErrValidation) {
+			t.Fatalf("%v: %v", ref, err)
+		}
+	}
+}
+
+func TestLookupPastCachedLists(t *testing.T) {
+	g := fake.New(fake.WithSeed(5))
+	first := g.Order()
+	nm := repository.OrderRef{Kind: repository.RefItemNmID, Value: strconv.FormatInt(first.Items[0].NmID, 10)}
+	orders := []models.Order{first}
+	for i := range maxRefOrders + 1 {
+		o := g.Order()
+		o.Items[0].NmID = first.Items[0].NmID
+		o.DateCreated = first.DateCreated.Add(-time.Duration(i+1) * time.Minute)
+		orders = append(orders, o)
+	}
+	repo := newMemRepo(orders...)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(repo, newMemCache(), time.Minute, logger, otel.Tracer("test"))
+	ctx := context.Background()
+
+	if _, next, err := svc.FindOrdersByItem(ctx, nm, 10, 0); err != nil || next != 10 {
+		t.Fatalf("first page: next %d, %v", next, err)
+	}
+	page, next, err := svc.FindOrdersByItem(ctx, nm, 1, maxRefOrders)
+	if err != nil || len(page) != 1 || page[0].OrderUID != orders[maxRefOrders].OrderUID || next != maxRefOrders+1 {
+		t.Fatalf("page at the cap: %d orders, next %d, %v", len(page), next, err)
+	}
+	page, next, err = svc.FindOrdersByItem(ctx, nm, 10, maxRefOrders+1)
+	if err != nil || len(page) != 1 || page[0].OrderUID != orders[maxRefOrders+1].OrderUID || next != 0 {
+		t.Fatalf("last page: %d orders, next %d, %v", len(page), next, err)
+	}
+}
+
+func TestLookupScopedByPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(6))
+	var orders []models.Order
+	for i := range 6 {
+		o := g.Order()
+		o.DateCreated = time.Date(2026, 1, 1, i, 0, 0, 0, time.UTC)
+		o.Items[0].Rid = "shared"
+		o.DeliveryService = "cdek"
+		if i%2 == 0 {
+			o.DeliveryService = "dhl"
+		}
+		orders = append(orders, o)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(orders...), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy))
+	support := auth.WithIdentity(context.Background(), &auth.Identity{
+		Subject: "agent", Roles: []string{"support"}, Attributes: map[string]string{"delivery_service": "dhl"},
+	})
+
+	// the pages are full: the scope is applied before paging
+	ref := repository.OrderRef{Kind: repository.RefItemRid, Value: "shared"}
+	var got []string
+	for offset := 0; ; {
+		page, next, err := svc.FindOrdersByItem(support, ref, 2, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != 0 && len(page) != 2 {
+			t.Fatalf("short page at %d: %d orders", offset, len(page))
+		}
+		for _, o := range page {
+			got = append(got, o.OrderUID)
+		}
+		if next == 0 {
+			break
+		}
+		offset = next
+	}
+	want := []string{orders[4].OrderUID, orders[2].OrderUID, orders[0].OrderUID}
+	if !slices.Equal(got, want) {
+		t.Fatalf("support orders %v, want %v", got, want)
+	}
+}
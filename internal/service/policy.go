@@ -0,0 +1,483 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+	"orderservice/pkg/redact"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// AnonymousRole is granted to callers without an identity (auth disabled).
+const AnonymousRole = "anonymous"
+
+// Policy decides which orders, and which fields of them, a caller may read.
+//
+//	subjects:            # extra roles by subject, e.g. for API keys
+//	  ops: [admin]
+//	roles:
+//	  admin: {}          # every order, every field
+//	  support:
+//	    match:           # own delivery service OR own region
+//	      - delivery_service: $delivery_service
+//	      - region: $region
+//	    fields: [order_uid, track_number, delivery_service, date_created, delivery, items]
+//	  customer:
+//	    match:
+//	      - customer_id: $sub
+//	    reveal: ["*"]    # personal data unmasked
+//	  dpo:
+//	    operations: [customer_export, customer_erase, audit_read]
+type Policy struct {
+	Roles    map[string]RolePolicy `yaml:"roles"`
+	Subjects map[string][]string   `yaml:"subjects"`
+}
+
+// RolePolicy grants access to the orders matching any entry of Match.
+type RolePolicy struct {
+	// Match lists alternatives; an order matches one when it equals all of its
+	// keys (customer_id, delivery_service, region). Values starting with "$"
+	// name an identity attribute ($sub is the subject). No entries match every order.
+	Match []map[string]string `yaml:"match"`
+	// Fields lists visible JSON field paths ("payment", "delivery.city").
+	// Fields not listed are cleared; no entries shows everything.
+	Fields []string `yaml:"fields"`
+	// Reveal lists personal-data paths ("delivery.phone") returned unmasked;
+	// "*" reveals all of them. Anything tagged `mask` is masked otherwise.
+	Reveal []string `yaml:"reveal"`
+	// Operations lists the privileged operations the role may run
+	// (OpCustomerExport, OpCustomerErase, OpAuditRead, OpAnalyticsRead,
+	// OpOrderExport, OpFlagsRead). They are not granted by Match.
+	Operations []string `yaml:"operations"`
+}
+
+// Privileged operations a role has to list in Operations.
+const (
+	OpCustomerExport = "customer_export"
+	OpCustomerErase  = "customer_erase"
+	OpAuditRead      = "audit_read"
+	OpAnalyticsRead  = "analytics_read"
+	OpOrderExport    = "order_export"
+	OpFlagsRead      = "flags_read"
+)
+
+var policyOperations = map[string]bool{OpCustomerExport: true, OpCustomerErase: true, OpAuditRead: true, OpAnalyticsRead: true, OpOrderExport: true, OpFlagsRead: true}
+
+var policyKeys = map[string]func(*repository.Scope) *string{
+	"customer_id":      func(s *repository.Scope) *string { return &s.CustomerID },
+	"delivery_service": func(s *repository.Scope) *string { return &s.DeliveryService },
+	"region":           func(s *repository.Scope) *string { return &s.Region },
+}
+
+var deniedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "authz_denied_total",
+	Help: "Order reads denied by the access policy.",
+}, []string{"action"})
+
+func init() {
+	prometheus.MustRegister(deniedCounter)
+}
+
+// LoadPolicy reads and validates a YAML policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Validate checks match keys and field paths.
+func (p *Policy) Validate() error {
+	valid := orderFieldPaths()
+	for name, r := range p.Roles {
+		for _, m := range r.Match {
+			for k := range m {
+				if policyKeys[k] == nil {
+					return fmt.Errorf("role %s: unknown match key %q", name, k)
+				}
+			}
+		}
+		for _, f := range r.Fields {
+			if !valid[f] {
+				return fmt.Errorf("role %s: unknown field %q", name, f)
+			}
+		}
+		for _, f := range r.Reveal {
+			if f != "*" && !maskedPaths[f] {
+				return fmt.Errorf("role %s: %q is not a masked field", name, f)
+			}
+		}
+		for _, op := range r.Operations {
+			if !policyOperations[op] {
+				return fmt.Errorf("role %s: unknown operation %q", name, op)
+			}
+		}
+	}
+	for sub, roles := range p.Subjects {
+		for _, r := range roles {
+			if _, ok := p.Roles[r]; !ok {
+				return fmt.Errorf("subject %s: unknown role %q", sub, r)
+			}
+		}
+	}
+	return nil
+}
+
+// grants returns the policies of every role the caller holds.
+func (p *Policy) grants(id *auth.Identity) []RolePolicy {
+	var names []string
+	if id == nil {
+		names = []string{AnonymousRole}
+	} else {
+		names = append(append(names, id.Roles...), p.Subjects[id.Subject]...)
+	}
+	var out []RolePolicy
+	for _, n := range names {
+		if r, ok := p.Roles[n]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// resolve substitutes identity attributes into a match entry. It returns false
+// when a referenced attribute is missing, so the entry can never match.
+func resolve(m map[string]string, id *auth.Identity) (map[string]string, bool) {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if name, ok := strings.CutPrefix(v, "$"); ok {
+			switch {
+			case id == nil:
+				return nil, false
+			case name == "sub":
+				v = id.Subject
+			default:
+				v = id.Attributes[name]
+			}
+			if v == "" {
+				return nil, false
+			}
+		}
+		out[k] = v
+	}
+	return out, true
+}
+
+func orderValue(o models.Order, key string) string {
+	switch key {
+	case "customer_id":
+		return o.CustomerID
+	case "delivery_service":
+		return o.DeliveryService
+	case "region":
+		return o.Delivery.Region
+	}
+	return ""
+}
+
+var maskedPaths = func() map[string]bool {
+	m := map[string]bool{}
+	for _, p := range redact.Paths(models.Order{}) {
+		m[p] = true
+	}
+	return m
+}()
+
+// view is what a caller sees of one order.
+type view struct {
+	fields map[string]bool // nil: every field
+	reveal map[string]bool // unmasked personal-data paths
+}
+
+func (v view) apply(o models.Order) models.Order {
+	return redact.Apply(maskOrder(o, v.fields), v.reveal)
+}
+
+// visible reports whether the caller may read o and how it is shown. Matching
+// grants add up: the union of their fields and revealed paths applies.
+func (p *Policy) visible(id *auth.Identity, o models.Order) (bool, view) {
+	allowed, revealAll := false, false
+	v := view{fields: map[string]bool{}, reveal: map[string]bool{}}
+	for _, g := range p.grants(id) {
+		if !matchesAny(g.Match, id, o) {
+			continue
+		}
+		allowed = true
+		if len(g.Fields) == 0 {
+			v.fields = nil
+		}
+		for _, f := range g.Fields {
+			if v.fields != nil {
+				v.fields[f] = true
+			}
+		}
+		for _, f := range g.Reveal {
+			revealAll = revealAll || f == "*"
+			v.reveal[f] = true
+		}
+	}
+	if revealAll {
+		v.reveal = maskedPaths
+	}
+	return allowed, v
+}
+
+func matchesAny(alts []map[string]string, id *auth.Identity, o models.Order) bool {
+	if len(alts) == 0 {
+		return true
+	}
+	for _, alt := range alts {
+		m, ok := resolve(alt, id)
+		if !ok {
+			continue
+		}
+		match := true
+		for k, v := range m {
+			if orderValue(o, k) != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// scope narrows a list filter to what the caller may see: the union of every
+// grant alternative compatible with the requested filter goes to its Scopes.
+// The grants with such an alternative are returned too; every order in the
+// scope is visible through one of them.
+func (p *Policy) scope(id *auth.Identity, f repository.OrderFilter) (repository.OrderFilter, []RolePolicy, bool) {
+	requested := repository.Scope{CustomerID: f.CustomerID, DeliveryService: f.DeliveryService, Region: f.Region}
+	var (
+		scopes []repository.Scope
+		grants []RolePolicy
+		all    bool
+	)
+	for _, g := range p.grants(id) {
+		if len(g.Match) == 0 {
+			all = true
+			grants = append(grants, g)
+			continue
+		}
+		used := false
+		for _, alt := range g.Match {
+			m, ok := resolve(alt, id)
+			if !ok {
+				continue
+			}
+			var sc repository.Scope
+			compatible := true
+			for k, v := range m {
+				if req := *policyKeys[k](&requested); req != "" && req != v {
+					compatible = false
+					break
+				}
+				*policyKeys[k](&sc) = v
+			}
+			if compatible {
+				scopes = append(scopes, sc)
+				used = true
+			}
+		}
+		if used {
+			grants = append(grants, g)
+		}
+	}
+	if len(grants) == 0 {
+		return f, nil, false
+	}
+	if !all {
+		f.Scopes = scopes
+	}
+	return f, grants, true
+}
+
+// shownByAll reports whether every grant shows the JSON path, so that
+// searching it cannot find orders through a grant hiding it.
+func shownByAll(grants []RolePolicy, path string) bool {
+	for _, g := range grants {
+		if !g.shows(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchableByAll reports whether every grant shows the JSON path and, for
+// personal data, reveals it unmasked. Matches on a masked field would give
+// away what the mask hides.
+func searchableByAll(grants []RolePolicy, path string) bool {
+	if !shownByAll(grants, path) {
+		return false
+	}
+	if !maskedPaths[path] {
+		return true
+	}
+	for _, g := range grants {
+		if !slices.Contains(g.Reveal, "*") && !slices.Contains(g.Reveal, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// shows reports whether the grant shows the JSON path: it, or a parent of
+// it, is listed in Fields.
+func (g RolePolicy) shows(path string) bool {
+	if len(g.Fields) == 0 {
+		return true
+	}
+	for _, f := range g.Fields {
+		if path == f || strings.HasPrefix(path, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// permits reports whether any of the caller's roles lists op.
+func (p *Policy) permits(id *auth.Identity, op string) bool {
+	for _, g := range p.grants(id) {
+		if slices.Contains(g.Operations, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible reports whether the caller may read o and how it is shown. Without
+// a policy every order is visible, with personal data masked.
+func (s *Service) visible(id *auth.Identity, o models.Order) (bool, view) {
+	if s.policy == nil {
+		return true, view{}
+	}
+	return s.policy.visible(id, o)
+}
+
+// authorize returns o with hidden fields cleared and personal data masked per
+// the caller's roles, or ErrPermissionDenied.
+func (s *Service) authorize(ctx context.Context, action string, o models.Order) (models.Order, error) {
+	ok, v := s.visible(auth.FromContext(ctx), o)
+	if !ok {
+		return models.Order{}, s.deny(ctx, action, "order_uid", o.OrderUID)
+	}
+	return v.apply(o), nil
+}
+
+// deny audit-logs a refused access and returns ErrPermissionDenied.
+func (s *Service) deny(ctx context.Context, action string, attrs ...any) error {
+	deniedCounter.WithLabelValues(action).Inc()
+	subject, method, roles := "", "", []string(nil)
+	if id := auth.FromContext(ctx); id != nil {
+		subject, method, roles = id.Subject, id.Method, id.Roles
+	}
+	s.logger.With(attrs...).Warn("access denied",
+		"audit", "authz",
+		"action", action,
+		"subject", subject,
+		"auth_method", method,
+		"roles", roles,
+	)
+	return ErrPermissionDenied
+}
+
+// maskOrder zeroes every field of o not covered by fields (nil keeps all).
+func maskOrder(o models.Order, fields map[string]bool) models.Order {
+	if fields == nil {
+		return o
+	}
+	partial := map[string]bool{}
+	for f := range fields {
+		for i := strings.LastIndex(f, "."); i > 0; i = strings.LastIndex(f[:i], ".") {
+			partial[f[:i]] = true
+		}
+	}
+	v := reflect.ValueOf(&o).Elem()
+	maskStruct(v, "", fields, partial)
+	return o
+}
+
+func maskStruct(v reflect.Value, prefix string, fields, partial map[string]bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		path := prefix + name
+		fv := v.Field(i)
+		switch {
+		case fields[path]:
+		case partial[path] && fv.Kind() == reflect.Struct:
+			maskStruct(fv, path+".", fields, partial)
+		case partial[path] && fv.Kind() == reflect.Slice:
+			// copy so masking never writes through to a shared backing array
+			cp := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+			reflect.Copy(cp, fv)
+			for j := 0; j < cp.Len(); j++ {
+				maskStruct(cp.Index(j), path+".", fields, partial)
+			}
+			fv.Set(cp)
+		default:
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" || !f.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// orderFieldPaths lists every JSON path policies may reference.
+func orderFieldPaths() map[string]bool {
+	paths := map[string]bool{}
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			name := jsonName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			paths[prefix+name] = true
+			ft := t.Field(i).Type
+			if ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft.PkgPath() == t.PkgPath() {
+				walk(ft, prefix+name+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(models.Order{}), "")
+	return paths
+}
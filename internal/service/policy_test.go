@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"orderservice/internal/analytics"
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/internal/search"
+	"orderservice/internal/stream"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestLoadPolicyExample(t *testing.T) {
+	if _, err := LoadPolicy("../../policy.example.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(bad, []byte("roles:\n  support:\n    fields: [delivery.iban]\n"), 0o600)
+	if _, err := LoadPolicy(bad); err == nil {
+		t.Fatalf("unknown field accepted")
+	}
+}
+
+func TestServicePolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(7))
+	mine, other := g.Order(), g.Order()
+	mine.CustomerID, other.CustomerID = "alice", "bob"
+	mine.DeliveryService, other.DeliveryService = "dhl", "cdek"
+	other.Delivery.Region = "Elsewhere"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(mine, other), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy))
+
+	customer := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if got, err := svc.GetOrder(customer, mine.OrderUID); err != nil || got.Delivery.Phone != mine.Delivery.Phone {
+		t.Fatalf("own order: %v, phone %q", err, got.Delivery.Phone)
+	}
+	if _, err := svc.GetOrder(customer, other.OrderUID); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("foreign order: %v", err)
+	}
+	orders, err := svc.ListOrders(customer, repository.OrderFilter{})
+	if err != nil || len(orders) != 1 || orders[0].OrderUID != mine.OrderUID {
+		t.Fatalf("customer list: %d orders, %v", len(orders), err)
+	}
+	if _, err := svc.ListOrders(customer, repository.OrderFilter{CustomerID: "bob"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("listing another customer: %v", err)
+	}
+
+	support := auth.WithIdentity(context.Background(), &auth.Identity{
+		Subject: "agent", Roles: []string{"support"}, Attributes: map[string]string{"delivery_service": "dhl"},
+	})
+	got, err := svc.GetOrder(support, mine.OrderUID)
+	if err != nil {
+		t.Fatalf("support own service: %v", err)
+	}
+	if got.Delivery.Email != "" || got.Payment.Amount != 0 || got.Delivery.City == "" || len(got.Items) == 0 {
+		t.Fatalf("support fields not hidden: %+v", got)
+	}
+	if got.Delivery.Phone == mine.Delivery.Phone || got.Delivery.Phone[:4] != mine.Delivery.Phone[:4] {
+		t.Fatalf("support phone not masked: %q", got.Delivery.Phone)
+	}
+	if _, err := svc.GetOrder(support, other.OrderUID); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("support other service: %v", err)
+	}
+
+	ops := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	if orders, err := svc.ListOrders(ops, repository.OrderFilter{}); err != nil || len(orders) != 2 {
+		t.Fatalf("admin list: %d, %v", len(orders), err)
+	}
+	if _, err := svc.GetOrder(context.Background(), mine.OrderUID); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("anonymous read allowed")
+	}
+}
+
+func TestMaskedWithoutPolicy(t *testing.T) {
+	o := fake.New(fake.WithSeed(8)).Order()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(o), newMemCache(), time.Minute, logger, otel.Tracer("test"))
+
+	got, err := svc.GetOrder(context.Background(), o.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Delivery.Phone == o.Delivery.Phone || got.Delivery.Email == o.Delivery.Email || got.Payment != o.Payment {
+		t.Fatalf("get without a policy: %+v", got)
+	}
+	orders, err := svc.ListOrders(context.Background(), repository.OrderFilter{})
+	if err != nil || len(orders) != 1 || orders[0].Delivery.Name == o.Delivery.Name {
+		t.Fatalf("list without a policy: %+v, %v", orders, err)
+	}
+}
+
+func TestSearchPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(11))
+	mine, other := g.Order(), g.Order()
+	mine.DeliveryService, other.DeliveryService = "dhl", "cdek"
+	repo := newMemRepo(mine, other)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(repo, newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy))
+
+	support := auth.WithIdentity(context.Background(), &auth.Identity{
+		Subject: "agent", Roles: []string{"support"}, Attributes: map[string]string{"delivery_service": "dhl"},
+	})
+	hits, err := svc.SearchOrders(support, `ivanov track:WB`, 0, 0)
+	if err != nil || len(hits) != 1 || hits[0].Order.OrderUID != mine.OrderUID {
+		t.Fatalf("support search: %d hits, %v", len(hits), err)
+	}
+	if hits[0].Order.Payment.Amount != 0 || hits[0].Order.Delivery.Phone == mine.Delivery.Phone {
+		t.Fatalf("search hit not masked: %+v", hits[0].Order)
+	}
+	q := repo.queries[0]
+	if !slices.Equal(q.Scope.Scopes, []repository.Scope{{DeliveryService: "dhl"}}) || q.Scope.Limit != defaultPageSize {
+		t.Fatalf("search scope %+v", q.Scope)
+	}
+	// support does not see addresses and sees names masked, so free text
+	// matches neither
+	if want := []string{"track", "item"}; !slices.Equal(q.TextFields, want) {
+		t.Fatalf("text fields %q, want %q", q.TextFields, want)
+	}
+	for _, query := range []string{`address:lenina`, `amount:>100`, `customer:bob`, `phone:1234`, `name:ivanov`} {
+		if _, err := svc.SearchOrders(support, query, 0, 0); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if _, err := svc.SearchOrders(support, `name:(`, 0, 0); !errors.Is(err, ErrValidation) {
+		t.Fatalf("bad query: %v", err)
+	}
+
+	ops := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	if hits, err := svc.SearchOrders(ops, `amount:>100 customer:bob phone:1234`, 0, 0); err != nil || len(hits) != 2 {
+		t.Fatalf("admin search: %d, %v", len(hits), err)
+	}
+	if q := repo.queries[len(repo.queries)-1]; !slices.Equal(q.TextFields, search.TextFields) {
+		t.Fatalf("admin text fields restricted: %q", q.TextFields)
+	}
+}
+
+func TestSearchWithoutPolicy(t *testing.T) {
+	repo := newMemRepo(fake.New(fake.WithSeed(12)).Order())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(repo, newMemCache(), time.Minute, logger, otel.Tracer("test"))
+
+	if _, err := svc.SearchOrders(context.Background(), `ivanov service:dhl`, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"track", "item"}; !slices.Equal(repo.queries[0].TextFields, want) {
+		t.Fatalf("text fields %q, want %q", repo.queries[0].TextFields, want)
+	}
+	// personal data is masked without a policy, so it cannot be searched
+	for _, query := range []string{`phone:1234`, `name:ivanov`, `address:lenina`} {
+		if _, err := svc.SearchOrders(context.Background(), query, 0, 0); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+func TestPolicyScopeUnion(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(13))
+	byService, byRegion, neither := g.Order(), g.Order(), g.Order()
+	byService.DeliveryService, byService.Delivery.Region = "dhl", "South"
+	byRegion.DeliveryService, byRegion.Delivery.Region = "cdek", "North"
+	neither.DeliveryService, neither.Delivery.Region = "cdek", "South"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hub := stream.NewHub(stream.Config{}, nil, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	svc := New(newMemRepo(byService, byRegion, neither), newMemCache(), time.Minute, logger, otel.Tracer("test"),
+		WithPolicy(policy), WithStream(hub))
+
+	// support matches its delivery service OR its region
+	support := auth.WithIdentity(ctx, &auth.Identity{
+		Subject: "agent", Roles: []string{"support"},
+		Attributes: map[string]string{"delivery_service": "dhl", "region": "North"},
+	})
+	want := []string{byService.OrderUID, byRegion.OrderUID}
+	slices.Sort(want)
+	orders, err := svc.ListOrders(support, repository.OrderFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range orders {
+		got = append(got, o.OrderUID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Fatalf("list %v, want %v", got, want)
+	}
+	hits, err := svc.SearchOrders(support, `ivanov`, 0, 0)
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("search: %d hits, %v", len(hits), err)
+	}
+	// a requested filter keeps the alternatives compatible with it
+	orders, err = svc.ListOrders(support, repository.OrderFilter{DeliveryService: "cdek"})
+	if err != nil || len(orders) != 1 || orders[0].OrderUID != byRegion.OrderUID {
+		t.Fatalf("cdek list: %d orders, %v", len(orders), err)
+	}
+
+	sub, err := svc.WatchOrders(support, stream.Filter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	// only new orders are published
+	want = nil
+	for _, o := range []models.Order{neither, byService, byRegion} {
+		o.OrderUID += "-new"
+		if err := svc.SaveOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+		if o.DeliveryService == "dhl" || o.Delivery.Region == "North" {
+			want = append(want, o.OrderUID)
+		}
+	}
+	slices.Sort(want)
+	got = nil
+	for range 2 {
+		select {
+		case ev := <-sub.Events():
+			got = append(got, ev.Order.OrderUID)
+		case <-time.After(time.Second):
+			t.Fatalf("streamed only %v", got)
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Fatalf("watch %v, want %v", got, want)
+	}
+}
+
+type memStats struct{ queries []analytics.OrderQuery }
+
+func (m *memStats) OrderStats(ctx context.Context, q analytics.OrderQuery) ([]analytics.OrderStats, error) {
+	m.queries = append(m.queries, q)
+	return []analytics.OrderStats{{Start: q.From, Orders: 1}}, nil
+}
+
+func (m *memStats) TopItems(ctx context.Context, q analytics.ItemQuery) ([]analytics.ItemStats, error) {
+	return nil, nil
+}
+
+func TestAnalyticsPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := &memStats{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy), WithAnalytics(stats))
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := analytics.OrderQuery{From: day, To: day.AddDate(0, 1, 0), Bucket: analytics.BucketWeek}
+	customer := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if _, err := svc.OrderStats(customer, q); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("customer analytics: %v", err)
+	}
+	ops := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	if rows, err := svc.OrderStats(ops, q); err != nil || len(rows) != 1 {
+		t.Fatalf("admin analytics: %d rows, %v", len(rows), err)
+	}
+	if _, err := svc.TopItems(ops, analytics.ItemQuery{From: day, To: day, Metric: analytics.MetricRevenue}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("invalid item query: %v", err)
+	}
+	if _, err := New(newMemRepo(), nil, time.Minute, logger, otel.Tracer("test")).OrderStats(ops, q); !errors.Is(err, ErrAnalyticsDisabled) {
+		t.Fatalf("without a store: %v", err)
+	}
+}
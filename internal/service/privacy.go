@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/observability"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const exportPageSize = 500
+
+// CustomerExport is everything stored about one customer, unmasked.
+type CustomerExport struct {
+	CustomerID string         `json:"customer_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Orders     []models.Order `json:"orders"`
+}
+
+// Erasure describes a completed erasure.
+type Erasure struct {
+	OrderUIDs []string
+	ErasedAt  time.Time
+}
+
+// SubjectHash identifies a customer in audit records without storing the id.
+func SubjectHash(customerID string) string {
+	sum := sha256.Sum256([]byte(customerID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Pseudonym replaces the customer id of erased orders. It is stable, so
+// orders of one erased customer stay grouped for reporting.
+func Pseudonym(customerID string) string {
+	return "erased:" + SubjectHash(customerID)[:16]
+}
+
+// ExportCustomer returns every order of customerID with personal data in the
+// clear, for a data subject access request. The export is recorded.
+func (s *Service) ExportCustomer(ctx context.Context, customerID string) (CustomerExport, error) {
+	if customerID == "" {
+		return CustomerExport{}, ErrValidation
+	}
+	ctx, span := s.tracer.Start(ctx, "service.ExportCustomer")
+	defer span.End()
+
+	if err := s.permit(ctx, OpCustomerExport); err != nil {
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return CustomerExport{}, err
+	}
+	out := CustomerExport{CustomerID: customerID, ExportedAt: time.Now().UTC(), Orders: []models.Order{}}
+	f := repository.OrderFilter{CustomerID: customerID, Limit: exportPageSize}
+	for {
+		page, err := s.repo.FindOrders(ctx, f)
+		if err != nil {
+			return CustomerExport{}, fmt.Errorf("find orders: %w", err)
+		}
+		out.Orders = append(out.Orders, page...)
+		if len(page) < f.Limit {
+			break
+		}
+		f.Offset += len(page)
+	}
+
+	uids := make([]string, len(out.Orders))
+	for i, o := range out.Orders {
+		uids[i] = o.OrderUID
+		s.recordAudit(ctx, audit.ActionRead, o.OrderUID, nil)
+	}
+	rec := s.privacyRecord(ctx, "export", customerID, out.ExportedAt)
+	rec.OrderUIDs = uids
+	if err := s.repo.RecordPrivacyRequest(ctx, rec); err != nil {
+		return CustomerExport{}, fmt.Errorf("record export: %w", err)
+	}
+	s.auditPrivacy(ctx, rec)
+	span.SetAttributes(attribute.Int("orders_count", len(uids)))
+	return out, nil
+}
+
+// EraseCustomer anonymizes the personal data of every order of customerID:
+// delivery contacts are overwritten and the customer id is replaced with its
+// Pseudonym. Payments and items are kept as financial records. Affected orders
+// are evicted from the cache; the erasure is recorded with the same transaction.
+func (s *Service) EraseCustomer(ctx context.Context, customerID string) (Erasure, error) {
+	if customerID == "" {
+		return Erasure{}, ErrValidation
+	}
+	ctx, span := s.tracer.Start(ctx, "service.EraseCustomer")
+	defer span.End()
+
+	if err := s.permit(ctx, OpCustomerErase); err != nil {
+		s.recordAudit(ctx, audit.ActionErase, "", err)
+		return Erasure{}, err
+	}
+	now := time.Now().UTC()
+	rec := s.privacyRecord(ctx, "erase", customerID, now)
+	uids, err := s.repo.EraseCustomer(ctx, customerID, Pseudonym(customerID), rec)
+	if err != nil {
+		return Erasure{}, fmt.Errorf("erase customer: %w", err)
+	}
+	rec.OrderUIDs = uids
+	s.auditPrivacy(ctx, rec)
+	for _, uid := range uids {
+		s.recordAudit(ctx, audit.ActionErase, uid, nil)
+	}
+	span.SetAttributes(attribute.Int("orders_count", len(uids)))
+
+	res := Erasure{OrderUIDs: uids, ErasedAt: now}
+	if len(uids) > 0 {
+		// the database is already anonymized; a failed eviction is reported so
+		// the caller can retry it with EvictCache
+		if err := s.EvictCache(ctx, uids...); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// permit checks that the caller may run a privileged operation: an admin
+// subject may run all of them, anyone else only those a role of theirs lists.
+// Without a policy only admins may.
+func (s *Service) permit(ctx context.Context, op string) error {
+	id := auth.FromContext(ctx)
+	if id != nil && slices.Contains(s.admins, id.Subject) {
+		return nil
+	}
+	if s.policy != nil && s.policy.permits(id, op) {
+		return nil
+	}
+	return s.deny(ctx, op)
+}
+
+func (s *Service) privacyRecord(ctx context.Context, kind, customerID string, at time.Time) repository.PrivacyRecord {
+	actor := AnonymousRole
+	if id := auth.FromContext(ctx); id != nil {
+		actor = id.Subject
+	}
+	return repository.PrivacyRecord{
+		Kind:        kind,
+		SubjectHash: SubjectHash(customerID),
+		Actor:       actor,
+		RequestID:   observability.RequestIDFromContext(ctx),
+		At:          at,
+	}
+}
+
+func (s *Service) auditPrivacy(ctx context.Context, rec repository.PrivacyRecord) {
+	s.logger.InfoContext(ctx, "privacy request",
+		"audit", "privacy",
+		"kind", rec.Kind,
+		"subject_hash", rec.SubjectHash,
+		"actor", rec.Actor,
+		"req_id", rec.RequestID,
+		"order_uids", rec.OrderUIDs,
+	)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestExportAndEraseCustomer(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := fake.New(fake.WithSeed(11))
+	first, second, other := g.Order(), g.Order(), g.Order()
+	first.CustomerID, second.CustomerID, other.CustomerID = "alice", "alice", "bob"
+	repo := newMemRepo(first, second, other)
+	cache := newMemCache()
+	ctx := context.Background()
+	for _, o := range []string{first.OrderUID, second.OrderUID, other.OrderUID} {
+		cache.Set(ctx, o, repo.orders[o], time.Minute)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(repo, cache, time.Minute, logger, otel.Tracer("test"), WithPolicy(policy))
+
+	customer := auth.WithIdentity(ctx, &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if _, err := svc.EraseCustomer(customer, "alice"); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("customer erased without the operation: %v", err)
+	}
+
+	ops := auth.WithIdentity(ctx, &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	export, err := svc.ExportCustomer(ops, "alice")
+	if err != nil || len(export.Orders) != 2 {
+		t.Fatalf("export: %d orders, %v", len(export.Orders), err)
+	}
+	if export.Orders[0].Delivery.Phone == "" || export.Orders[0].Payment.Amount == 0 {
+		t.Fatalf("export is incomplete: %+v", export.Orders[0])
+	}
+
+	res, err := svc.EraseCustomer(ops, "alice")
+	if err != nil || len(res.OrderUIDs) != 2 {
+		t.Fatalf("erase: %v, %v", res.OrderUIDs, err)
+	}
+	for _, uid := range res.OrderUIDs {
+		if cache.has(uid) {
+			t.Fatalf("erased order %s still cached", uid)
+		}
+		o := repo.orders[uid]
+		if o.CustomerID != Pseudonym("alice") || o.Delivery.Phone != repository.Erased || o.Payment.Amount == 0 {
+			t.Fatalf("order %s not anonymized: %+v", uid, o)
+		}
+	}
+	if !cache.has(other.OrderUID) || repo.orders[other.OrderUID].CustomerID != "bob" {
+		t.Fatalf("another customer was touched")
+	}
+
+	if len(repo.privacy) != 2 {
+		t.Fatalf("expected export and erase records, got %+v", repo.privacy)
+	}
+	rec := repo.privacy[1]
+	if rec.Kind != "erase" || rec.Actor != "ops" || rec.SubjectHash != SubjectHash("alice") || len(rec.OrderUIDs) != 2 {
+		t.Fatalf("unexpected erase record %+v", rec)
+	}
+}
+
+func TestPrivilegedWithoutPolicy(t *testing.T) {
+	g := fake.New(fake.WithSeed(12))
+	o := g.Order()
+	o.CustomerID = "alice"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(newMemRepo(o), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithAdmins("ops"))
+
+	ctx := context.Background()
+	alice := auth.WithIdentity(ctx, &auth.Identity{Subject: "alice", Roles: []string{"admin"}})
+	for name, caller := range map[string]context.Context{"anonymous": ctx, "alice": alice} {
+		if _, err := svc.ExportCustomer(caller, "alice"); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s exported without a policy: %v", name, err)
+		}
+		if _, err := svc.EraseCustomer(caller, "alice"); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s erased without a policy: %v", name, err)
+		}
+	}
+	ops := auth.WithIdentity(ctx, &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey})
+	if export, err := svc.ExportCustomer(ops, "alice"); err != nil || len(export.Orders) != 1 {
+		t.Fatalf("admin export: %d orders, %v", len(export.Orders), err)
+	}
+}
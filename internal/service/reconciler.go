@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"orderservice/internal/repository"
+	"orderservice/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReconcileMode decides what the Reconciler does with a cached copy that
+// differs from the database.
+type ReconcileMode string
+
+const (
+	// ReconcileRepair overwrites the cached copy with the database copy.
+	ReconcileRepair ReconcileMode = "repair"
+	// ReconcileEvict deletes the cached copy.
+	ReconcileEvict ReconcileMode = "evict"
+	// ReconcileReportOnly only logs and counts mismatches.
+	ReconcileReportOnly ReconcileMode = "report"
+)
+
+// ParseReconcileMode validates a mode name from config or flags.
+func ParseReconcileMode(s string) (ReconcileMode, error) {
+	switch m := ReconcileMode(s); m {
+	case ReconcileRepair, ReconcileEvict, ReconcileReportOnly:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown reconcile mode %q (want repair, evict or report)", s)
+}
+
+// Outcomes of checking one cached order.
+const (
+	OutcomeOK       = "ok"
+	OutcomeNotCache = "not_cached"
+	OutcomeMismatch = "mismatch"
+	OutcomeOrphan   = "orphan"
+	OutcomeError    = "error"
+)
+
+var reconcileCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_reconcile_total",
+	Help: "Cached orders checked against the database, by outcome and action taken.",
+}, []string{"outcome", "action"})
+
+func init() {
+	prometheus.MustRegister(reconcileCounter)
+}
+
+// ReconcilerConfig configures the background cache/DB consistency checker.
+type ReconcilerConfig struct {
+	// Interval between background passes; 0 disables the background loop.
+	Interval time.Duration
+	// BatchSize is how many cached keys one pass checks. The SCAN cursor is
+	// kept between passes, so the whole keyspace is covered incrementally.
+	// 0 scans the whole cache every pass.
+	BatchSize int
+	Mode      ReconcileMode
+}
+
+// ReconcileResult is the outcome for one order.
+type ReconcileResult struct {
+	OrderUID string `json:"order_uid"`
+	Outcome  string `json:"outcome"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReconcileReport aggregates a reconcile pass.
+type ReconcileReport struct {
+	Checked  int               `json:"checked"`
+	Outcomes map[string]int    `json:"outcomes"`
+	Problems []ReconcileResult `json:"problems,omitempty"`
+}
+
+func (r *ReconcileReport) add(res ReconcileResult) {
+	r.Checked++
+	if r.Outcomes == nil {
+		r.Outcomes = make(map[string]int)
+	}
+	r.Outcomes[res.Outcome]++
+	if res.Outcome != OutcomeOK && res.Outcome != OutcomeNotCache {
+		r.Problems = append(r.Problems, res)
+	}
+}
+
+// Reconciler compares cached orders with the repository by content hash and
+// repairs or evicts stale entries.
+type Reconciler struct {
+	repo     repository.OrderRepository
+	cache    repository.CacheRepository
+	cacheTTL atomic.Int64 // time.Duration
+	cfg      ReconcilerConfig
+	logger   *slog.Logger
+	tracer   trace.Tracer
+
+	cursor uint64
+}
+
+func NewReconciler(repo repository.OrderRepository, cache repository.CacheRepository, cacheTTL time.Duration, cfg ReconcilerConfig, logger *slog.Logger, tracer trace.Tracer) *Reconciler {
+	if cfg.Mode == "" {
+		cfg.Mode = ReconcileRepair
+	}
+	r := &Reconciler{repo: repo, cache: cache, cfg: cfg, logger: logger, tracer: tracer}
+	r.cacheTTL.Store(int64(cacheTTL))
+	return r
+}
+
+// SetCacheTTL changes the TTL of entries repaired from now on.
+func (r *Reconciler) SetCacheTTL(ttl time.Duration) { r.cacheTTL.Store(int64(ttl)) }
+
+func (r *Reconciler) ttl() time.Duration { return time.Duration(r.cacheTTL.Load()) }
+
+// Run performs a pass every cfg.Interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) error {
+	if r.cfg.Interval <= 0 || r.cache == nil {
+		return nil
+	}
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			report, err := r.ScanCache(ctx)
+			if err != nil {
+				r.logger.Error("cache reconcile", "err", err)
+				continue
+			}
+			r.logger.Info("cache reconcile", "checked", report.Checked, "outcomes", report.Outcomes)
+		}
+	}
+}
+
+// ScanCache checks the next cfg.BatchSize cached keys (or all keys when
+// BatchSize is 0).
+func (r *Reconciler) ScanCache(ctx context.Context) (ReconcileReport, error) {
+	ctx, span := r.tracer.Start(ctx, "reconciler.ScanCache")
+	defer span.End()
+
+	var report ReconcileReport
+	for {
+		keys, next, err := r.cache.Scan(ctx, r.cursor, 100)
+		if err != nil {
+			return report, err
+		}
+		r.cursor = next
+		for _, uid := range keys {
+			report.add(r.check(ctx, uid))
+		}
+		if next == 0 || (r.cfg.BatchSize > 0 && report.Checked >= r.cfg.BatchSize) {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("checked", report.Checked))
+	return report, nil
+}
+
+// ReconcileUID checks a single order.
+func (r *Reconciler) ReconcileUID(ctx context.Context, uid string) (ReconcileResult, error) {
+	if uid == "" {
+		return ReconcileResult{}, ErrValidation
+	}
+	ctx, span := r.tracer.Start(ctx, "reconciler.ReconcileUID")
+	defer span.End()
+
+	res := r.check(ctx, uid)
+	if res.Outcome == OutcomeError {
+		return res, errors.New(res.Error)
+	}
+	return res, nil
+}
+
+// ReconcileRange checks every order created in [from, to).
+func (r *Reconciler) ReconcileRange(ctx context.Context, from, to time.Time) (ReconcileReport, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return ReconcileReport{}, ErrValidation
+	}
+	ctx, span := r.tracer.Start(ctx, "reconciler.ReconcileRange")
+	defer span.End()
+
+	const page = 200
+	var report ReconcileReport
+	for offset := 0; ; offset += page {
+		orders, err := r.repo.FindOrders(ctx, repository.OrderFilter{From: from, To: to, Limit: page, Offset: offset})
+		if err != nil {
+			return report, fmt.Errorf("find orders: %w", err)
+		}
+		for _, o := range orders {
+			report.add(r.compare(ctx, o.OrderUID, &o))
+		}
+		if len(orders) < page {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("checked", report.Checked))
+	return report, nil
+}
+
+func (r *Reconciler) check(ctx context.Context, uid string) ReconcileResult {
+	dbCopy, err := r.repo.GetOrder(ctx, uid)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return r.compare(ctx, uid, nil)
+	case err != nil:
+		return r.record(ReconcileResult{OrderUID: uid, Outcome: OutcomeError, Action: "none", Error: err.Error()})
+	}
+	return r.compare(ctx, uid, &dbCopy)
+}
+
+// compare checks the cached copy of uid against dbCopy (nil when the order is
+// not in the database) and applies the configured action.
+func (r *Reconciler) compare(ctx context.Context, uid string, dbCopy *models.Order) ReconcileResult {
+	res := ReconcileResult{OrderUID: uid, Action: "none"}
+	cached, ok, err := r.cache.Get(ctx, uid)
+	if err != nil {
+		res.Outcome, res.Error = OutcomeError, err.Error()
+		return r.record(res)
+	}
+	switch {
+	case !ok:
+		res.Outcome = OutcomeNotCache
+		return r.record(res)
+	case dbCopy == nil:
+		res.Outcome = OutcomeOrphan
+	case cached.ContentHash() == dbCopy.ContentHash():
+		res.Outcome = OutcomeOK
+		return r.record(res)
+	default:
+		res.Outcome = OutcomeMismatch
+	}
+
+	switch {
+	case r.cfg.Mode == ReconcileReportOnly:
+	case r.cfg.Mode == ReconcileEvict || dbCopy == nil:
+		if err := r.cache.Delete(ctx, uid); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Action = "evicted"
+		}
+	default:
+		if err := r.cache.Set(ctx, uid, *dbCopy, r.ttl()); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Action = "repaired"
+		}
+	}
+	r.logger.Warn("cache drift", "uid", uid, "outcome", res.Outcome, "action", res.Action, "err", res.Error)
+	return r.record(res)
+}
+
+func (r *Reconciler) record(res ReconcileResult) ReconcileResult {
+	reconcileCounter.WithLabelValues(res.Outcome, res.Action).Inc()
+	return res
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"orderservice/pkg/models/fake"
+)
+
+func TestReconcilerScanCache(t *testing.T) {
+	g := fake.New(fake.WithSeed(3))
+	good, stale, orphan := g.Order(), g.Order(), g.Order()
+	repo := newMemRepo(good, stale)
+	cache := newMemCache()
+
+	ctx := context.Background()
+	cache.Set(ctx, good.OrderUID, good, time.Minute)
+	drifted := stale
+	drifted.Delivery.Phone = "+10000000000"
+	cache.Set(ctx, stale.OrderUID, drifted, time.Minute)
+	cache.Set(ctx, orphan.OrderUID, orphan, time.Minute)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r := NewReconciler(repo, cache, time.Minute, ReconcilerConfig{Mode: ReconcileRepair}, logger, otel.Tracer("test"))
+	report, err := r.ScanCache(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 || report.Outcomes[OutcomeOK] != 1 || report.Outcomes[OutcomeMismatch] != 1 || report.Outcomes[OutcomeOrphan] != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if got, _, _ := cache.Get(ctx, stale.OrderUID); got.ContentHash() != stale.ContentHash() {
+		t.Fatalf("stale entry not repaired")
+	}
+	if cache.has(orphan.OrderUID) {
+		t.Fatalf("orphan entry not evicted")
+	}
+}
+
+func TestReconcilerEvictAndRange(t *testing.T) {
+	now := time.Now().UTC()
+	g := fake.New(fake.WithNow(now))
+	a, b := g.Order(), g.Order()
+	repo := newMemRepo(a, b)
+	cache := newMemCache()
+	ctx := context.Background()
+	changed := a
+	changed.Items = nil
+	cache.Set(ctx, a.OrderUID, changed, time.Minute)
+	cache.Set(ctx, b.OrderUID, b, time.Minute)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r := NewReconciler(repo, cache, time.Minute, ReconcilerConfig{Mode: ReconcileEvict}, logger, otel.Tracer("test"))
+
+	res, err := r.ReconcileUID(ctx, b.OrderUID)
+	if err != nil || res.Outcome != OutcomeOK {
+		t.Fatalf("ReconcileUID: %+v, %v", res, err)
+	}
+	report, err := r.ReconcileRange(ctx, now.Add(-31*24*time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 2 || len(report.Problems) != 1 || report.Problems[0].Action != "evicted" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if cache.has(a.OrderUID) {
+		t.Fatalf("mismatched entry not evicted")
+	}
+}
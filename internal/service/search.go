@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/repository"
+	"orderservice/internal/search"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// searchPaths maps search fields to the order fields they read. Searching a
+// field the caller cannot see, or sees masked, would reveal its value through
+// the matches.
+var searchPaths = map[string]string{
+	search.FieldName:     "delivery.name",
+	search.FieldAddress:  "delivery.address",
+	search.FieldPhone:    "delivery.phone",
+	search.FieldTrack:    "track_number",
+	search.FieldItem:     "items",
+	search.FieldBrand:    "items",
+	search.FieldItems:    "items",
+	search.FieldCustomer: "customer_id",
+	search.FieldService:  "delivery_service",
+	search.FieldCity:     "delivery.city",
+	search.FieldRegion:   "delivery.region",
+	search.FieldUID:      "order_uid",
+	search.FieldAmount:   "payment",
+	search.FieldCreated:  "date_created",
+}
+
+// SearchOrders finds the orders matching query (see package search), most
+// relevant first. Only fields the caller's policy shows can be searched, and
+// personal data only where every grant reveals it unmasked; free text skips
+// the rest. The query text is never logged or traced, only its fields.
+func (s *Service) SearchOrders(ctx context.Context, query string, limit, offset int) ([]repository.SearchHit, error) {
+	if limit < 0 || offset < 0 {
+		return nil, ErrValidation
+	}
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	expr, err := search.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	ctx, span := s.tracer.Start(ctx, "service.SearchOrders")
+	defer span.End()
+
+	q := repository.SearchQuery{Expr: expr, Scope: repository.OrderFilter{Limit: limit, Offset: offset}}
+	fields := search.Fields(expr)
+	// without a policy personal data is masked for everyone, so it cannot be searched
+	searchable := func(path string) bool { return !maskedPaths[path] }
+	if s.policy != nil {
+		scoped, grants, ok := s.policy.scope(auth.FromContext(ctx), q.Scope)
+		if !ok {
+			err := s.deny(ctx, "search", "fields", fields)
+			s.recordAudit(ctx, audit.ActionRead, "", err)
+			return nil, err
+		}
+		q.Scope = scoped
+		searchable = func(path string) bool { return searchableByAll(grants, path) }
+	}
+	q.TextFields = []string{}
+	for _, f := range search.TextFields {
+		if searchable(searchPaths[f]) {
+			q.TextFields = append(q.TextFields, f)
+		}
+	}
+	for _, f := range fields {
+		if f == search.FieldText && len(q.TextFields) > 0 || f != search.FieldText && searchable(searchPaths[f]) {
+			continue
+		}
+		err := s.deny(ctx, "search", "fields", fields, "field", f)
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return nil, err
+	}
+
+	hits, err := s.repo.SearchOrders(ctx, q)
+	if err != nil {
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+	id := auth.FromContext(ctx)
+	visible := hits[:0]
+	for _, h := range hits {
+		if ok, v := s.visible(id, h.Order); ok {
+			h.Order = v.apply(h.Order)
+			visible = append(visible, h)
+		}
+	}
+	hits = visible
+	for _, h := range hits {
+		s.recordAudit(ctx, audit.ActionRead, h.Order.OrderUID, nil)
+	}
+	span.SetAttributes(attribute.StringSlice("fields", fields), attribute.Int("orders_count", len(hits)))
+	return hits, nil
+}
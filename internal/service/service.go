@@ -2,12 +2,20 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"orderservice/internal/analytics"
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
+	"orderservice/internal/features"
 	"orderservice/internal/observability"
 	"orderservice/internal/repository"
+	"orderservice/internal/stream"
 	"orderservice/pkg/models"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -17,22 +25,88 @@ import (
 type Service struct {
 	repo     repository.OrderRepository
 	cache    repository.CacheRepository
-	cacheTTL time.Duration
+	cacheTTL atomic.Int64 // time.Duration, changed by SetCacheTTL
 	logger   *slog.Logger
 	tracer   trace.Tracer
+	policy   *Policy
+	admins   []string
+	auditor  Auditor
+	auditLog audit.Store
+	stream   *stream.Hub
+	stats    analytics.Store
+	exports  export.Source
+	flags    *features.Flags
 }
 
-func New(repo repository.OrderRepository, cache repository.CacheRepository, cacheTTL time.Duration, logger *slog.Logger, tracer trace.Tracer) *Service {
-	return &Service{
-		repo:     repo,
-		cache:    cache,
-		cacheTTL: cacheTTL,
-		logger:   logger,
-		tracer:   tracer,
+// Auditor receives an event for every read and write of order data.
+// Record must not block.
+type Auditor interface {
+	Record(ctx context.Context, e audit.Event)
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithPolicy enforces p on every read; without it all reads are allowed with
+// personal data masked.
+func WithPolicy(p *Policy) Option {
+	return func(s *Service) { s.policy = p }
+}
+
+// WithAdmins lets the authenticated subjects run every privileged operation,
+// with or without a policy.
+func WithAdmins(subjects ...string) Option {
+	return func(s *Service) { s.admins = subjects }
+}
+
+// WithAudit records order access to a and serves QueryAudit from store.
+func WithAudit(a Auditor, store audit.Store) Option {
+	return func(s *Service) { s.auditor, s.auditLog = a, store }
+}
+
+// WithAnalytics serves OrderStats and TopItems from store.
+func WithAnalytics(store analytics.Store) Option {
+	return func(s *Service) { s.stats = store }
+}
+
+// WithExport serves ExportOrders from src.
+func WithExport(src export.Source) Option {
+	return func(s *Service) { s.exports = src }
+}
+
+// WithStream publishes every saved order to h for WatchOrders.
+func WithStream(h *stream.Hub) Option {
+	return func(s *Service) { s.stream = h }
+}
+
+func New(repo repository.OrderRepository, cache repository.CacheRepository, cacheTTL time.Duration, logger *slog.Logger, tracer trace.Tracer, opts ...Option) *Service {
+	s := &Service{
+		repo:   repo,
+		cache:  cache,
+		logger: logger,
+		tracer: tracer,
 	}
+	s.cacheTTL.Store(int64(cacheTTL))
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
+// SetCacheTTL changes the TTL of entries cached from now on.
+func (s *Service) SetCacheTTL(ttl time.Duration) { s.cacheTTL.Store(int64(ttl)) }
+
+func (s *Service) ttl() time.Duration { return time.Duration(s.cacheTTL.Load()) }
+
 func (s *Service) SaveOrder(ctx context.Context, order models.Order) error {
+	err := s.saveOrder(ctx, order)
+	if !errors.Is(err, ErrDuplicate) {
+		s.recordAudit(ctx, audit.ActionCreate, order.OrderUID, err)
+	}
+	return err
+}
+
+func (s *Service) saveOrder(ctx context.Context, order models.Order) error {
 	if err := ValidateOrder(order); err != nil {
 		return err
 	}
@@ -45,24 +119,55 @@ func (s *Service) SaveOrder(ctx context.Context, order models.Order) error {
 	}
 
 	if err := s.repo.SaveOrder(ctx, order); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			// the stored order stays; cache and stream keep matching it
+			span.SetAttributes(attribute.String("order_uid", order.OrderUID), attribute.Bool("duplicate", true))
+			return ErrDuplicate
+		}
 		return fmt.Errorf("save order: %w", err)
 	}
 	if s.cache != nil {
-		if err := s.cache.Set(ctx, order.OrderUID, order, s.cacheTTL); err != nil {
-			logger.Error("cache set failed", "err", err, "uid", order.OrderUID)
+		if !s.flags.Enabled(FlagLazyCache, features.ForOrder(order)) {
+			if err := s.cache.Set(ctx, order.OrderUID, order, s.ttl()); err != nil {
+				logger.Error("cache set failed", "err", err, "uid", order.OrderUID)
+			}
+		}
+		match := repository.OrderRefMatch{OrderUID: order.OrderUID, DateCreated: order.DateCreated}
+		if err := s.cache.AddRefs(ctx, match, repository.OrderRefs(order), s.ttl()); err != nil {
+			logger.Error("cache add refs failed", "err", err, "uid", order.OrderUID)
 		}
 	}
+	if s.stream != nil {
+		s.stream.Publish(stream.Summarize(order))
+	}
 	span.SetAttributes(attribute.String("order_uid", order.OrderUID))
 	return nil
 }
 
 func (s *Service) GetOrder(ctx context.Context, uid string) (models.Order, error) {
+	o, err := s.getOrder(ctx, uid)
+	s.recordAudit(ctx, audit.ActionRead, uid, err)
+	return o, err
+}
+
+func (s *Service) getOrder(ctx context.Context, uid string) (models.Order, error) {
 	if uid == "" {
 		return models.Order{}, ErrValidation
 	}
 	ctx, span := s.tracer.Start(ctx, "service.GetOrder")
 	defer span.End()
 
+	order, err := s.loadOrder(ctx, uid)
+	if err != nil {
+		return models.Order{}, err
+	}
+	return s.authorize(ctx, "get", order)
+}
+
+// loadOrder reads an order from the cache, falling back to the repository
+// and caching what it read. Access is not checked.
+func (s *Service) loadOrder(ctx context.Context, uid string) (models.Order, error) {
+	span := trace.SpanFromContext(ctx)
 	if s.cache != nil {
 		if cached, ok, err := s.cache.Get(ctx, uid); err == nil && ok {
 			span.SetAttributes(attribute.String("source", "cache"))
@@ -86,7 +191,7 @@ func (s *Service) GetOrder(ctx context.Context, uid string) (models.Order, error
 	}
 
 	if s.cache != nil {
-		if err := s.cache.Set(ctx, order.OrderUID, order, s.cacheTTL); err != nil {
+		if err := s.cache.Set(ctx, order.OrderUID, order, s.ttl()); err != nil {
 			logger.Error("cache set failed", "err", err, "uid", uid)
 		}
 	}
@@ -94,6 +199,121 @@ func (s *Service) GetOrder(ctx context.Context, uid string) (models.Order, error
 	return order, nil
 }
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+func (s *Service) ListOrders(ctx context.Context, f repository.OrderFilter) ([]models.Order, error) {
+	if f.Limit < 0 || f.Offset < 0 || (!f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From)) {
+		return nil, ErrValidation
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	ctx, span := s.tracer.Start(ctx, "service.ListOrders")
+	defer span.End()
+
+	if s.policy != nil {
+		scoped, _, ok := s.policy.scope(auth.FromContext(ctx), f)
+		if !ok {
+			err := s.deny(ctx, "list", "customer_id", f.CustomerID, "delivery_service", f.DeliveryService)
+			s.recordAudit(ctx, audit.ActionRead, "", err)
+			return nil, err
+		}
+		f = scoped
+	}
+	orders, err := s.repo.FindOrders(ctx, f)
+	if err != nil {
+		s.recordAudit(ctx, audit.ActionRead, "", err)
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	id := auth.FromContext(ctx)
+	visible := orders[:0]
+	for _, o := range orders {
+		if ok, v := s.visible(id, o); ok {
+			visible = append(visible, v.apply(o))
+		}
+	}
+	orders = visible
+	for _, o := range orders {
+		s.recordAudit(ctx, audit.ActionRead, o.OrderUID, nil)
+	}
+	span.SetAttributes(attribute.Int("orders_count", len(orders)))
+	return orders, nil
+}
+
+// WatchOrders subscribes to orders saved from now on (or after lastID, as far
+// as the replay buffer reaches), narrowed to what the caller may list. The
+// caller must Close the subscription and report every delivered event with
+// OrderWatched.
+func (s *Service) WatchOrders(ctx context.Context, f stream.Filter, lastID uint64) (*stream.Subscription, error) {
+	if s.stream == nil {
+		return nil, ErrStreamDisabled
+	}
+	if s.policy != nil {
+		scoped, _, ok := s.policy.scope(auth.FromContext(ctx), repository.OrderFilter{
+			CustomerID: f.CustomerID, DeliveryService: f.DeliveryService, Region: f.Region,
+		})
+		if !ok {
+			err := s.deny(ctx, "watch", "customer_id", f.CustomerID, "delivery_service", f.DeliveryService)
+			s.recordAudit(ctx, audit.ActionRead, "", err)
+			return nil, err
+		}
+		for _, sc := range scoped.Scopes {
+			f.Scopes = append(f.Scopes, stream.Filter{CustomerID: sc.CustomerID, DeliveryService: sc.DeliveryService, Region: sc.Region})
+		}
+	}
+	return s.stream.Subscribe(f, lastID), nil
+}
+
+// OrderWatched records the read of an order delivered to a WatchOrders
+// subscriber.
+func (s *Service) OrderWatched(ctx context.Context, ev stream.Event) {
+	s.recordAudit(ctx, audit.ActionRead, ev.Order.OrderUID, nil)
+}
+
+// EvictCache drops the cached copies of the given orders.
+func (s *Service) EvictCache(ctx context.Context, uids ...string) error {
+	if s.cache == nil {
+		return nil
+	}
+	ctx, span := s.tracer.Start(ctx, "service.EvictCache")
+	defer span.End()
+
+	if err := s.cache.Delete(ctx, uids...); err != nil {
+		return fmt.Errorf("evict cache: %w", err)
+	}
+	return nil
+}
+
+// WarmCache reloads the given orders from the repository into the cache.
+func (s *Service) WarmCache(ctx context.Context, uids ...string) error {
+	if s.cache == nil {
+		return nil
+	}
+	ctx, span := s.tracer.Start(ctx, "service.WarmCache")
+	defer span.End()
+
+	for _, uid := range uids {
+		order, err := s.repo.GetOrder(ctx, uid)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%s: %w", uid, ErrNotFound)
+			}
+			return fmt.Errorf("get order %s: %w", uid, err)
+		}
+		if err := s.cache.Set(ctx, uid, order, s.ttl()); err != nil {
+			return fmt.Errorf("cache set %s: %w", uid, err)
+		}
+	}
+	span.SetAttributes(attribute.Int("cache_primed", len(uids)))
+	return nil
+}
+
 func (s *Service) RestoreCache(ctx context.Context) error {
 	if s.cache == nil {
 		return nil
@@ -106,10 +326,62 @@ func (s *Service) RestoreCache(ctx context.Context) error {
 		return fmt.Errorf("list orders: %w", err)
 	}
 	for _, o := range orders {
-		if err := s.cache.Set(ctx, o.OrderUID, o, s.cacheTTL); err != nil {
+		if err := s.cache.Set(ctx, o.OrderUID, o, s.ttl()); err != nil {
 			s.logger.Error("cache warmup failed", "err", err, "uid", o.OrderUID)
 		}
 	}
 	span.SetAttributes(attribute.Int("cache_primed", len(orders)))
 	return nil
 }
+
+func (s *Service) recordAudit(ctx context.Context, action, uid string, err error) {
+	if s.auditor == nil {
+		return
+	}
+	outcome := audit.OutcomeOK
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrPermissionDenied):
+		outcome = audit.OutcomeDenied
+	case errors.Is(err, ErrNotFound):
+		outcome = audit.OutcomeNotFound
+	case errors.Is(err, ErrValidation):
+		outcome = audit.OutcomeInvalid
+	default:
+		outcome = audit.OutcomeError
+	}
+	s.auditor.Record(ctx, audit.Event{Action: action, OrderUID: uid, Outcome: outcome})
+}
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// QueryAudit searches the audit log, newest events first.
+func (s *Service) QueryAudit(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	if f.Limit < 0 || f.Offset < 0 || (!f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From)) {
+		return nil, ErrValidation
+	}
+	if s.auditLog == nil {
+		return nil, ErrAuditDisabled
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultAuditPageSize
+	}
+	if f.Limit > maxAuditPageSize {
+		f.Limit = maxAuditPageSize
+	}
+	ctx, span := s.tracer.Start(ctx, "service.QueryAudit")
+	defer span.End()
+
+	if err := s.permit(ctx, OpAuditRead); err != nil {
+		return nil, err
+	}
+	events, err := s.auditLog.QueryEvents(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	span.SetAttributes(attribute.Int("events_count", len(events)))
+	return events, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/auth"
+	"orderservice/internal/stream"
+	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestWatchOrdersScopedByPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../policy.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test")).
+		WatchOrders(context.Background(), stream.Filter{}, 0); !errors.Is(err, ErrStreamDisabled) {
+		t.Fatalf("without hub: %v", err)
+	}
+
+	hub := stream.NewHub(stream.Config{}, nil, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	auditor := &memAuditor{}
+	svc := New(newMemRepo(), newMemCache(), time.Minute, logger, otel.Tracer("test"), WithPolicy(policy), WithStream(hub), WithAudit(auditor, nil))
+
+	customer := auth.WithIdentity(ctx, &auth.Identity{Subject: "alice", Roles: []string{"customer"}})
+	if _, err := svc.WatchOrders(customer, stream.Filter{CustomerID: "bob"}, 0); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("watching another customer: %v", err)
+	}
+	sub, err := svc.WatchOrders(customer, stream.Filter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	g := fake.New(fake.WithSeed(11))
+	foreign, own := g.Order(), g.Order()
+	foreign.CustomerID, own.CustomerID = "bob", "alice"
+	for _, o := range []models.Order{foreign, own} {
+		if err := svc.SaveOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case ev := <-sub.Events():
+		if ev.Order.OrderUID != own.OrderUID {
+			t.Fatalf("customer saw %s", ev.Order.OrderUID)
+		}
+		svc.OrderWatched(customer, ev)
+	case <-time.After(time.Second):
+		t.Fatalf("own order not streamed")
+	}
+
+	var reads []audit.Event
+	for _, e := range auditor.events {
+		if e.Action == audit.ActionRead {
+			reads = append(reads, e)
+		}
+	}
+	if len(reads) != 2 || reads[0].Outcome != audit.OutcomeDenied || reads[1].OrderUID != own.OrderUID || reads[1].Outcome != audit.OutcomeOK {
+		t.Fatalf("audit %+v", reads)
+	}
+}
@@ -2,32 +2,20 @@ package service
 
 import (
 	"testing"
-	"time"
 
 	"orderservice/pkg/models"
+	"orderservice/pkg/models/fake"
 )
 
 func TestValidateOrder(t *testing.T) {
-	o := models.Order{
-		OrderUID:        "1",
-		TrackNumber:     "tn",
-		Entry:           "entry",
-		Locale:          "en",
-		CustomerID:      "c",
-		DeliveryService: "d",
-		ShardKey:        "1",
-		SmID:            1,
-		DateCreated:     time.Now(),
-		OofShard:        "1",
-		Items:           []models.Item{{ChrtID: 1, TrackNumber: "tn", Price: 1, Rid: "1", Name: "n"}},
-		Delivery:        models.Delivery{Name: "n", Phone: "p", Zip: "z", City: "c", Address: "a", Region: "r", Email: "e"},
-		Payment:         models.Payment{Transaction: "t", Currency: "c", Provider: "p", Amount: 1},
-	}
-	if err := ValidateOrder(o); err != nil {
+	if err := ValidateOrder(fake.Order()); err != nil {
 		t.Fatalf("valid order: %v", err)
 	}
 	bad := models.Order{}
 	if err := ValidateOrder(bad); err == nil {
 		t.Fatalf("expected error for invalid order")
 	}
+	if err := ValidateOrder(fake.Order(fake.MissingRequired)); err == nil {
+		t.Fatalf("expected error for order with missing fields")
+	}
 }
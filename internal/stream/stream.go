@@ -0,0 +1,332 @@
+// Package stream fans newly saved orders out to live subscribers. Events are
+// numbered and published through Redis, so every replica delivers the same
+// events with the same ids; a short replay buffer lets subscribers resume
+// from the last id they saw.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"orderservice/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSlowSubscriber ends a subscription that did not keep up.
+var ErrSlowSubscriber = errors.New("subscriber too slow, events dropped")
+
+// Summary is the part of an order pushed to watchers; it carries no
+// delivery contacts.
+type Summary struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Region          string    `json:"region"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	Items           int       `json:"items"`
+	DateCreated     time.Time `json:"date_created"`
+}
+
+func Summarize(o models.Order) Summary {
+	return Summary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Region:          o.Delivery.Region,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		Items:           len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
+
+type Event struct {
+	ID    uint64  `json:"id"`
+	Order Summary `json:"order"`
+}
+
+// Filter selects events for a subscriber. Zero values mean "no condition".
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+	Region          string
+	// Scopes, when not empty, keeps only the events matching one of them too.
+	Scopes []Filter
+}
+
+func (f Filter) match(s Summary) bool {
+	if f.CustomerID != "" && f.CustomerID != s.CustomerID ||
+		f.DeliveryService != "" && f.DeliveryService != s.DeliveryService ||
+		f.Region != "" && f.Region != s.Region {
+		return false
+	}
+	if len(f.Scopes) == 0 {
+		return true
+	}
+	for _, sc := range f.Scopes {
+		if sc.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	subscribersGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "order_stream_subscribers",
+		Help: "Live order stream subscribers.",
+	})
+	slowDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "order_stream_slow_subscribers_total",
+		Help: "Subscribers disconnected because their buffer was full.",
+	})
+	publishFailedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "order_stream_publish_failed_total",
+		Help: "Order events not published (queue full or Redis error).",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(subscribersGauge, slowDroppedTotal, publishFailedTotal)
+}
+
+const (
+	seqKey  = "orders:events:seq"
+	channel = "orders:events"
+)
+
+// publishScript numbers and publishes an event in one step, so ids increase
+// in the order Redis delivers them to every replica.
+var publishScript = redis.NewScript(`
+local id = redis.call('INCR', KEYS[1])
+redis.call('PUBLISH', ARGV[1], id .. ':' .. ARGV[2])
+return id
+`)
+
+type Config struct {
+	Replay           int // events kept for resuming
+	SubscriberBuffer int // events a subscriber may lag behind before it is dropped
+}
+
+// Hub delivers events to subscribers on this replica.
+type Hub struct {
+	cfg    Config
+	redis  *redis.Client // nil: events stay on this replica
+	logger *slog.Logger
+
+	queue chan Summary
+
+	mu     sync.Mutex
+	seq    uint64 // local mode only
+	ring   []Event
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub returns a hub publishing through client, or locally when client is nil.
+func NewHub(cfg Config, client *redis.Client, logger *slog.Logger) *Hub {
+	if cfg.Replay <= 0 {
+		cfg.Replay = 1000
+	}
+	if cfg.SubscriberBuffer <= 0 {
+		cfg.SubscriberBuffer = 256
+	}
+	return &Hub{
+		cfg:    cfg,
+		redis:  client,
+		logger: logger,
+		queue:  make(chan Summary, 1024),
+		subs:   map[*Subscription]struct{}{},
+	}
+}
+
+// Publish queues s for delivery without blocking; when the queue is full the
+// event is dropped and counted.
+func (h *Hub) Publish(s Summary) {
+	select {
+	case h.queue <- s:
+	default:
+		publishFailedTotal.Inc()
+	}
+}
+
+// Run publishes queued events and, with Redis, delivers events published by
+// every replica until ctx is cancelled. Subscriptions are closed on return.
+func (h *Hub) Run(ctx context.Context) error {
+	defer h.shutdown()
+	if h.redis == nil {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case s := <-h.queue:
+				h.mu.Lock()
+				h.seq++
+				id := h.seq
+				h.mu.Unlock()
+				h.deliver(Event{ID: id, Order: s})
+			}
+		}
+	}
+
+	ps := h.redis.Subscribe(ctx, channel)
+	defer ps.Close()
+	go h.publishLoop(ctx)
+	msgs := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			ev, err := decode(msg.Payload)
+			if err != nil {
+				h.logger.Error("order stream: bad message", "err", err)
+				continue
+			}
+			h.deliver(ev)
+		}
+	}
+}
+
+func (h *Hub) publishLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-h.queue:
+			raw, err := json.Marshal(s)
+			if err == nil {
+				err = publishScript.Run(ctx, h.redis, []string{seqKey}, channel, raw).Err()
+			}
+			if err != nil {
+				publishFailedTotal.Inc()
+				h.logger.Error("order stream: publish failed", "uid", s.OrderUID, "err", err)
+			}
+		}
+	}
+}
+
+func decode(payload string) (Event, error) {
+	rawID, body, ok := strings.Cut(payload, ":")
+	if !ok {
+		return Event{}, fmt.Errorf("no id in %q", payload)
+	}
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return Event{}, fmt.Errorf("id %q: %w", rawID, err)
+	}
+	ev := Event{ID: id}
+	if err := json.Unmarshal([]byte(body), &ev.Order); err != nil {
+		return Event{}, fmt.Errorf("event %d: %w", id, err)
+	}
+	return ev, nil
+}
+
+// deliver records ev for replay and hands it to every matching subscriber;
+// subscribers with a full buffer are dropped rather than waited for.
+func (h *Hub) deliver(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ring = append(h.ring, ev)
+	if len(h.ring) > h.cfg.Replay {
+		h.ring = h.ring[len(h.ring)-h.cfg.Replay:]
+	}
+	for sub := range h.subs {
+		if !sub.filter.match(ev.Order) {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			slowDroppedTotal.Inc()
+			h.remove(sub, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Subscribe starts a subscription. With lastID > 0 the buffered events after
+// it are replayed first; events older than the replay buffer are lost.
+func (h *Hub) Subscribe(f Filter, lastID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []Event
+	if lastID > 0 {
+		for _, ev := range h.ring {
+			if ev.ID > lastID && f.match(ev.Order) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	sub := &Subscription{hub: h, filter: f, events: make(chan Event, h.cfg.SubscriberBuffer+len(replay))}
+	for _, ev := range replay {
+		sub.events <- ev
+	}
+	if h.closed {
+		close(sub.events)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	subscribersGauge.Inc()
+	return sub
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription, err error) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	subscribersGauge.Dec()
+	sub.err = err
+	close(sub.events)
+}
+
+func (h *Hub) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		h.remove(sub, nil)
+	}
+}
+
+// Subscription receives events until it is closed, dropped as slow or the
+// hub stops.
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+	err    error // set under hub.mu before events is closed
+}
+
+// Events is closed when the subscription ends; Err tells why.
+func (s *Subscription) Events() <-chan Event { return s.events }
+
+// Err returns ErrSlowSubscriber if the subscriber was dropped. It is only
+// meaningful after Events is closed.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s, nil)
+}
@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func startHub(t *testing.T, cfg Config) *Hub {
+	t.Helper()
+	h := NewHub(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return h
+}
+
+func next(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("subscription closed: %v", sub.Err())
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatalf("no event")
+	}
+	return Event{}
+}
+
+func TestHubFilterAndResume(t *testing.T) {
+	h := startHub(t, Config{Replay: 2})
+	all := h.Subscribe(Filter{}, 0)
+	defer all.Close()
+	alice := h.Subscribe(Filter{CustomerID: "alice"}, 0)
+	defer alice.Close()
+
+	h.Publish(Summary{OrderUID: "a1", CustomerID: "alice"})
+	h.Publish(Summary{OrderUID: "b1", CustomerID: "bob"})
+	h.Publish(Summary{OrderUID: "a2", CustomerID: "alice"})
+
+	var ids []uint64
+	for range 3 {
+		ids = append(ids, next(t, all).ID)
+	}
+	if ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Fatalf("ids %v", ids)
+	}
+	if ev := next(t, alice); ev.Order.OrderUID != "a1" {
+		t.Fatalf("alice got %+v", ev)
+	}
+	if ev := next(t, alice); ev.Order.OrderUID != "a2" || ev.ID != 3 {
+		t.Fatalf("alice got %+v", ev)
+	}
+
+	// the ring keeps the last two events; resuming after 1 replays 2 and 3
+	resumed := h.Subscribe(Filter{}, 1)
+	defer resumed.Close()
+	if ev := next(t, resumed); ev.ID != 2 {
+		t.Fatalf("first replayed %d", ev.ID)
+	}
+	if ev := next(t, resumed); ev.ID != 3 {
+		t.Fatalf("second replayed %d", ev.ID)
+	}
+	h.Publish(Summary{OrderUID: "a3", CustomerID: "alice"})
+	if ev := next(t, resumed); ev.ID != 4 {
+		t.Fatalf("live after replay %d", ev.ID)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := startHub(t, Config{SubscriberBuffer: 1})
+	slow := h.Subscribe(Filter{}, 0)
+	watcher := h.Subscribe(Filter{}, 0)
+	defer watcher.Close()
+	h.Publish(Summary{OrderUID: "o1"})
+	next(t, watcher)
+	h.Publish(Summary{OrderUID: "o2"})
+	next(t, watcher)
+	// Err takes the hub lock, so the second delivery has finished
+	slow.Err()
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-slow.Events():
+			if !ok {
+				if !errors.Is(slow.Err(), ErrSlowSubscriber) {
+					t.Fatalf("err %v", slow.Err())
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("slow subscriber not dropped")
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	ev, err := decode(`42:{"order_uid":"x","customer_id":"c"}`)
+	if err != nil || ev.ID != 42 || ev.Order.OrderUID != "x" {
+		t.Fatalf("decode: %+v, %v", ev, err)
+	}
+	for _, bad := range []string{"", "x:{}", "1:not json"} {
+		if _, err := decode(bad); err == nil {
+			t.Fatalf("%q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Ключи данных (DEK), зашифрованные мастер-ключом той же версии из keyfile.
+CREATE TABLE IF NOT EXISTS data_keys (
+    id TEXT PRIMARY KEY,
+    wrapped BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- key_id IS NULL — строка ещё не зашифрована (её подхватит перешифровка).
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS key_id TEXT REFERENCES data_keys(id);
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS phone_bidx BYTEA;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS email_bidx BYTEA;
+CREATE INDEX IF NOT EXISTS idx_deliveries_key_id ON deliveries (key_id);
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone_bidx ON deliveries (phone_bidx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_bidx ON deliveries (email_bidx);
+
+-- +goose Down
+-- Перед откатом расшифруйте данные: ordersctl reencrypt -decrypt
+DROP INDEX IF EXISTS idx_deliveries_email_bidx;
+DROP INDEX IF EXISTS idx_deliveries_phone_bidx;
+DROP INDEX IF EXISTS idx_deliveries_key_id;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS email_bidx;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS phone_bidx;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS key_id;
+DROP TABLE IF EXISTS data_keys;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Журнал запросов субъектов данных (экспорт/удаление). customer_id не храним:
+-- после удаления его не должно остаться, поэтому пишем sha256.
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    subject_hash TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT,
+    order_uids TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_subject ON privacy_requests (subject_hash, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS privacy_requests;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Журнал аудита: кто, что и с каким заказом сделал. Только дописывается.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    at TIMESTAMPTZ NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    order_uid TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    trace_id TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    outcome TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log (at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_order ON audit_log (order_uid, at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor, at);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Поисковый документ заказа. Веса tsvector разделяют группы полей:
+--   A — трек-номера, B — имя получателя, C — названия и бренды товаров,
+--   D — город, регион и адрес.
+-- Имя, адрес и телефон попадают сюда открытым текстом, только пока строка
+-- deliveries не зашифрована; для зашифрованных строк вместо них хранятся
+-- слепые токены (HMAC слов и фрагментов телефона) в blind_terms.
+CREATE TABLE IF NOT EXISTS order_search (
+    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid),
+    document TSVECTOR NOT NULL,
+    phone TEXT,                               -- цифры телефона, без шифрования
+    blind_terms TEXT[] NOT NULL DEFAULT '{}',
+    pii_indexed BOOLEAN NOT NULL DEFAULT true -- false: ждёт перешифровки
+);
+CREATE INDEX IF NOT EXISTS idx_order_search_document ON order_search USING gin (document);
+CREATE INDEX IF NOT EXISTS idx_order_search_blind ON order_search USING gin (blind_terms);
+CREATE INDEX IF NOT EXISTS idx_order_search_phone_trgm ON order_search USING gin (phone gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number_trgm ON orders USING gin (track_number gin_trgm_ops);
+
+-- Существующие заказы. ПДн зашифрованных строк здесь прочитать нельзя: их
+-- токены допишет фоновая перешифровка (pii_indexed = false).
+INSERT INTO order_search (order_uid, document, phone, pii_indexed)
+SELECT o.order_uid,
+       setweight(to_tsvector('simple', concat_ws(' ', o.track_number,
+           (SELECT string_agg(DISTINCT i.track_number, ' ') FROM items i WHERE i.order_uid = o.order_uid))), 'A') ||
+       setweight(to_tsvector('simple', CASE WHEN d.key_id IS NULL THEN coalesce(nullif(d.name, '[erased]'), '') ELSE '' END), 'B') ||
+       setweight(to_tsvector('simple', coalesce(
+           (SELECT string_agg(concat_ws(' ', i.name, i.brand), ' ') FROM items i WHERE i.order_uid = o.order_uid), '')), 'C') ||
+       setweight(to_tsvector('simple', concat_ws(' ', d.city, d.region,
+           CASE WHEN d.key_id IS NULL THEN nullif(d.address, '[erased]') END)), 'D'),
+       CASE WHEN d.key_id IS NULL THEN nullif(regexp_replace(d.phone, '[^0-9]', '', 'g'), '') END,
+       d.key_id IS NULL
+FROM orders o
+LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+ON CONFLICT (order_uid) DO NOTHING;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_track_number_trgm;
+DROP TABLE IF EXISTS order_search;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Вторичные ключи заказа: по ним звонят покупатели и ищет саппорт.
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number, date_created);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_items_rid ON items (rid);
+CREATE INDEX IF NOT EXISTS idx_items_chrt_id ON items (chrt_id);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_items_nm_id;
+DROP INDEX IF EXISTS idx_items_chrt_id;
+DROP INDEX IF EXISTS idx_items_rid;
+DROP INDEX IF EXISTS idx_payments_transaction;
+DROP INDEX IF EXISTS idx_orders_track_number;
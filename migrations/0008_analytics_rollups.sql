@@ -0,0 +1,54 @@
+-- +goose Up
+-- Дневные агрегаты для аналитики. Обновляются в транзакции сохранения заказа,
+-- поэтому запросы аналитики не читают items и payments.
+-- Дни — по UTC; пустые значения измерений хранятся как ''.
+CREATE TABLE IF NOT EXISTS order_rollup_daily (
+    day DATE NOT NULL,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,   -- позиций в заказах
+    revenue BIGINT NOT NULL, -- сумма payments.amount
+    PRIMARY KEY (day, currency, provider, delivery_service)
+);
+
+CREATE TABLE IF NOT EXISTS item_rollup_daily (
+    day DATE NOT NULL,
+    currency TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    nm_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    quantity BIGINT NOT NULL, -- проданных позиций
+    orders BIGINT NOT NULL,   -- заказов с товаром
+    revenue BIGINT NOT NULL,  -- сумма items.total_price
+    PRIMARY KEY (day, currency, brand, nm_id, name)
+);
+
+-- Существующие заказы
+INSERT INTO order_rollup_daily (day, currency, provider, delivery_service, orders, items, revenue)
+SELECT (o.date_created AT TIME ZONE 'UTC')::date,
+       coalesce(p.currency, ''), coalesce(p.provider, ''), coalesce(o.delivery_service, ''),
+       count(*),
+       coalesce(sum((SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid)), 0),
+       coalesce(sum(p.amount), 0)
+FROM orders o
+LEFT JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.date_created IS NOT NULL
+GROUP BY 1, 2, 3, 4
+ON CONFLICT DO NOTHING;
+
+INSERT INTO item_rollup_daily (day, currency, brand, nm_id, name, quantity, orders, revenue)
+SELECT (o.date_created AT TIME ZONE 'UTC')::date,
+       coalesce(p.currency, ''), coalesce(i.brand, ''), coalesce(i.nm_id, 0), coalesce(i.name, ''),
+       count(*), count(DISTINCT o.order_uid), coalesce(sum(i.total_price), 0)
+FROM items i
+JOIN orders o ON o.order_uid = i.order_uid
+LEFT JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.date_created IS NOT NULL
+GROUP BY 1, 2, 3, 4, 5
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS item_rollup_daily;
+DROP TABLE IF EXISTS order_rollup_daily;
@@ -0,0 +1,9 @@
+// migrations: SQL-миграции goose, встраиваются в бинарник сервиса
+package migrations
+
+import "embed"
+
+// FS содержит все *.sql миграции каталога.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	names, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatalf("no migrations embedded")
+	}
+	seen := make(map[int64]string)
+	for _, name := range names {
+		v, err := goose.NumericComponent(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if prev, ok := seen[v]; ok {
+			t.Fatalf("duplicate version %d: %s and %s", v, prev, name)
+		}
+		seen[v] = name
+		body, err := fs.ReadFile(FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "-- +goose Up") || !strings.Contains(string(body), "-- +goose Down") {
+			t.Fatalf("%s: missing goose Up/Down annotations", name)
+		}
+	}
+}
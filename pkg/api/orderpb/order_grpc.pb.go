@@ -8,6 +8,7 @@ package orderpb
 
 import (
 	context "context"
+
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -19,7 +20,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	OrderService_GetOrder_FullMethodName = "/order.v1.OrderService/GetOrder"
+	OrderService_GetOrder_FullMethodName              = "/order.v1.OrderService/GetOrder"
+	OrderService_GetOrderByTrackNumber_FullMethodName = "/order.v1.OrderService/GetOrderByTrackNumber"
+	OrderService_GetOrderByTransaction_FullMethodName = "/order.v1.OrderService/GetOrderByTransaction"
+	OrderService_FindOrdersByItem_FullMethodName      = "/order.v1.OrderService/FindOrdersByItem"
+	OrderService_ListOrders_FullMethodName            = "/order.v1.OrderService/ListOrders"
+	OrderService_ExportCustomerData_FullMethodName    = "/order.v1.OrderService/ExportCustomerData"
+	OrderService_EraseCustomerData_FullMethodName     = "/order.v1.OrderService/EraseCustomerData"
+	OrderService_QueryAuditLog_FullMethodName         = "/order.v1.OrderService/QueryAuditLog"
+	OrderService_SearchOrders_FullMethodName          = "/order.v1.OrderService/SearchOrders"
+	OrderService_WatchOrders_FullMethodName           = "/order.v1.OrderService/WatchOrders"
+	OrderService_ExportOrders_FullMethodName          = "/order.v1.OrderService/ExportOrders"
 )
 
 // OrderServiceClient is the client API for OrderService service.
@@ -27,6 +38,25 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type OrderServiceClient interface {
 	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*GetOrderResponse, error)
+	// The newest order with the track number.
+	GetOrderByTrackNumber(ctx context.Context, in *GetOrderByTrackNumberRequest, opts ...grpc.CallOption) (*GetOrderResponse, error)
+	// The newest order paid by the payment transaction.
+	GetOrderByTransaction(ctx context.Context, in *GetOrderByTransactionRequest, opts ...grpc.CallOption) (*GetOrderResponse, error)
+	// Orders containing an item, newest first.
+	FindOrdersByItem(ctx context.Context, in *FindOrdersByItemRequest, opts ...grpc.CallOption) (*FindOrdersByItemResponse, error)
+	ListOrders(ctx context.Context, in *ListOrdersRequest, opts ...grpc.CallOption) (*ListOrdersResponse, error)
+	// Data subject export: every order of a customer, unmasked.
+	ExportCustomerData(ctx context.Context, in *ExportCustomerDataRequest, opts ...grpc.CallOption) (*ExportCustomerDataResponse, error)
+	// Erasure: anonymizes delivery data and the customer id, keeps payments and items.
+	EraseCustomerData(ctx context.Context, in *EraseCustomerDataRequest, opts ...grpc.CallOption) (*EraseCustomerDataResponse, error)
+	// Audit log search, newest events first.
+	QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error)
+	// Full-text search, most relevant first.
+	SearchOrders(ctx context.Context, in *SearchOrdersRequest, opts ...grpc.CallOption) (*SearchOrdersResponse, error)
+	// Orders as they are saved. Served over HTTP as SSE at /orders/stream.
+	WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderEvent], error)
+	// Orders flattened to one row per item. Served over HTTP at /orders/export.
+	ExportOrders(ctx context.Context, in *ExportOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error)
 }
 
 type orderServiceClient struct {
@@ -47,11 +77,148 @@ func (c *orderServiceClient) GetOrder(ctx context.Context, in *GetOrderRequest,
 	return out, nil
 }
 
+func (c *orderServiceClient) GetOrderByTrackNumber(ctx context.Context, in *GetOrderByTrackNumberRequest, opts ...grpc.CallOption) (*GetOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrderResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrderByTrackNumber_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) GetOrderByTransaction(ctx context.Context, in *GetOrderByTransactionRequest, opts ...grpc.CallOption) (*GetOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrderResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrderByTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) FindOrdersByItem(ctx context.Context, in *FindOrdersByItemRequest, opts ...grpc.CallOption) (*FindOrdersByItemResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindOrdersByItemResponse)
+	err := c.cc.Invoke(ctx, OrderService_FindOrdersByItem_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) ListOrders(ctx context.Context, in *ListOrdersRequest, opts ...grpc.CallOption) (*ListOrdersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListOrdersResponse)
+	err := c.cc.Invoke(ctx, OrderService_ListOrders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) ExportCustomerData(ctx context.Context, in *ExportCustomerDataRequest, opts ...grpc.CallOption) (*ExportCustomerDataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExportCustomerDataResponse)
+	err := c.cc.Invoke(ctx, OrderService_ExportCustomerData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) EraseCustomerData(ctx context.Context, in *EraseCustomerDataRequest, opts ...grpc.CallOption) (*EraseCustomerDataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EraseCustomerDataResponse)
+	err := c.cc.Invoke(ctx, OrderService_EraseCustomerData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryAuditLogResponse)
+	err := c.cc.Invoke(ctx, OrderService_QueryAuditLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) SearchOrders(ctx context.Context, in *SearchOrdersRequest, opts ...grpc.CallOption) (*SearchOrdersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchOrdersResponse)
+	err := c.cc.Invoke(ctx, OrderService_SearchOrders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrderService_ServiceDesc.Streams[0], OrderService_WatchOrders_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchOrdersRequest, OrderEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_WatchOrdersClient = grpc.ServerStreamingClient[OrderEvent]
+
+func (c *orderServiceClient) ExportOrders(ctx context.Context, in *ExportOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrderService_ServiceDesc.Streams[1], OrderService_ExportOrders_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportOrdersRequest, ExportChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_ExportOrdersClient = grpc.ServerStreamingClient[ExportChunk]
+
 // OrderServiceServer is the server API for OrderService service.
 // All implementations must embed UnimplementedOrderServiceServer
 // for forward compatibility.
 type OrderServiceServer interface {
 	GetOrder(context.Context, *GetOrderRequest) (*GetOrderResponse, error)
+	// The newest order with the track number.
+	GetOrderByTrackNumber(context.Context, *GetOrderByTrackNumberRequest) (*GetOrderResponse, error)
+	// The newest order paid by the payment transaction.
+	GetOrderByTransaction(context.Context, *GetOrderByTransactionRequest) (*GetOrderResponse, error)
+	// Orders containing an item, newest first.
+	FindOrdersByItem(context.Context, *FindOrdersByItemRequest) (*FindOrdersByItemResponse, error)
+	ListOrders(context.Context, *ListOrdersRequest) (*ListOrdersResponse, error)
+	// Data subject export: every order of a customer, unmasked.
+	ExportCustomerData(context.Context, *ExportCustomerDataRequest) (*ExportCustomerDataResponse, error)
+	// Erasure: anonymizes delivery data and the customer id, keeps payments and items.
+	EraseCustomerData(context.Context, *EraseCustomerDataRequest) (*EraseCustomerDataResponse, error)
+	// Audit log search, newest events first.
+	QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error)
+	// Full-text search, most relevant first.
+	SearchOrders(context.Context, *SearchOrdersRequest) (*SearchOrdersResponse, error)
+	// Orders as they are saved. Served over HTTP as SSE at /orders/stream.
+	WatchOrders(*WatchOrdersRequest, grpc.ServerStreamingServer[OrderEvent]) error
+	// Orders flattened to one row per item. Served over HTTP at /orders/export.
+	ExportOrders(*ExportOrdersRequest, grpc.ServerStreamingServer[ExportChunk]) error
 	mustEmbedUnimplementedOrderServiceServer()
 }
 
@@ -65,6 +232,36 @@ type UnimplementedOrderServiceServer struct{}
 func (UnimplementedOrderServiceServer) GetOrder(context.Context, *GetOrderRequest) (*GetOrderResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetOrder not implemented")
 }
+func (UnimplementedOrderServiceServer) GetOrderByTrackNumber(context.Context, *GetOrderByTrackNumberRequest) (*GetOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetOrderByTrackNumber not implemented")
+}
+func (UnimplementedOrderServiceServer) GetOrderByTransaction(context.Context, *GetOrderByTransactionRequest) (*GetOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetOrderByTransaction not implemented")
+}
+func (UnimplementedOrderServiceServer) FindOrdersByItem(context.Context, *FindOrdersByItemRequest) (*FindOrdersByItemResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FindOrdersByItem not implemented")
+}
+func (UnimplementedOrderServiceServer) ListOrders(context.Context, *ListOrdersRequest) (*ListOrdersResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListOrders not implemented")
+}
+func (UnimplementedOrderServiceServer) ExportCustomerData(context.Context, *ExportCustomerDataRequest) (*ExportCustomerDataResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExportCustomerData not implemented")
+}
+func (UnimplementedOrderServiceServer) EraseCustomerData(context.Context, *EraseCustomerDataRequest) (*EraseCustomerDataResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method EraseCustomerData not implemented")
+}
+func (UnimplementedOrderServiceServer) QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryAuditLog not implemented")
+}
+func (UnimplementedOrderServiceServer) SearchOrders(context.Context, *SearchOrdersRequest) (*SearchOrdersResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SearchOrders not implemented")
+}
+func (UnimplementedOrderServiceServer) WatchOrders(*WatchOrdersRequest, grpc.ServerStreamingServer[OrderEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchOrders not implemented")
+}
+func (UnimplementedOrderServiceServer) ExportOrders(*ExportOrdersRequest, grpc.ServerStreamingServer[ExportChunk]) error {
+	return status.Error(codes.Unimplemented, "method ExportOrders not implemented")
+}
 func (UnimplementedOrderServiceServer) mustEmbedUnimplementedOrderServiceServer() {}
 func (UnimplementedOrderServiceServer) testEmbeddedByValue()                      {}
 
@@ -104,6 +301,172 @@ func _OrderService_GetOrder_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrderService_GetOrderByTrackNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderByTrackNumberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrderByTrackNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrderByTrackNumber_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrderByTrackNumber(ctx, req.(*GetOrderByTrackNumberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_GetOrderByTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderByTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrderByTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrderByTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrderByTransaction(ctx, req.(*GetOrderByTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_FindOrdersByItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindOrdersByItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).FindOrdersByItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_FindOrdersByItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).FindOrdersByItem(ctx, req.(*FindOrdersByItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_ListOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).ListOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_ListOrders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).ListOrders(ctx, req.(*ListOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_ExportCustomerData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportCustomerDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).ExportCustomerData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_ExportCustomerData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).ExportCustomerData(ctx, req.(*ExportCustomerDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_EraseCustomerData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EraseCustomerDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).EraseCustomerData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_EraseCustomerData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).EraseCustomerData(ctx, req.(*EraseCustomerDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_QueryAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).QueryAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_QueryAuditLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).QueryAuditLog(ctx, req.(*QueryAuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_SearchOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).SearchOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_SearchOrders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).SearchOrders(ctx, req.(*SearchOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_WatchOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderServiceServer).WatchOrders(m, &grpc.GenericServerStream[WatchOrdersRequest, OrderEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_WatchOrdersServer = grpc.ServerStreamingServer[OrderEvent]
+
+func _OrderService_ExportOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderServiceServer).ExportOrders(m, &grpc.GenericServerStream[ExportOrdersRequest, ExportChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_ExportOrdersServer = grpc.ServerStreamingServer[ExportChunk]
+
 // OrderService_ServiceDesc is the grpc.ServiceDesc for OrderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +478,197 @@ var OrderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOrder",
 			Handler:    _OrderService_GetOrder_Handler,
 		},
+		{
+			MethodName: "GetOrderByTrackNumber",
+			Handler:    _OrderService_GetOrderByTrackNumber_Handler,
+		},
+		{
+			MethodName: "GetOrderByTransaction",
+			Handler:    _OrderService_GetOrderByTransaction_Handler,
+		},
+		{
+			MethodName: "FindOrdersByItem",
+			Handler:    _OrderService_FindOrdersByItem_Handler,
+		},
+		{
+			MethodName: "ListOrders",
+			Handler:    _OrderService_ListOrders_Handler,
+		},
+		{
+			MethodName: "ExportCustomerData",
+			Handler:    _OrderService_ExportCustomerData_Handler,
+		},
+		{
+			MethodName: "EraseCustomerData",
+			Handler:    _OrderService_EraseCustomerData_Handler,
+		},
+		{
+			MethodName: "QueryAuditLog",
+			Handler:    _OrderService_QueryAuditLog_Handler,
+		},
+		{
+			MethodName: "SearchOrders",
+			Handler:    _OrderService_SearchOrders_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOrders",
+			Handler:       _OrderService_WatchOrders_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportOrders",
+			Handler:       _OrderService_ExportOrders_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "order.proto",
+}
+
+const (
+	OrderAnalytics_OrderStats_FullMethodName = "/order.v1.OrderAnalytics/OrderStats"
+	OrderAnalytics_TopItems_FullMethodName   = "/order.v1.OrderAnalytics/TopItems"
+)
+
+// OrderAnalyticsClient is the client API for OrderAnalytics service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Aggregates served from daily rollups, maintained as orders are saved.
+type OrderAnalyticsClient interface {
+	// Orders, items and revenue per time bucket and group.
+	OrderStats(ctx context.Context, in *OrderStatsRequest, opts ...grpc.CallOption) (*OrderStatsResponse, error)
+	// Best-selling brands or items.
+	TopItems(ctx context.Context, in *TopItemsRequest, opts ...grpc.CallOption) (*TopItemsResponse, error)
+}
+
+type orderAnalyticsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrderAnalyticsClient(cc grpc.ClientConnInterface) OrderAnalyticsClient {
+	return &orderAnalyticsClient{cc}
+}
+
+func (c *orderAnalyticsClient) OrderStats(ctx context.Context, in *OrderStatsRequest, opts ...grpc.CallOption) (*OrderStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderStatsResponse)
+	err := c.cc.Invoke(ctx, OrderAnalytics_OrderStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderAnalyticsClient) TopItems(ctx context.Context, in *TopItemsRequest, opts ...grpc.CallOption) (*TopItemsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TopItemsResponse)
+	err := c.cc.Invoke(ctx, OrderAnalytics_TopItems_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OrderAnalyticsServer is the server API for OrderAnalytics service.
+// All implementations must embed UnimplementedOrderAnalyticsServer
+// for forward compatibility.
+//
+// Aggregates served from daily rollups, maintained as orders are saved.
+type OrderAnalyticsServer interface {
+	// Orders, items and revenue per time bucket and group.
+	OrderStats(context.Context, *OrderStatsRequest) (*OrderStatsResponse, error)
+	// Best-selling brands or items.
+	TopItems(context.Context, *TopItemsRequest) (*TopItemsResponse, error)
+	mustEmbedUnimplementedOrderAnalyticsServer()
+}
+
+// UnimplementedOrderAnalyticsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOrderAnalyticsServer struct{}
+
+func (UnimplementedOrderAnalyticsServer) OrderStats(context.Context, *OrderStatsRequest) (*OrderStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method OrderStats not implemented")
+}
+func (UnimplementedOrderAnalyticsServer) TopItems(context.Context, *TopItemsRequest) (*TopItemsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method TopItems not implemented")
+}
+func (UnimplementedOrderAnalyticsServer) mustEmbedUnimplementedOrderAnalyticsServer() {}
+func (UnimplementedOrderAnalyticsServer) testEmbeddedByValue()                        {}
+
+// UnsafeOrderAnalyticsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrderAnalyticsServer will
+// result in compilation errors.
+type UnsafeOrderAnalyticsServer interface {
+	mustEmbedUnimplementedOrderAnalyticsServer()
+}
+
+func RegisterOrderAnalyticsServer(s grpc.ServiceRegistrar, srv OrderAnalyticsServer) {
+	// If the following call panics, it indicates UnimplementedOrderAnalyticsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&OrderAnalytics_ServiceDesc, srv)
+}
+
+func _OrderAnalytics_OrderStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderAnalyticsServer).OrderStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderAnalytics_OrderStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderAnalyticsServer).OrderStats(ctx, req.(*OrderStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderAnalytics_TopItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderAnalyticsServer).TopItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderAnalytics_TopItems_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderAnalyticsServer).TopItems(ctx, req.(*TopItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// OrderAnalytics_ServiceDesc is the grpc.ServiceDesc for OrderAnalytics service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OrderAnalytics_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "order.v1.OrderAnalytics",
+	HandlerType: (*OrderAnalyticsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "OrderStats",
+			Handler:    _OrderAnalytics_OrderStats_Handler,
+		},
+		{
+			MethodName: "TopItems",
+			Handler:    _OrderAnalytics_TopItems_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "order.proto",
@@ -0,0 +1,263 @@
+// Package fake builds realistic, internally consistent orders for tests,
+// load generation and backfills.
+package fake
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"orderservice/pkg/models"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// Defect is a deliberate invalidity injected into a generated order.
+type Defect string
+
+const (
+	// MissingRequired clears required fields (order_uid, delivery phone, item rid).
+	MissingRequired Defect = "missing_required"
+	// NoItems drops all items.
+	NoItems Defect = "no_items"
+	// TotalsMismatch makes payment.amount disagree with goods_total + delivery_cost + custom_fee.
+	TotalsMismatch Defect = "totals_mismatch"
+	// TrackMismatch gives the first item a track number different from the order.
+	TrackMismatch Defect = "track_mismatch"
+	// BadCurrency sets a currency that is not an ISO 4217 code used by any locale.
+	BadCurrency Defect = "bad_currency"
+	// BadLocale sets an unknown locale.
+	BadLocale Defect = "bad_locale"
+	// BadEmail sets a malformed delivery e-mail.
+	BadEmail Defect = "bad_email"
+	// FutureDate moves date_created a day past the generator's reference time.
+	FutureDate Defect = "future_date"
+)
+
+// Defects lists every supported Defect.
+var Defects = []Defect{MissingRequired, NoItems, TotalsMismatch, TrackMismatch, BadCurrency, BadLocale, BadEmail, FutureDate}
+
+// Locale describes a market: language code, currency and address book.
+type Locale struct {
+	Code        string
+	Currency    string
+	PhonePrefix string
+	Cities      []City
+}
+
+// City is a city together with the region it belongs to.
+type City struct {
+	Name   string
+	Region string
+}
+
+// Locales are the markets generated orders are drawn from.
+var Locales = []Locale{
+	{Code: "en", Currency: "USD", PhonePrefix: "+1", Cities: []City{
+		{"New York", "New York"}, {"Chicago", "Illinois"}, {"Houston", "Texas"}, {"Seattle", "Washington"}, {"Boston", "Massachusetts"},
+	}},
+	{Code: "ru", Currency: "RUB", PhonePrefix: "+7", Cities: []City{
+		{"Moscow", "Moscow"}, {"Kazan", "Tatarstan"}, {"Novosibirsk", "Novosibirsk Oblast"}, {"Yekaterinburg", "Sverdlovsk Oblast"},
+	}},
+	{Code: "he", Currency: "ILS", PhonePrefix: "+972", Cities: []City{
+		{"Tel Aviv", "Tel Aviv District"}, {"Haifa", "Haifa District"}, {"Kiryat Mozkin", "Kraiot"}, {"Jerusalem", "Jerusalem District"},
+	}},
+	{Code: "de", Currency: "EUR", PhonePrefix: "+49", Cities: []City{
+		{"Berlin", "Berlin"}, {"Munich", "Bavaria"}, {"Hamburg", "Hamburg"}, {"Cologne", "North Rhine-Westphalia"},
+	}},
+}
+
+// LocaleByCode returns the Locale with the given code.
+func LocaleByCode(code string) (Locale, bool) {
+	for _, l := range Locales {
+		if l.Code == code {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+var (
+	deliveryServices = []string{"meest", "dhl", "cdek", "boxberry", "wbexpress"}
+	providers        = []string{"wbpay", "visa", "mastercard", "mir"}
+	banks            = []string{"alpha", "sber", "tinkoff", "leumi", "chase"}
+	sizes            = []string{"0", "XS", "S", "M", "L", "XL"}
+	sales            = []int{0, 0, 10, 15, 30, 50}
+)
+
+// Option configures a Generator.
+type Option func(*Generator)
+
+// WithSeed makes output reproducible: the same seed, 0 included, yields the
+// same orders, including UUIDs. Timestamps are generated back from the
+// reference time, so they repeat too only with WithNow.
+func WithSeed(seed uint64) Option {
+	// gofakeit.New takes 0 for a random seed; a source of our own does not
+	return func(g *Generator) { g.faker = gofakeit.NewFaker(rand.NewPCG(seed, seed), false) }
+}
+
+// WithNow sets the reference time date_created is generated back from.
+func WithNow(now time.Time) Option {
+	return func(g *Generator) { g.now = now.UTC() }
+}
+
+// WithItems sets the inclusive range of items per order.
+func WithItems(min, max int) Option {
+	return func(g *Generator) { g.minItems, g.maxItems = min, max }
+}
+
+// WithLocale restricts generation to a single locale code.
+func WithLocale(code string) Option {
+	return func(g *Generator) {
+		if l, ok := LocaleByCode(code); ok {
+			g.locales = []Locale{l}
+		}
+	}
+}
+
+// WithDefects injects the given defects into every generated order.
+func WithDefects(defects ...Defect) Option {
+	return func(g *Generator) { g.defects = append(g.defects, defects...) }
+}
+
+// Generator produces orders. It is safe for concurrent use.
+type Generator struct {
+	mu       sync.Mutex
+	faker    *gofakeit.Faker // used under mu only
+	now      time.Time
+	locales  []Locale
+	minItems int
+	maxItems int
+	defects  []Defect
+}
+
+// New returns a Generator. Without WithSeed output is random.
+func New(opts ...Option) *Generator {
+	g := &Generator{
+		faker:    gofakeit.New(0),
+		now:      time.Now().UTC().Truncate(time.Second),
+		locales:  Locales,
+		minItems: 1,
+		maxItems: 4,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+var defaultGenerator = New()
+
+// Order returns a random valid order (plus the given defects) from a shared generator.
+func Order(defects ...Defect) models.Order { return defaultGenerator.Order(defects...) }
+
+// Order generates one order with the generator's defects plus the given ones.
+func (g *Generator) Order(defects ...Defect) models.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f := g.faker
+	loc := g.locales[f.Number(0, len(g.locales)-1)]
+	city := loc.Cities[f.Number(0, len(loc.Cities)-1)]
+	created := g.now.Add(-time.Duration(f.Number(0, 30*24*60)) * time.Minute)
+	track := "WB" + f.LetterN(10) + "TRACK"
+
+	items := make([]models.Item, f.Number(g.minItems, g.maxItems))
+	goods := 0
+	for i := range items {
+		price := f.Number(1, 500) * 10
+		salePct := sales[f.Number(0, len(sales)-1)]
+		total := price * (100 - salePct) / 100
+		goods += total
+		items[i] = models.Item{
+			ChrtID:      int64(f.Number(1000000, 9999999)),
+			TrackNumber: track,
+			Price:       price,
+			Rid:         strings.ReplaceAll(f.UUID(), "-", "")[:19] + "test",
+			Name:        f.ProductName(),
+			Sale:        salePct,
+			Size:        f.RandomString(sizes),
+			TotalPrice:  total,
+			NmID:        int64(f.Number(1000000, 9999999)),
+			Brand:       f.Company(),
+			Status:      202,
+		}
+	}
+	deliveryCost := f.Number(0, 150) * 10
+	customFee := 0
+	if f.Number(0, 9) == 0 {
+		customFee = f.Number(1, 20) * 10
+	}
+
+	first, last := f.FirstName(), f.LastName()
+	o := models.Order{
+		OrderUID:          strings.ReplaceAll(f.UUID(), "-", "")[:19] + "test",
+		TrackNumber:       track,
+		Entry:             "WBIL",
+		Locale:            loc.Code,
+		InternalSignature: "",
+		CustomerID:        strings.ToLower(first) + f.Numerify("####"),
+		DeliveryService:   f.RandomString(deliveryServices),
+		ShardKey:          fmt.Sprint(f.Number(1, 10)),
+		SmID:              f.Number(1, 100),
+		DateCreated:       created,
+		OofShard:          fmt.Sprint(f.Number(1, 2)),
+		Delivery: models.Delivery{
+			Name:    first + " " + last,
+			Phone:   loc.PhonePrefix + f.Numerify("#########"),
+			Zip:     f.Numerify("######"),
+			City:    city.Name,
+			Address: f.Street(),
+			Region:  city.Region,
+			Email:   strings.ToLower(first+"."+last) + f.Numerify("##") + "@" + f.RandomString([]string{"gmail.com", "yandex.ru", "outlook.com", "example.com"}),
+		},
+		Payment: models.Payment{
+			Transaction:  f.UUID(),
+			RequestID:    "",
+			Currency:     loc.Currency,
+			Provider:     f.RandomString(providers),
+			Amount:       goods + deliveryCost + customFee,
+			PaymentDT:    created.Unix(),
+			Bank:         f.RandomString(banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goods,
+			CustomFee:    customFee,
+		},
+		Items: items,
+	}
+
+	for _, d := range append(append([]Defect{}, g.defects...), defects...) {
+		g.apply(&o, d)
+	}
+	return o
+}
+
+func (g *Generator) apply(o *models.Order, d Defect) {
+	switch d {
+	case MissingRequired:
+		o.OrderUID = ""
+		o.Delivery.Phone = ""
+		for i := range o.Items {
+			o.Items[i].Rid = ""
+		}
+	case NoItems:
+		o.Items = nil
+	case TotalsMismatch:
+		o.Payment.Amount += 1
+	case TrackMismatch:
+		if len(o.Items) > 0 {
+			o.Items[0].TrackNumber = o.TrackNumber + "X"
+		}
+	case BadCurrency:
+		o.Payment.Currency = "XXX"
+	case BadLocale:
+		o.Locale = "zz"
+	case BadEmail:
+		o.Delivery.Email = strings.ReplaceAll(o.Delivery.Email, "@", "")
+	case FutureDate:
+		o.DateCreated = g.now.Add(24 * time.Hour)
+		o.Payment.PaymentDT = o.DateCreated.Unix()
+	}
+}
@@ -0,0 +1,74 @@
+package fake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrderConsistent(t *testing.T) {
+	g := New()
+	for i := 0; i < 50; i++ {
+		o := g.Order()
+		if len(o.Items) == 0 {
+			t.Fatalf("order without items")
+		}
+		goods := 0
+		for _, it := range o.Items {
+			if it.TrackNumber != o.TrackNumber {
+				t.Fatalf("item track %q != order track %q", it.TrackNumber, o.TrackNumber)
+			}
+			if it.TotalPrice != it.Price*(100-it.Sale)/100 {
+				t.Fatalf("item total %d inconsistent with price %d sale %d", it.TotalPrice, it.Price, it.Sale)
+			}
+			goods += it.TotalPrice
+		}
+		if o.Payment.GoodsTotal != goods {
+			t.Fatalf("goods_total %d, want %d", o.Payment.GoodsTotal, goods)
+		}
+		if o.Payment.Amount != goods+o.Payment.DeliveryCost+o.Payment.CustomFee {
+			t.Fatalf("amount %d does not add up", o.Payment.Amount)
+		}
+		loc, ok := LocaleByCode(o.Locale)
+		if !ok || loc.Currency != o.Payment.Currency {
+			t.Fatalf("locale %q / currency %q mismatch", o.Locale, o.Payment.Currency)
+		}
+	}
+}
+
+func TestSeedReproducible(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := New(WithSeed(42), WithNow(now))
+	b := New(WithNow(now), WithSeed(42))
+	for i := 0; i < 5; i++ {
+		if oa, ob := a.Order(), b.Order(); !reflect.DeepEqual(oa, ob) {
+			t.Fatalf("seeded generators diverged at %d", i)
+		}
+	}
+	// 0 is a seed like any other, not a request for a random one
+	if oa, ob := New(WithSeed(0), WithNow(now)).Order(), New(WithSeed(0), WithNow(now)).Order(); !reflect.DeepEqual(oa, ob) {
+		t.Fatalf("seed 0 is not reproducible")
+	}
+	if reflect.DeepEqual(New(WithSeed(1)).Order(), New(WithSeed(2)).Order()) {
+		t.Fatalf("different seeds produced identical orders")
+	}
+}
+
+func TestDefects(t *testing.T) {
+	g := New(WithSeed(7))
+	if o := g.Order(MissingRequired); o.OrderUID != "" || o.Delivery.Phone != "" {
+		t.Fatalf("MissingRequired not applied")
+	}
+	if o := g.Order(NoItems); len(o.Items) != 0 {
+		t.Fatalf("NoItems not applied")
+	}
+	if o := g.Order(TotalsMismatch); o.Payment.Amount == o.Payment.GoodsTotal+o.Payment.DeliveryCost+o.Payment.CustomFee {
+		t.Fatalf("TotalsMismatch not applied")
+	}
+	if o := g.Order(TrackMismatch); o.Items[0].TrackNumber == o.TrackNumber {
+		t.Fatalf("TrackMismatch not applied")
+	}
+	if o := New(WithDefects(BadLocale)).Order(); o.Locale != "zz" {
+		t.Fatalf("WithDefects not applied")
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ContentHash возвращает sha256 от канонического JSON заказа. Часовой пояс
+// date_created и nil/пустой список позиций на хеш не влияют.
+func (o Order) ContentHash() string {
+	o.DateCreated = o.DateCreated.UTC()
+	if o.Items == nil {
+		o.Items = []Item{}
+	}
+	raw, _ := json.Marshal(o)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"log/slog"
+
+	"orderservice/pkg/redact"
+)
+
+// LogValue выводит доставку в лог с замаскированными персональными данными.
+func (d Delivery) LogValue() slog.Value {
+	return slog.GroupValue(redact.Attrs(d)...)
+}
+
+// LogValue выводит заказ в лог без персональных данных и без позиций.
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.String("customer_id", o.CustomerID),
+		slog.String("delivery_service", o.DeliveryService),
+		slog.Any("delivery", o.Delivery),
+		slog.Int("amount", o.Payment.Amount),
+		slog.String("currency", o.Payment.Currency),
+		slog.Int("items", len(o.Items)),
+	)
+}
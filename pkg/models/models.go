@@ -3,17 +3,18 @@ package models
 
 import "time"
 
-// Delivery содержит данные доставки заказа
+// Delivery содержит данные доставки заказа.
+// Тег mask задаёт маскирование персональных данных (см. pkg/redact).
 type Delivery struct {
 	OrderUID string `json:"-"`
 
-	Name    string `json:"name" validate:"required"`
-	Phone   string `json:"phone" validate:"required"`
+	Name    string `json:"name" validate:"required" mask:"initials"`
+	Phone   string `json:"phone" validate:"required" mask:"phone"`
 	Zip     string `json:"zip" validate:"required"`
 	City    string `json:"city" validate:"required"`
-	Address string `json:"address" validate:"required"`
+	Address string `json:"address" validate:"required" mask:"full"`
 	Region  string `json:"region" validate:"required"`
-	Email   string `json:"email" validate:"required"`
+	Email   string `json:"email" validate:"required" mask:"email"`
 }
 
 // Payment содержит данные оплаты заказа
@@ -0,0 +1,200 @@
+// Package redact masks personal data. Rules are declared on struct fields with
+// a `mask` tag, so responses, logs and traces share one definition:
+//
+//	Phone string `json:"phone" mask:"phone"`
+package redact
+
+import (
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Masking kinds accepted in the `mask` tag.
+const (
+	Phone    = "phone"    // +972****000
+	Email    = "email"    // t***@gmail.com
+	Initials = "initials" // T*** T***
+	Full     = "full"     // ***
+)
+
+const stars = "***"
+
+// Mask masks value according to kind. Unknown kinds mask fully.
+func Mask(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	switch kind {
+	case Phone:
+		if len(value) < 8 {
+			return stars
+		}
+		return value[:4] + "****" + value[len(value)-3:]
+	case Email:
+		local, domain, ok := strings.Cut(value, "@")
+		if !ok || local == "" {
+			return stars
+		}
+		r, _ := utf8.DecodeRuneInString(local)
+		return string(r) + stars + "@" + domain
+	case Initials:
+		words := strings.Fields(value)
+		for i, w := range words {
+			r, _ := utf8.DecodeRuneInString(w)
+			words[i] = string(r) + stars
+		}
+		return strings.Join(words, " ")
+	}
+	return stars
+}
+
+// Apply returns a copy of v with every `mask`-tagged string field masked,
+// descending into nested structs and slices. Fields whose JSON path (e.g.
+// "delivery.phone") is in reveal are left as is.
+func Apply[T any](v T, reveal map[string]bool) T {
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.Kind() == reflect.Struct {
+		applyStruct(rv, "", reveal)
+	}
+	return v
+}
+
+func applyStruct(v reflect.Value, prefix string, reveal map[string]bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		path := prefix + jsonName(f)
+		fv := v.Field(i)
+		if kind, ok := f.Tag.Lookup("mask"); ok {
+			if fv.Kind() == reflect.String && !reveal[path] {
+				fv.SetString(Mask(kind, fv.String()))
+			}
+			continue
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && hasMasks(f.Type):
+			applyStruct(fv, path+".", reveal)
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct && hasMasks(fv.Type().Elem()) && fv.Len() > 0:
+			cp := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+			reflect.Copy(cp, fv)
+			for j := 0; j < cp.Len(); j++ {
+				applyStruct(cp.Index(j), path+".", reveal)
+			}
+			fv.Set(cp)
+		}
+	}
+}
+
+// hasMasks reports whether t or any struct nested in it has a `mask` tag.
+func hasMasks(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("mask"); ok {
+			return true
+		}
+		if f.IsExported() && f.Type != t && hasMasks(f.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths lists the JSON paths of the masked fields of v's type.
+func Paths(v any) []string {
+	var out []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			path := prefix + jsonName(f)
+			if _, ok := f.Tag.Lookup("mask"); ok {
+				out = append(out, path)
+				continue
+			}
+			ft := f.Type
+			if ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && hasMasks(ft) {
+				walk(ft, path+".")
+			}
+		}
+	}
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Struct {
+		walk(t, "")
+	}
+	return out
+}
+
+// Attrs renders v as slog attributes with masks applied.
+func Attrs(v any) []slog.Attr {
+	rv := reflect.ValueOf(v)
+	masked := reflect.New(rv.Type()).Elem()
+	masked.Set(rv)
+	applyStruct(masked, "", nil)
+	t := rv.Type()
+	attrs := make([]slog.Attr, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		attrs = append(attrs, slog.Any(name, masked.Field(i).Interface()))
+	}
+	return attrs
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phoneRe = regexp.MustCompile(`\+[0-9]{8,15}`)
+)
+
+// Scrub masks e-mail addresses and international phone numbers found in free
+// text such as error messages.
+func Scrub(s string) string {
+	if !strings.ContainsAny(s, "@+") {
+		return s
+	}
+	s = emailRe.ReplaceAllStringFunc(s, func(m string) string { return Mask(Email, m) })
+	return phoneRe.ReplaceAllStringFunc(s, func(m string) string { return Mask(Phone, m) })
+}
+
+// ReplaceAttr is a slog.HandlerOptions.ReplaceAttr that scrubs string
+// attributes and error messages, for PII logged outside of tagged structs.
+func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); strings.ContainsAny(s, "@+") {
+			return slog.String(a.Key, Scrub(s))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,62 @@
+package redact_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"orderservice/pkg/models"
+	"orderservice/pkg/redact"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct{ kind, in, want string }{
+		{redact.Phone, "+972501234000", "+972****000"},
+		{redact.Phone, "+123", "***"},
+		{redact.Email, "test@gmail.com", "t***@gmail.com"},
+		{redact.Email, "broken", "***"},
+		{redact.Initials, "Test Testov", "T*** T***"},
+		{redact.Full, "Ploshad Mira 15", "***"},
+		{redact.Phone, "", ""},
+	}
+	for _, tt := range tests {
+		if got := redact.Mask(tt.kind, tt.in); got != tt.want {
+			t.Errorf("Mask(%s, %q) = %q, want %q", tt.kind, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	o := models.Order{OrderUID: "u1", Delivery: models.Delivery{Name: "Test Testov", Phone: "+972501234000", Email: "test@gmail.com", City: "Haifa"}}
+	got := redact.Apply(o, map[string]bool{"delivery.email": true})
+	if got.Delivery.Phone != "+972****000" || got.Delivery.Name != "T*** T***" || got.Delivery.City != "Haifa" {
+		t.Fatalf("not masked: %+v", got.Delivery)
+	}
+	if got.Delivery.Email != o.Delivery.Email {
+		t.Fatalf("revealed field masked: %q", got.Delivery.Email)
+	}
+	if o.Delivery.Phone != "+972501234000" {
+		t.Fatalf("Apply modified its argument")
+	}
+	want := "delivery.name delivery.phone delivery.address delivery.email"
+	if paths := strings.Join(redact.Paths(o), " "); paths != want {
+		t.Fatalf("Paths = %s", paths)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: redact.ReplaceAttr}))
+	o := models.Order{OrderUID: "u1", Delivery: models.Delivery{Phone: "+972501234000", Email: "test@gmail.com"}}
+	logger.Info("saved", "order", o, "err", errors.New("duplicate email test@gmail.com"), "note", "call +972501234000")
+
+	out := buf.String()
+	if strings.Contains(out, "+972501234000") || strings.Contains(out, "test@gmail.com") {
+		t.Fatalf("PII in log: %s", out)
+	}
+	if !strings.Contains(out, "+972****000") || !strings.Contains(out, `"order_uid":"u1"`) {
+		t.Fatalf("unexpected log: %s", out)
+	}
+}
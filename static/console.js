@@ -0,0 +1,457 @@
+// static: консоль оператора. Ходит в тот же HTTP API, что и внешние клиенты:
+// GET /orders, GET /orders/search, GET /order/{uid}, GET /admin/audit и SSE
+// GET /orders/stream.
+'use strict';
+
+const $ = (sel) => document.querySelector(sel);
+
+// ---- учётные данные ----
+
+const creds = {
+    get kind() { return sessionStorage.getItem('cred-kind') || 'bearer'; },
+    get value() { return sessionStorage.getItem('cred-value') || ''; },
+    save(kind, value) {
+        sessionStorage.setItem('cred-kind', kind);
+        sessionStorage.setItem('cred-value', value);
+    },
+    clear() {
+        sessionStorage.removeItem('cred-kind');
+        sessionStorage.removeItem('cred-value');
+    },
+    headers() {
+        if (!this.value) return {};
+        return this.kind === 'apikey'
+            ? { 'X-Api-Key': this.value }
+            : { 'Authorization': 'Bearer ' + this.value };
+    },
+};
+
+$('#cred-kind').value = creds.kind;
+$('#cred-value').value = creds.value;
+$('#creds').addEventListener('submit', (e) => {
+    e.preventDefault();
+    creds.save($('#cred-kind').value, $('#cred-value').value.trim());
+});
+$('#cred-clear').addEventListener('click', () => {
+    creds.clear();
+    $('#cred-value').value = '';
+});
+
+// ---- HTTP ----
+
+class APIError extends Error {
+    constructor(status, message) {
+        super(message);
+        this.status = status;
+    }
+}
+
+async function api(path) {
+    const res = await fetch(path, { headers: creds.headers() });
+    if (!res.ok) throw await apiError(res);
+    return res.json();
+}
+
+// apiError разбирает ответ об ошибке gateway ({code, message}) или текст.
+async function apiError(res) {
+    const text = await res.text();
+    let message = text.trim();
+    try {
+        message = JSON.parse(text).message || message;
+    } catch (_) { /* не JSON */ }
+    const hints = {
+        401: 'нужны учётные данные',
+        403: 'нет доступа',
+        404: 'не найдено',
+        429: 'слишком много запросов, повторите через ' + (res.headers.get('Retry-After') || '?') + ' с',
+    };
+    return new APIError(res.status, `${res.status}: ${hints[res.status] || message || res.statusText}`);
+}
+
+function setStatus(el, text, isError) {
+    el.textContent = text;
+    el.classList.toggle('error', Boolean(isError));
+}
+
+// ---- форматирование ----
+
+function fmtTime(ts) {
+    if (!ts) return '';
+    const d = new Date(ts);
+    return isNaN(d) ? String(ts) : d.toLocaleString();
+}
+
+function fmtMoney(amount, currency) {
+    return `${(amount || 0).toLocaleString()} ${currency || ''}`.trim();
+}
+
+function cell(tr, text, cls) {
+    const td = tr.insertCell();
+    td.textContent = text == null ? '' : String(text);
+    if (cls) td.className = cls;
+    return td;
+}
+
+function fill(dl, title, pairs) {
+    dl.replaceChildren();
+    const head = document.createElement('dt');
+    head.className = 'section';
+    head.textContent = title;
+    dl.append(head);
+    for (const [k, v] of pairs) {
+        const dt = document.createElement('dt');
+        dt.textContent = k;
+        const dd = document.createElement('dd');
+        dd.textContent = v == null || v === '' ? '—' : String(v);
+        dl.append(dt, dd);
+    }
+}
+
+// ---- вкладки ----
+
+function showTab(name) {
+    document.querySelectorAll('nav button').forEach((b) => b.classList.toggle('active', b.dataset.tab === name));
+    document.querySelectorAll('.tab').forEach((s) => s.classList.toggle('active', s.id === 'tab-' + name));
+}
+
+document.querySelectorAll('nav button').forEach((b) => b.addEventListener('click', () => showTab(b.dataset.tab)));
+
+// ---- поиск и список ----
+
+// pages[i] — page_token i-й страницы текущего поиска
+const search = { params: null, pages: [''], index: 0 };
+
+function searchParams(form) {
+    const p = new URLSearchParams();
+    const data = new FormData(form);
+    // полнотекстовый запрос сам задаёт все условия, фильтры формы не нужны
+    if (data.get('query').trim()) {
+        p.set('query', data.get('query').trim());
+        p.set('page_size', data.get('page_size'));
+        return p;
+    }
+    for (const [k, v] of data) {
+        if (!v) continue;
+        if (k === 'from') {
+            p.set(k, `${v}T00:00:00Z`);
+        } else if (k === 'to') {
+            // «по» включительно: API ждёт границу «создан до»
+            const d = new Date(`${v}T00:00:00Z`);
+            d.setUTCDate(d.getUTCDate() + 1);
+            p.set(k, d.toISOString());
+        } else {
+            p.set(k, v);
+        }
+    }
+    return p;
+}
+
+async function loadPage() {
+    const status = $('#search-status');
+    const p = new URLSearchParams(search.params);
+    if (search.pages[search.index]) p.set('page_token', search.pages[search.index]);
+    setStatus(status, 'Загрузка…');
+    try {
+        const res = await api((p.has('query') ? '/orders/search?' : '/orders?') + p);
+        const orders = res.orders || (res.hits || []).map((h) => h.order);
+        const body = $('#search-results tbody');
+        body.replaceChildren();
+        for (const o of orders) {
+            const tr = body.insertRow();
+            cell(tr, o.orderUid);
+            cell(tr, fmtTime(o.dateCreated));
+            cell(tr, o.customerId);
+            cell(tr, o.deliveryService);
+            cell(tr, o.delivery && o.delivery.region);
+            cell(tr, (o.items || []).length, 'num');
+            cell(tr, o.payment ? fmtMoney(o.payment.amount, o.payment.currency) : '', 'num');
+            tr.addEventListener('click', () => openOrder(o.orderUid));
+        }
+        if (res.nextPageToken) search.pages[search.index + 1] = res.nextPageToken;
+        else search.pages.length = search.index + 1;
+        $('#page-prev').disabled = search.index === 0;
+        $('#page-next').disabled = !res.nextPageToken;
+        $('#page-no').textContent = `стр. ${search.index + 1}`;
+        setStatus(status, orders.length ? `Заказов на странице: ${orders.length}` : 'Ничего не найдено.');
+    } catch (err) {
+        setStatus(status, err.message, true);
+    }
+}
+
+$('#search-form').addEventListener('submit', (e) => {
+    e.preventDefault();
+    search.params = searchParams(e.target);
+    search.pages = [''];
+    search.index = 0;
+    loadPage();
+});
+$('#page-prev').addEventListener('click', () => {
+    if (search.index > 0) {
+        search.index--;
+        loadPage();
+    }
+});
+$('#page-next').addEventListener('click', () => {
+    if (search.pages[search.index + 1]) {
+        search.index++;
+        loadPage();
+    }
+});
+$('#open-form').addEventListener('submit', (e) => {
+    e.preventDefault();
+    const uid = $('#open-uid').value.trim();
+    if (uid) openOrder(uid);
+});
+
+// ---- карточка заказа ----
+
+async function openOrder(uid) {
+    showTab('order');
+    history.replaceState(null, '', '#order/' + encodeURIComponent(uid));
+    const status = $('#order-status');
+    setStatus(status, `Загрузка ${uid}…`);
+    $('#order-view').hidden = true;
+    let order;
+    try {
+        order = (await api('/order/' + encodeURIComponent(uid))).order;
+    } catch (err) {
+        setStatus(status, err.message, true);
+        return;
+    }
+    setStatus(status, '');
+    renderOrder(order);
+    $('#order-view').hidden = false;
+    loadHistory(uid);
+}
+
+function renderOrder(o) {
+    const d = o.delivery || {};
+    const p = o.payment || {};
+    const items = o.items || [];
+    $('#order-title').textContent = `Заказ ${o.orderUid}`;
+
+    fill($('#order-main'), 'Заказ', [
+        ['Создан', fmtTime(o.dateCreated)],
+        ['Трек-номер', o.trackNumber],
+        ['Entry', o.entry],
+        ['Покупатель', o.customerId],
+        ['Служба доставки', o.deliveryService],
+        ['Локаль', o.locale],
+        ['Shard / OOF shard', `${o.shardkey || '—'} / ${o.oofShard || '—'}`],
+        ['sm_id', o.smId || 0],
+    ]);
+    fill($('#order-delivery'), 'Доставка', [
+        ['Получатель', d.name],
+        ['Телефон', d.phone],
+        ['E-mail', d.email],
+        ['Адрес', [d.zip, d.region, d.city, d.address].filter(Boolean).join(', ')],
+    ]);
+    fill($('#order-payment'), 'Оплата', [
+        ['Транзакция', p.transaction],
+        ['Провайдер / банк', `${p.provider || '—'} / ${p.bank || '—'}`],
+        ['Оплачено', p.paymentDt ? fmtTime(Number(p.paymentDt) * 1000) : ''],
+        ['Товары', fmtMoney(p.goodsTotal, p.currency)],
+        ['Доставка', fmtMoney(p.deliveryCost, p.currency)],
+        ['Пошлина', fmtMoney(p.customFee, p.currency)],
+        ['Итого', fmtMoney(p.amount, p.currency)],
+    ]);
+
+    const body = $('#order-items tbody');
+    body.replaceChildren();
+    let itemsTotal = 0;
+    for (const it of items) {
+        const tr = body.insertRow();
+        cell(tr, it.chrtId);
+        cell(tr, it.name);
+        cell(tr, it.brand);
+        cell(tr, it.size);
+        cell(tr, (it.price || 0).toLocaleString(), 'num');
+        cell(tr, it.sale || 0, 'num');
+        cell(tr, (it.totalPrice || 0).toLocaleString(), 'num');
+        cell(tr, it.status);
+        itemsTotal += it.totalPrice || 0;
+    }
+    const foot = $('#order-items tfoot');
+    foot.replaceChildren();
+    const tr = foot.insertRow();
+    cell(tr, `Позиций: ${items.length}`).colSpan = 6;
+    cell(tr, fmtMoney(itemsTotal, p.currency), 'num');
+    cell(tr, '');
+
+    renderChecks(items, p, itemsTotal);
+    $('#order-json').textContent = JSON.stringify(o, null, 2);
+}
+
+// renderChecks сверяет суммы так же, как их строит генератор заказов:
+// итог позиции = цена со скидкой, goods_total = сумма позиций,
+// amount = goods_total + delivery_cost + custom_fee.
+function renderChecks(items, p, itemsTotal) {
+    const checks = [];
+    const badItems = items.filter((it) => (it.totalPrice || 0) !== Math.floor((it.price || 0) * (100 - (it.sale || 0)) / 100));
+    checks.push([badItems.length === 0, badItems.length === 0
+        ? 'Итоги позиций совпадают с ценой и скидкой'
+        : `Итог не сходится с ценой и скидкой у позиций: ${badItems.map((it) => it.chrtId).join(', ')}`]);
+    const goods = p.goodsTotal || 0;
+    checks.push([goods === itemsTotal, goods === itemsTotal
+        ? 'goods_total равен сумме позиций'
+        : `goods_total ${goods} ≠ сумме позиций ${itemsTotal}`]);
+    const expected = goods + (p.deliveryCost || 0) + (p.customFee || 0);
+    const amount = p.amount || 0;
+    checks.push([amount === expected, amount === expected
+        ? 'amount = товары + доставка + пошлина'
+        : `amount ${amount} ≠ товары + доставка + пошлина (${expected})`]);
+
+    const ul = $('#order-checks');
+    ul.replaceChildren();
+    for (const [ok, text] of checks) {
+        const li = document.createElement('li');
+        li.className = ok ? 'ok' : 'bad';
+        li.textContent = text;
+        ul.append(li);
+    }
+}
+
+// loadHistory показывает журнал аудита заказа: создание, чтения, удаление
+// ПДн и смены статуса (update-status), когда они появятся.
+async function loadHistory(uid) {
+    const status = $('#history-status');
+    const body = $('#order-history tbody');
+    body.replaceChildren();
+    setStatus(status, 'Загрузка…');
+    try {
+        const res = await api('/admin/audit?' + new URLSearchParams({ order_uid: uid, page_size: '100' }));
+        const events = (res.events || []).slice().reverse();
+        for (const ev of events) {
+            const tr = body.insertRow();
+            cell(tr, fmtTime(ev.at));
+            cell(tr, ev.action);
+            cell(tr, ev.actor);
+            cell(tr, ev.source);
+            cell(tr, ev.outcome);
+            cell(tr, ev.requestId);
+        }
+        setStatus(status, events.length
+            ? (res.nextPageToken ? 'Показаны последние 100 событий.' : '')
+            : 'Событий нет.');
+    } catch (err) {
+        const text = err.status === 403 ? 'История доступна ролям с операцией audit_read.'
+            : err.status === 400 ? 'Журнал аудита отключён.'
+                : err.message;
+        setStatus(status, text, true);
+    }
+}
+
+// ---- живая лента ----
+
+const LIVE_LIMIT = 200;
+const live = { controller: null, lastId: 0, retry: null };
+
+function liveRow(ev) {
+    const o = ev.order || {};
+    const body = $('#live-feed tbody');
+    const tr = body.insertRow(0);
+    tr.className = 'fresh';
+    cell(tr, ev.id, 'num');
+    cell(tr, fmtTime(o.date_created));
+    cell(tr, o.order_uid);
+    cell(tr, o.customer_id);
+    cell(tr, o.delivery_service);
+    cell(tr, o.region);
+    cell(tr, o.items || 0, 'num');
+    cell(tr, fmtMoney(o.amount, o.currency), 'num');
+    tr.addEventListener('click', () => openOrder(o.order_uid));
+    while (body.rows.length > LIVE_LIMIT) body.deleteRow(-1);
+}
+
+// readEvents разбирает text/event-stream: EventSource не умеет слать
+// заголовки авторизации, поэтому поток читается через fetch.
+async function readEvents(res, onEvent) {
+    const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buf = '';
+    for (;;) {
+        const { value, done } = await reader.read();
+        if (done) return;
+        buf += value.replace(/\r\n?/g, '\n');
+        let end;
+        while ((end = buf.indexOf('\n\n')) >= 0) {
+            const frame = buf.slice(0, end);
+            buf = buf.slice(end + 2);
+            const ev = { id: '', event: 'message', data: [] };
+            for (const line of frame.split('\n')) {
+                if (!line || line.startsWith(':')) continue;
+                const i = line.indexOf(':');
+                const field = i < 0 ? line : line.slice(0, i);
+                const val = i < 0 ? '' : line.slice(i + 1).replace(/^ /, '');
+                if (field === 'data') ev.data.push(val);
+                else if (field === 'id') ev.id = val;
+                else if (field === 'event') ev.event = val;
+            }
+            if (ev.data.length) onEvent(ev.event, ev.id, ev.data.join('\n'));
+        }
+    }
+}
+
+async function liveConnect(params) {
+    const status = $('#live-status');
+    const controller = new AbortController();
+    live.controller = controller;
+    const headers = creds.headers();
+    if (live.lastId) headers['Last-Event-ID'] = String(live.lastId);
+    let retryIn = 3;
+    try {
+        const res = await fetch('/orders/stream?' + params, { headers, signal: controller.signal });
+        if (!res.ok) {
+            const err = await apiError(res);
+            if (res.status === 401 || res.status === 403) {
+                setStatus(status, err.message, true);
+                liveStop();
+                return;
+            }
+            retryIn = Number(res.headers.get('Retry-After')) || retryIn;
+            throw err;
+        }
+        setStatus(status, live.lastId ? `Подключено, продолжение после #${live.lastId}.` : 'Подключено, ждём новые заказы…');
+        await readEvents(res, (event, id, data) => {
+            if (event === 'error') {
+                setStatus(status, 'Сервер закрыл поток: ' + JSON.parse(data), true);
+                return;
+            }
+            const ev = JSON.parse(data);
+            live.lastId = Number(id) || ev.id;
+            liveRow(ev);
+        });
+    } catch (err) {
+        if (controller.signal.aborted) return;
+        setStatus(status, `${err.message}. Переподключение через ${retryIn} с…`, true);
+    }
+    if (live.controller !== controller) return;
+    live.retry = setTimeout(() => liveConnect(params), retryIn * 1000);
+}
+
+function liveStop() {
+    clearTimeout(live.retry);
+    if (live.controller) live.controller.abort();
+    live.controller = null;
+    $('#live-toggle').textContent = 'Подключиться';
+}
+
+$('#live-form').addEventListener('submit', (e) => {
+    e.preventDefault();
+    if (live.controller) {
+        liveStop();
+        setStatus($('#live-status'), 'Отключено.');
+        return;
+    }
+    const params = new URLSearchParams();
+    for (const [k, v] of new FormData(e.target)) if (v) params.set(k, v);
+    live.lastId = 0;
+    $('#live-feed tbody').replaceChildren();
+    $('#live-toggle').textContent = 'Отключиться';
+    liveConnect(params);
+});
+
+// ---- ссылка на заказ: #order/<uid> ----
+
+if (location.hash.startsWith('#order/')) {
+    openOrder(decodeURIComponent(location.hash.slice('#order/'.length)));
+}
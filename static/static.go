@@ -0,0 +1,9 @@
+// static: консоль оператора, встраивается в бинарник сервиса
+package static
+
+import "embed"
+
+// FS содержит страницу консоли и её ресурсы.
+//
+//go:embed index.html console.css console.js
+var FS embed.FS
@@ -1,21 +1,167 @@
 package gofakeit
 
 import (
+	crand "crypto/rand"
+	"encoding/binary"
 	"fmt"
-	"math/rand"
-	"time"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// Faker generates fake data from its own random source.
+type Faker struct {
+	mu   sync.Mutex
+	lock bool
+	rnd  *rand.Rand
 }
 
+// New returns a Faker seeded with seed. A zero seed picks a random one.
+func New(seed uint64) *Faker {
+	if seed == 0 {
+		var b [8]byte
+		crand.Read(b[:])
+		seed = binary.LittleEndian.Uint64(b[:])
+	}
+	return NewFaker(rand.NewPCG(seed, seed), true)
+}
+
+// NewFaker returns a Faker drawing from src. With lock it is safe for
+// concurrent use; without, the caller serializes access.
+func NewFaker(src rand.Source, lock bool) *Faker {
+	return &Faker{rnd: rand.New(src), lock: lock}
+}
+
+// global backs the package-level functions; Seed may swap it while they run.
+var global atomic.Pointer[Faker]
+
+func init() { global.Store(New(0)) }
+
+// Seed reseeds the global faker. Generators that must not share a sequence
+// use their own Faker from New.
+func Seed(seed uint64) { global.Store(New(seed)) }
+
+func (f *Faker) intn(n int) int {
+	if f.lock {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+	}
+	return f.rnd.IntN(n)
+}
+
+func (f *Faker) pick(list []string) string { return list[f.intn(len(list))] }
+
 // UUID returns a random UUID v4 string.
-func UUID() string {
+func (f *Faker) UUID() string {
 	b := make([]byte, 16)
-	rand.Read(b)
+	if f.lock {
+		f.mu.Lock()
+	}
+	binary.LittleEndian.PutUint64(b[:8], f.rnd.Uint64())
+	binary.LittleEndian.PutUint64(b[8:], f.rnd.Uint64())
+	if f.lock {
+		f.mu.Unlock()
+	}
 	// Set version and variant bits
 	b[6] = (b[6] & 0x0f) | 0x40
 	b[8] = (b[8] & 0x3f) | 0x80
 	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
 }
+
+// Number returns a random integer in [min, max].
+func (f *Faker) Number(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + f.intn(max-min+1)
+}
+
+// Bool returns a random boolean.
+func (f *Faker) Bool() bool { return f.intn(2) == 1 }
+
+// RandomString returns a random element of list.
+func (f *Faker) RandomString(list []string) string { return f.pick(list) }
+
+// Numerify replaces every '#' in s with a random digit.
+func (f *Faker) Numerify(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '#' {
+			b.WriteByte(byte('0' + f.intn(10)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// LetterN returns n random upper-case ASCII letters.
+func (f *Faker) LetterN(n uint) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('A' + f.intn(26))
+	}
+	return string(b)
+}
+
+func (f *Faker) FirstName() string { return f.pick(firstNames) }
+func (f *Faker) LastName() string  { return f.pick(lastNames) }
+
+// Name returns a random "First Last" full name.
+func (f *Faker) Name() string { return f.FirstName() + " " + f.LastName() }
+
+// Phone returns a random 10 digit phone number.
+func (f *Faker) Phone() string { return f.Numerify("##########") }
+
+// Email returns a random e-mail address.
+func (f *Faker) Email() string {
+	return strings.ToLower(f.FirstName()+f.LastName()) + f.Numerify("##") + "@" + f.pick(emailDomains)
+}
+
+func (f *Faker) City() string    { return f.pick(cities) }
+func (f *Faker) State() string   { return f.pick(states) }
+func (f *Faker) Zip() string     { return f.Numerify("#####") }
+func (f *Faker) Company() string { return f.pick(companies) }
+
+// Street returns a random street address such as "12 Oak Street".
+func (f *Faker) Street() string {
+	return fmt.Sprintf("%d %s %s", f.Number(1, 999), f.pick(streetNames), f.pick(streetSuffixes))
+}
+
+// ProductName returns a random product name such as "Smart Cotton Shirt".
+func (f *Faker) ProductName() string {
+	return f.pick(productAdjectives) + " " + f.pick(productMaterials) + " " + f.pick(productNouns)
+}
+
+func UUID() string                      { return global.Load().UUID() }
+func Number(min, max int) int           { return global.Load().Number(min, max) }
+func Bool() bool                        { return global.Load().Bool() }
+func RandomString(list []string) string { return global.Load().RandomString(list) }
+func Numerify(s string) string          { return global.Load().Numerify(s) }
+func LetterN(n uint) string             { return global.Load().LetterN(n) }
+func FirstName() string                 { return global.Load().FirstName() }
+func LastName() string                  { return global.Load().LastName() }
+func Name() string                      { return global.Load().Name() }
+func Phone() string                     { return global.Load().Phone() }
+func Email() string                     { return global.Load().Email() }
+func City() string                      { return global.Load().City() }
+func State() string                     { return global.Load().State() }
+func Zip() string                       { return global.Load().Zip() }
+func Company() string                   { return global.Load().Company() }
+func Street() string                    { return global.Load().Street() }
+func ProductName() string               { return global.Load().ProductName() }
+
+var (
+	firstNames        = []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "Daniel", "Sarah", "Thomas", "Karen", "Anna", "Ivan", "Olga", "Noam", "Tamar", "Lucas"}
+	lastNames         = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Moore", "Martin", "Lee", "Clark", "Ivanov", "Petrova", "Cohen", "Levi", "Silva"}
+	emailDomains      = []string{"gmail.com", "yahoo.com", "hotmail.com", "outlook.com", "mail.ru", "example.com"}
+	cities            = []string{"New York", "Chicago", "Houston", "Phoenix", "Boston", "Seattle", "Denver", "Austin", "Moscow", "Kazan", "Tel Aviv", "Haifa", "Berlin", "Madrid", "Lisbon"}
+	states            = []string{"California", "Texas", "Florida", "New York", "Illinois", "Ohio", "Georgia", "Washington", "Moscow Oblast", "Tatarstan", "Central District", "Bavaria"}
+	companies         = []string{"Acme", "Globex", "Initech", "Umbrella", "Stark", "Wayne", "Hooli", "Vandelay", "Soylent", "Wonka"}
+	streetNames       = []string{"Oak", "Maple", "Cedar", "Pine", "Elm", "Lake", "Hill", "Park", "Main", "Church", "Lenina", "Herzl"}
+	streetSuffixes    = []string{"Street", "Avenue", "Road", "Lane", "Boulevard", "Way"}
+	productAdjectives = []string{"Smart", "Ultra", "Classic", "Portable", "Compact", "Deluxe", "Eco", "Premium"}
+	productMaterials  = []string{"Cotton", "Leather", "Steel", "Wooden", "Plastic", "Wool", "Silk", "Glass"}
+	productNouns      = []string{"Shirt", "Bag", "Lamp", "Chair", "Watch", "Mug", "Jacket", "Shoes", "Scarf", "Backpack"}
+)